@@ -8,7 +8,9 @@ toolchain go1.23.8
 
 require (
 	github.com/Axway/agent-sdk v1.1.121
+	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/time v0.3.0
 )
 
 require (
@@ -31,7 +33,6 @@ require (
 	github.com/go-openapi/swag v0.23.0 // indirect
 	github.com/goccy/go-json v0.10.2 // indirect
 	github.com/gofrs/uuid v4.4.0+incompatible // indirect
-	github.com/golang-jwt/jwt/v5 v5.2.2 // indirect
 	github.com/golang/protobuf v1.5.4 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/gorhill/cronexpr v0.0.0-20180427100037-88b0669f7d75 // indirect
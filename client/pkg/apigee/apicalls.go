@@ -1,6 +1,7 @@
 package apigee
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -14,41 +15,58 @@ const (
 )
 
 // GetEnvironments - get the list of environments for the org
-func (a *ApigeeClient) GetEnvironments() []string {
+func (a *ApigeeClient) GetEnvironments() ([]string, error) {
+	return a.GetEnvironmentsWithContext(context.Background())
+}
+
+// GetEnvironmentsWithContext - get the list of environments for the org, cancelled when the context is done
+func (a *ApigeeClient) GetEnvironmentsWithContext(ctx context.Context) ([]string, error) {
 	// Get the developers
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/environments", a.orgURL),
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/environments", a.orgURL),
 		WithDefaultHeaders(),
 	).Execute()
+	if err != nil {
+		return nil, err
+	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "retrieving the environments")
+	}
 
 	environments := []string{}
-	if err == nil {
-		json.Unmarshal(response.Body, &environments)
+	err = json.Unmarshal(response.Body, &environments)
+	if err != nil {
+		return nil, err
 	}
 
-	return environments
+	return environments, nil
 }
 
 // CreateDeveloperApp - create an app for the developer
 func (a *ApigeeClient) CreateDeveloperApp(newApp models.DeveloperApp) (*models.DeveloperApp, error) {
+	return a.CreateDeveloperAppWithContext(context.Background(), newApp)
+}
+
+// CreateDeveloperAppWithContext - create an app for the developer, cancelled when the context is done
+func (a *ApigeeClient) CreateDeveloperAppWithContext(ctx context.Context, newApp models.DeveloperApp) (*models.DeveloperApp, error) {
 	// create a new developer app
 	data, err := json.Marshal(newApp)
 	if err != nil {
 		return nil, err
 	}
 
-	response, err := a.newRequest(http.MethodPost, fmt.Sprintf("%s/developers/%s/apps", a.orgURL, newApp.DeveloperId),
+	response, err := a.newRequest(ctx, http.MethodPost, fmt.Sprintf("%s/developers/%s/apps", a.orgURL, newApp.DeveloperId),
 		WithDefaultHeaders(),
 		WithBody(data),
 	).Execute()
 	if err != nil {
 		return nil, err
 	}
-	if response.Code != http.StatusCreated {
-		return nil, fmt.Errorf("received an unexpected response code %d from Apigee when creating the app", response.Code)
+	if response.Code != a.createdStatus() {
+		return nil, newAPIError(response, "creating the app")
 	}
 
 	devApp := models.DeveloperApp{}
-	err = json.Unmarshal(response.Body, &devApp)
+	err = a.unmarshal(response.Body, &devApp)
 	if err != nil {
 		return nil, err
 	}
@@ -58,12 +76,17 @@ func (a *ApigeeClient) CreateDeveloperApp(newApp models.DeveloperApp) (*models.D
 
 // UpdateDeveloperApp - update an app for the developer
 func (a *ApigeeClient) UpdateDeveloperApp(app models.DeveloperApp) (*models.DeveloperApp, error) {
+	return a.UpdateDeveloperAppWithContext(context.Background(), app)
+}
+
+// UpdateDeveloperAppWithContext - update an app for the developer, cancelled when the context is done
+func (a *ApigeeClient) UpdateDeveloperAppWithContext(ctx context.Context, app models.DeveloperApp) (*models.DeveloperApp, error) {
 	data, err := json.Marshal(app)
 	if err != nil {
 		return nil, err
 	}
 
-	response, err := a.newRequest(http.MethodPut, fmt.Sprintf(developerAppsURL, a.orgURL, app.DeveloperId, app.Name),
+	response, err := a.newRequest(ctx, http.MethodPut, fmt.Sprintf(developerAppsURL, a.orgURL, app.DeveloperId, app.Name),
 		WithDefaultHeaders(),
 		WithBody(data),
 	).Execute()
@@ -71,11 +94,11 @@ func (a *ApigeeClient) UpdateDeveloperApp(app models.DeveloperApp) (*models.Deve
 		return nil, err
 	}
 	if response.Code != http.StatusOK {
-		return nil, fmt.Errorf("received an unexpected response code %d from Apigee when creating the app", response.Code)
+		return nil, newAPIError(response, "updating the app")
 	}
 
 	devApp := models.DeveloperApp{}
-	err = json.Unmarshal(response.Body, &devApp)
+	err = a.unmarshal(response.Body, &devApp)
 	if err != nil {
 		return nil, err
 	}
@@ -85,27 +108,37 @@ func (a *ApigeeClient) UpdateDeveloperApp(app models.DeveloperApp) (*models.Deve
 
 // GetDeveloperApp gets an app by name
 func (a *ApigeeClient) GetDeveloperApp(name string) (*models.DeveloperApp, error) {
+	return a.GetDeveloperAppWithContext(context.Background(), name)
+}
+
+// GetDeveloperAppWithContext - gets an app by name, cancelled when the context is done
+func (a *ApigeeClient) GetDeveloperAppWithContext(ctx context.Context, name string) (*models.DeveloperApp, error) {
 	url := fmt.Sprintf(developerAppsURL, a.orgURL, a.GetDeveloperID(), name)
 	response, err := a.newRequest(
-		http.MethodGet, url,
+		ctx, http.MethodGet, url,
 		WithDefaultHeaders(),
 	).Execute()
 	if err != nil {
 		return nil, err
 	}
 	if response.Code != http.StatusOK {
-		return nil, fmt.Errorf("received an unexpected response code %d from Apigee when retrieving the app", response.Code)
+		return nil, newAPIError(response, "retrieving the app")
 	}
 
 	devApp := models.DeveloperApp{}
-	err = json.Unmarshal(response.Body, &devApp)
+	err = a.unmarshal(response.Body, &devApp)
 	return &devApp, err
 }
 
 // RemoveDeveloperApp - create an app for the developer
 func (a *ApigeeClient) RemoveDeveloperApp(appName, developerID string) error {
+	return a.RemoveDeveloperAppWithContext(context.Background(), appName, developerID)
+}
+
+// RemoveDeveloperAppWithContext - create an app for the developer, cancelled when the context is done
+func (a *ApigeeClient) RemoveDeveloperAppWithContext(ctx context.Context, appName, developerID string) error {
 	// create a new developer app
-	response, err := a.newRequest(http.MethodDelete, fmt.Sprintf(developerAppsURL, a.orgURL, developerID, appName),
+	response, err := a.newRequest(ctx, http.MethodDelete, fmt.Sprintf(developerAppsURL, a.orgURL, developerID, appName),
 		WithDefaultHeaders(),
 	).Execute()
 
@@ -113,34 +146,35 @@ func (a *ApigeeClient) RemoveDeveloperApp(appName, developerID string) error {
 		return err
 	}
 	if response.Code != http.StatusOK {
-		return fmt.Errorf("received an unexpected response code %d from Apigee when deleting the app", response.Code)
+		return newAPIError(response, "deleting the app")
 	}
 
 	return nil
 }
 
-// GetProducts - get the list of products for the org
+// GetProducts - get the list of products for the org, reading every page of the list
 func (a *ApigeeClient) GetProducts() (Products, error) {
-	// Get the products
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/apiproducts", a.orgURL),
-		WithDefaultHeaders(),
-	).Execute()
+	return a.GetProductsWithContext(context.Background())
+}
+
+// GetProductsWithContext - get the list of products for the org, reading every page of the list, cancelled when the context is done
+func (a *ApigeeClient) GetProductsWithContext(ctx context.Context) (Products, error) {
+	products, err := collectNames(a.ListProductsWithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
-
-	products := Products{}
-	if err == nil {
-		json.Unmarshal(response.Body, &products)
-	}
-
 	return products, nil
 }
 
 // GetProduct - get details of the product
 func (a *ApigeeClient) GetProduct(productName string) (*models.ApiProduct, error) {
+	return a.GetProductWithContext(context.Background(), productName)
+}
+
+// GetProductWithContext - get details of the product, cancelled when the context is done
+func (a *ApigeeClient) GetProductWithContext(ctx context.Context, productName string) (*models.ApiProduct, error) {
 	// Get the product
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/apiproducts/%s", a.orgURL, productName),
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/apiproducts/%s", a.orgURL, productName),
 		WithDefaultHeaders(),
 	).Execute()
 	if err != nil {
@@ -148,11 +182,11 @@ func (a *ApigeeClient) GetProduct(productName string) (*models.ApiProduct, error
 	}
 
 	if response.Code != http.StatusOK {
-		return nil, fmt.Errorf("received an unexpected response code %d from Apigee when retrieving the app", response.Code)
+		return nil, newAPIError(response, "retrieving the product")
 	}
 
 	product := &models.ApiProduct{}
-	err = json.Unmarshal(response.Body, product)
+	err = a.unmarshal(response.Body, product)
 	if err != nil {
 		return nil, err
 	}
@@ -161,25 +195,38 @@ func (a *ApigeeClient) GetProduct(productName string) (*models.ApiProduct, error
 }
 
 // GetRevisionSpec - gets the resource file of type openapi for the org, api, revision, and spec file specified
-func (a *ApigeeClient) GetRevisionSpec(apiName, revisionNumber, specFile string) []byte {
+func (a *ApigeeClient) GetRevisionSpec(apiName, revisionNumber, specFile string) ([]byte, error) {
+	return a.GetRevisionSpecWithContext(context.Background(), apiName, revisionNumber, specFile)
+}
+
+// GetRevisionSpecWithContext - gets the resource file of type openapi for the org, api, revision, and spec file specified, cancelled when the context is done
+func (a *ApigeeClient) GetRevisionSpecWithContext(ctx context.Context, apiName, revisionNumber, specFile string) ([]byte, error) {
 	// Get the openapi resource file
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/apis/%s/revisions/%s/resourcefiles/openapi/%s", a.orgURL, apiName, revisionNumber, specFile),
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/apis/%s/revisions/%s/resourcefiles/openapi/%s", a.orgURL, apiName, revisionNumber, specFile),
 		WithDefaultHeaders(),
 	).Execute()
 
 	if err != nil {
-		return []byte{}
+		return nil, err
+	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "retrieving the revision spec")
 	}
 
-	return response.Body
+	return response.Body, nil
 }
 
 // GetStats - get the api stats for a specific environment
 func (a *ApigeeClient) GetStats(env, dimension, metricSelect string, start, end time.Time) (*models.Metrics, error) {
+	return a.GetStatsWithContext(context.Background(), env, dimension, metricSelect, start, end)
+}
+
+// GetStatsWithContext - get the api stats for a specific environment, cancelled when the context is done
+func (a *ApigeeClient) GetStatsWithContext(ctx context.Context, env, dimension, metricSelect string, start, end time.Time) (*models.Metrics, error) {
 	// Get the spec content file
 	const format = "01/02/2006 15:04"
 
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/environments/%v/stats/%s", a.orgURL, env, dimension),
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/environments/%v/stats/%s", a.orgURL, env, dimension),
 		WithQueryParams(map[string]string{
 			"select":    metricSelect,
 			"timeUnit":  "minute",
@@ -193,6 +240,9 @@ func (a *ApigeeClient) GetStats(env, dimension, metricSelect string, start, end
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the stats")
+	}
 
 	stats := &models.Metrics{}
 	err = json.Unmarshal(response.Body, stats)
@@ -203,7 +253,13 @@ func (a *ApigeeClient) GetStats(env, dimension, metricSelect string, start, end
 	return stats, nil
 }
 
+// CreateAPIProduct - create an api product
 func (a *ApigeeClient) CreateAPIProduct(product *models.ApiProduct) (*models.ApiProduct, error) {
+	return a.CreateAPIProductWithContext(context.Background(), product)
+}
+
+// CreateAPIProductWithContext - create an api product, cancelled when the context is done
+func (a *ApigeeClient) CreateAPIProductWithContext(ctx context.Context, product *models.ApiProduct) (*models.ApiProduct, error) {
 	// create a new developer app
 	data, err := json.Marshal(product)
 	if err != nil {
@@ -211,7 +267,7 @@ func (a *ApigeeClient) CreateAPIProduct(product *models.ApiProduct) (*models.Api
 	}
 
 	u := fmt.Sprintf("%s/apiproducts", a.orgURL)
-	response, err := a.newRequest(http.MethodPost, u,
+	response, err := a.newRequest(ctx, http.MethodPost, u,
 		WithDefaultHeaders(),
 		WithBody(data),
 	).Execute()
@@ -220,12 +276,12 @@ func (a *ApigeeClient) CreateAPIProduct(product *models.ApiProduct) (*models.Api
 		return nil, err
 	}
 
-	if response.Code != http.StatusCreated {
-		return nil, fmt.Errorf("received an unexpected response code %d from Apigee when creating the api product", response.Code)
+	if response.Code != a.createdStatus() {
+		return nil, newAPIError(response, "creating the api product")
 	}
 
 	newProduct := models.ApiProduct{}
-	err = json.Unmarshal(response.Body, &newProduct)
+	err = a.unmarshal(response.Body, &newProduct)
 	if err != nil {
 		return nil, err
 	}
@@ -15,6 +15,7 @@ func TestGetEnvironments(t *testing.T) {
 	cases := map[string]struct {
 		responses    []api.MockResponse
 		expectedEnvs int
+		expectErr    bool
 	}{
 		"environments returned": {
 			responses: []api.MockResponse{
@@ -31,14 +32,27 @@ func TestGetEnvironments(t *testing.T) {
 					ErrString: "error",
 				},
 			},
-			expectedEnvs: 0,
+			expectErr: true,
+		},
+		"error, unexpected response code": {
+			responses: []api.MockResponse{
+				{
+					RespCode: http.StatusForbidden,
+				},
+			},
+			expectErr: true,
 		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
 			c := createTestClient(t, &api.MockHTTPClient{Responses: tc.responses})
 
-			data := c.GetEnvironments()
+			data, err := c.GetEnvironments()
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
 			assert.Len(t, data, tc.expectedEnvs)
 		})
 	}
@@ -373,6 +387,7 @@ func TestGetRevisionSpec(t *testing.T) {
 	cases := map[string]struct {
 		responses    []api.MockResponse
 		expectedSpec []byte
+		expectErr    bool
 	}{
 		"spec data returned": {
 			responses: []api.MockResponse{
@@ -383,20 +398,33 @@ func TestGetRevisionSpec(t *testing.T) {
 			},
 			expectedSpec: expectedSpec,
 		},
-		"error getting environments": {
+		"error getting spec": {
 			responses: []api.MockResponse{
 				{
 					ErrString: "error",
 				},
 			},
-			expectedSpec: []byte{},
+			expectErr: true,
+		},
+		"error, spec not found": {
+			responses: []api.MockResponse{
+				{
+					RespCode: http.StatusNotFound,
+				},
+			},
+			expectErr: true,
 		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
 			c := createTestClient(t, &api.MockHTTPClient{Responses: tc.responses})
 
-			data := c.GetRevisionSpec("api", "rev", "spec")
+			data, err := c.GetRevisionSpec("api", "rev", "spec")
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
 			assert.Equal(t, tc.expectedSpec, data)
 		})
 	}
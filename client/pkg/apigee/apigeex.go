@@ -0,0 +1,202 @@
+package apigee
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+)
+
+// timestampKeys - fields that apigee x returns as strings, while apigee edge returns them as numbers
+var timestampKeys = map[string]struct{}{
+	"createdAt":      {},
+	"lastModifiedAt": {},
+	"issuedAt":       {},
+	"expiresAt":      {},
+}
+
+// xProxiesResponse - response from the apigee x list proxies call
+type xProxiesResponse struct {
+	Proxies []xNamedItem `json:"proxies"`
+}
+
+// xProductsResponse - response from the apigee x list api products call
+type xProductsResponse struct {
+	APIProduct []xNamedItem `json:"apiProduct"`
+}
+
+type xNamedItem struct {
+	Name string `json:"name"`
+}
+
+// xDevelopersResponse - response from the apigee x list developers call
+type xDevelopersResponse struct {
+	Developer []xDeveloper `json:"developer"`
+}
+
+type xDeveloper struct {
+	Email string `json:"email"`
+}
+
+// xDeveloperAppsResponse - response from the apigee x list developer apps call
+type xDeveloperAppsResponse struct {
+	App []xDeveloperApp `json:"app"`
+}
+
+// xDeveloperApp - apigee x returns the app name as the app id when listing developer apps
+type xDeveloperApp struct {
+	AppID string `json:"appId"`
+}
+
+// xDeploymentsResponse - response from the apigee x list proxy deployments call
+type xDeploymentsResponse struct {
+	Deployments []xDeployment `json:"deployments"`
+}
+
+type xDeployment struct {
+	Environment     string `json:"environment"`
+	APIProxy        string `json:"apiProxy"`
+	Revision        string `json:"revision"`
+	DeployStartTime string `json:"deployStartTime"`
+	State           string `json:"state"`
+}
+
+// EnvironmentGroup - an apigee x environment group, replaces virtual hosts on apigee x and hybrid
+type EnvironmentGroup struct {
+	Name      string   `json:"name"`
+	Hostnames []string `json:"hostnames"`
+	State     string   `json:"state"`
+}
+
+type xEnvironmentGroupsResponse struct {
+	EnvironmentGroups []EnvironmentGroup `json:"environmentGroups"`
+}
+
+type xEnvironmentGroupAttachment struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment"`
+}
+
+type xEnvironmentGroupAttachmentsResponse struct {
+	EnvironmentGroupAttachments []xEnvironmentGroupAttachment `json:"environmentGroupAttachments"`
+}
+
+func (r xProxiesResponse) toProxies() Proxies {
+	proxies := Proxies{}
+	for _, p := range r.Proxies {
+		proxies = append(proxies, p.Name)
+	}
+	return proxies
+}
+
+func (r xProductsResponse) toProducts() Products {
+	products := Products{}
+	for _, p := range r.APIProduct {
+		products = append(products, p.Name)
+	}
+	return products
+}
+
+func (r xDevelopersResponse) toDevelopers() []string {
+	developers := []string{}
+	for _, d := range r.Developer {
+		developers = append(developers, d.Email)
+	}
+	return developers
+}
+
+func (r xDeveloperAppsResponse) toDeveloperApps() []string {
+	apps := []string{}
+	for _, app := range r.App {
+		apps = append(apps, app.AppID)
+	}
+	return apps
+}
+
+// toDeploymentDetails - groups the apigee x deployments by environment, matching the apigee edge structure
+func (r xDeploymentsResponse) toDeploymentDetails(proxyName string) *models.DeploymentDetails {
+	details := &models.DeploymentDetails{
+		Name:        proxyName,
+		Environment: []models.DeploymentDetailsEnvironment{},
+	}
+
+	envIndex := make(map[string]int)
+	for _, d := range r.Deployments {
+		i, found := envIndex[d.Environment]
+		if !found {
+			i = len(details.Environment)
+			envIndex[d.Environment] = i
+			details.Environment = append(details.Environment, models.DeploymentDetailsEnvironment{Name: d.Environment})
+		}
+		details.Environment[i].Revision = append(details.Environment[i].Revision, models.DeploymentDetailsRevision{
+			Name:  d.Revision,
+			State: xDeploymentState(d.State),
+		})
+	}
+	return details
+}
+
+// xDeploymentState - converts an apigee x deployment state to the apigee edge state, the list of
+// deployments only includes the state when it is not ready
+func xDeploymentState(state string) string {
+	if state == "" || state == "READY" {
+		return DeployedState
+	}
+	return strings.ToLower(state)
+}
+
+// unmarshal - decodes an apigee response, converting the apigee x string timestamps when necessary
+func (a *ApigeeClient) unmarshal(data []byte, v interface{}) error {
+	if a.cfg.IsApigeeX() {
+		data = normalizeTimestamps(data)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// createdStatus - the response code apigee returns after successfully creating a resource
+func (a *ApigeeClient) createdStatus() int {
+	if a.cfg.IsApigeeX() {
+		return http.StatusOK
+	}
+	return http.StatusCreated
+}
+
+// normalizeTimestamps - converts any numeric timestamp strings to json numbers
+func normalizeTimestamps(data []byte) []byte {
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return data
+	}
+
+	normalized, err := json.Marshal(normalizeValue(decoded))
+	if err != nil {
+		return data
+	}
+	return normalized
+}
+
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			str, isString := val.(string)
+			if _, isTimestamp := timestampKeys[key]; isTimestamp && isString {
+				if _, err := strconv.ParseInt(str, 10, 64); err == nil {
+					v[key] = json.Number(str)
+					continue
+				}
+			}
+			v[key] = normalizeValue(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeValue(val)
+		}
+	}
+	return value
+}
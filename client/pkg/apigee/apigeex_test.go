@@ -0,0 +1,183 @@
+package apigee
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+	"github.com/Axway/agents-apigee/client/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const apigeeXFixtures = "testdata/apigeex"
+
+// newApigeeXServer - serves the recorded apigee x fixtures from the testdata directory
+func newApigeeXServer(t *testing.T) *httptest.Server {
+	routes := map[string]string{
+		"GET /v1/organizations/org/apis":                                 "proxies.json",
+		"GET /v1/organizations/org/apis/petstore/deployments":            "deployments.json",
+		"GET /v1/organizations/org/apis/petstore/revisions/2":            "revision.json",
+		"GET /v1/organizations/org/apiproducts":                          "products.json",
+		"GET /v1/organizations/org/apiproducts/petstore-product":         "product.json",
+		"POST /v1/organizations/org/developers/dev@example.com/apps":     "developerapp.json",
+		"GET /v1/organizations/org/envgroups":                            "envgroups.json",
+		"GET /v1/organizations/org/developers":                           "developers.json",
+		"GET /v1/organizations/org/developers/dev@example.com/apps":      "developerapps.json",
+		"GET /v1/organizations/org/envgroups/eval-group/attachments":     "attachments-eval-group.json",
+		"GET /v1/organizations/org/envgroups/prod-group/attachments":     "attachments-prod-group.json",
+		"GET /v1/organizations/org/developers/dev@example.com/apps/none": "",
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		if r.URL.Query().Get("format") == "bundle" {
+			w.Write(zipFixtureBundle(t))
+			return
+		}
+
+		fixture, found := routes[r.Method+" "+r.URL.Path]
+		if !found || fixture == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		data, err := os.ReadFile(filepath.Join(apigeeXFixtures, fixture))
+		assert.Nil(t, err)
+		w.Write(data)
+	}))
+}
+
+// zipFixtureBundle - creates a proxy bundle zip from the bundle fixture directory
+func zipFixtureBundle(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(buf)
+	bundleDir := filepath.Join(apigeeXFixtures, "bundle")
+
+	err := filepath.Walk(bundleDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, _ := filepath.Rel(bundleDir, path)
+		w, err := zipWriter.Create(filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, zipWriter.Close())
+	return buf.Bytes()
+}
+
+func createApigeeXTestClient(t *testing.T, serverURL string) *ApigeeClient {
+	cfg := &config.ApigeeConfig{
+		Platform:     "x",
+		URL:          serverURL,
+		APIVersion:   "v1",
+		Organization: "org",
+		DeveloperID:  "dev@example.com",
+		Auth: &config.AuthConfig{
+			Token: "token",
+		},
+	}
+
+	c, err := NewClient(cfg)
+	assert.Nil(t, err)
+	assert.True(t, c.IsReady())
+	return c
+}
+
+func TestApigeeXProxies(t *testing.T) {
+	server := newApigeeXServer(t)
+	defer server.Close()
+	c := createApigeeXTestClient(t, server.URL)
+
+	proxies, err := c.GetAllProxies()
+	assert.Nil(t, err)
+	assert.Equal(t, Proxies{"petstore", "weather"}, proxies)
+
+	deployments, err := c.GetDeployments("petstore")
+	assert.Nil(t, err)
+	assert.Len(t, deployments.Environment, 2)
+	assert.Equal(t, "eval", deployments.Environment[0].Name)
+	assert.Equal(t, "2", deployments.Environment[0].Revision[0].Name)
+	assert.Equal(t, DeployedState, deployments.Environment[0].Revision[0].State)
+	assert.Equal(t, "3", deployments.Environment[0].Revision[1].Name)
+	assert.Equal(t, "progressing", deployments.Environment[0].Revision[1].State)
+	assert.Equal(t, "prod", deployments.Environment[1].Name)
+	assert.Equal(t, "1", deployments.Environment[1].Revision[0].Name)
+
+	revision, err := c.GetRevision("petstore", "2")
+	assert.Nil(t, err)
+	assert.Equal(t, "petstore", revision.Name)
+	assert.Equal(t, "Petstore", revision.DisplayName)
+	assert.Equal(t, 1668448921845, revision.LastModifiedAt)
+	assert.Equal(t, []string{"verify-api-key"}, revision.Policies)
+
+	connection, err := c.GetRevisionConnectionType("petstore", "2")
+	assert.Nil(t, err)
+	assert.Equal(t, "/petstore", connection.BasePath)
+
+	policy, err := c.GetRevisionPolicyByName("petstore", "2", "verify-api-key")
+	assert.Nil(t, err)
+	assert.Equal(t, "VerifyAPIKey", policy.PolicyType)
+	assert.Equal(t, "Verify API Key", policy.DisplayName)
+
+	_, err = c.GetRevisionPolicyByName("petstore", "2", "missing")
+	assert.True(t, IsNotFound(err))
+
+	association, err := c.GetRevisionResourceFile("petstore", "2", "openapi", "association.json")
+	assert.Nil(t, err)
+	assert.Contains(t, string(association), "https://specs.example.com/petstore.json")
+
+	_, err = c.GetRevisionResourceFile("petstore", "2", "openapi", "missing.json")
+	assert.True(t, IsNotFound(err))
+
+	hostnames, err := c.GetEnvironmentGroupHostnames("prod")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"api.example.com", "www.example.com"}, hostnames)
+}
+
+func TestApigeeXProducts(t *testing.T) {
+	server := newApigeeXServer(t)
+	defer server.Close()
+	c := createApigeeXTestClient(t, server.URL)
+
+	products, err := c.GetProducts()
+	assert.Nil(t, err)
+	assert.Equal(t, Products{"petstore-product"}, products)
+
+	product, err := c.GetProduct("petstore-product")
+	assert.Nil(t, err)
+	assert.Equal(t, "Petstore Product", product.DisplayName)
+	assert.Equal(t, 1668448921845, product.LastModifiedAt)
+	assert.Equal(t, "100", product.Quota)
+
+	app, err := c.CreateDeveloperApp(models.DeveloperApp{Name: "my-app", DeveloperId: "dev@example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "my-app", app.Name)
+	assert.Equal(t, 1668448821845, app.CreatedAt)
+	assert.Equal(t, -1, app.Credentials[0].ExpiresAt)
+
+	_, err = c.GetDeveloperApp("none")
+	assert.NotNil(t, err)
+
+	developers, err := collectNames(c.ListDevelopers())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dev@example.com", "other@example.com"}, developers)
+
+	apps, err := collectNames(c.ListDeveloperApps("dev@example.com"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"my-app"}, apps)
+}
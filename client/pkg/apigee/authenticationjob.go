@@ -1,11 +1,17 @@
 package apigee
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	coreapi "github.com/Axway/agent-sdk/pkg/api"
 	"github.com/Axway/agent-sdk/pkg/jobs"
@@ -19,12 +25,24 @@ const (
 	usernameKey         = "username"
 	passwordKey         = "password"
 	refreshTokenKey     = "refresh_token"
+	passcodeKey         = "passcode"
+	responseTypeKey     = "response_type"
+	mfaTokenKey         = "mfa_token"
+
+	// authCheckInterval - how often the auth job checks if the token should be refreshed
+	authCheckInterval = 30 * time.Second
+	// maxRefreshMargin - the longest time before expiration that a token is refreshed
+	maxRefreshMargin = 5 * time.Minute
+	// defaultTokenLifetime - used when the auth server does not return an expiration
+	defaultTokenLifetime = 10 * time.Minute
+	// defaultAuthTimeout - bounds the auth calls when the request timeout is disabled
+	defaultAuthTimeout = 60 * time.Second
 )
 
 type authJobOpt func(*authJob)
 
 func newAuthJob(opts ...authJobOpt) *authJob {
-	a := &authJob{}
+	a := &authJob{timeout: defaultAuthTimeout}
 	for _, o := range opts {
 		o(a)
 	}
@@ -73,6 +91,40 @@ func withAuthServerPassword(password string) authJobOpt {
 	}
 }
 
+func withServiceAccountKey(key *serviceAccountKey) authJobOpt {
+	return func(a *authJob) {
+		a.serviceAccount = key
+	}
+}
+
+func withPasscode(passcode string) authJobOpt {
+	return func(a *authJob) {
+		a.passcode = passcode
+	}
+}
+
+func withMFASeed(seed string) authJobOpt {
+	return func(a *authJob) {
+		a.mfaSeed = seed
+	}
+}
+
+// withTimeout - bounds each auth call, the calls are bounded by the default when the request timeout is disabled as
+// every apigee call waits on them
+func withTimeout(timeout time.Duration) authJobOpt {
+	return func(a *authJob) {
+		if timeout > 0 {
+			a.timeout = timeout
+		}
+	}
+}
+
+func withTokenFile(tokenFile string) authJobOpt {
+	return func(a *authJob) {
+		a.tokenFile = tokenFile
+	}
+}
+
 type authJob struct {
 	jobs.Job
 	apiClient      coreapi.Client
@@ -82,11 +134,27 @@ type authJob struct {
 	url            string
 	serverUsername string
 	serverPassword string
+	passcode       string
+	mfaSeed        string
+	tokenFile      string
 	tokenSetter    func(string)
+	serviceAccount *serviceAccountKey
+	refreshAt      time.Time
+	timeout        time.Duration
+	authLock       sync.Mutex
 }
 
 func (j *authJob) Ready() bool {
-	err := j.passwordAuth()
+	j.authLock.Lock()
+	defer j.authLock.Unlock()
+
+	var err error
+	if j.serviceAccount != nil {
+		err = j.serviceAccountAuth()
+	} else {
+		j.loadRefreshToken()
+		err = j.login()
+	}
 	if err != nil {
 		log.Error(err)
 		return false
@@ -105,7 +173,7 @@ func (j *authJob) checkConnection() error {
 	}
 
 	// Validate we can reach the apigee auth server
-	_, err := j.apiClient.Send(request)
+	_, err := sendWithContext(context.Background(), j.apiClient, request, j.timeout)
 	if err != nil {
 		log.Errorf(err.Error())
 		return err
@@ -114,18 +182,53 @@ func (j *authJob) checkConnection() error {
 	return nil
 }
 
+// Execute - refreshes the token when it is close to expiring
 func (j *authJob) Execute() error {
+	j.authLock.Lock()
+	defer j.authLock.Unlock()
+
+	if time.Now().Before(j.refreshAt) {
+		// the current token is still valid
+		return nil
+	}
+	return j.authenticate()
+}
+
+// forceRefresh - gets a new token regardless of the current token expiration
+func (j *authJob) forceRefresh() error {
+	j.authLock.Lock()
+	defer j.authLock.Unlock()
+	return j.authenticate()
+}
+
+func (j *authJob) authenticate() error {
+	if j.serviceAccount != nil {
+		return j.serviceAccountAuth()
+	}
+
 	err := j.checkConnection()
 	if err != nil {
 		return err
 	}
+	return j.login()
+}
 
+// login - uses the refresh token when there is one, falling back to the configured credentials
+func (j *authJob) login() error {
 	if j.refreshToken != "" {
-		err = j.refreshAuth()
+		if err := j.refreshAuth(); err == nil {
+			return nil
+		}
 	}
-	if err != nil {
-		err = j.passwordAuth()
+
+	if j.passcode != "" {
+		return j.passcodeAuth()
 	}
+	if j.username != "" {
+		return j.passwordAuth()
+	}
+	err := fmt.Errorf("the apigee refresh token is no longer valid, configure a new passcode to authenticate")
+	log.Error(err)
 	return err
 }
 
@@ -136,7 +239,17 @@ func (j *authJob) passwordAuth() error {
 	authData.Set(usernameKey, j.username)
 	authData.Set(passwordKey, j.password)
 
-	err := j.postAuth(authData)
+	queryParams := map[string]string{}
+	if j.mfaSeed != "" {
+		mfaToken, err := generateTOTP(j.mfaSeed, time.Now())
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		queryParams[mfaTokenKey] = mfaToken
+	}
+
+	err := j.postAuth(authData, queryParams)
 	if err != nil {
 		// clear out the refreshToken attribute
 		j.refreshToken = ""
@@ -150,10 +263,53 @@ func (j *authJob) refreshAuth() error {
 	authData.Set(grantTypeKey, refresh.String())
 	authData.Set(refreshTokenKey, j.refreshToken)
 
-	return j.postAuth(authData)
+	return j.postAuth(authData, nil)
 }
 
-func (j *authJob) postAuth(authData url.Values) error {
+// passcodeAuth - exchanges the one-time passcode of an sso user for a token
+func (j *authJob) passcodeAuth() error {
+	log.Tracef("Getting new auth token with passcode")
+	authData := url.Values{}
+	authData.Set(grantTypeKey, password.String())
+	authData.Set(responseTypeKey, "token")
+	authData.Set(passcodeKey, j.passcode)
+
+	// the passcode can only be used once, the refresh token is used from now on
+	j.passcode = ""
+	return j.postAuth(authData, nil)
+}
+
+func (j *authJob) serviceAccountAuth() error {
+	log.Tracef("Getting new service account auth token")
+	assertion, err := j.serviceAccount.signAssertion(time.Now())
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	authData := url.Values{}
+	authData.Set(grantTypeKey, jwtBearer.String())
+	authData.Set(assertionKey, assertion)
+
+	request := coreapi.Request{
+		Method: coreapi.POST,
+		URL:    j.serviceAccount.TokenURI,
+		Headers: map[string]string{
+			"Content-Type": "application/x-www-form-urlencoded",
+		},
+		Body: []byte(authData.Encode()),
+	}
+
+	authResponse, err := j.sendAuth(request)
+	if err != nil {
+		return err
+	}
+
+	j.setToken(authResponse)
+	return nil
+}
+
+func (j *authJob) postAuth(authData url.Values, queryParams map[string]string) error {
 	basicAuth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", j.serverUsername, j.serverPassword)))
 	request := coreapi.Request{
 		Method: coreapi.POST,
@@ -162,28 +318,84 @@ func (j *authJob) postAuth(authData url.Values) error {
 			"Content-Type":  "application/x-www-form-urlencoded",
 			"Authorization": "Basic " + basicAuth,
 		},
-		Body: []byte(authData.Encode()),
+		QueryParams: queryParams,
+		Body:        []byte(authData.Encode()),
 	}
 
 	// Get the initial authentication token
-	response, err := j.apiClient.Send(request)
+	authResponse, err := j.sendAuth(request)
 	if err != nil {
-		log.Errorf(err.Error())
 		return err
 	}
 
+	// save this refreshToken and send the token to the client
+	j.refreshToken = authResponse.RefreshToken
+	j.saveRefreshToken()
+	j.setToken(authResponse)
+	return nil
+}
+
+// loadRefreshToken - reads the refresh token saved by a previous run of the agent
+func (j *authJob) loadRefreshToken() {
+	if j.tokenFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(j.tokenFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("could not read the apigee refresh token file %s: %s", j.tokenFile, err)
+		}
+		return
+	}
+	j.refreshToken = strings.TrimSpace(string(data))
+}
+
+// saveRefreshToken - writes the refresh token so it can be used when the agent restarts
+func (j *authJob) saveRefreshToken() {
+	if j.tokenFile == "" || j.refreshToken == "" {
+		return
+	}
+
+	err := os.MkdirAll(filepath.Dir(j.tokenFile), 0700)
+	if err == nil {
+		err = os.WriteFile(j.tokenFile, []byte(j.refreshToken), 0600)
+	}
+	if err != nil {
+		log.Warnf("could not save the apigee refresh token file %s: %s", j.tokenFile, err)
+	}
+}
+
+// setToken - sends the token to the client and schedules the next refresh from its expiration
+func (j *authJob) setToken(authResponse *AuthResponse) {
+	lifetime := time.Duration(authResponse.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = defaultTokenLifetime
+	}
+	margin := lifetime / 2
+	if margin > maxRefreshMargin {
+		margin = maxRefreshMargin
+	}
+	j.refreshAt = time.Now().Add(lifetime - margin)
+	log.Tracef("auth token expires in %s, will refresh at %s", lifetime, j.refreshAt.Format(time.RFC3339))
+	j.tokenSetter(authResponse.AccessToken)
+}
+
+func (j *authJob) sendAuth(request coreapi.Request) (*AuthResponse, error) {
+	response, err := sendWithContext(context.Background(), j.apiClient, request, j.timeout)
+	if err != nil {
+		log.Errorf(err.Error())
+		return nil, err
+	}
+
 	// if the response code is not ok log and return an err
 	if response.Code != http.StatusOK {
 		err := fmt.Errorf("unexpected response code %d from authentication call: %s", response.Code, response.Body)
 		log.Error(err)
-		return err
+		return nil, err
 	}
 
-	// save this refreshToken and send the token to the client
-	authResponse := AuthResponse{}
-	json.Unmarshal(response.Body, &authResponse)
-	log.Trace(authResponse.AccessToken)
-	j.refreshToken = authResponse.RefreshToken
-	j.tokenSetter(authResponse.AccessToken)
-	return nil
+	authResponse := &AuthResponse{}
+	json.Unmarshal(response.Body, authResponse)
+	return authResponse, nil
 }
@@ -0,0 +1,315 @@
+package apigee
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	coreapi "github.com/Axway/agent-sdk/pkg/api"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeServiceAccountKey - creates a service account key file, pointing at the token url, in a temp dir
+func writeServiceAccountKey(t *testing.T, tokenURL string) (string, *rsa.PrivateKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	keyPem := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+	data, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "project",
+		"private_key_id": "key-id",
+		"private_key":    string(keyPem),
+		"client_email":   "agent@project.iam.gserviceaccount.com",
+		"token_uri":      tokenURL,
+	})
+
+	keyFile := filepath.Join(t.TempDir(), "service-account.json")
+	assert.Nil(t, os.WriteFile(keyFile, data, 0600))
+	return keyFile, privateKey
+}
+
+func TestServiceAccountAuth(t *testing.T) {
+	var privateKey *rsa.PrivateKey
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, jwtBearer.String(), r.Form.Get(grantTypeKey))
+
+		// validate the signed assertion
+		token, err := jwt.Parse(r.Form.Get(assertionKey), func(token *jwt.Token) (interface{}, error) {
+			return &privateKey.PublicKey, nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "key-id", token.Header["kid"])
+		claims := token.Claims.(jwt.MapClaims)
+		assert.Equal(t, "agent@project.iam.gserviceaccount.com", claims["iss"])
+		assert.Equal(t, googleCloudScope, claims["scope"])
+
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, calls)
+	}))
+	defer server.Close()
+
+	keyFile, key := writeServiceAccountKey(t, server.URL)
+	privateKey = key
+
+	saKey, err := loadServiceAccountKey(keyFile)
+	assert.Nil(t, err)
+
+	token := ""
+	job := newAuthJob(
+		withAPIClient(coreapi.NewClient(nil, "")),
+		withServiceAccountKey(saKey),
+		withTokenSetter(func(t string) { token = t }),
+	)
+
+	assert.True(t, job.Ready())
+	assert.Equal(t, "token-1", token)
+
+	// token still valid, no new request
+	assert.Nil(t, job.Execute())
+	assert.Equal(t, 1, calls)
+
+	// refresh scheduled before the token expires
+	assert.WithinDuration(t, time.Now().Add(55*time.Minute), job.refreshAt, 5*time.Second)
+
+	// refresh time reached, token refreshed
+	job.refreshAt = time.Now().Add(-time.Second)
+	assert.Nil(t, job.Execute())
+	assert.Equal(t, "token-2", token)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRequestReplayOnUnauthorized(t *testing.T) {
+	authCalls := 0
+	apiCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == apigeeAuthCheckPath {
+			return
+		}
+		if r.URL.Path == apigeeAuthPath {
+			authCalls++
+			fmt.Fprintf(w, `{"access_token":"token-%d","refresh_token":"refresh","expires_in":1799}`, authCalls)
+			return
+		}
+
+		apiCalls++
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`["petstore"]`))
+	}))
+	defer server.Close()
+
+	c := &ApigeeClient{apiClient: coreapi.NewClient(nil, ""), authType: "Bearer"}
+	c.auth = newAuthJob(
+		withAPIClient(c.apiClient),
+		withURL(server.URL),
+		withUsername("user@example.com"),
+		withTokenSetter(c.setAccessToken),
+	)
+	assert.True(t, c.auth.Ready())
+	assert.Equal(t, "token-1", c.getAccessToken())
+
+	// the stale token is rejected, refreshed and the request replayed
+	response, err := c.newRequest(context.Background(), http.MethodGet, server.URL+"/apis", WithDefaultHeaders()).Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "token-2", c.getAccessToken())
+	assert.Equal(t, 2, apiCalls)
+
+	// a token refreshed by another worker is used without a new auth call
+	token, err := c.refreshAccessToken("token-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "token-2", token)
+	assert.Equal(t, 2, authCalls)
+
+	// clients without an auth job do not replay
+	basic := &ApigeeClient{apiClient: coreapi.NewClient(nil, ""), authType: "Basic", authValue: "bad"}
+	response, err = basic.newRequest(context.Background(), http.MethodGet, server.URL+"/apis", WithDefaultHeaders()).Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	assert.Equal(t, 3, apiCalls)
+}
+
+func TestLoadServiceAccountKey(t *testing.T) {
+	_, err := loadServiceAccountKey(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(t, err)
+
+	badFile := filepath.Join(t.TempDir(), "bad.json")
+	os.WriteFile(badFile, []byte(`{"type":"authorized_user"}`), 0600)
+	_, err = loadServiceAccountKey(badFile)
+	assert.NotNil(t, err)
+
+	keyFile, _ := writeServiceAccountKey(t, "")
+	key, err := loadServiceAccountKey(keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, googleTokenURL, key.TokenURI)
+}
+
+func TestGenerateTOTP(t *testing.T) {
+	// rfc 6238 sha1 test vectors, seed is "12345678901234567890"
+	seed := "gezd gnbv gy3t qojq gezd gnbv gy3t qojq"
+	tests := map[string]struct {
+		unix int64
+		code string
+	}{
+		"59":         {unix: 59, code: "287082"},
+		"1111111109": {unix: 1111111109, code: "081804"},
+		"1234567890": {unix: 1234567890, code: "005924"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			code, err := generateTOTP(seed, time.Unix(tc.unix, 0))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.code, code)
+		})
+	}
+
+	_, err := generateTOTP("not-base32!", time.Now())
+	assert.NotNil(t, err)
+}
+
+// newLoginServer - stand-in for the apigee sso login server
+func newLoginServer(t *testing.T, mfaSeed string) (*httptest.Server, *[]string) {
+	grants := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == apigeeAuthCheckPath {
+			return
+		}
+		username, password, _ := r.BasicAuth()
+		assert.Equal(t, "edgecli", username)
+		assert.Equal(t, "edgeclisecret", password)
+		assert.Nil(t, r.ParseForm())
+
+		switch {
+		case r.Form.Get(passcodeKey) != "":
+			grants = append(grants, passcodeKey)
+			if r.Form.Get(passcodeKey) != "one-time" || r.Form.Get(responseTypeKey) != "token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case r.Form.Get(grantTypeKey) == refresh.String():
+			grants = append(grants, refreshTokenKey)
+			if r.Form.Get(refreshTokenKey) != "refresh" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		default:
+			grants = append(grants, passwordKey)
+			mfaToken, _ := generateTOTP(mfaSeed, time.Now())
+			if r.Form.Get(passwordKey) != "password" || r.URL.Query().Get(mfaTokenKey) != mfaToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		w.Write([]byte(`{"access_token":"token","refresh_token":"refresh","expires_in":1799}`))
+	}))
+	return server, &grants
+}
+
+func TestPasscodeAuth(t *testing.T) {
+	server, grants := newLoginServer(t, "")
+	defer server.Close()
+	tokenFile := filepath.Join(t.TempDir(), "data", "apigee_token")
+
+	newJob := func(passcode string) *authJob {
+		return newAuthJob(
+			withAPIClient(coreapi.NewClient(nil, "")),
+			withURL(server.URL),
+			withAuthServerUsername("edgecli"),
+			withAuthServerPassword("edgeclisecret"),
+			withPasscode(passcode),
+			withTokenFile(tokenFile),
+			withTokenSetter(func(string) {}),
+		)
+	}
+
+	// the passcode is exchanged for a refresh token, which is saved
+	job := newJob("one-time")
+	assert.True(t, job.Ready())
+	assert.Equal(t, "", job.passcode)
+	data, err := os.ReadFile(tokenFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "refresh", string(data))
+
+	// a restarted agent uses the saved refresh token rather than the used passcode
+	job = newJob("one-time")
+	assert.True(t, job.Ready())
+	assert.Equal(t, []string{passcodeKey, refreshTokenKey}, *grants)
+
+	// once the refresh token is rejected a new passcode is required
+	os.WriteFile(tokenFile, []byte("expired"), 0600)
+	job = newJob("")
+	assert.False(t, job.Ready())
+	assert.Equal(t, []string{passcodeKey, refreshTokenKey, refreshTokenKey}, *grants)
+}
+
+func TestMFAPasswordAuth(t *testing.T) {
+	seed := "JBSWY3DPEHPK3PXP"
+	server, grants := newLoginServer(t, seed)
+	defer server.Close()
+
+	newJob := func(mfaSeed string) *authJob {
+		return newAuthJob(
+			withAPIClient(coreapi.NewClient(nil, "")),
+			withURL(server.URL),
+			withAuthServerUsername("edgecli"),
+			withAuthServerPassword("edgeclisecret"),
+			withUsername("user@example.com"),
+			withPassword("password"),
+			withMFASeed(mfaSeed),
+			withTokenSetter(func(string) {}),
+		)
+	}
+
+	assert.True(t, newJob(seed).Ready())
+	assert.False(t, newJob("").Ready())
+	assert.Equal(t, []string{passwordKey, passwordKey}, *grants)
+}
+
+func TestAuthTimeout(t *testing.T) {
+	// an auth server that never answers
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	job := newAuthJob(
+		withAPIClient(&httpClient{client: &http.Client{}}),
+		withTimeout(50*time.Millisecond),
+		withURL(server.URL),
+		withUsername("user"),
+		withPassword("pass"),
+		withTokenSetter(func(string) {}),
+	)
+
+	// the auth calls hold the auth lock, they give up rather than block every apigee call
+	start := time.Now()
+	assert.NotNil(t, job.forceRefresh())
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// a disabled request timeout still bounds the auth calls
+	assert.Equal(t, defaultAuthTimeout, newAuthJob(withTimeout(0)).timeout)
+}
@@ -0,0 +1,383 @@
+// Package bundle reads the apiproxy bundle of an Apigee proxy revision.
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	rootDir      = "apiproxy"
+	proxiesDir   = "proxies"
+	targetsDir   = "targets"
+	policiesDir  = "policies"
+	resourcesDir = "resources"
+	// propertySetType - the resource type of the property set files, key=value lines
+	propertySetType = "properties"
+)
+
+// Bundle - the parsed configuration of a proxy revision bundle
+type Bundle struct {
+	Name            string
+	DisplayName     string
+	Description     string
+	BasePaths       []string
+	ProxyEndpoints  []*ProxyEndpoint
+	TargetEndpoints []*TargetEndpoint
+	Policies        []*Policy
+	Resources       []*Resource
+}
+
+// apiProxyXML - the apiproxy/{name}.xml file describing the proxy
+type apiProxyXML struct {
+	XMLName     xml.Name `xml:"APIProxy"`
+	Name        string   `xml:"name,attr"`
+	DisplayName string   `xml:"DisplayName"`
+	Description string   `xml:"Description"`
+	BasePaths   string   `xml:"Basepaths"`
+}
+
+// ProxyEndpoint - a file in apiproxy/proxies
+type ProxyEndpoint struct {
+	XMLName             xml.Name             `xml:"ProxyEndpoint"`
+	Name                string               `xml:"name,attr"`
+	Description         string               `xml:"Description"`
+	PreFlow             *Flow                `xml:"PreFlow"`
+	Flows               []*Flow              `xml:"Flows>Flow"`
+	PostFlow            *Flow                `xml:"PostFlow"`
+	HTTPProxyConnection *HTTPProxyConnection `xml:"HTTPProxyConnection"`
+	RouteRules          []*RouteRule         `xml:"RouteRule"`
+}
+
+// HTTPProxyConnection - the base path and virtual hosts a proxy endpoint is served on
+type HTTPProxyConnection struct {
+	BasePath     string      `xml:"BasePath"`
+	VirtualHosts []string    `xml:"VirtualHost"`
+	Properties   []*Property `xml:"Properties>Property"`
+}
+
+// Property - a named setting of a proxy or target connection
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// TargetEndpoint - a file in apiproxy/targets
+type TargetEndpoint struct {
+	XMLName              xml.Name              `xml:"TargetEndpoint"`
+	Name                 string                `xml:"name,attr"`
+	Description          string                `xml:"Description"`
+	PreFlow              *Flow                 `xml:"PreFlow"`
+	Flows                []*Flow               `xml:"Flows>Flow"`
+	PostFlow             *Flow                 `xml:"PostFlow"`
+	HTTPTargetConnection *HTTPTargetConnection `xml:"HTTPTargetConnection"`
+}
+
+// HTTPTargetConnection - the backend a target endpoint sends requests to, either a url or load balanced target servers
+type HTTPTargetConnection struct {
+	URL        string          `xml:"URL"`
+	Path       string          `xml:"Path"`
+	Servers    []*TargetServer `xml:"LoadBalancer>Server"`
+	Properties []*Property     `xml:"Properties>Property"`
+}
+
+// TargetServer - a target server of the load balancer, configured on the environment
+type TargetServer struct {
+	Name string `xml:"name,attr"`
+}
+
+// Flow - a named set of request and response steps, a conditional flow only runs when its condition is met
+type Flow struct {
+	Name        string  `xml:"name,attr"`
+	Description string  `xml:"Description"`
+	Condition   string  `xml:"Condition"`
+	Request     []*Step `xml:"Request>Step"`
+	Response    []*Step `xml:"Response>Step"`
+}
+
+// Step - a policy attached to a flow
+type Step struct {
+	Name      string `xml:"Name"`
+	Condition string `xml:"Condition"`
+}
+
+// RouteRule - selects the target endpoint, or url, a request is sent to
+type RouteRule struct {
+	Name           string `xml:"name,attr"`
+	Condition      string `xml:"Condition"`
+	TargetEndpoint string `xml:"TargetEndpoint"`
+	URL            string `xml:"URL"`
+}
+
+// Policy - a file in apiproxy/policies, the root element name is the policy type
+type Policy struct {
+	Name            string
+	Type            string
+	DisplayName     string
+	Enabled         bool
+	ContinueOnError bool
+	Async           bool
+	// Content - the policy xml, for reading the policy type specific settings
+	Content []byte
+}
+
+// policyXML - the attributes that every policy has
+type policyXML struct {
+	XMLName         xml.Name
+	Name            string `xml:"name,attr"`
+	Enabled         string `xml:"enabled,attr"`
+	ContinueOnError string `xml:"continueOnError,attr"`
+	Async           string `xml:"async,attr"`
+	DisplayName     string `xml:"DisplayName"`
+}
+
+// Resource - a file in apiproxy/resources/{type}
+type Resource struct {
+	Type    string
+	Name    string
+	Content []byte
+}
+
+// Parse - reads the bundle from the zip returned by the revision api
+func Parse(data []byte) (*Bundle, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading the bundle zip: %w", err)
+	}
+
+	b := &Bundle{
+		BasePaths:       []string{},
+		ProxyEndpoints:  []*ProxyEndpoint{},
+		TargetEndpoints: []*TargetEndpoint{},
+		Policies:        []*Policy{},
+		Resources:       []*Resource{},
+	}
+
+	// sort the files so the endpoints and policies are always in the same order
+	files := zipReader.File
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	for _, zipFile := range files {
+		if zipFile.FileInfo().IsDir() {
+			continue
+		}
+
+		parts := strings.Split(strings.TrimPrefix(path.Clean(zipFile.Name), "/"), "/")
+		if len(parts) < 2 || parts[0] != rootDir {
+			continue
+		}
+
+		content, err := readZipFile(zipFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", zipFile.Name, err)
+		}
+
+		if err = b.addFile(parts[1:], content); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", zipFile.Name, err)
+		}
+	}
+
+	return b, nil
+}
+
+// addFile - adds the file, at the path under the apiproxy directory, to the bundle
+func (b *Bundle) addFile(parts []string, content []byte) error {
+	isXML := path.Ext(parts[len(parts)-1]) == ".xml"
+
+	switch {
+	case len(parts) == 1 && isXML:
+		return b.addProxy(content)
+	case len(parts) == 2 && parts[0] == proxiesDir && isXML:
+		endpoint := &ProxyEndpoint{}
+		if err := xml.Unmarshal(content, endpoint); err != nil {
+			return err
+		}
+		b.ProxyEndpoints = append(b.ProxyEndpoints, endpoint)
+	case len(parts) == 2 && parts[0] == targetsDir && isXML:
+		endpoint := &TargetEndpoint{}
+		if err := xml.Unmarshal(content, endpoint); err != nil {
+			return err
+		}
+		b.TargetEndpoints = append(b.TargetEndpoints, endpoint)
+	case len(parts) == 2 && parts[0] == policiesDir && isXML:
+		policy, err := ParsePolicy(content)
+		if err != nil {
+			return err
+		}
+		b.Policies = append(b.Policies, policy)
+	case len(parts) >= 3 && parts[0] == resourcesDir:
+		b.Resources = append(b.Resources, &Resource{
+			Type:    parts[1],
+			Name:    path.Join(parts[2:]...),
+			Content: content,
+		})
+	}
+	return nil
+}
+
+func (b *Bundle) addProxy(content []byte) error {
+	proxy := &apiProxyXML{}
+	if err := xml.Unmarshal(content, proxy); err != nil {
+		return err
+	}
+
+	b.Name = proxy.Name
+	b.DisplayName = proxy.DisplayName
+	b.Description = proxy.Description
+	for _, basePath := range strings.Split(proxy.BasePaths, ",") {
+		if basePath = strings.TrimSpace(basePath); basePath != "" {
+			b.BasePaths = append(b.BasePaths, basePath)
+		}
+	}
+	return nil
+}
+
+// ParsePolicy - reads a policy file
+func ParsePolicy(content []byte) (*Policy, error) {
+	data := &policyXML{}
+	if err := xml.Unmarshal(content, data); err != nil {
+		return nil, err
+	}
+
+	return &Policy{
+		Name:            data.Name,
+		Type:            data.XMLName.Local,
+		DisplayName:     data.DisplayName,
+		Enabled:         data.Enabled != "false",
+		ContinueOnError: data.ContinueOnError == "true",
+		Async:           data.Async == "true",
+		Content:         content,
+	}, nil
+}
+
+func readZipFile(zf *zip.File) ([]byte, error) {
+	f, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// GetProxyEndpoint - returns the proxy endpoint with the name, nil when it is not in the bundle
+func (b *Bundle) GetProxyEndpoint(name string) *ProxyEndpoint {
+	for _, endpoint := range b.ProxyEndpoints {
+		if endpoint.Name == name {
+			return endpoint
+		}
+	}
+	return nil
+}
+
+// GetTargetEndpoint - returns the target endpoint with the name, nil when it is not in the bundle
+func (b *Bundle) GetTargetEndpoint(name string) *TargetEndpoint {
+	for _, endpoint := range b.TargetEndpoints {
+		if endpoint.Name == name {
+			return endpoint
+		}
+	}
+	return nil
+}
+
+// GetPolicy - returns the policy with the name, nil when it is not in the bundle
+func (b *Bundle) GetPolicy(name string) *Policy {
+	for _, policy := range b.Policies {
+		if policy.Name == name {
+			return policy
+		}
+	}
+	return nil
+}
+
+// GetResource - returns the resource file with the type and name, nil when it is not in the bundle
+func (b *Bundle) GetResource(resourceType, name string) *Resource {
+	for _, resource := range b.Resources {
+		if resource.Type == resourceType && resource.Name == name {
+			return resource
+		}
+	}
+	return nil
+}
+
+// GetProperties - the properties of the proxy and target connections, and the values of the property sets named
+// {set}.{key}, a later endpoint overrides the value of a property with the same name
+func (b *Bundle) GetProperties() map[string]string {
+	properties := map[string]string{}
+	for _, endpoint := range b.ProxyEndpoints {
+		if endpoint.HTTPProxyConnection != nil {
+			addProperties(properties, endpoint.HTTPProxyConnection.Properties)
+		}
+	}
+	for _, endpoint := range b.TargetEndpoints {
+		if endpoint.HTTPTargetConnection != nil {
+			addProperties(properties, endpoint.HTTPTargetConnection.Properties)
+		}
+	}
+
+	for _, resource := range b.Resources {
+		if resource.Type != propertySetType {
+			continue
+		}
+		set := strings.TrimSuffix(resource.Name, path.Ext(resource.Name))
+		for _, line := range strings.Split(string(resource.Content), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if key, value, found := strings.Cut(line, "="); found {
+				properties[set+"."+strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return properties
+}
+
+func addProperties(properties map[string]string, connProperties []*Property) {
+	for _, p := range connProperties {
+		properties[p.Name] = strings.TrimSpace(p.Value)
+	}
+}
+
+// GetBasePath - the base path of the proxy endpoint, empty when it has no connection
+func (p *ProxyEndpoint) GetBasePath() string {
+	if p.HTTPProxyConnection == nil {
+		return ""
+	}
+	return p.HTTPProxyConnection.BasePath
+}
+
+// GetVirtualHosts - the virtual hosts the proxy endpoint is served on
+func (p *ProxyEndpoint) GetVirtualHosts() []string {
+	if p.HTTPProxyConnection == nil {
+		return []string{}
+	}
+	return p.HTTPProxyConnection.VirtualHosts
+}
+
+// GetSteps - the steps of every flow of the proxy endpoint, in the order the flows run
+func (p *ProxyEndpoint) GetSteps() []*Step {
+	return flowSteps(append(append([]*Flow{p.PreFlow}, p.Flows...), p.PostFlow))
+}
+
+// GetSteps - the steps of every flow of the target endpoint, in the order the flows run
+func (t *TargetEndpoint) GetSteps() []*Step {
+	return flowSteps(append(append([]*Flow{t.PreFlow}, t.Flows...), t.PostFlow))
+}
+
+func flowSteps(flows []*Flow) []*Step {
+	steps := []*Step{}
+	for _, flow := range flows {
+		if flow == nil {
+			continue
+		}
+		steps = append(steps, flow.Request...)
+		steps = append(steps, flow.Response...)
+	}
+	return steps
+}
@@ -0,0 +1,140 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// zipTestBundle - creates a bundle zip from the testdata directory
+func zipTestBundle(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(buf)
+
+	err := filepath.Walk("testdata", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, _ := filepath.Rel("testdata", path)
+		w, err := zipWriter.Create(filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, zipWriter.Close())
+	return buf.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	b, err := Parse(zipTestBundle(t))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "petstore", b.Name)
+	assert.Equal(t, "Petstore", b.DisplayName)
+	assert.Equal(t, "The petstore proxy", b.Description)
+	assert.Equal(t, []string{"/petstore", "/admin"}, b.BasePaths)
+
+	// proxy endpoints
+	assert.Len(t, b.ProxyEndpoints, 2)
+	assert.Equal(t, "admin", b.ProxyEndpoints[0].Name)
+
+	endpoint := b.GetProxyEndpoint("default")
+	assert.NotNil(t, endpoint)
+	assert.Equal(t, "/petstore", endpoint.GetBasePath())
+	assert.Equal(t, []string{"default", "secure"}, endpoint.GetVirtualHosts())
+	assert.Len(t, endpoint.Flows, 2)
+	assert.Equal(t, "listPets", endpoint.Flows[0].Name)
+	assert.Equal(t, "List all pets", endpoint.Flows[0].Description)
+	assert.Equal(t, `(proxy.pathsuffix MatchesPath "/pets") and (request.verb = "GET")`, endpoint.Flows[0].Condition)
+	assert.Equal(t, `request.verb != "OPTIONS"`, endpoint.PreFlow.Request[1].Condition)
+	assert.Equal(t, []*RouteRule{{Name: "default", TargetEndpoint: "default"}}, endpoint.RouteRules)
+
+	steps := []string{}
+	for _, step := range endpoint.GetSteps() {
+		steps = append(steps, step.Name)
+	}
+	assert.Equal(t, []string{"verify-api-key", "quota", "set-headers"}, steps)
+
+	admin := b.GetProxyEndpoint("admin")
+	assert.Equal(t, []string{"secure"}, admin.GetVirtualHosts())
+	assert.Empty(t, admin.Flows)
+	assert.Len(t, admin.RouteRules, 2)
+	assert.Equal(t, "https://mock.example.com", admin.RouteRules[0].URL)
+	assert.Equal(t, `request.header.mock = "true"`, admin.RouteRules[0].Condition)
+	assert.Nil(t, b.GetProxyEndpoint("missing"))
+
+	// target endpoints
+	assert.Len(t, b.TargetEndpoints, 2)
+	assert.Equal(t, "https://petstore.example.com/v1", b.GetTargetEndpoint("default").HTTPTargetConnection.URL)
+	target := b.GetTargetEndpoint("admin")
+	assert.Equal(t, "/admin", target.HTTPTargetConnection.Path)
+	assert.Equal(t, []*TargetServer{{Name: "admin-1"}, {Name: "admin-2"}}, target.HTTPTargetConnection.Servers)
+	assert.Empty(t, target.GetSteps())
+
+	// policies
+	assert.Len(t, b.Policies, 4)
+	policy := b.GetPolicy("verify-api-key")
+	assert.Equal(t, "VerifyAPIKey", policy.Type)
+	assert.Equal(t, "Verify API Key", policy.DisplayName)
+	assert.True(t, policy.Enabled)
+	assert.False(t, policy.ContinueOnError)
+	quota := b.GetPolicy("quota")
+	assert.Equal(t, "Quota", quota.Type)
+	assert.False(t, quota.Enabled)
+	assert.True(t, quota.ContinueOnError)
+	assert.Contains(t, string(quota.Content), "<Distributed>true</Distributed>")
+	assert.Nil(t, b.GetPolicy("missing"))
+
+	// resources
+	assert.Len(t, b.Resources, 3)
+	association := b.GetResource("openapi", "association.json")
+	assert.NotNil(t, association)
+	assert.Contains(t, string(association.Content), "/specs/doc/1/content")
+	assert.NotNil(t, b.GetResource("jsc", "lib/util.js"))
+	assert.Nil(t, b.GetResource("jsc", "association.json"))
+
+	// properties, the target endpoint overrides the proxy endpoint
+	assert.Equal(t, map[string]string{
+		"team":              "pets",
+		"visibility":        "public",
+		"io.timeout.millis": "5000",
+		"settings.owner":    "pet-team",
+		"settings.tier":     "gold",
+	}, b.GetProperties())
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse([]byte("not a zip"))
+	assert.NotNil(t, err)
+
+	buf := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(buf)
+	w, _ := zipWriter.Create("apiproxy/proxies/default.xml")
+	w.Write([]byte("<ProxyEndpoint"))
+	zipWriter.Close()
+	_, err = Parse(buf.Bytes())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "apiproxy/proxies/default.xml")
+
+	// files outside of the apiproxy directory are ignored
+	buf = &bytes.Buffer{}
+	zipWriter = zip.NewWriter(buf)
+	w, _ = zipWriter.Create("README.md")
+	w.Write([]byte("readme"))
+	zipWriter.Close()
+	b, err := Parse(buf.Bytes())
+	assert.Nil(t, err)
+	assert.Empty(t, b.ProxyEndpoints)
+	assert.Empty(t, b.Resources)
+}
@@ -0,0 +1,165 @@
+package bundle
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// policy types the agent reads the settings of
+const (
+	VerifyAPIKeyType = "VerifyAPIKey"
+	OAuthV2Type      = "OAuthV2"
+	QuotaType        = "Quota"
+)
+
+// VerifyAccessTokenOperation - the OAuthV2 operation that protects a flow, the other operations issue or revoke tokens
+const VerifyAccessTokenOperation = "VerifyAccessToken"
+
+// keyRefRegex - a flow variable of a request header or query parameter, e.g. request.header.x-api-key
+var keyRefRegex = regexp.MustCompile(`^request\.(header|queryparam)\.(.+)$`)
+
+// VerifyAPIKey - the settings of a VerifyAPIKey policy
+type VerifyAPIKey struct {
+	// KeyRef - the flow variable the key is read from
+	KeyRef string
+	// In - header or query, empty when the key is not read from a request header or query parameter
+	In string
+	// Name - the name of the header or query parameter
+	Name string
+}
+
+// OAuthV2 - the settings of an OAuthV2 policy
+type OAuthV2 struct {
+	Operation string
+	// Scopes - the scopes a verified token must have
+	Scopes []string
+}
+
+// Quota - the settings of a Quota policy, a ref is the flow variable that overrides the value when it is set
+type Quota struct {
+	Type        string
+	Allow       int
+	AllowRef    string
+	Interval    int
+	IntervalRef string
+	TimeUnit    string
+	TimeUnitRef string
+	Identifier  string
+	Distributed bool
+	Synchronous bool
+}
+
+type verifyAPIKeyXML struct {
+	APIKey struct {
+		Ref string `xml:"ref,attr"`
+	} `xml:"APIKey"`
+}
+
+type oauthV2XML struct {
+	Operation string `xml:"Operation"`
+	Scope     string `xml:"Scope"`
+}
+
+type quotaXML struct {
+	Type  string `xml:"type,attr"`
+	Allow struct {
+		Count    string `xml:"count,attr"`
+		CountRef string `xml:"countRef,attr"`
+	} `xml:"Allow"`
+	Interval struct {
+		Value string `xml:",chardata"`
+		Ref   string `xml:"ref,attr"`
+	} `xml:"Interval"`
+	TimeUnit struct {
+		Value string `xml:",chardata"`
+		Ref   string `xml:"ref,attr"`
+	} `xml:"TimeUnit"`
+	Identifier struct {
+		Ref string `xml:"ref,attr"`
+	} `xml:"Identifier"`
+	Distributed string `xml:"Distributed"`
+	Synchronous string `xml:"Synchronous"`
+}
+
+// VerifyAPIKey - reads the settings of a VerifyAPIKey policy
+func (p *Policy) VerifyAPIKey() (*VerifyAPIKey, error) {
+	data := &verifyAPIKeyXML{}
+	if err := p.unmarshal(VerifyAPIKeyType, data); err != nil {
+		return nil, err
+	}
+
+	settings := &VerifyAPIKey{KeyRef: strings.TrimSpace(data.APIKey.Ref)}
+	if match := keyRefRegex.FindStringSubmatch(settings.KeyRef); match != nil {
+		settings.In = "header"
+		if match[1] == "queryparam" {
+			settings.In = "query"
+		}
+		settings.Name = match[2]
+	}
+	return settings, nil
+}
+
+// OAuthV2 - reads the settings of an OAuthV2 policy
+func (p *Policy) OAuthV2() (*OAuthV2, error) {
+	data := &oauthV2XML{}
+	if err := p.unmarshal(OAuthV2Type, data); err != nil {
+		return nil, err
+	}
+
+	return &OAuthV2{
+		Operation: strings.TrimSpace(data.Operation),
+		Scopes:    strings.Fields(data.Scope),
+	}, nil
+}
+
+// Quota - reads the settings of a Quota policy
+func (p *Policy) Quota() (*Quota, error) {
+	data := &quotaXML{}
+	if err := p.unmarshal(QuotaType, data); err != nil {
+		return nil, err
+	}
+
+	allow, _ := strconv.Atoi(strings.TrimSpace(data.Allow.Count))
+	interval, _ := strconv.Atoi(strings.TrimSpace(data.Interval.Value))
+	return &Quota{
+		Type:        data.Type,
+		Allow:       allow,
+		AllowRef:    data.Allow.CountRef,
+		Interval:    interval,
+		IntervalRef: data.Interval.Ref,
+		TimeUnit:    strings.TrimSpace(data.TimeUnit.Value),
+		TimeUnitRef: data.TimeUnit.Ref,
+		Identifier:  data.Identifier.Ref,
+		Distributed: strings.TrimSpace(data.Distributed) == "true",
+		Synchronous: strings.TrimSpace(data.Synchronous) == "true",
+	}, nil
+}
+
+func (p *Policy) unmarshal(policyType string, v interface{}) error {
+	if p.Type != policyType {
+		return fmt.Errorf("policy %s is a %s policy, not %s", p.Name, p.Type, policyType)
+	}
+	return xml.Unmarshal(p.Content, v)
+}
+
+// IsPolicyReferenced - returns true when a step of a proxy or target endpoint flow runs the policy
+func (b *Bundle) IsPolicyReferenced(name string) bool {
+	for _, endpoint := range b.ProxyEndpoints {
+		for _, step := range endpoint.GetSteps() {
+			if step.Name == name {
+				return true
+			}
+		}
+	}
+	for _, endpoint := range b.TargetEndpoints {
+		for _, step := range endpoint.GetSteps() {
+			if step.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package bundle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicySettings(t *testing.T) {
+	b, err := Parse(zipTestBundle(t))
+	assert.Nil(t, err)
+
+	apiKey, err := b.GetPolicy("verify-api-key").VerifyAPIKey()
+	assert.Nil(t, err)
+	assert.Equal(t, &VerifyAPIKey{KeyRef: "request.header.x-api-key", In: "header", Name: "x-api-key"}, apiKey)
+
+	oauth, err := b.GetPolicy("verify-token").OAuthV2()
+	assert.Nil(t, err)
+	assert.Equal(t, &OAuthV2{Operation: VerifyAccessTokenOperation, Scopes: []string{"pets:read", "pets:write"}}, oauth)
+
+	quota, err := b.GetPolicy("quota").Quota()
+	assert.Nil(t, err)
+	assert.Equal(t, &Quota{
+		Type:        "calendar",
+		Allow:       100,
+		AllowRef:    "verifyapikey.verify-api-key.apiproduct.developer.quota.limit",
+		Interval:    1,
+		IntervalRef: "verifyapikey.verify-api-key.apiproduct.developer.quota.interval",
+		TimeUnit:    "minute",
+		TimeUnitRef: "verifyapikey.verify-api-key.apiproduct.developer.quota.timeunit",
+		Identifier:  "client_id",
+		Distributed: true,
+	}, quota)
+
+	// the settings of another policy type can not be read
+	_, err = b.GetPolicy("quota").VerifyAPIKey()
+	assert.NotNil(t, err)
+	_, err = b.GetPolicy("set-headers").OAuthV2()
+	assert.NotNil(t, err)
+
+	// a key in a query parameter, or another variable
+	policy, err := ParsePolicy([]byte(`<VerifyAPIKey name="key"><APIKey ref="request.queryparam.apikey"/></VerifyAPIKey>`))
+	assert.Nil(t, err)
+	apiKey, _ = policy.VerifyAPIKey()
+	assert.Equal(t, &VerifyAPIKey{KeyRef: "request.queryparam.apikey", In: "query", Name: "apikey"}, apiKey)
+
+	policy, _ = ParsePolicy([]byte(`<VerifyAPIKey name="key"><APIKey ref="private.key"/></VerifyAPIKey>`))
+	apiKey, _ = policy.VerifyAPIKey()
+	assert.Equal(t, &VerifyAPIKey{KeyRef: "private.key"}, apiKey)
+}
+
+func TestIsPolicyReferenced(t *testing.T) {
+	b, err := Parse(zipTestBundle(t))
+	assert.Nil(t, err)
+
+	assert.True(t, b.IsPolicyReferenced("verify-api-key"))
+	assert.True(t, b.IsPolicyReferenced("quota"))
+	assert.True(t, b.IsPolicyReferenced("set-headers"))
+	assert.False(t, b.IsPolicyReferenced("verify-token"))
+	assert.False(t, b.IsPolicyReferenced("missing"))
+}
@@ -3,6 +3,7 @@ package apigee
 import (
 	"encoding/base64"
 	"fmt"
+	"sync"
 	"time"
 
 	coreapi "github.com/Axway/agent-sdk/pkg/api"
@@ -15,6 +16,13 @@ import (
 type ApigeeClient struct {
 	cfg         *config.ApigeeConfig
 	apiClient   coreapi.Client
+	specClients map[string]coreapi.Client
+	retry       *retryPolicy
+	pageSize    int
+	timeout     time.Duration
+	auth        *authJob
+	authLock    sync.RWMutex
+	refreshLock sync.Mutex
 	authType    string
 	authValue   string
 	developerID string
@@ -26,17 +34,58 @@ type ApigeeClient struct {
 
 // NewClient - Creates a new Gateway Client
 func NewClient(apigeeCfg *config.ApigeeConfig) (*ApigeeClient, error) {
+	apiClient, err := newHTTPClient(apigeeCfg)
+	if err != nil {
+		return nil, err
+	}
+
 	client := &ApigeeClient{
-		apiClient:   coreapi.NewClient(nil, ""),
+		apiClient:   apiClient,
+		retry:       newRetryPolicy(apigeeCfg.GetRetry()),
+		pageSize:    listPageSize,
+		timeout:     apigeeCfg.GetRequestTimeout(),
 		cfg:         apigeeCfg,
 		envToURLs:   make(map[string][]string),
 		isReady:     false,
 		developerID: apigeeCfg.DeveloperID,
 		orgURL:      fmt.Sprintf("%s/%s/organizations/%s", apigeeCfg.URL, apigeeCfg.APIVersion, apigeeCfg.Organization),
 		dataURL:     apigeeCfg.DataURL,
+		specClients: make(map[string]coreapi.Client),
 	}
 
-	if apigeeCfg.Auth.UseBasicAuth() {
+	// the credential profiles with a client certificate download their specs on their own connections
+	for name, creds := range apigeeCfg.GetSpecCredentials() {
+		if !creds.HasClientCert() {
+			continue
+		}
+		specClient, err := newSpecCredentialsClient(apigeeCfg, creds)
+		if err != nil {
+			return nil, err
+		}
+		client.specClients[name] = specClient
+	}
+
+	if apigeeCfg.IsApigeeX() && apigeeCfg.Auth.GetServiceAccount() != "" {
+		// apigee x and hybrid exchange a signed service account assertion for a google oauth token
+		key, err := loadServiceAccountKey(apigeeCfg.Auth.GetServiceAccount())
+		if err != nil {
+			return nil, err
+		}
+
+		client.authType = "Bearer"
+		client.auth = newAuthJob(
+			withAPIClient(client.apiClient),
+			withTimeout(client.timeout),
+			withServiceAccountKey(key),
+			withTokenSetter(client.setAccessToken),
+		)
+		jobs.RegisterIntervalJobWithName(client.auth, authCheckInterval, "APIGEE Service Account Auth Token")
+	} else if apigeeCfg.IsApigeeX() {
+		// apigee x and hybrid use a google oauth access token
+		client.authType = "Bearer"
+		client.authValue = apigeeCfg.Auth.GetToken()
+		client.isReady = true
+	} else if apigeeCfg.Auth.UseBasicAuth() {
 		// setup the use of basic auth
 		client.authType = "Basic"
 		client.authValue = base64.StdEncoding.EncodeToString([]byte(
@@ -45,26 +94,58 @@ func NewClient(apigeeCfg *config.ApigeeConfig) (*ApigeeClient, error) {
 	} else {
 		// create the auth job and register it
 		client.authType = "Bearer"
-		authentication := newAuthJob(
+		client.auth = newAuthJob(
 			withAPIClient(client.apiClient),
+			withTimeout(client.timeout),
 			withUsername(apigeeCfg.Auth.GetUsername()),
 			withPassword(apigeeCfg.Auth.GetPassword()),
 			withURL(apigeeCfg.Auth.GetURL()),
 			withAuthServerUsername(apigeeCfg.Auth.GetServerUsername()),
 			withAuthServerPassword(apigeeCfg.Auth.GetServerPassword()),
+			withPasscode(apigeeCfg.Auth.GetPasscode()),
+			withMFASeed(apigeeCfg.Auth.GetMFASeed()),
+			withTokenFile(apigeeCfg.Auth.GetTokenFile()),
 			withTokenSetter(client.setAccessToken),
 		)
-		jobs.RegisterIntervalJobWithName(authentication, 10*time.Minute, "APIGEE Auth Token")
+		jobs.RegisterIntervalJobWithName(client.auth, authCheckInterval, "APIGEE Auth Token")
 	}
 
 	return client, nil
 }
 
 func (a *ApigeeClient) setAccessToken(token string) {
+	a.authLock.Lock()
+	defer a.authLock.Unlock()
 	a.authValue = token
 	a.isReady = true
 }
 
+func (a *ApigeeClient) getAccessToken() string {
+	a.authLock.RLock()
+	defer a.authLock.RUnlock()
+	return a.authValue
+}
+
+// refreshAccessToken - gets a new token when the stale token is still the current one, returns the current token
+func (a *ApigeeClient) refreshAccessToken(staleToken string) (string, error) {
+	if a.auth == nil {
+		return "", fmt.Errorf("the configured authentication can not be refreshed")
+	}
+
+	// only one worker refreshes the token, the others use the new token
+	a.refreshLock.Lock()
+	defer a.refreshLock.Unlock()
+	if token := a.getAccessToken(); token != staleToken {
+		return token, nil
+	}
+
+	err := a.auth.forceRefresh()
+	if err != nil {
+		return "", err
+	}
+	return a.getAccessToken(), nil
+}
+
 // GetDeveloperID - get the developer id to be used when creating apps
 func (a *ApigeeClient) GetDeveloperID() string {
 	return a.developerID
@@ -77,5 +158,7 @@ func (a *ApigeeClient) GetConfig() *config.ApigeeConfig {
 
 // IsReady - returns true when the apigee client authenticates
 func (a *ApigeeClient) IsReady() bool {
+	a.authLock.RLock()
+	defer a.authLock.RUnlock()
 	return a.isReady
 }
@@ -1,7 +1,11 @@
 package apigee
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Axway/agent-sdk/pkg/api"
 	"github.com/Axway/agents-apigee/client/pkg/config"
@@ -37,3 +41,42 @@ func TestNewClient(t *testing.T) {
 	assert.Equal(t, c.GetDeveloperID(), "test@dev.id")
 	assert.True(t, c.IsReady())
 }
+
+func TestClientContext(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// hold the call until the client gives up on it
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+
+	c := createTestClient(t, nil)
+	c.apiClient, _ = newHTTPClient(c.cfg)
+	c.retry = newRetryPolicy(nil)
+	c.orgURL = server.URL
+
+	// the configured timeout bounds the call
+	c.timeout = 50 * time.Millisecond
+	start := time.Now()
+	_, err := c.GetProxy("proxy")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// cancelling the context stops the call
+	c.timeout = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err = c.GetProxyWithContext(ctx, "proxy")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// a done context is not sent
+	_, err = c.GetDeploymentsWithContext(ctx, "proxy")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -10,6 +10,7 @@ type grantType int
 const (
 	password grantType = iota
 	refresh
+	jwtBearer
 )
 
 const (
@@ -22,7 +23,7 @@ var ApigeeAgentAttribute = models.Attribute{
 }
 
 func (g grantType) String() string {
-	return [...]string{"password", "refresh_token"}[g]
+	return [...]string{"password", "refresh_token", "urn:ietf:params:oauth:grant-type:jwt-bearer"}[g]
 }
 
 // AuthResponse - response struct from APIGEE auth call
@@ -97,8 +98,16 @@ type SpecDetails struct {
 	FolderLink  string        `json:"folder"`
 	FolderID    string        `json:"folderId"`
 	Body        *string       `json:"body"`
+	// FolderPath - the path of the folder the spec is in, from the home folder, "/" for the home folder itself
+	FolderPath string `json:"-"`
 }
 
+// spec store entry kinds
+const (
+	SpecKindFolder = "Folder"
+	SpecKindDoc    = "Doc"
+)
+
 // VirtualHosts
 type VirtualHosts []string
 
@@ -1,6 +1,7 @@
 package apigee
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -8,15 +9,35 @@ import (
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
 )
 
+// DeployedState - the state of a proxy revision that is serving traffic in an environment
+const DeployedState = "deployed"
+
 // GetDeployments - get a deployments for a proxy
 func (a *ApigeeClient) GetDeployments(proxyName string) (*models.DeploymentDetails, error) {
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/apis/%s/deployments", a.orgURL, proxyName),
+	return a.GetDeploymentsWithContext(context.Background(), proxyName)
+}
+
+// GetDeploymentsWithContext - get a deployments for a proxy, cancelled when the context is done
+func (a *ApigeeClient) GetDeploymentsWithContext(ctx context.Context, proxyName string) (*models.DeploymentDetails, error) {
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/apis/%s/deployments", a.orgURL, proxyName),
 		WithDefaultHeaders(),
 	).Execute()
 
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the proxy deployments")
+	}
+
+	if a.cfg.IsApigeeX() {
+		xDeployments := xDeploymentsResponse{}
+		err = json.Unmarshal(response.Body, &xDeployments)
+		if err != nil {
+			return nil, err
+		}
+		return xDeployments.toDeploymentDetails(proxyName), nil
+	}
 
 	details := &models.DeploymentDetails{}
 	err = json.Unmarshal(response.Body, details)
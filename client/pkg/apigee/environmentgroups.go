@@ -0,0 +1,75 @@
+package apigee
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetEnvironmentGroups - returns all of the environment groups defined, apigee x and hybrid only
+func (a *ApigeeClient) GetEnvironmentGroups() ([]EnvironmentGroup, error) {
+	return a.GetEnvironmentGroupsWithContext(context.Background())
+}
+
+// GetEnvironmentGroupsWithContext - returns all of the environment groups defined, apigee x and hybrid only, cancelled when the context is done
+func (a *ApigeeClient) GetEnvironmentGroupsWithContext(ctx context.Context) ([]EnvironmentGroup, error) {
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/envgroups", a.orgURL),
+		WithDefaultHeaders(),
+	).Execute()
+	if err != nil {
+		return nil, err
+	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the environment groups")
+	}
+
+	groups := xEnvironmentGroupsResponse{}
+	err = json.Unmarshal(response.Body, &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	return groups.EnvironmentGroups, nil
+}
+
+// GetEnvironmentGroupHostnames - returns the hostnames of all environment groups the environment is attached to
+func (a *ApigeeClient) GetEnvironmentGroupHostnames(envName string) ([]string, error) {
+	return a.GetEnvironmentGroupHostnamesWithContext(context.Background(), envName)
+}
+
+// GetEnvironmentGroupHostnamesWithContext - returns the hostnames of all environment groups the environment is attached to, cancelled when the context is done
+func (a *ApigeeClient) GetEnvironmentGroupHostnamesWithContext(ctx context.Context, envName string) ([]string, error) {
+	groups, err := a.GetEnvironmentGroupsWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hostnames := []string{}
+	for _, group := range groups {
+		response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/envgroups/%s/attachments", a.orgURL, group.Name),
+			WithDefaultHeaders(),
+		).Execute()
+		if err != nil {
+			return nil, err
+		}
+		if response.Code != http.StatusOK {
+			return nil, newAPIError(response, "getting the environment group attachments")
+		}
+
+		attachments := xEnvironmentGroupAttachmentsResponse{}
+		err = json.Unmarshal(response.Body, &attachments)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, attachment := range attachments.EnvironmentGroupAttachments {
+			if attachment.Environment == envName {
+				hostnames = append(hostnames, group.Hostnames...)
+				break
+			}
+		}
+	}
+
+	return hostnames, nil
+}
@@ -0,0 +1,84 @@
+package apigee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	coreapi "github.com/Axway/agent-sdk/pkg/api"
+)
+
+// APIError - an unexpected response from the Apigee api
+type APIError struct {
+	// StatusCode - the http status code of the response
+	StatusCode int
+	// Code - the Apigee error code, e.g. keymanagement.service.app_doesnot_exist, or the google status on Apigee X
+	Code string
+	// Message - the Apigee error message
+	Message string
+	// operation - what the client was doing when it received the response
+	operation string
+}
+
+// apigeeErrorResponse - the error body returned by Apigee Edge
+type apigeeErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// xErrorResponse - the error body returned by Apigee X and hybrid
+type xErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// newAPIError - creates the error from the response, parsing the Apigee error body when there is one
+func newAPIError(response *coreapi.Response, operation string) *APIError {
+	apiErr := &APIError{
+		StatusCode: response.Code,
+		operation:  operation,
+	}
+
+	xErr := xErrorResponse{}
+	if json.Unmarshal(response.Body, &xErr) == nil && xErr.Error.Status != "" {
+		apiErr.Code = xErr.Error.Status
+		apiErr.Message = xErr.Error.Message
+		return apiErr
+	}
+
+	edgeErr := apigeeErrorResponse{}
+	if json.Unmarshal(response.Body, &edgeErr) == nil {
+		apiErr.Code = edgeErr.Code
+		apiErr.Message = edgeErr.Message
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("received an unexpected response code %d from Apigee when %s", e.StatusCode, e.operation)
+	if e.Code != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Code)
+	}
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+	return msg
+}
+
+// IsNotFound - returns true when the error is an Apigee not found response
+func IsNotFound(err error) bool {
+	return hasStatusCode(err, http.StatusNotFound)
+}
+
+// IsConflict - returns true when the error is an Apigee conflict response, i.e. the entity already exists
+func IsConflict(err error) bool {
+	return hasStatusCode(err, http.StatusConflict)
+}
+
+func hasStatusCode(err error, statusCode int) bool {
+	apiErr := &APIError{}
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
@@ -0,0 +1,66 @@
+package apigee
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Axway/agent-sdk/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError(t *testing.T) {
+	cases := map[string]struct {
+		response        api.MockResponse
+		expectedCode    string
+		expectedMessage string
+		notFound        bool
+		conflict        bool
+	}{
+		"edge error body": {
+			response: api.MockResponse{
+				RespCode: http.StatusNotFound,
+				RespData: `{"code":"keymanagement.service.app_doesnot_exist","message":"App named app does not exist under dev","contexts":[]}`,
+			},
+			expectedCode:    "keymanagement.service.app_doesnot_exist",
+			expectedMessage: "App named app does not exist under dev",
+			notFound:        true,
+		},
+		"apigee x error body": {
+			response: api.MockResponse{
+				RespCode: http.StatusConflict,
+				RespData: `{"error":{"code":409,"message":"App app already exists","status":"ALREADY_EXISTS"}}`,
+			},
+			expectedCode:    "ALREADY_EXISTS",
+			expectedMessage: "App app already exists",
+			conflict:        true,
+		},
+		"no error body": {
+			response: api.MockResponse{
+				RespCode: http.StatusInternalServerError,
+				RespData: "<html>server error</html>",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := createTestClient(t, &api.MockHTTPClient{Responses: []api.MockResponse{tc.response}})
+
+			_, err := c.GetDeveloperApp("app")
+			apiErr, ok := err.(*APIError)
+			assert.True(t, ok)
+			assert.Equal(t, tc.response.RespCode, apiErr.StatusCode)
+			assert.Equal(t, tc.expectedCode, apiErr.Code)
+			assert.Equal(t, tc.expectedMessage, apiErr.Message)
+			assert.Contains(t, err.Error(), "retrieving the app")
+
+			// the helpers see through wrapped errors
+			wrapped := fmt.Errorf("failed to retrieve app: %w", err)
+			assert.Equal(t, tc.notFound, IsNotFound(wrapped))
+			assert.Equal(t, tc.conflict, IsConflict(wrapped))
+		})
+	}
+
+	assert.False(t, IsNotFound(fmt.Errorf("404")))
+	assert.False(t, IsNotFound(nil))
+}
@@ -0,0 +1,171 @@
+package apigee
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	coreapi "github.com/Axway/agent-sdk/pkg/api"
+
+	"github.com/Axway/agents-apigee/client/pkg/config"
+)
+
+// contextClient - an api client that cancels in-flight requests when the context is done
+type contextClient interface {
+	coreapi.Client
+	SendWithContext(ctx context.Context, request coreapi.Request) (*coreapi.Response, error)
+}
+
+// httpClient - sends the apigee api calls, the sdk client can not cancel a request once it is sent
+type httpClient struct {
+	client *http.Client
+}
+
+// newHTTPClient - creates the client with the configured proxy and tls settings, used for every apigee call
+func newHTTPClient(apigeeCfg *config.ApigeeConfig) (*httpClient, error) {
+	return newHTTPClientWithTLS(apigeeCfg, apigeeCfg.GetTLS())
+}
+
+// newSpecCredentialsClient - creates the client the specs of a credential profile with a client certificate are
+// downloaded with, the profile certificate replaces the configured one
+func newSpecCredentialsClient(apigeeCfg *config.ApigeeConfig, creds *config.SpecCredentials) (*httpClient, error) {
+	tlsCfg := config.ApigeeTLS{}
+	if apigeeCfg.GetTLS() != nil {
+		tlsCfg = *apigeeCfg.GetTLS()
+	}
+	tlsCfg.ClientCertPath = creds.ClientCertPath
+	tlsCfg.ClientKeyPath = creds.ClientKeyPath
+
+	client, err := newHTTPClientWithTLS(apigeeCfg, &tlsCfg)
+	if err != nil {
+		return nil, fmt.Errorf("spec credentials %s: %w", creds.Name, err)
+	}
+	return client, nil
+}
+
+func newHTTPClientWithTLS(apigeeCfg *config.ApigeeConfig, tlsCfg *config.ApigeeTLS) (*httpClient, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxyURL := apigeeCfg.GetProxyURL(); proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the proxy url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig, err := newTLSConfig(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &httpClient{
+		client: &http.Client{
+			Transport: transport,
+		},
+	}, nil
+}
+
+// newTLSConfig - trusts the configured ca certificates, along with the system ones, and loads the client certificate
+func newTLSConfig(tlsCfg *config.ApigeeTLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if tlsCfg == nil {
+		return tlsConfig, nil
+	}
+	tlsConfig.InsecureSkipVerify = tlsCfg.InsecureSkipVerify
+
+	if tlsCfg.RootCACertPath != "" {
+		caCerts, err := os.ReadFile(tlsCfg.RootCACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the root ca certificates: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("no certificates found in the root ca file %s", tlsCfg.RootCACertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if tlsCfg.ClientCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(tlsCfg.ClientCertPath, tlsCfg.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not load the client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Send - sends the request without a deadline
+func (c *httpClient) Send(request coreapi.Request) (*coreapi.Response, error) {
+	return c.SendWithContext(context.Background(), request)
+}
+
+// SendWithContext - sends the request, the call is cancelled when the context is done
+func (c *httpClient) SendWithContext(ctx context.Context, request coreapi.Request) (*coreapi.Response, error) {
+	requestURL := request.URL
+	if len(request.QueryParams) > 0 {
+		params := url.Values{}
+		for key, value := range request.QueryParams {
+			params.Add(key, value)
+		}
+		requestURL += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, request.Method, requestURL, bytes.NewReader(request.Body))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range request.Headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &coreapi.Response{
+		Code:    res.StatusCode,
+		Body:    body,
+		Headers: res.Header,
+	}, nil
+}
+
+// sendWithContext - sends the request with the client, bounded by the timeout when one is set
+func sendWithContext(ctx context.Context, client coreapi.Client, request coreapi.Request, timeout time.Duration) (*coreapi.Response, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if c, ok := client.(contextClient); ok {
+		return c.SendWithContext(ctx, request)
+	}
+
+	// clients without context support can only be stopped before the request is sent
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return client.Send(request)
+}
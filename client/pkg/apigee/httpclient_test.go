@@ -0,0 +1,155 @@
+package apigee
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	coreapi "github.com/Axway/agent-sdk/pkg/api"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-apigee/client/pkg/config"
+)
+
+func writePEM(t *testing.T, name, blockType string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600)
+	assert.Nil(t, err)
+	return path
+}
+
+// createClientCert - creates a self signed client certificate, returning the cert and key file paths
+func createClientCert(t *testing.T) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	return writePEM(t, "client.crt", "CERTIFICATE", cert), writePEM(t, "client.key", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+}
+
+func TestHTTPClientTLS(t *testing.T) {
+	clientCerts := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCerts = len(r.TLS.PeerCertificates)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := writePEM(t, "ca.crt", "CERTIFICATE", server.Certificate().Raw)
+	certPath, keyPath := createClientCert(t)
+
+	cases := map[string]struct {
+		tls          *config.ApigeeTLS
+		expectErr    bool
+		expectedCert int
+	}{
+		"unknown ca": {
+			tls:       &config.ApigeeTLS{},
+			expectErr: true,
+		},
+		"skip verify": {
+			tls: &config.ApigeeTLS{InsecureSkipVerify: true},
+		},
+		"trusted ca": {
+			tls: &config.ApigeeTLS{RootCACertPath: caPath},
+		},
+		"mutual tls": {
+			tls:          &config.ApigeeTLS{RootCACertPath: caPath, ClientCertPath: certPath, ClientKeyPath: keyPath},
+			expectedCert: 1,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			clientCerts = 0
+			c, err := newHTTPClient(&config.ApigeeConfig{TLS: tc.tls})
+			assert.Nil(t, err)
+
+			response, err := c.Send(coreapi.Request{Method: http.MethodGet, URL: server.URL})
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.Equal(t, tc.expectedCert, clientCerts)
+		})
+	}
+
+	// files that can not be loaded fail the client creation
+	_, err := newHTTPClient(&config.ApigeeConfig{TLS: &config.ApigeeTLS{RootCACertPath: filepath.Join(t.TempDir(), "missing.crt")}})
+	assert.NotNil(t, err)
+	_, err = newHTTPClient(&config.ApigeeConfig{TLS: &config.ApigeeTLS{RootCACertPath: keyPath}})
+	assert.NotNil(t, err)
+	_, err = newHTTPClient(&config.ApigeeConfig{TLS: &config.ApigeeTLS{ClientCertPath: certPath, ClientKeyPath: caPath}})
+	assert.NotNil(t, err)
+}
+
+func TestSpecCredentialsClient(t *testing.T) {
+	clientCerts := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCerts = len(r.TLS.PeerCertificates)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := writePEM(t, "ca.crt", "CERTIFICATE", server.Certificate().Raw)
+	certPath, keyPath := createClientCert(t)
+
+	// the profile certificate is presented, the configured ca is still trusted
+	cfg := &config.ApigeeConfig{TLS: &config.ApigeeTLS{RootCACertPath: caPath}}
+	c, err := newSpecCredentialsClient(cfg, &config.SpecCredentials{Name: "git", ClientCertPath: certPath, ClientKeyPath: keyPath})
+	assert.Nil(t, err)
+	response, err := c.Send(coreapi.Request{Method: http.MethodGet, URL: server.URL})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, 1, clientCerts)
+	assert.Empty(t, cfg.TLS.ClientCertPath)
+
+	_, err = newSpecCredentialsClient(&config.ApigeeConfig{}, &config.SpecCredentials{Name: "git", ClientCertPath: certPath, ClientKeyPath: caPath})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "spec credentials git")
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	proxied := ""
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a forward proxy receives the full url of the apigee call
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	c, err := newHTTPClient(&config.ApigeeConfig{ProxyURL: proxy.URL})
+	assert.Nil(t, err)
+
+	response, err := c.Send(coreapi.Request{Method: http.MethodGet, URL: "http://apigee.internal/v1/organizations/org/apis", QueryParams: map[string]string{"count": "10"}})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "http://apigee.internal/v1/organizations/org/apis?count=10", proxied)
+
+	_, err = newHTTPClient(&config.ApigeeConfig{ProxyURL: "http://[::1"})
+	assert.NotNil(t, err)
+}
@@ -12,7 +12,7 @@ package models
 // ApiProxyRevision API proxy revision.
 type ApiProxyRevision struct {
 	// Base URL of the API proxy.
-	Basepaths            string                               `json:"basepaths,omitempty"`
+	Basepaths            []string                             `json:"basepaths,omitempty"`
 	ConfigurationVersion ApiProxyRevisionConfigurationVersion `json:"configurationVersion,omitempty"`
 	// Revision number, app name, and organization for the API proxy.
 	ContextInfo string `json:"contextInfo,omitempty"`
@@ -0,0 +1,164 @@
+package apigee
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// listPageSize - the max number of entities apigee returns from a single list call
+	listPageSize  = 1000
+	countParam    = "count"
+	startKeyParam = "startKey"
+)
+
+// pageDecoder - reads the entity names from a page of a list call
+type pageDecoder func(body []byte) ([]string, error)
+
+// ListProxies - iterates over the names of all proxies, requesting them a page at a time
+func (a *ApigeeClient) ListProxies() iter.Seq2[string, error] {
+	return a.ListProxiesWithContext(context.Background())
+}
+
+// ListProxiesWithContext - iterates over the names of all proxies, requesting them a page at a time, cancelled when the context is done
+func (a *ApigeeClient) ListProxiesWithContext(ctx context.Context) iter.Seq2[string, error] {
+	decode := decodeNames
+	if a.cfg.IsApigeeX() {
+		decode = func(body []byte) ([]string, error) {
+			xProxies := xProxiesResponse{}
+			err := json.Unmarshal(body, &xProxies)
+			return xProxies.toProxies(), err
+		}
+	}
+	return a.pageNames(ctx, fmt.Sprintf("%s/apis", a.orgURL), "getting the proxies", decode)
+}
+
+// ListProducts - iterates over the names of all api products, requesting them a page at a time
+func (a *ApigeeClient) ListProducts() iter.Seq2[string, error] {
+	return a.ListProductsWithContext(context.Background())
+}
+
+// ListProductsWithContext - iterates over the names of all api products, requesting them a page at a time, cancelled when the context is done
+func (a *ApigeeClient) ListProductsWithContext(ctx context.Context) iter.Seq2[string, error] {
+	decode := decodeNames
+	if a.cfg.IsApigeeX() {
+		decode = func(body []byte) ([]string, error) {
+			xProducts := xProductsResponse{}
+			err := json.Unmarshal(body, &xProducts)
+			return xProducts.toProducts(), err
+		}
+	}
+	return a.pageNames(ctx, fmt.Sprintf("%s/apiproducts", a.orgURL), "retrieving the products", decode)
+}
+
+// ListDevelopers - iterates over the emails of all developers, requesting them a page at a time
+func (a *ApigeeClient) ListDevelopers() iter.Seq2[string, error] {
+	return a.ListDevelopersWithContext(context.Background())
+}
+
+// ListDevelopersWithContext - iterates over the emails of all developers, requesting them a page at a time, cancelled when the context is done
+func (a *ApigeeClient) ListDevelopersWithContext(ctx context.Context) iter.Seq2[string, error] {
+	decode := decodeNames
+	if a.cfg.IsApigeeX() {
+		decode = func(body []byte) ([]string, error) {
+			xDevelopers := xDevelopersResponse{}
+			err := json.Unmarshal(body, &xDevelopers)
+			return xDevelopers.toDevelopers(), err
+		}
+	}
+	return a.pageNames(ctx, fmt.Sprintf("%s/developers", a.orgURL), "retrieving the developers", decode)
+}
+
+// ListDeveloperApps - iterates over the names of all apps of the developer, requesting them a page at a time
+func (a *ApigeeClient) ListDeveloperApps(developerID string) iter.Seq2[string, error] {
+	return a.ListDeveloperAppsWithContext(context.Background(), developerID)
+}
+
+// ListDeveloperAppsWithContext - iterates over the names of all apps of the developer, requesting them a page at a time, cancelled when the context is done
+func (a *ApigeeClient) ListDeveloperAppsWithContext(ctx context.Context, developerID string) iter.Seq2[string, error] {
+	decode := decodeNames
+	if a.cfg.IsApigeeX() {
+		decode = func(body []byte) ([]string, error) {
+			xApps := xDeveloperAppsResponse{}
+			err := json.Unmarshal(body, &xApps)
+			return xApps.toDeveloperApps(), err
+		}
+	}
+	return a.pageNames(ctx, fmt.Sprintf("%s/developers/%s/apps", a.orgURL, developerID), "retrieving the developer apps", decode)
+}
+
+// pageNames - iterates over the names returned by a list call using the count and startKey params,
+// apigee includes the startKey, the last name of the previous page, as the first name of the next page
+func (a *ApigeeClient) pageNames(ctx context.Context, url, operation string, decode pageDecoder) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		startKey := ""
+		for {
+			params := map[string]string{countParam: strconv.Itoa(a.pageSize)}
+			if startKey != "" {
+				params[startKeyParam] = startKey
+			}
+
+			response, err := a.newRequest(ctx, http.MethodGet, url,
+				WithDefaultHeaders(),
+				WithQueryParams(params),
+			).Execute()
+			if err != nil {
+				yield("", err)
+				return
+			}
+			if response.Code != http.StatusOK {
+				yield("", newAPIError(response, operation))
+				return
+			}
+
+			names, err := decode(response.Body)
+			if err != nil {
+				yield("", err)
+				return
+			}
+
+			pageLen := len(names)
+			if startKey != "" {
+				if pageLen == 0 || names[0] != startKey {
+					// the list call does not support paging, the first page was returned again
+					return
+				}
+				names = names[1:]
+			}
+
+			for _, name := range names {
+				if !yield(name, nil) {
+					return
+				}
+			}
+
+			// a partial page is the last one, as is a page larger than requested from a call without paging
+			if pageLen != a.pageSize || len(names) == 0 {
+				return
+			}
+			startKey = names[len(names)-1]
+		}
+	}
+}
+
+// collectNames - reads all of the names from the iterator
+func collectNames(names iter.Seq2[string, error]) ([]string, error) {
+	all := []string{}
+	for name, err := range names {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, name)
+	}
+	return all, nil
+}
+
+func decodeNames(body []byte) ([]string, error) {
+	names := []string{}
+	err := json.Unmarshal(body, &names)
+	return names, err
+}
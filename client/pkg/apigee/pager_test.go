@@ -0,0 +1,109 @@
+package apigee
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Axway/agent-sdk/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageNames(t *testing.T) {
+	cases := map[string]struct {
+		responses         []api.MockResponse
+		expectedNames     []string
+		expectedStartKeys []string
+		expectErr         bool
+	}{
+		"single partial page": {
+			responses: []api.MockResponse{
+				{RespCode: http.StatusOK, RespData: `["a","b"]`},
+			},
+			expectedNames:     []string{"a", "b"},
+			expectedStartKeys: []string{""},
+		},
+		"multiple pages": {
+			responses: []api.MockResponse{
+				{RespCode: http.StatusOK, RespData: `["a","b","c"]`},
+				{RespCode: http.StatusOK, RespData: `["c","d","e"]`},
+				{RespCode: http.StatusOK, RespData: `["e","f"]`},
+			},
+			expectedNames:     []string{"a", "b", "c", "d", "e", "f"},
+			expectedStartKeys: []string{"", "c", "e"},
+		},
+		"last page only has the start key": {
+			responses: []api.MockResponse{
+				{RespCode: http.StatusOK, RespData: `["a","b","c"]`},
+				{RespCode: http.StatusOK, RespData: `["c"]`},
+			},
+			expectedNames:     []string{"a", "b", "c"},
+			expectedStartKeys: []string{"", "c"},
+		},
+		"call without paging returns the first page again": {
+			responses: []api.MockResponse{
+				{RespCode: http.StatusOK, RespData: `["a","b","c"]`},
+				{RespCode: http.StatusOK, RespData: `["a","b","c"]`},
+			},
+			expectedNames:     []string{"a", "b", "c"},
+			expectedStartKeys: []string{"", "c"},
+		},
+		"call without paging returns more than a page": {
+			responses: []api.MockResponse{
+				{RespCode: http.StatusOK, RespData: `["a","b","c","d"]`},
+			},
+			expectedNames:     []string{"a", "b", "c", "d"},
+			expectedStartKeys: []string{""},
+		},
+		"error on a later page": {
+			responses: []api.MockResponse{
+				{RespCode: http.StatusOK, RespData: `["a","b","c"]`},
+				{RespCode: http.StatusTooManyRequests},
+			},
+			expectErr: true,
+		},
+		"error, bad page data": {
+			responses: []api.MockResponse{
+				{RespCode: http.StatusOK, RespData: `{"proxies":[]}`},
+			},
+			expectErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockClient := &api.MockHTTPClient{Responses: tc.responses}
+			c := createTestClient(t, mockClient)
+			c.pageSize = 3
+
+			names, err := c.GetAllProxies()
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedNames, []string(names))
+
+			startKeys := []string{}
+			for _, req := range mockClient.Requests {
+				assert.Equal(t, "3", req.QueryParams[countParam])
+				startKeys = append(startKeys, req.QueryParams[startKeyParam])
+			}
+			assert.Equal(t, tc.expectedStartKeys, startKeys)
+		})
+	}
+}
+
+func TestListStopsEarly(t *testing.T) {
+	mockClient := &api.MockHTTPClient{Responses: []api.MockResponse{
+		{RespCode: http.StatusOK, RespData: `["dev1@example.com","dev2@example.com"]`},
+	}}
+	c := createTestClient(t, mockClient)
+	c.pageSize = 2
+
+	// breaking out of the loop does not request the next page
+	for developer, err := range c.ListDevelopers() {
+		assert.Nil(t, err)
+		assert.Equal(t, "dev1@example.com", developer)
+		break
+	}
+	assert.Len(t, mockClient.Requests, 1)
+}
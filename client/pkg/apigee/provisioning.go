@@ -1,6 +1,7 @@
 package apigee
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -13,10 +14,16 @@ const (
 	developerAppsKeysURL = "%s/developers/%s/apps/%s/keys/%s"
 )
 
+// GetAppCredential - gets the credential, by key, of the developer app
 func (a *ApigeeClient) GetAppCredential(appName, devID, key string) (*models.DeveloperAppCredentials, error) {
+	return a.GetAppCredentialWithContext(context.Background(), appName, devID, key)
+}
+
+// GetAppCredentialWithContext - gets the credential, by key, of the developer app, cancelled when the context is done
+func (a *ApigeeClient) GetAppCredentialWithContext(ctx context.Context, appName, devID, key string) (*models.DeveloperAppCredentials, error) {
 	url := fmt.Sprintf(developerAppsKeysURL, a.orgURL, devID, appName, key)
 	response, err := a.newRequest(
-		http.MethodGet, url, WithDefaultHeaders(),
+		ctx, http.MethodGet, url, WithDefaultHeaders(),
 	).Execute()
 
 	if err != nil {
@@ -24,21 +31,25 @@ func (a *ApigeeClient) GetAppCredential(appName, devID, key string) (*models.Dev
 	}
 
 	if response.Code != http.StatusOK {
-		return nil, fmt.Errorf(
-			"received an unexpected response code %d from Apigee while retrieving app credentials", response.Code,
-		)
+		return nil, newAPIError(response, "retrieving app credentials")
 	}
 
 	creds := &models.DeveloperAppCredentials{}
-	err = json.Unmarshal(response.Body, creds)
+	err = a.unmarshal(response.Body, creds)
 
 	return creds, err
 }
 
+// RemoveAppCredential - removes the credential, by key, from the developer app
 func (a *ApigeeClient) RemoveAppCredential(appName, devID, key string) error {
+	return a.RemoveAppCredentialWithContext(context.Background(), appName, devID, key)
+}
+
+// RemoveAppCredentialWithContext - removes the credential, by key, from the developer app, cancelled when the context is done
+func (a *ApigeeClient) RemoveAppCredentialWithContext(ctx context.Context, appName, devID, key string) error {
 	url := fmt.Sprintf(developerAppsKeysURL, a.orgURL, devID, appName, key)
 	response, err := a.newRequest(
-		http.MethodDelete, url, WithDefaultHeaders(),
+		ctx, http.MethodDelete, url, WithDefaultHeaders(),
 	).Execute()
 
 	if err != nil {
@@ -46,15 +57,19 @@ func (a *ApigeeClient) RemoveAppCredential(appName, devID, key string) error {
 	}
 
 	if response.Code != http.StatusOK {
-		return fmt.Errorf(
-			"received an unexpected response code %d from Apigee while removing app credentials", response.Code,
-		)
+		return newAPIError(response, "removing app credentials")
 	}
 
 	return nil
 }
 
+// UpdateAppCredential - approves or revokes the credential, by key, of the developer app
 func (a *ApigeeClient) UpdateAppCredential(appName, devID, key string, enable bool) error {
+	return a.UpdateAppCredentialWithContext(context.Background(), appName, devID, key, enable)
+}
+
+// UpdateAppCredentialWithContext - approves or revokes the credential, by key, of the developer app, cancelled when the context is done
+func (a *ApigeeClient) UpdateAppCredentialWithContext(ctx context.Context, appName, devID, key string, enable bool) error {
 	url := fmt.Sprintf(developerAppsKeysURL, a.orgURL, devID, appName, key)
 
 	action := "revoke"
@@ -63,7 +78,7 @@ func (a *ApigeeClient) UpdateAppCredential(appName, devID, key string, enable bo
 	}
 
 	response, err := a.newRequest(
-		http.MethodPost, url,
+		ctx, http.MethodPost, url,
 		WithDefaultHeaders(), WithQueryParam("action", action),
 	).Execute()
 
@@ -72,15 +87,19 @@ func (a *ApigeeClient) UpdateAppCredential(appName, devID, key string, enable bo
 	}
 
 	if response.Code != http.StatusNoContent {
-		return fmt.Errorf(
-			"received an unexpected response code %d from Apigee while revoking/enabling app credentials", response.Code,
-		)
+		return newAPIError(response, "revoking/enabling app credentials")
 	}
 
 	return err
 }
 
+// CreateAppCredential - adds a new credential, for the products, to the developer app
 func (a *ApigeeClient) CreateAppCredential(appName, devID string, products []string, expDays int) (*models.DeveloperApp, error) {
+	return a.CreateAppCredentialWithContext(context.Background(), appName, devID, products, expDays)
+}
+
+// CreateAppCredentialWithContext - adds a new credential, for the products, to the developer app, cancelled when the context is done
+func (a *ApigeeClient) CreateAppCredentialWithContext(ctx context.Context, appName, devID string, products []string, expDays int) (*models.DeveloperApp, error) {
 	url := fmt.Sprintf("%s/developers/%s/apps/%s", a.orgURL, devID, appName)
 
 	appCredReq := CredentialProvisionRequest{
@@ -94,7 +113,7 @@ func (a *ApigeeClient) CreateAppCredential(appName, devID string, products []str
 	credData, _ := json.Marshal(appCredReq)
 
 	response, err := a.newRequest(
-		http.MethodPost, url, WithDefaultHeaders(), WithBody(credData),
+		ctx, http.MethodPost, url, WithDefaultHeaders(), WithBody(credData),
 	).Execute()
 
 	if err != nil {
@@ -102,18 +121,22 @@ func (a *ApigeeClient) CreateAppCredential(appName, devID string, products []str
 	}
 
 	if response.Code != http.StatusOK {
-		return nil, fmt.Errorf(
-			"received an unexpected response code %d from Apigee while creating app credentials", response.Code,
-		)
+		return nil, newAPIError(response, "creating app credentials")
 	}
 
 	appData := &models.DeveloperApp{}
-	err = json.Unmarshal(response.Body, appData)
+	err = a.unmarshal(response.Body, appData)
 
 	return appData, err
 }
 
+// AddCredentialProduct - adds products to the credential, by key, of the developer app
 func (a *ApigeeClient) AddCredentialProduct(appName, devID, key string, cpr CredentialProvisionRequest) (*models.DeveloperAppCredentials, error) {
+	return a.AddCredentialProductWithContext(context.Background(), appName, devID, key, cpr)
+}
+
+// AddCredentialProductWithContext - adds products to the credential, by key, of the developer app, cancelled when the context is done
+func (a *ApigeeClient) AddCredentialProductWithContext(ctx context.Context, appName, devID, key string, cpr CredentialProvisionRequest) (*models.DeveloperAppCredentials, error) {
 	data, err := json.Marshal(cpr)
 	if err != nil {
 		return nil, err
@@ -122,7 +145,7 @@ func (a *ApigeeClient) AddCredentialProduct(appName, devID, key string, cpr Cred
 	url := fmt.Sprintf(developerAppsKeysURL, a.orgURL, devID, appName, key)
 
 	response, err := a.newRequest(
-		http.MethodPost, url, WithDefaultHeaders(),
+		ctx, http.MethodPost, url, WithDefaultHeaders(),
 		WithBody(data),
 	).Execute()
 	if err != nil {
@@ -130,37 +153,45 @@ func (a *ApigeeClient) AddCredentialProduct(appName, devID, key string, cpr Cred
 	}
 
 	if response.Code != http.StatusOK {
-		return nil, fmt.Errorf(
-			"received an unexpected response code %d from Apigee while adding a product to an app credentials: %s", response.Code, response.Body,
-		)
+		return nil, newAPIError(response, "adding a product to an app credentials")
 	}
 
 	cred := &models.DeveloperAppCredentials{}
-	err = json.Unmarshal(response.Body, cred)
+	err = a.unmarshal(response.Body, cred)
 
 	return cred, err
 }
 
+// RemoveCredentialProduct - removes a product from the credential, by key, of the developer app
 func (a *ApigeeClient) RemoveCredentialProduct(appName, devID, key, productName string) error {
+	return a.RemoveCredentialProductWithContext(context.Background(), appName, devID, key, productName)
+}
+
+// RemoveCredentialProductWithContext - removes a product from the credential, by key, of the developer app, cancelled when the context is done
+func (a *ApigeeClient) RemoveCredentialProductWithContext(ctx context.Context, appName, devID, key, productName string) error {
 	url := fmt.Sprintf("%s/developers/%s/apps/%s/keys/%s/apiproducts/%s", a.orgURL, devID, appName, key, productName)
 
 	response, err := a.newRequest(
-		http.MethodDelete, url, WithDefaultHeaders(),
+		ctx, http.MethodDelete, url, WithDefaultHeaders(),
 	).Execute()
 	if err != nil {
 		return err
 	}
 
 	if response.Code != http.StatusOK {
-		return fmt.Errorf(
-			"received an unexpected response code %d from Apigee while removing product from an app credentials", response.Code,
-		)
+		return newAPIError(response, "removing product from an app credentials")
 	}
 
 	return err
 }
 
+// UpdateCredentialProduct - approves or revokes a product on the credential, by key, of the developer app
 func (a *ApigeeClient) UpdateCredentialProduct(appName, devID, key, productName string, enable bool) error {
+	return a.UpdateCredentialProductWithContext(context.Background(), appName, devID, key, productName, enable)
+}
+
+// UpdateCredentialProductWithContext - approves or revokes a product on the credential, by key, of the developer app, cancelled when the context is done
+func (a *ApigeeClient) UpdateCredentialProductWithContext(ctx context.Context, appName, devID, key, productName string, enable bool) error {
 	url := fmt.Sprintf("%s/developers/%s/apps/%s/keys/%s/apiproducts/%s", a.orgURL, devID, appName, key, productName)
 
 	action := "revoke"
@@ -169,7 +200,7 @@ func (a *ApigeeClient) UpdateCredentialProduct(appName, devID, key, productName
 	}
 
 	response, err := a.newRequest(
-		http.MethodPost, url,
+		ctx, http.MethodPost, url,
 		WithDefaultHeaders(), WithQueryParam("action", action),
 	).Execute()
 	if err != nil {
@@ -177,9 +208,7 @@ func (a *ApigeeClient) UpdateCredentialProduct(appName, devID, key, productName
 	}
 
 	if response.Code != http.StatusNoContent {
-		return fmt.Errorf(
-			"received an unexpected response code %d from Apigee while updating a product on an app credentials", response.Code,
-		)
+		return newAPIError(response, "updating a product on an app credentials")
 	}
 
 	return err
@@ -3,20 +3,19 @@ package apigee
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
 
+	"github.com/Axway/agents-apigee/client/pkg/apigee/bundle"
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
 )
 
-// proxyXML
-type proxyXML struct {
-	XMLName             xml.Name             `xml:"ProxyEndpoint"`
-	HTTPProxyConnection *HTTPProxyConnection `xml:"HTTPProxyConnection"`
-}
+// defaultProxyEndpoint - the name of the proxy endpoint created with a new proxy
+const defaultProxyEndpoint = "default"
 
 // HTTPProxyConnection
 type HTTPProxyConnection struct {
@@ -25,38 +24,52 @@ type HTTPProxyConnection struct {
 	VirtualHost string   `xml:"VirtualHost"`
 }
 
+// policyXML - the root element of a policy file in a proxy bundle, the element name is the policy type
+type policyXML struct {
+	XMLName         xml.Name
+	Name            string `xml:"name,attr"`
+	Enabled         string `xml:"enabled,attr"`
+	ContinueOnError string `xml:"continueOnError,attr"`
+	Async           string `xml:"async,attr"`
+	DisplayName     string `xml:"DisplayName"`
+}
+
 // Products
 type Proxies []string
 
-// GetAllProxies - get all proxies
+// GetAllProxies - get all proxies, reading every page of the list
 func (a *ApigeeClient) GetAllProxies() (Proxies, error) {
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/apis", a.orgURL),
-		WithDefaultHeaders(),
-	).Execute()
-	if err != nil {
-		return nil, err
-	}
+	return a.GetAllProxiesWithContext(context.Background())
+}
 
-	proxies := Proxies{}
-	err = json.Unmarshal(response.Body, &proxies)
+// GetAllProxiesWithContext - get all proxies, reading every page of the list, cancelled when the context is done
+func (a *ApigeeClient) GetAllProxiesWithContext(ctx context.Context) (Proxies, error) {
+	proxies, err := collectNames(a.ListProxiesWithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
-
 	return proxies, nil
 }
 
 // GetProxy - get a proxy with a name
 func (a *ApigeeClient) GetProxy(proxyName string) (*models.ApiProxy, error) {
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/apis/%s", a.orgURL, proxyName),
+	return a.GetProxyWithContext(context.Background(), proxyName)
+}
+
+// GetProxyWithContext - get a proxy with a name, cancelled when the context is done
+func (a *ApigeeClient) GetProxyWithContext(ctx context.Context, proxyName string) (*models.ApiProxy, error) {
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/apis/%s", a.orgURL, proxyName),
 		WithDefaultHeaders(),
 	).Execute()
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the proxy")
+	}
 
 	proxy := &models.ApiProxy{}
-	err = json.Unmarshal(response.Body, proxy)
+	err = a.unmarshal(response.Body, proxy)
 	if err != nil {
 		return nil, err
 	}
@@ -66,15 +79,23 @@ func (a *ApigeeClient) GetProxy(proxyName string) (*models.ApiProxy, error) {
 
 // GetRevision - get a revision of a proxy with a name
 func (a *ApigeeClient) GetRevision(proxyName, revision string) (*models.ApiProxyRevision, error) {
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/apis/%s/revisions/%s", a.orgURL, proxyName, revision),
+	return a.GetRevisionWithContext(context.Background(), proxyName, revision)
+}
+
+// GetRevisionWithContext - get a revision of a proxy with a name, cancelled when the context is done
+func (a *ApigeeClient) GetRevisionWithContext(ctx context.Context, proxyName, revision string) (*models.ApiProxyRevision, error) {
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/apis/%s/revisions/%s", a.orgURL, proxyName, revision),
 		WithDefaultHeaders(),
 	).Execute()
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the proxy revision")
+	}
 
 	proxyRevision := &models.ApiProxyRevision{}
-	json.Unmarshal(response.Body, proxyRevision)
+	err = a.unmarshal(response.Body, proxyRevision)
 	if err != nil {
 		return nil, err
 	}
@@ -82,43 +103,90 @@ func (a *ApigeeClient) GetRevision(proxyName, revision string) (*models.ApiProxy
 	return proxyRevision, nil
 }
 
-// GetRevisionConnectionType - get a revision bundle and open the proxy config file
+// GetRevisionConnectionType - get a revision bundle and return the connection of the default proxy endpoint
 func (a *ApigeeClient) GetRevisionConnectionType(proxyName, revision string) (*HTTPProxyConnection, error) {
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/apis/%s/revisions/%s", a.orgURL, proxyName, revision),
+	return a.GetRevisionConnectionTypeWithContext(context.Background(), proxyName, revision)
+}
+
+// GetRevisionConnectionTypeWithContext - get a revision bundle and return the connection of the default proxy endpoint,
+// or the first when there is no default, cancelled when the context is done
+func (a *ApigeeClient) GetRevisionConnectionTypeWithContext(ctx context.Context, proxyName, revision string) (*HTTPProxyConnection, error) {
+	proxyBundle, err := a.GetRevisionBundleWithContext(ctx, proxyName, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(proxyBundle.ProxyEndpoints) == 0 {
+		return nil, fmt.Errorf("could not find the proxy configuration file in the api revision bundle")
+	}
+
+	endpoint := proxyBundle.GetProxyEndpoint(defaultProxyEndpoint)
+	if endpoint == nil {
+		endpoint = proxyBundle.ProxyEndpoints[0]
+	}
+
+	connection := &HTTPProxyConnection{BasePath: endpoint.GetBasePath()}
+	if vhosts := endpoint.GetVirtualHosts(); len(vhosts) > 0 {
+		connection.VirtualHost = vhosts[0]
+	}
+	return connection, nil
+}
+
+// GetRevisionBundle - get a revision bundle and parse all of its proxy endpoints, target endpoints, policies and resources
+func (a *ApigeeClient) GetRevisionBundle(proxyName, revision string) (*bundle.Bundle, error) {
+	return a.GetRevisionBundleWithContext(context.Background(), proxyName, revision)
+}
+
+// GetRevisionBundleWithContext - get a revision bundle and parse all of its proxy endpoints, target endpoints, policies
+// and resources, cancelled when the context is done
+func (a *ApigeeClient) GetRevisionBundleWithContext(ctx context.Context, proxyName, revision string) (*bundle.Bundle, error) {
+	data, err := a.getRevisionBundleZip(ctx, proxyName, revision)
+	if err != nil {
+		return nil, err
+	}
+	return bundle.Parse(data)
+}
+
+// getRevisionBundleZip - get the zip file of a revision bundle
+func (a *ApigeeClient) getRevisionBundleZip(ctx context.Context, proxyName, revision string) ([]byte, error) {
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/apis/%s/revisions/%s", a.orgURL, proxyName, revision),
 		WithDefaultHeaders(),
 		WithQueryParam("format", "bundle"),
 	).Execute()
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the proxy revision bundle")
+	}
+	return response.Body, nil
+}
+
+// getRevisionBundleFile - get a revision bundle and read the named file from it, a file that is not in the bundle is
+// not found, as it is on apigee edge
+func (a *ApigeeClient) getRevisionBundleFile(ctx context.Context, proxyName, revision, fileName string) ([]byte, error) {
+	data, err := a.getRevisionBundleZip(ctx, proxyName, revision)
+	if err != nil {
+		return nil, err
+	}
 
-	// response is a zip file, lets open it and find the proxy config file
-	zipReader, err := zip.NewReader(bytes.NewReader(response.Body), int64(len(response.Body)))
+	// response is a zip file, lets open it and find the file
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
 	if err != nil {
 		return nil, err
 	}
 
-	// Read all the files from the zip archive
-	var fileBytes []byte
 	for _, zipFile := range zipReader.File {
-		if zipFile.Name != "apiproxy/proxies/default.xml" {
+		if zipFile.Name != fileName {
 			continue
 		}
-		fileBytes, err = readZipFile(zipFile)
-		if err != nil {
-			return nil, err
-		}
-		break
+		return readZipFile(zipFile)
 	}
-
-	if len(fileBytes) == 0 {
-		return nil, fmt.Errorf("could not find the proxy configuration file in the api revision bundle")
+	return nil, &APIError{
+		StatusCode: http.StatusNotFound,
+		Message:    fmt.Sprintf("%s is not in the bundle", fileName),
+		operation:  "reading the proxy revision bundle file",
 	}
-
-	data := &proxyXML{}
-	xml.Unmarshal(fileBytes, data)
-
-	return data.HTTPProxyConnection, nil
 }
 
 func readZipFile(zf *zip.File) ([]byte, error) {
@@ -130,32 +198,86 @@ func readZipFile(zf *zip.File) ([]byte, error) {
 	return io.ReadAll(f)
 }
 
-// GetProxy - get a proxy with a name
+// GetRevisionResourceFile - get a resource file from a revision of a proxy
 func (a *ApigeeClient) GetRevisionResourceFile(proxyName, revision, resourceType, resourceName string) ([]byte, error) {
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/apis/%s/revisions/%s/resourcefiles/%s/%s", a.orgURL, proxyName, revision, resourceType, resourceName),
+	return a.GetRevisionResourceFileWithContext(context.Background(), proxyName, revision, resourceType, resourceName)
+}
+
+// GetRevisionResourceFileWithContext - get a resource file from a revision of a proxy, cancelled when the context is done
+func (a *ApigeeClient) GetRevisionResourceFileWithContext(ctx context.Context, proxyName, revision, resourceType, resourceName string) ([]byte, error) {
+	if a.cfg.IsApigeeX() {
+		// apigee x does not have a resource file api, read it from the bundle
+		return a.getRevisionBundleFile(ctx, proxyName, revision, fmt.Sprintf("apiproxy/resources/%s/%s", resourceType, resourceName))
+	}
+
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/apis/%s/revisions/%s/resourcefiles/%s/%s", a.orgURL, proxyName, revision, resourceType, resourceName),
 		WithDefaultHeaders(),
 	).Execute()
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the proxy revision resource file")
+	}
 
 	return response.Body, nil
 }
 
 // GetRevisionPolicyByName - get the details about a named policy on a revision
 func (a *ApigeeClient) GetRevisionPolicyByName(proxyName, revision, policyName string) (*PolicyDetail, error) {
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/apis/%s/revisions/%s/policies/%s", a.orgURL, proxyName, revision, policyName),
+	return a.GetRevisionPolicyByNameWithContext(context.Background(), proxyName, revision, policyName)
+}
+
+// GetRevisionPolicyByNameWithContext - get the details about a named policy on a revision, cancelled when the context is done
+func (a *ApigeeClient) GetRevisionPolicyByNameWithContext(ctx context.Context, proxyName, revision, policyName string) (*PolicyDetail, error) {
+	if a.cfg.IsApigeeX() {
+		return a.getRevisionBundlePolicy(ctx, proxyName, revision, policyName)
+	}
+
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/apis/%s/revisions/%s/policies/%s", a.orgURL, proxyName, revision, policyName),
 		WithDefaultHeaders(),
 	).Execute()
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the proxy revision policy")
+	}
 
 	policyDetails := &PolicyDetail{}
-	json.Unmarshal(response.Body, policyDetails)
+	err = json.Unmarshal(response.Body, policyDetails)
 	if err != nil {
 		return nil, err
 	}
 
 	return policyDetails, nil
 }
+
+// getRevisionBundlePolicy - apigee x does not have a policy api, read the policy from the bundle
+func (a *ApigeeClient) getRevisionBundlePolicy(ctx context.Context, proxyName, revision, policyName string) (*PolicyDetail, error) {
+	fileBytes, err := a.getRevisionBundleFile(ctx, proxyName, revision, fmt.Sprintf("apiproxy/policies/%s.xml", policyName))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fileBytes) == 0 {
+		return nil, fmt.Errorf("could not find the policy %s in the api revision bundle", policyName)
+	}
+
+	data := &policyXML{}
+	err = xml.Unmarshal(fileBytes, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PolicyDetail{
+		Policy: models.Policy{
+			Async:           data.Async,
+			ContinueOnError: data.ContinueOnError,
+			DisplayName:     data.DisplayName,
+			Enabled:         data.Enabled,
+			Name:            data.Name,
+		},
+		PolicyType: data.XMLName.Local,
+	}, nil
+}
@@ -1,7 +1,10 @@
 package apigee
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"time"
 
 	coreapi "github.com/Axway/agent-sdk/pkg/api"
 )
@@ -9,6 +12,8 @@ import (
 type RequestOption func(*apigeeRequest)
 
 type apigeeRequest struct {
+	ctx         context.Context
+	timeout     time.Duration
 	method      string
 	url         string
 	authValue   string
@@ -17,22 +22,66 @@ type apigeeRequest struct {
 	queryParams map[string]string
 	body        []byte
 	client      coreapi.Client
+	retry       *retryPolicy
+	refreshAuth func(staleToken string) (string, error)
 }
 
 func (r *apigeeRequest) Execute() (*coreapi.Response, error) {
-	// return the api response
-	request := coreapi.Request{
+	response, err := r.send()
+	if err != nil || response.Code != http.StatusUnauthorized || !r.hasAuthHeader() || r.refreshAuth == nil {
+		return response, err
+	}
+
+	// the token may have expired, refresh it and replay the request once
+	authValue, refreshErr := r.refreshAuth(r.authValue)
+	if refreshErr != nil {
+		return response, err
+	}
+	r.authValue = authValue
+	r.headers["Authorization"] = fmt.Sprintf("%s %s", r.authType, r.authValue)
+	return r.send()
+}
+
+func (r *apigeeRequest) send() (*coreapi.Response, error) {
+	if r.retry == nil {
+		return r.sendOnce(r.ctx, r.apiRequest())
+	}
+	return r.retry.send(r.ctx, r.apiRequest(), r.sendOnce)
+}
+
+// sendOnce - sends the request a single time, bounded by the request timeout
+func (r *apigeeRequest) sendOnce(ctx context.Context, request coreapi.Request) (*coreapi.Response, error) {
+	return sendWithContext(ctx, r.client, request, r.timeout)
+}
+
+func (r *apigeeRequest) apiRequest() coreapi.Request {
+	return coreapi.Request{
 		Method:      r.method,
 		URL:         r.url,
 		Headers:     r.headers,
 		QueryParams: r.queryParams,
 		Body:        r.body,
 	}
-	return r.client.Send(request)
 }
 
-func (a *ApigeeClient) newRequest(method, url string, options ...RequestOption) *apigeeRequest {
-	req := &apigeeRequest{method: method, url: url, client: a.apiClient, authValue: a.authValue, authType: a.authType}
+// hasAuthHeader - returns true when the request was sent with the apigee auth header
+func (r *apigeeRequest) hasAuthHeader() bool {
+	_, found := r.headers["Authorization"]
+	return found
+}
+
+func (a *ApigeeClient) newRequest(ctx context.Context, method, url string, options ...RequestOption) *apigeeRequest {
+	req := &apigeeRequest{
+		ctx:         ctx,
+		timeout:     a.timeout,
+		method:      method,
+		url:         url,
+		client:      a.apiClient,
+		retry:       a.retry,
+		authValue:   a.getAccessToken(),
+		authType:    a.authType,
+		refreshAuth: a.refreshAccessToken,
+	}
 	for _, o := range options {
 		o(req)
 	}
@@ -50,6 +99,13 @@ func WithDefaultHeaders() RequestOption {
 	}
 }
 
+// WithTimeout - bound each attempt of the request by the timeout, rather than the configured request timeout
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(r *apigeeRequest) {
+		r.timeout = timeout
+	}
+}
+
 // WithHeaders - add additional headers to the request
 func WithHeaders(headers map[string]string) RequestOption {
 	return func(r *apigeeRequest) {
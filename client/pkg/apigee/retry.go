@@ -0,0 +1,134 @@
+package apigee
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	coreapi "github.com/Axway/agent-sdk/pkg/api"
+	"github.com/Axway/agent-sdk/pkg/util/log"
+	"golang.org/x/time/rate"
+
+	"github.com/Axway/agents-apigee/client/pkg/config"
+)
+
+// retryPolicy - rate limits the apigee api calls and retries the throttled or failed ones
+type retryPolicy struct {
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	limiter        *rate.Limiter
+	sleep          func(context.Context, time.Duration) error
+}
+
+func newRetryPolicy(cfg *config.ApigeeRetry) *retryPolicy {
+	p := &retryPolicy{sleep: sleepWithContext}
+	if cfg == nil {
+		return p
+	}
+
+	p.maxRetries = cfg.MaxRetries
+	p.initialBackoff = cfg.InitialBackoff
+	p.maxBackoff = cfg.MaxBackoff
+	if cfg.RateLimit > 0 {
+		// the limiter is shared by every request the client sends, across all workers
+		p.limiter = rate.NewLimiter(rate.Limit(cfg.RateLimit), cfg.RateLimit)
+	}
+	return p
+}
+
+// send - sends the request, waiting for the rate limiter and retrying with exponential backoff
+func (p *retryPolicy) send(ctx context.Context, request coreapi.Request, sendFunc func(context.Context, coreapi.Request) (*coreapi.Response, error)) (*coreapi.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if p.limiter != nil {
+			if err := p.limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		response, err := sendFunc(ctx, request)
+		if ctx.Err() != nil || attempt >= p.maxRetries || !shouldRetry(request.Method, response, err) {
+			return response, err
+		}
+
+		delay, ok := p.backoff(attempt, response)
+		if !ok {
+			log.Debugf("not retrying %s %s, the Retry-After of %s is longer than the max backoff", request.Method, request.URL, delay)
+			return response, err
+		}
+		log.Debugf("retrying %s %s in %s, retry %d of %d", request.Method, request.URL, delay, attempt+1, p.maxRetries)
+		if err := p.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleepWithContext - waits for the delay, returning early when the context is done
+func sleepWithContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff - the time to wait before the next attempt, the Retry-After header takes precedence. False when the
+// Retry-After is longer than the max backoff, the call is not retried before the server allows it
+func (p *retryPolicy) backoff(attempt int, response *coreapi.Response) (time.Duration, bool) {
+	if delay, found := retryAfter(response); found {
+		return delay, p.maxBackoff <= 0 || delay <= p.maxBackoff
+	}
+
+	delay := p.maxBackoff
+	if attempt < 32 && p.initialBackoff<<attempt < p.maxBackoff {
+		delay = p.initialBackoff << attempt
+	}
+	if delay <= 0 {
+		return 0, true
+	}
+
+	// equal jitter, wait between half and the full backoff
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1)), true
+}
+
+// shouldRetry - throttled calls are always retried, failed calls only when they are safe to send again
+func shouldRetry(method string, response *coreapi.Response, err error) bool {
+	if err == nil && response.Code == http.StatusTooManyRequests {
+		return true
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return err != nil || response.Code >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// retryAfter - parses the Retry-After header, in either seconds or http date format
+func retryAfter(response *coreapi.Response) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+
+	value := http.Header(response.Headers).Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
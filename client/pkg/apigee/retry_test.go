@@ -0,0 +1,216 @@
+package apigee
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/api"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-apigee/client/pkg/config"
+)
+
+func TestRetryPolicySend(t *testing.T) {
+	cases := map[string]struct {
+		method       string
+		responses    []api.MockResponse
+		expectedCode int
+		expectErr    bool
+		expectedWait int
+	}{
+		"success, no retry": {
+			method:       http.MethodGet,
+			responses:    []api.MockResponse{{RespCode: http.StatusOK}},
+			expectedCode: http.StatusOK,
+		},
+		"throttled then success": {
+			method: http.MethodPost,
+			responses: []api.MockResponse{
+				{RespCode: http.StatusTooManyRequests},
+				{RespCode: http.StatusTooManyRequests},
+				{RespCode: http.StatusCreated},
+			},
+			expectedCode: http.StatusCreated,
+			expectedWait: 2,
+		},
+		"server error then success": {
+			method: http.MethodGet,
+			responses: []api.MockResponse{
+				{RespCode: http.StatusServiceUnavailable},
+				{ErrString: "connection reset"},
+				{RespCode: http.StatusOK},
+			},
+			expectedCode: http.StatusOK,
+			expectedWait: 2,
+		},
+		"server error on post is not retried": {
+			method:       http.MethodPost,
+			responses:    []api.MockResponse{{RespCode: http.StatusBadGateway}},
+			expectedCode: http.StatusBadGateway,
+		},
+		"client error is not retried": {
+			method:       http.MethodGet,
+			responses:    []api.MockResponse{{RespCode: http.StatusNotFound}},
+			expectedCode: http.StatusNotFound,
+		},
+		"retries exhausted": {
+			method: http.MethodGet,
+			responses: []api.MockResponse{
+				{RespCode: http.StatusTooManyRequests},
+				{RespCode: http.StatusTooManyRequests},
+				{RespCode: http.StatusTooManyRequests},
+				{RespCode: http.StatusTooManyRequests},
+			},
+			expectedCode: http.StatusTooManyRequests,
+			expectedWait: 3,
+		},
+		"error after retries exhausted": {
+			method: http.MethodDelete,
+			responses: []api.MockResponse{
+				{ErrString: "error"},
+				{ErrString: "error"},
+				{ErrString: "error"},
+				{ErrString: "error"},
+			},
+			expectErr:    true,
+			expectedWait: 3,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			waits := []time.Duration{}
+			p := newRetryPolicy(&config.ApigeeRetry{
+				MaxRetries:     3,
+				InitialBackoff: time.Second,
+				MaxBackoff:     30 * time.Second,
+				RateLimit:      100,
+			})
+			p.sleep = func(_ context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+
+			client := &api.MockHTTPClient{Responses: tc.responses}
+			response, err := p.send(context.Background(), api.Request{Method: tc.method, URL: "http://test.com"}, func(_ context.Context, request api.Request) (*api.Response, error) {
+				return client.Send(request)
+			})
+			assert.Len(t, waits, tc.expectedWait)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedCode, response.Code)
+		})
+	}
+}
+
+func TestRetryPolicySendCancelled(t *testing.T) {
+	p := newRetryPolicy(&config.ApigeeRetry{
+		MaxRetries:     3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     time.Minute,
+	})
+
+	// the backoff wait ends when the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	sends := 0
+	response, err := p.send(ctx, api.Request{Method: http.MethodGet, URL: "http://test.com"}, func(_ context.Context, _ api.Request) (*api.Response, error) {
+		sends++
+		cancel()
+		return &api.Response{Code: http.StatusServiceUnavailable}, nil
+	})
+	assert.Equal(t, 1, sends)
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+	assert.Nil(t, err)
+
+	// a cancelled context is not sent again
+	response, err = p.send(ctx, api.Request{Method: http.MethodGet, URL: "http://test.com"}, func(ctx context.Context, _ api.Request) (*api.Response, error) {
+		sends++
+		return nil, ctx.Err()
+	})
+	assert.Equal(t, 2, sends)
+	assert.Nil(t, response)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.ErrorIs(t, sleepWithContext(ctx, time.Minute), context.Canceled)
+	assert.Nil(t, sleepWithContext(context.Background(), time.Millisecond))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := newRetryPolicy(&config.ApigeeRetry{
+		MaxRetries:     10,
+		InitialBackoff: time.Second,
+		MaxBackoff:     10 * time.Second,
+	})
+	assert.Nil(t, p.limiter)
+
+	// exponential backoff with jitter, capped at the max backoff
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		delay, ok := p.backoff(attempt, &api.Response{Code: http.StatusTooManyRequests})
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, delay, max/2)
+		assert.LessOrEqual(t, delay, max)
+	}
+
+	// the Retry-After header takes precedence, the call is not retried when it is longer than the max backoff
+	response := &api.Response{Headers: map[string][]string{"Retry-After": {"7"}}}
+	delay, ok := p.backoff(0, response)
+	assert.True(t, ok)
+	assert.Equal(t, 7*time.Second, delay)
+
+	response.Headers["Retry-After"] = []string{"42"}
+	delay, ok = p.backoff(0, response)
+	assert.False(t, ok)
+	assert.Equal(t, 42*time.Second, delay)
+
+	response.Headers["Retry-After"] = []string{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)}
+	delay, ok = p.backoff(0, response)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), delay)
+
+	response.Headers["Retry-After"] = []string{"soon"}
+	delay, ok = p.backoff(0, response)
+	assert.True(t, ok)
+	assert.LessOrEqual(t, delay, time.Second)
+}
+
+func TestRetryPolicySendRetryAfter(t *testing.T) {
+	p := newRetryPolicy(&config.ApigeeRetry{
+		MaxRetries:     3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+	})
+	waits := []time.Duration{}
+	p.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	retryAfters := []string{"20", "60"}
+	sends := 0
+	response, err := p.send(context.Background(), api.Request{Method: http.MethodGet, URL: "http://test.com"}, func(_ context.Context, _ api.Request) (*api.Response, error) {
+		retryAfter := retryAfters[sends]
+		sends++
+		return &api.Response{Code: http.StatusTooManyRequests, Headers: map[string][]string{"Retry-After": {retryAfter}}}, nil
+	})
+
+	// the full Retry-After is waited for, the call is given up once the server asks for longer than the max backoff
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, response.Code)
+	assert.Equal(t, 2, sends)
+	assert.Equal(t, []time.Duration{20 * time.Second}, waits)
+}
+
+func TestGetAllProxiesThrottled(t *testing.T) {
+	c := createTestClient(t, &api.MockHTTPClient{Responses: []api.MockResponse{
+		{RespCode: http.StatusTooManyRequests},
+	}})
+
+	// throttled calls are not reported as an empty list of proxies
+	proxies, err := c.GetAllProxies()
+	assert.NotNil(t, err)
+	assert.Nil(t, proxies)
+}
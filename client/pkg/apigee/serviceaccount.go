@@ -0,0 +1,73 @@
+package apigee
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	googleTokenURL     = "https://oauth2.googleapis.com/token"
+	googleCloudScope   = "https://www.googleapis.com/auth/cloud-platform"
+	assertionKey       = "assertion"
+	assertionLifetime  = time.Hour
+	serviceAccountType = "service_account"
+)
+
+// serviceAccountKey - the google service account json key file
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+	signingKey   *rsa.PrivateKey
+}
+
+// loadServiceAccountKey - reads and validates the google service account json key file
+func loadServiceAccountKey(keyFile string) (*serviceAccountKey, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the service account key file: %s", err)
+	}
+
+	key := &serviceAccountKey{}
+	err = json.Unmarshal(data, key)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the service account key file: %s", err)
+	}
+
+	if key.Type != serviceAccountType {
+		return nil, fmt.Errorf("unexpected key type %s in the service account key file", key.Type)
+	}
+	if key.ClientEmail == "" {
+		return nil, fmt.Errorf("the service account key file does not have a client email")
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = googleTokenURL
+	}
+
+	key.signingKey, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(key.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the service account private key: %s", err)
+	}
+	return key, nil
+}
+
+// signAssertion - creates the signed jwt that is exchanged for an access token
+func (k *serviceAccountKey) signAssertion(now time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   k.ClientEmail,
+		"scope": googleCloudScope,
+		"aud":   k.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(assertionLifetime).Unix(),
+	})
+	token.Header["kid"] = k.PrivateKeyID
+	return token.SignedString(k.signingKey)
+}
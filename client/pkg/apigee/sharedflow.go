@@ -2,6 +2,7 @@ package apigee
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,26 +14,39 @@ import (
 
 // GetSharedFlow - gets the list of shared flows
 func (a *ApigeeClient) GetSharedFlow(name string) (*models.SharedFlowRevisionDeploymentDetails, error) {
+	return a.GetSharedFlowWithContext(context.Background(), name)
+}
+
+// GetSharedFlowWithContext - gets the list of shared flows, cancelled when the context is done
+func (a *ApigeeClient) GetSharedFlowWithContext(ctx context.Context, name string) (*models.SharedFlowRevisionDeploymentDetails, error) {
 	// Get the shared flows list
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/sharedflows/%v", a.orgURL, name),
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/sharedflows/%v", a.orgURL, name),
 		WithDefaultHeaders(),
 	).Execute()
 	if err != nil {
 		return nil, err
 	}
 
-	if response.Code == http.StatusNotFound {
-		return nil, fmt.Errorf("could not find shared flow named %v", name)
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, fmt.Sprintf("getting the shared flow %s", name))
 	}
 
 	flow := models.SharedFlowRevisionDeploymentDetails{}
-	json.Unmarshal(response.Body, &flow)
+	err = json.Unmarshal(response.Body, &flow)
+	if err != nil {
+		return nil, err
+	}
 
 	return &flow, nil
 }
 
 // CreateSharedFlow - uploads an apigee bundle as a shared flow
 func (a *ApigeeClient) CreateSharedFlow(data []byte, name string) error {
+	return a.CreateSharedFlowWithContext(context.Background(), data, name)
+}
+
+// CreateSharedFlowWithContext - uploads an apigee bundle as a shared flow, cancelled when the context is done
+func (a *ApigeeClient) CreateSharedFlowWithContext(ctx context.Context, data []byte, name string) error {
 	var buffer bytes.Buffer
 	writer := multipart.NewWriter(&buffer)
 
@@ -41,24 +55,33 @@ func (a *ApigeeClient) CreateSharedFlow(data []byte, name string) error {
 	io.Copy(flow, bytes.NewReader(data))
 	writer.Close()
 
-	// assemble the request with the writer content type and buffer data
-	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/sharedflows?action=import&name=%s", a.orgURL, name), &buffer)
-	req.Header.Add("Content-Type", writer.FormDataContentType())
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Authorization", "Bearer "+a.authValue)
-	client := &http.Client{}
-
-	// submit the request
-	_, err := client.Do(req)
+	// send the buffer data with the writer content type
+	response, err := a.newRequest(ctx, http.MethodPost, fmt.Sprintf("%s/sharedflows", a.orgURL),
+		WithDefaultHeaders(),
+		WithQueryParams(map[string]string{"action": "import", "name": name}),
+		WithBody(buffer.Bytes()),
+		WithHeader("Content-Type", writer.FormDataContentType()),
+	).Execute()
+	if err != nil {
+		return err
+	}
 
-	return err
+	if response.Code != http.StatusOK && response.Code != http.StatusCreated {
+		return newAPIError(response, "importing the shared flow")
+	}
+	return nil
 }
 
 // DeploySharedFlow - deploy the shared flow and revision to the environment
 func (a *ApigeeClient) DeploySharedFlow(env, name, revision string) error {
+	return a.DeploySharedFlowWithContext(context.Background(), env, name, revision)
+}
+
+// DeploySharedFlowWithContext - deploy the shared flow and revision to the environment, cancelled when the context is done
+func (a *ApigeeClient) DeploySharedFlowWithContext(ctx context.Context, env, name, revision string) error {
 
 	// deploy the shared flow to the environment
-	_, err := a.newRequest(http.MethodPost, fmt.Sprintf("%s/environments/%v/sharedflows/%v/revisions/%v/deployments", a.orgURL, env, name, revision),
+	response, err := a.newRequest(ctx, http.MethodPost, fmt.Sprintf("%s/environments/%v/sharedflows/%v/revisions/%v/deployments", a.orgURL, env, name, revision),
 		WithDefaultHeaders(),
 		WithBody([]byte{}),
 		WithQueryParam("override", "true"),
@@ -67,12 +90,20 @@ func (a *ApigeeClient) DeploySharedFlow(env, name, revision string) error {
 	if err != nil {
 		return err
 	}
+	if response.Code != http.StatusOK {
+		return newAPIError(response, "deploying the shared flow")
+	}
 
 	return nil
 }
 
 // PublishSharedFlowToEnvironment - publish the shared flow
 func (a *ApigeeClient) PublishSharedFlowToEnvironment(env, name string) error {
+	return a.PublishSharedFlowToEnvironmentWithContext(context.Background(), env, name)
+}
+
+// PublishSharedFlowToEnvironmentWithContext - publish the shared flow, cancelled when the context is done
+func (a *ApigeeClient) PublishSharedFlowToEnvironmentWithContext(ctx context.Context, env, name string) error {
 	// This is the structure that is expected for adding a shared flow as a flow hook
 	type flowhook struct {
 		ContinueOnError bool   `json:"continueOnError"`
@@ -89,9 +120,15 @@ func (a *ApigeeClient) PublishSharedFlowToEnvironment(env, name string) error {
 	data, _ := json.Marshal(hook)
 
 	// Add the flow to the post proxy flow hook
-	_, err := a.newRequest(http.MethodPut, fmt.Sprintf("%s/environments/%v/flowhooks/PostProxyFlowHook", a.orgURL, env),
+	response, err := a.newRequest(ctx, http.MethodPut, fmt.Sprintf("%s/environments/%v/flowhooks/PostProxyFlowHook", a.orgURL, env),
 		WithDefaultHeaders(),
 		WithBody(data),
 	).Execute()
-	return err
+	if err != nil {
+		return err
+	}
+	if response.Code != http.StatusOK {
+		return newAPIError(response, "adding the shared flow to the flow hook")
+	}
+	return nil
 }
@@ -0,0 +1,361 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+)
+
+const (
+	statusApproved = "approved"
+	statusRevoked  = "revoked"
+)
+
+// keyRequest - the body used to create a key on an app or to add products to a key
+type keyRequest struct {
+	ApiProducts  []string           `json:"apiProducts"`
+	Attributes   []models.Attribute `json:"attributes"`
+	KeyExpiresIn int                `json:"keyExpiresIn"`
+}
+
+func (s *Server) listDevelopers(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	emails := []string{}
+	for email := range s.developers {
+		emails = append(emails, email)
+	}
+	writeJSON(w, http.StatusOK, pageNames(r, emails))
+}
+
+// developerFromPath - writes the not found error when the developer does not exist, the lock must be held
+func (s *Server) developerFromPath(w http.ResponseWriter, r *http.Request) *developer {
+	d := s.findDeveloper(r.PathValue("dev"))
+	if d == nil {
+		writeError(w, http.StatusNotFound, "developer.service.DeveloperDoesNotExist", fmt.Sprintf("Developer with email or id %s does not exist", r.PathValue("dev")))
+	}
+	return d
+}
+
+// appFromPath - writes the not found error when the developer or app does not exist, the lock must be held
+func (s *Server) appFromPath(w http.ResponseWriter, r *http.Request) (*developer, *models.DeveloperApp) {
+	d := s.developerFromPath(w, r)
+	if d == nil {
+		return nil, nil
+	}
+	_, app := d.findApp(r.PathValue("app"))
+	if app == nil {
+		writeError(w, http.StatusNotFound, "developer.service.AppDoesNotExist", fmt.Sprintf("App named %s does not exist under %s", r.PathValue("app"), d.Email))
+		return nil, nil
+	}
+	return d, app
+}
+
+// keyFromPath - writes the not found error when the developer, app or key does not exist, the lock must be held
+func (s *Server) keyFromPath(w http.ResponseWriter, r *http.Request) (*models.DeveloperApp, *models.DeveloperAppCredentials) {
+	_, app := s.appFromPath(w, r)
+	if app == nil {
+		return nil, nil
+	}
+	_, cred := findCredential(app, r.PathValue("key"))
+	if cred == nil {
+		writeError(w, http.StatusNotFound, "keymanagement.service.InvalidClientIdForGivenApp", fmt.Sprintf("Invalid consumer key for app %s", app.Name))
+		return nil, nil
+	}
+	return app, cred
+}
+
+// productRefs - writes the error when a product does not exist, the lock must be held
+func (s *Server) productRefs(w http.ResponseWriter, products []string) ([]models.ApiProductRef, bool) {
+	refs := []models.ApiProductRef{}
+	for _, name := range products {
+		if _, found := s.products[name]; !found {
+			writeError(w, http.StatusBadRequest, "keymanagement.service.apiproduct_doesnot_exist", fmt.Sprintf("API Product [%s] does not exist for tenant [%s]", name, Organization))
+			return nil, false
+		}
+		refs = append(refs, models.ApiProductRef{Apiproduct: name, Status: statusApproved})
+	}
+	return refs, true
+}
+
+// newCredential - creates an approved key for the products, expiring after keyExpiresIn milliseconds when it is positive
+func newCredential(products []models.ApiProductRef, attributes []models.Attribute, keyExpiresIn int) models.DeveloperAppCredentials {
+	now := int(time.Now().UnixMilli())
+	expiresAt := -1
+	if keyExpiresIn > 0 {
+		expiresAt = now + keyExpiresIn
+	}
+	return models.DeveloperAppCredentials{
+		ApiProducts:    products,
+		Attributes:     attributes,
+		ConsumerKey:    newToken(),
+		ConsumerSecret: newToken(),
+		ExpiresAt:      expiresAt,
+		IssuedAt:       now,
+		Status:         statusApproved,
+	}
+}
+
+func (s *Server) listApps(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	d := s.developerFromPath(w, r)
+	if d == nil {
+		return
+	}
+
+	names := []string{}
+	for _, app := range d.Apps {
+		names = append(names, app.Name)
+	}
+	writeJSON(w, http.StatusOK, pageNames(r, names))
+}
+
+// createApp - creates the app with a key for the requested products
+func (s *Server) createApp(w http.ResponseWriter, r *http.Request) {
+	app := &models.DeveloperApp{}
+	if err := json.NewDecoder(r.Body).Decode(app); err != nil || app.Name == "" {
+		writeError(w, http.StatusBadRequest, "developer.service.InvalidApp", "Invalid app")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	d := s.developerFromPath(w, r)
+	if d == nil {
+		return
+	}
+	if _, existing := d.findApp(app.Name); existing != nil {
+		writeError(w, http.StatusConflict, "developer.service.AppAlreadyExists", fmt.Sprintf("App named %s already exists under %s", app.Name, d.Email))
+		return
+	}
+
+	refs, ok := s.productRefs(w, app.ApiProducts)
+	if !ok {
+		return
+	}
+
+	now := int(time.Now().UnixMilli())
+	app.AppId = s.newID()
+	app.DeveloperId = d.DeveloperID
+	app.CreatedAt = now
+	app.CreatedBy = Username
+	app.LastModifiedAt = now
+	app.LastModifiedBy = Username
+	app.Status = statusApproved
+	app.Credentials = []models.DeveloperAppCredentials{newCredential(refs, nil, app.KeyExpiresIn)}
+	app.ApiProducts = nil
+	d.Apps = append(d.Apps, app)
+	writeJSON(w, http.StatusCreated, app)
+}
+
+func (s *Server) getApp(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, app := s.appFromPath(w, r); app != nil {
+		writeJSON(w, http.StatusOK, app)
+	}
+}
+
+// updateApp - replaces the attributes, callback url and scopes of the app, the keys are not changed
+func (s *Server) updateApp(w http.ResponseWriter, r *http.Request) {
+	update := &models.DeveloperApp{}
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		writeError(w, http.StatusBadRequest, "developer.service.InvalidApp", "Invalid app")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, app := s.appFromPath(w, r)
+	if app == nil {
+		return
+	}
+
+	app.Attributes = update.Attributes
+	app.CallbackUrl = update.CallbackUrl
+	app.Scopes = update.Scopes
+	app.LastModifiedAt = int(time.Now().UnixMilli())
+	app.LastModifiedBy = Username
+	writeJSON(w, http.StatusOK, app)
+}
+
+// createKey - adds a new key, for the requested products, to the app
+func (s *Server) createKey(w http.ResponseWriter, r *http.Request) {
+	req := keyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "developer.service.InvalidApp", "Invalid app")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, app := s.appFromPath(w, r)
+	if app == nil {
+		return
+	}
+
+	refs, ok := s.productRefs(w, req.ApiProducts)
+	if !ok {
+		return
+	}
+
+	app.Credentials = append(app.Credentials, newCredential(refs, req.Attributes, req.KeyExpiresIn))
+	app.LastModifiedAt = int(time.Now().UnixMilli())
+	writeJSON(w, http.StatusOK, app)
+}
+
+func (s *Server) deleteApp(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	d, app := s.appFromPath(w, r)
+	if app == nil {
+		return
+	}
+
+	i, _ := d.findApp(app.Name)
+	d.Apps = append(d.Apps[:i], d.Apps[i+1:]...)
+	writeJSON(w, http.StatusOK, app)
+}
+
+func (s *Server) getKey(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, cred := s.keyFromPath(w, r); cred != nil {
+		writeJSON(w, http.StatusOK, cred)
+	}
+}
+
+// updateKey - approves or revokes the key when there is an action, otherwise adds the requested products to it
+func (s *Server) updateKey(w http.ResponseWriter, r *http.Request) {
+	req := keyRequest{}
+	action := r.URL.Query().Get("action")
+	if action == "" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "keymanagement.service.InvalidRequest", "Invalid request")
+			return
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, cred := s.keyFromPath(w, r)
+	if cred == nil {
+		return
+	}
+
+	switch action {
+	case "approve":
+		cred.Status = statusApproved
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case "revoke":
+		cred.Status = statusRevoked
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case "":
+	default:
+		writeError(w, http.StatusBadRequest, "keymanagement.service.InvalidAction", fmt.Sprintf("Invalid action %s", action))
+		return
+	}
+
+	refs, ok := s.productRefs(w, req.ApiProducts)
+	if !ok {
+		return
+	}
+	for _, ref := range refs {
+		if !hasProduct(cred, ref.Apiproduct) {
+			cred.ApiProducts = append(cred.ApiProducts, ref)
+		}
+	}
+	if req.Attributes != nil {
+		cred.Attributes = req.Attributes
+	}
+	writeJSON(w, http.StatusOK, cred)
+}
+
+func (s *Server) deleteKey(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	app, cred := s.keyFromPath(w, r)
+	if cred == nil {
+		return
+	}
+
+	removed := *cred
+	i, _ := findCredential(app, cred.ConsumerKey)
+	app.Credentials = append(app.Credentials[:i], app.Credentials[i+1:]...)
+	writeJSON(w, http.StatusOK, removed)
+}
+
+// updateKeyProduct - approves or revokes a product of the key
+func (s *Server) updateKeyProduct(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, cred := s.keyFromPath(w, r)
+	if cred == nil {
+		return
+	}
+
+	status := ""
+	switch r.URL.Query().Get("action") {
+	case "approve":
+		status = statusApproved
+	case "revoke":
+		status = statusRevoked
+	default:
+		writeError(w, http.StatusBadRequest, "keymanagement.service.InvalidAction", fmt.Sprintf("Invalid action %s", r.URL.Query().Get("action")))
+		return
+	}
+
+	for i := range cred.ApiProducts {
+		if cred.ApiProducts[i].Apiproduct == r.PathValue("product") {
+			cred.ApiProducts[i].Status = status
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "keymanagement.service.apiproduct_doesnot_exist", fmt.Sprintf("API Product [%s] is not associated with the key", r.PathValue("product")))
+}
+
+func (s *Server) deleteKeyProduct(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, cred := s.keyFromPath(w, r)
+	if cred == nil {
+		return
+	}
+
+	for i := range cred.ApiProducts {
+		if cred.ApiProducts[i].Apiproduct == r.PathValue("product") {
+			cred.ApiProducts = append(cred.ApiProducts[:i], cred.ApiProducts[i+1:]...)
+			writeJSON(w, http.StatusOK, cred)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "keymanagement.service.apiproduct_doesnot_exist", fmt.Sprintf("API Product [%s] is not associated with the key", r.PathValue("product")))
+}
+
+func hasProduct(cred *models.DeveloperAppCredentials, productName string) bool {
+	for _, ref := range cred.ApiProducts {
+		if ref.Apiproduct == productName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package simulator
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+func (s *Server) listEnvironments(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := []string{}
+	for name := range s.environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	writeJSON(w, http.StatusOK, names)
+}
+
+// getEnvironment - the client reads the virtual host names of the environment from this call
+func (s *Server) getEnvironment(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	env, found := s.environments[r.PathValue("env")]
+	if !found {
+		writeError(w, http.StatusNotFound, "environment.service.EnvironmentDoesNotExist", "Environment does not exist")
+		return
+	}
+
+	names := []string{}
+	for _, vh := range env.VirtualHosts {
+		names = append(names, vh.Name)
+	}
+	writeJSON(w, http.StatusOK, names)
+}
+
+func (s *Server) getVirtualHost(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	env, found := s.environments[r.PathValue("env")]
+	if !found {
+		writeError(w, http.StatusNotFound, "environment.service.EnvironmentDoesNotExist", "Environment does not exist")
+		return
+	}
+
+	for _, vh := range env.VirtualHosts {
+		if vh.Name == r.PathValue("vhost") {
+			writeJSON(w, http.StatusOK, vh)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "messaging.config.beans.VirtualHostDoesNotExist", "Virtual host does not exist")
+}
+
+// getStats - returns the metrics of the environment from the fixtures, regardless of the dimension and time range
+func (s *Server) getStats(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	envName := r.PathValue("env")
+	if _, found := s.environments[envName]; !found {
+		writeError(w, http.StatusNotFound, "environment.service.EnvironmentDoesNotExist", "Environment does not exist")
+		return
+	}
+	if r.URL.Query().Get("select") == "" || r.URL.Query().Get("timeRange") == "" {
+		writeError(w, http.StatusBadRequest, "analytics.service.InvalidQuery", "select and timeRange are required")
+		return
+	}
+
+	stats, found := s.stats[envName]
+	if !found {
+		stats, _ = json.Marshal(map[string]interface{}{"name": envName, "dimensions": []string{}})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"environments": []json.RawMessage{stats}})
+}
@@ -0,0 +1,258 @@
+package simulator
+
+import (
+	"archive/zip"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+const bundlesDir = "fixtures/bundles"
+
+type environment struct {
+	Name         string               `json:"name"`
+	VirtualHosts []models.VirtualHost `json:"virtualHosts"`
+}
+
+type deployment struct {
+	Environment string `json:"environment"`
+	Revision    string `json:"revision"`
+}
+
+type proxy struct {
+	Name        string                    `json:"name"`
+	Revisions   []models.ApiProxyRevision `json:"revisions"`
+	Deployments []deployment              `json:"deployments"`
+	// bundles - the files of each revision bundle, by revision and file name
+	bundles map[string]map[string][]byte
+}
+
+type developer struct {
+	Email       string                 `json:"email"`
+	DeveloperID string                 `json:"developerId"`
+	Apps        []*models.DeveloperApp `json:"apps"`
+}
+
+type spec struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Modified string `json:"modified"`
+	File     string `json:"file"`
+	// Folder - the path of the spec store folder the spec is in, the home folder when not set
+	Folder  string `json:"folder"`
+	Trashed bool   `json:"trashed"`
+	content []byte
+}
+
+// load - seeds the organization from the fixtures
+func (s *Server) load() error {
+	environments := []*environment{}
+	if err := readFixture("environments.json", &environments); err != nil {
+		return err
+	}
+	s.environments = make(map[string]*environment)
+	for _, env := range environments {
+		s.environments[env.Name] = env
+	}
+
+	proxies := []*proxy{}
+	if err := readFixture("proxies.json", &proxies); err != nil {
+		return err
+	}
+	s.proxies = make(map[string]*proxy)
+	for _, p := range proxies {
+		bundles, err := readBundles(p.Name)
+		if err != nil {
+			return err
+		}
+		p.bundles = bundles
+		s.proxies[p.Name] = p
+	}
+
+	products := []*models.ApiProduct{}
+	if err := readFixture("products.json", &products); err != nil {
+		return err
+	}
+	s.products = make(map[string]*models.ApiProduct)
+	for _, p := range products {
+		s.products[p.Name] = p
+	}
+
+	developers := []*developer{}
+	if err := readFixture("developers.json", &developers); err != nil {
+		return err
+	}
+	s.developers = make(map[string]*developer)
+	for _, d := range developers {
+		for _, app := range d.Apps {
+			app.DeveloperId = d.DeveloperID
+			app.AppId = s.newID()
+		}
+		s.developers[d.Email] = d
+	}
+
+	if err := readFixture("specs.json", &s.specs); err != nil {
+		return err
+	}
+	for _, sp := range s.specs {
+		content, err := fixtures.ReadFile(path.Join("fixtures/specs", sp.File))
+		if err != nil {
+			return err
+		}
+		sp.content = content
+	}
+
+	stats := []json.RawMessage{}
+	if err := readFixture("stats.json", &stats); err != nil {
+		return err
+	}
+	s.stats = make(map[string]json.RawMessage)
+	for _, raw := range stats {
+		env := struct {
+			Name string `json:"name"`
+		}{}
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return err
+		}
+		s.stats[env.Name] = raw
+	}
+	return nil
+}
+
+func readFixture(name string, v interface{}) error {
+	data, err := fixtures.ReadFile(path.Join("fixtures", name))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// readBundles - reads the files of all revision bundles of the proxy, a revision without fixtures has no bundle
+func readBundles(proxyName string) (map[string]map[string][]byte, error) {
+	bundles := make(map[string]map[string][]byte)
+	proxyDir := path.Join(bundlesDir, proxyName)
+	if _, err := fs.Stat(fixtures, proxyDir); err != nil {
+		return bundles, nil
+	}
+
+	err := fs.WalkDir(fixtures, proxyDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		// the first directory under the proxy is the revision, the rest is the path in the bundle
+		revision, fileName, _ := strings.Cut(strings.TrimPrefix(filePath, proxyDir+"/"), "/")
+		content, err := fixtures.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		if _, found := bundles[revision]; !found {
+			bundles[revision] = make(map[string][]byte)
+		}
+		bundles[revision][fileName] = content
+		return nil
+	})
+	return bundles, err
+}
+
+// zipBundle - creates the zip file apigee returns when downloading a revision bundle
+func zipBundle(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	for _, name := range names {
+		f, err := writer.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// findDeveloper - apigee accepts the developer email or id in the api paths, the lock must be held
+func (s *Server) findDeveloper(emailOrID string) *developer {
+	if d, found := s.developers[emailOrID]; found {
+		return d
+	}
+	for _, d := range s.developers {
+		if d.DeveloperID == emailOrID {
+			return d
+		}
+	}
+	return nil
+}
+
+func (d *developer) findApp(name string) (int, *models.DeveloperApp) {
+	for i, app := range d.Apps {
+		if app.Name == name {
+			return i, app
+		}
+	}
+	return -1, nil
+}
+
+func findCredential(app *models.DeveloperApp, key string) (int, *models.DeveloperAppCredentials) {
+	for i := range app.Credentials {
+		if app.Credentials[i].ConsumerKey == key {
+			return i, &app.Credentials[i]
+		}
+	}
+	return -1, nil
+}
+
+// App - returns a copy of the named app of the agent developer, nil when it does not exist
+func (s *Server) App(name string) *models.DeveloperApp {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	d := s.findDeveloper(DeveloperID)
+	if d == nil {
+		return nil
+	}
+	_, app := d.findApp(name)
+	if app == nil {
+		return nil
+	}
+	appCopy := &models.DeveloperApp{}
+	clone(app, appCopy)
+	return appCopy
+}
+
+// Product - returns a copy of the named api product, nil when it does not exist
+func (s *Server) Product(name string) *models.ApiProduct {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	product, found := s.products[name]
+	if !found {
+		return nil
+	}
+	productCopy := &models.ApiProduct{}
+	clone(product, productCopy)
+	return productCopy
+}
+
+// clone - deep copies the entity so callers can not change the model without the lock
+func clone(from, to interface{}) {
+	data, _ := json.Marshal(from)
+	json.Unmarshal(data, to)
+}
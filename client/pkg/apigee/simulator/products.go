@@ -0,0 +1,57 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+)
+
+func (s *Server) listProducts(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := []string{}
+	for name := range s.products {
+		names = append(names, name)
+	}
+	writeJSON(w, http.StatusOK, pageNames(r, names))
+}
+
+func (s *Server) getProduct(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	product, found := s.products[r.PathValue("product")]
+	if !found {
+		writeError(w, http.StatusNotFound, "keymanagement.service.apiproduct_doesnot_exist", fmt.Sprintf("API Product [%s] does not exist for tenant [%s]", r.PathValue("product"), Organization))
+		return
+	}
+	writeJSON(w, http.StatusOK, product)
+}
+
+func (s *Server) createProduct(w http.ResponseWriter, r *http.Request) {
+	product := &models.ApiProduct{}
+	if err := json.NewDecoder(r.Body).Decode(product); err != nil || product.Name == "" {
+		writeError(w, http.StatusBadRequest, "keymanagement.service.InvalidAPIProduct", "Invalid API product")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.products[product.Name]; found {
+		writeError(w, http.StatusConflict, "keymanagement.service.apiproduct_already_exists", fmt.Sprintf("API Product [%s] already exists", product.Name))
+		return
+	}
+
+	now := int(time.Now().UnixMilli())
+	product.CreatedAt = now
+	product.CreatedBy = Username
+	product.LastModifiedAt = now
+	product.LastModifiedBy = Username
+	s.products[product.Name] = product
+	writeJSON(w, http.StatusCreated, product)
+}
@@ -0,0 +1,184 @@
+package simulator
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+)
+
+// policyXML - the attributes of the root element of a policy file, the element name is the policy type
+type policyXML struct {
+	XMLName         xml.Name
+	Name            string `xml:"name,attr"`
+	Enabled         string `xml:"enabled,attr"`
+	ContinueOnError string `xml:"continueOnError,attr"`
+	Async           string `xml:"async,attr"`
+	DisplayName     string `xml:"DisplayName"`
+}
+
+// pageNames - returns a page of the sorted names, starting with the startKey and holding up to count names
+func pageNames(r *http.Request, names []string) []string {
+	sort.Strings(names)
+
+	if startKey := r.URL.Query().Get("startKey"); startKey != "" {
+		start := sort.SearchStrings(names, startKey)
+		names = names[start:]
+	}
+	if count, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil && count >= 0 && count < len(names) {
+		names = names[:count]
+	}
+	return names
+}
+
+func (s *Server) listProxies(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := []string{}
+	for name := range s.proxies {
+		names = append(names, name)
+	}
+	writeJSON(w, http.StatusOK, pageNames(r, names))
+}
+
+// findProxy - writes the not found error when the proxy does not exist, the lock must be held
+func (s *Server) findProxy(w http.ResponseWriter, r *http.Request) *proxy {
+	p, found := s.proxies[r.PathValue("proxy")]
+	if !found {
+		writeError(w, http.StatusNotFound, "messaging.config.beans.ApplicationDoesNotExist", fmt.Sprintf("APIProxy named %s does not exist in organization %s", r.PathValue("proxy"), Organization))
+		return nil
+	}
+	return p
+}
+
+// findRevision - writes the not found error when the proxy or revision does not exist, the lock must be held
+func (s *Server) findRevision(w http.ResponseWriter, r *http.Request) (*proxy, *models.ApiProxyRevision) {
+	p := s.findProxy(w, r)
+	if p == nil {
+		return nil, nil
+	}
+	for i := range p.Revisions {
+		if p.Revisions[i].Revision == r.PathValue("rev") {
+			return p, &p.Revisions[i]
+		}
+	}
+	writeError(w, http.StatusNotFound, "messaging.config.beans.ApplicationRevisionDoesNotExist", fmt.Sprintf("APIProxy revision %s does not exist for %s", r.PathValue("rev"), p.Name))
+	return nil, nil
+}
+
+func (s *Server) getProxy(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p := s.findProxy(w, r)
+	if p == nil {
+		return
+	}
+
+	revisions := []string{}
+	for _, rev := range p.Revisions {
+		revisions = append(revisions, rev.Revision)
+	}
+	writeJSON(w, http.StatusOK, models.ApiProxy{Name: p.Name, Revision: revisions})
+}
+
+func (s *Server) getDeployments(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p := s.findProxy(w, r)
+	if p == nil {
+		return
+	}
+
+	details := models.DeploymentDetails{
+		Name:         p.Name,
+		Organization: Organization,
+		Environment:  []models.DeploymentDetailsEnvironment{},
+	}
+	for _, d := range p.Deployments {
+		details.Environment = append(details.Environment, models.DeploymentDetailsEnvironment{
+			Name: d.Environment,
+			Revision: []models.DeploymentDetailsRevision{
+				{Name: d.Revision, State: "deployed"},
+			},
+		})
+	}
+	writeJSON(w, http.StatusOK, details)
+}
+
+// getRevision - returns the revision details, or the zipped bundle when the bundle format is requested
+func (s *Server) getRevision(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p, revision := s.findRevision(w, r)
+	if revision == nil {
+		return
+	}
+
+	if r.URL.Query().Get("format") != "bundle" {
+		writeJSON(w, http.StatusOK, revision)
+		return
+	}
+
+	bundle, err := zipBundle(p.bundles[revision.Revision])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "messaging.config.beans.BundleError", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	w.Write(bundle)
+}
+
+// getPolicy - returns the policy details read from the policy file in the revision bundle
+func (s *Server) getPolicy(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p, revision := s.findRevision(w, r)
+	if revision == nil {
+		return
+	}
+
+	policyName := r.PathValue("policy")
+	content, found := p.bundles[revision.Revision][fmt.Sprintf("apiproxy/policies/%s.xml", policyName)]
+	data := &policyXML{}
+	if !found || xml.Unmarshal(content, data) != nil {
+		writeError(w, http.StatusNotFound, "messaging.config.beans.PolicyDoesNotExist", fmt.Sprintf("Policy %s does not exist", policyName))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"async":           data.Async,
+		"continueOnError": data.ContinueOnError,
+		"DisplayName":     data.DisplayName,
+		"enabled":         data.Enabled,
+		"name":            data.Name,
+		"policyType":      data.XMLName.Local,
+	})
+}
+
+func (s *Server) getResourceFile(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p, revision := s.findRevision(w, r)
+	if revision == nil {
+		return
+	}
+
+	content, found := p.bundles[revision.Revision][fmt.Sprintf("apiproxy/resources/%s/%s", r.PathValue("type"), r.PathValue("name"))]
+	if !found {
+		writeError(w, http.StatusNotFound, "messaging.config.beans.ResourceDoesNotExist", fmt.Sprintf("Resource with name %s and type %s does not exist", r.PathValue("name"), r.PathValue("type")))
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
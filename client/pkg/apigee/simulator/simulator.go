@@ -0,0 +1,241 @@
+package simulator
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+	"github.com/Axway/agents-apigee/client/pkg/config"
+)
+
+const (
+	// Organization - the apigee organization served by the simulator
+	Organization = "org"
+	// APIVersion - the version of the management api served by the simulator
+	APIVersion = "v1"
+	// DeveloperID - the developer that owns the apps created by the agent
+	DeveloperID = "developer@example.com"
+	// Username - the user that can call the management api and request tokens
+	Username = "user@example.com"
+	// Password - the password of the user
+	Password = "password"
+	// ServerUsername - the client id of the oauth token endpoint
+	ServerUsername = "edgecli"
+	// ServerPassword - the client secret of the oauth token endpoint
+	ServerPassword = "edgeclisecret"
+
+	// tokenLifetime - the seconds an access token is valid for
+	tokenLifetime = 1799
+)
+
+// Server - a fake Apigee Edge management api, backed by an in-memory organization seeded from the fixtures
+type Server struct {
+	*httptest.Server
+	lock          sync.Mutex
+	orgPath       string
+	accessTokens  map[string]struct{}
+	refreshTokens map[string]struct{}
+	environments  map[string]*environment
+	proxies       map[string]*proxy
+	products      map[string]*models.ApiProduct
+	developers    map[string]*developer
+	specs         []*spec
+	stats         map[string]json.RawMessage
+	nextID        int
+}
+
+// NewServer - starts a simulator with the organization read from the fixtures, close it when done
+func NewServer() (*Server, error) {
+	s := &Server{
+		orgPath:       fmt.Sprintf("/%s/organizations/%s", APIVersion, Organization),
+		accessTokens:  make(map[string]struct{}),
+		refreshTokens: make(map[string]struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	s.Server = httptest.NewServer(s.routes())
+	return s, nil
+}
+
+// Config - returns an apigee config pointed at the simulator, authenticating with the oauth token endpoint
+func (s *Server) Config() *config.ApigeeConfig {
+	cfg := config.NewApigeeConfig()
+	cfg.Platform = "edge"
+	cfg.URL = s.URL
+	cfg.APIVersion = APIVersion
+	cfg.DataURL = s.URL
+	cfg.Organization = Organization
+	cfg.DeveloperID = DeveloperID
+	cfg.Auth.URL = s.URL
+	cfg.Auth.ServerUsername = ServerUsername
+	cfg.Auth.ServerPassword = ServerPassword
+	cfg.Auth.Username = Username
+	cfg.Auth.Password = Password
+	return cfg
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	// authentication
+	mux.HandleFunc("POST /oauth/token", s.token)
+	mux.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	// environments and virtual hosts
+	s.handle(mux, "GET %s/environments", s.listEnvironments)
+	s.handle(mux, "GET %s/environments/{env}", s.getEnvironment)
+	s.handle(mux, "GET %s/environments/{env}/virtualhosts/{vhost}", s.getVirtualHost)
+	s.handle(mux, "GET %s/environments/{env}/stats/{dimension}", s.getStats)
+
+	// proxies, revisions and deployments
+	s.handle(mux, "GET %s/apis", s.listProxies)
+	s.handle(mux, "GET %s/apis/{proxy}", s.getProxy)
+	s.handle(mux, "GET %s/apis/{proxy}/deployments", s.getDeployments)
+	s.handle(mux, "GET %s/apis/{proxy}/revisions/{rev}", s.getRevision)
+	s.handle(mux, "GET %s/apis/{proxy}/revisions/{rev}/policies/{policy}", s.getPolicy)
+	s.handle(mux, "GET %s/apis/{proxy}/revisions/{rev}/resourcefiles/{type}/{name}", s.getResourceFile)
+
+	// api products
+	s.handle(mux, "GET %s/apiproducts", s.listProducts)
+	s.handle(mux, "POST %s/apiproducts", s.createProduct)
+	s.handle(mux, "GET %s/apiproducts/{product}", s.getProduct)
+
+	// developers, apps and keys
+	s.handle(mux, "GET %s/developers", s.listDevelopers)
+	s.handle(mux, "GET %s/developers/{dev}/apps", s.listApps)
+	s.handle(mux, "POST %s/developers/{dev}/apps", s.createApp)
+	s.handle(mux, "GET %s/developers/{dev}/apps/{app}", s.getApp)
+	s.handle(mux, "PUT %s/developers/{dev}/apps/{app}", s.updateApp)
+	s.handle(mux, "POST %s/developers/{dev}/apps/{app}", s.createKey)
+	s.handle(mux, "DELETE %s/developers/{dev}/apps/{app}", s.deleteApp)
+	s.handle(mux, "GET %s/developers/{dev}/apps/{app}/keys/{key}", s.getKey)
+	s.handle(mux, "POST %s/developers/{dev}/apps/{app}/keys/{key}", s.updateKey)
+	s.handle(mux, "DELETE %s/developers/{dev}/apps/{app}/keys/{key}", s.deleteKey)
+	s.handle(mux, "POST %s/developers/{dev}/apps/{app}/keys/{key}/apiproducts/{product}", s.updateKeyProduct)
+	s.handle(mux, "DELETE %s/developers/{dev}/apps/{app}/keys/{key}/apiproducts/{product}", s.deleteKeyProduct)
+
+	// spec store, served from the data url
+	mux.HandleFunc(fmt.Sprintf("GET /organizations/%s/specs/folder/{id}", Organization), s.authorized(s.getSpecFolder))
+	mux.HandleFunc(fmt.Sprintf("GET /organizations/%s/specs/doc/{id}/content", Organization), s.authorized(s.getSpecContent))
+
+	return mux
+}
+
+// handle - registers an authorized handler for a management api pattern, the organization path is added to the pattern
+func (s *Server) handle(mux *http.ServeMux, pattern string, handler http.HandlerFunc) {
+	mux.HandleFunc(fmt.Sprintf(pattern, s.orgPath), s.authorized(handler))
+}
+
+// authorized - rejects calls without the basic auth credentials or a token issued by the simulator
+func (s *Server) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authType, value, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		valid := false
+		switch authType {
+		case "Basic":
+			username, password, ok := r.BasicAuth()
+			valid = ok && username == Username && password == Password
+		case "Bearer":
+			s.lock.Lock()
+			_, valid = s.accessTokens[value]
+			s.lock.Unlock()
+		}
+
+		if !valid {
+			writeError(w, http.StatusUnauthorized, "oauth.v2.InvalidAccessToken", "Invalid access token")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// token - the oauth token endpoint, issuing tokens for the user password or a refresh token
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != ServerUsername || clientSecret != ServerPassword {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Bad client credentials")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch r.PostForm.Get("grant_type") {
+	case "password":
+		if r.PostForm.Get("username") != Username || r.PostForm.Get("password") != Password {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Bad credentials")
+			return
+		}
+	case "refresh_token":
+		refreshToken := r.PostForm.Get("refresh_token")
+		if _, found := s.refreshTokens[refreshToken]; !found {
+			writeError(w, http.StatusUnauthorized, "invalid_token", "Invalid refresh token")
+			return
+		}
+		delete(s.refreshTokens, refreshToken)
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant type")
+		return
+	}
+
+	accessToken, refreshToken := newToken(), newToken()
+	s.accessTokens[accessToken] = struct{}{}
+	s.refreshTokens[refreshToken] = struct{}{}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  accessToken,
+		"token_type":    "bearer",
+		"refresh_token": refreshToken,
+		"expires_in":    tokenLifetime,
+		"scope":         "scim.emails.read scim.me openid password.write approvals.me scim.ids.read oauth.approvals",
+		"jti":           newToken(),
+	})
+}
+
+// RevokeTokens - invalidates all issued access tokens, the next calls with a token are unauthorized
+func (s *Server) RevokeTokens() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.accessTokens = make(map[string]struct{})
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newID - returns a unique id for an entity created through the api, the lock must be held
+func (s *Server) newID() string {
+	s.nextID++
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("%d-%s", s.nextID, base64.RawURLEncoding.EncodeToString(b))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError - writes the error body apigee edge returns
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"code":     code,
+		"message":  message,
+		"contexts": []string{},
+	})
+}
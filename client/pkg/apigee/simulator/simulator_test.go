@@ -0,0 +1,212 @@
+package simulator
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee"
+	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+)
+
+func newTestClient(t *testing.T, basicAuth bool) (*Server, *apigee.ApigeeClient) {
+	s, err := NewServer()
+	assert.Nil(t, err)
+	t.Cleanup(s.Close)
+
+	cfg := s.Config()
+	cfg.Auth.BasicAuth = basicAuth
+	c, err := apigee.NewClient(cfg)
+	assert.Nil(t, err)
+	assert.Eventually(t, c.IsReady, 10*time.Second, 10*time.Millisecond)
+	return s, c
+}
+
+func TestAuthentication(t *testing.T) {
+	s, c := newTestClient(t, false)
+
+	envs, err := c.GetEnvironments()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"prod", "test"}, envs)
+
+	// the client gets a new token when the current one is no longer accepted
+	s.RevokeTokens()
+	envs, err = c.GetEnvironments()
+	assert.Nil(t, err)
+	assert.Len(t, envs, 2)
+
+	_, basic := newTestClient(t, true)
+	envs, err = basic.GetEnvironments()
+	assert.Nil(t, err)
+	assert.Len(t, envs, 2)
+
+	// calls without valid credentials are rejected
+	cfg := s.Config()
+	cfg.Auth.BasicAuth = true
+	cfg.Auth.Password = "wrong"
+	wrong, err := apigee.NewClient(cfg)
+	assert.Nil(t, err)
+	_, err = wrong.GetEnvironments()
+	apiErr := &apigee.APIError{}
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func TestProxies(t *testing.T) {
+	_, c := newTestClient(t, true)
+
+	proxies, err := c.GetAllProxies()
+	assert.Nil(t, err)
+	assert.Equal(t, apigee.Proxies{"legacy", "orders", "petstore"}, proxies)
+
+	proxy, err := c.GetProxy("orders")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, proxy.Revision)
+
+	_, err = c.GetProxy("unknown")
+	assert.True(t, apigee.IsNotFound(err))
+
+	deployments, err := c.GetDeployments("petstore")
+	assert.Nil(t, err)
+	assert.Len(t, deployments.Environment, 1)
+	assert.Equal(t, "prod", deployments.Environment[0].Name)
+	assert.Equal(t, "1", deployments.Environment[0].Revision[0].Name)
+
+	deployments, err = c.GetDeployments("legacy")
+	assert.Nil(t, err)
+	assert.Empty(t, deployments.Environment)
+
+	revision, err := c.GetRevision("petstore", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Petstore", revision.DisplayName)
+	assert.Equal(t, []string{"verify-api-key", "quota"}, revision.Policies)
+
+	_, err = c.GetRevision("petstore", "9")
+	assert.True(t, apigee.IsNotFound(err))
+
+	connection, err := c.GetRevisionConnectionType("petstore", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "/petstore", connection.BasePath)
+	assert.Equal(t, "secure", connection.VirtualHost)
+
+	proxyBundle, err := c.GetRevisionBundle("petstore", "1")
+	assert.Nil(t, err)
+	assert.Len(t, proxyBundle.ProxyEndpoints, 1)
+	assert.Len(t, proxyBundle.Policies, 2)
+	assert.NotNil(t, proxyBundle.GetResource("openapi", "association.json"))
+
+	// a revision without bundle fixtures has an empty bundle
+	proxyBundle, err = c.GetRevisionBundle("legacy", "1")
+	assert.Nil(t, err)
+	assert.Empty(t, proxyBundle.ProxyEndpoints)
+
+	policy, err := c.GetRevisionPolicyByName("petstore", "1", "verify-api-key")
+	assert.Nil(t, err)
+	assert.Equal(t, "VerifyAPIKey", policy.PolicyType)
+	assert.Equal(t, "Verify API Key", policy.DisplayName)
+
+	_, err = c.GetRevisionPolicyByName("petstore", "1", "missing")
+	assert.True(t, apigee.IsNotFound(err))
+
+	association, err := c.GetRevisionResourceFile("petstore", "1", "openapi", "association.json")
+	assert.Nil(t, err)
+	assert.Contains(t, string(association), "/specs/doc/1/content")
+
+	hosts, err := c.GetAllEnvironmentVirtualHosts("prod")
+	assert.Nil(t, err)
+	assert.Len(t, hosts, 1)
+	assert.Equal(t, []string{"api.example.com"}, hosts[0].HostAliases)
+	assert.NotNil(t, hosts[0].SSLInfo)
+}
+
+func TestSpecsAndStats(t *testing.T) {
+	_, c := newTestClient(t, true)
+
+	// the specs of the sub folders are listed, the trashed spec is skipped
+	specs, err := c.GetAllSpecs()
+	assert.Nil(t, err)
+	assert.Len(t, specs, 2)
+	assert.Equal(t, "petstore", specs[0].Name)
+	assert.Equal(t, "/", specs[0].FolderPath)
+	assert.Equal(t, "orders", specs[1].Name)
+	assert.Equal(t, "/sales/orders", specs[1].FolderPath)
+
+	content, err := c.GetSpecFile(specs[0].ContentLink)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "listPets")
+
+	_, err = c.GetSpecFile("/organizations/org/specs/doc/9/content")
+	assert.True(t, apigee.IsNotFound(err))
+
+	stats, err := c.GetStats("prod", "apiproxy", "sum(message_count)", time.Now().Add(-time.Hour), time.Now())
+	assert.Nil(t, err)
+	assert.Len(t, stats.Environments, 1)
+	assert.Equal(t, "petstore-product", stats.Environments[0].Dimensions[0].Name)
+	assert.Equal(t, "42.0", stats.Environments[0].Dimensions[0].Metrics[0].MetricValues[0].Value)
+
+	stats, err = c.GetStats("test", "apiproxy", "sum(message_count)", time.Now().Add(-time.Hour), time.Now())
+	assert.Nil(t, err)
+	assert.Empty(t, stats.Environments[0].Dimensions)
+}
+
+func TestProductsAndApps(t *testing.T) {
+	s, c := newTestClient(t, true)
+
+	products, err := c.GetProducts()
+	assert.Nil(t, err)
+	assert.Len(t, products, 3)
+
+	_, err = c.CreateAPIProduct(&models.ApiProduct{Name: "orders-gold", Proxies: []string{"orders"}})
+	assert.Nil(t, err)
+	_, err = c.CreateAPIProduct(&models.ApiProduct{Name: "orders-gold"})
+	assert.True(t, apigee.IsConflict(err))
+	product, err := c.GetProduct("orders-gold")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"orders"}, product.Proxies)
+
+	for developer, err := range c.ListDevelopers() {
+		assert.Nil(t, err)
+		assert.Equal(t, DeveloperID, developer)
+	}
+
+	app, err := c.CreateDeveloperApp(models.DeveloperApp{Name: "new-app", DeveloperId: DeveloperID})
+	assert.Nil(t, err)
+	assert.Len(t, app.Credentials, 1)
+	assert.Nil(t, c.RemoveAppCredential("new-app", DeveloperID, app.Credentials[0].ConsumerKey))
+
+	app, err = c.CreateAppCredential("new-app", DeveloperID, []string{"orders-gold"}, 1)
+	assert.Nil(t, err)
+	assert.Len(t, app.Credentials, 1)
+	key := app.Credentials[0].ConsumerKey
+	assert.Greater(t, app.Credentials[0].ExpiresAt, app.Credentials[0].IssuedAt)
+
+	_, err = c.AddCredentialProduct("new-app", DeveloperID, key, apigee.CredentialProvisionRequest{ApiProducts: []string{"orders"}})
+	assert.Nil(t, err)
+	_, err = c.AddCredentialProduct("new-app", DeveloperID, key, apigee.CredentialProvisionRequest{ApiProducts: []string{"unknown"}})
+	assert.NotNil(t, err)
+
+	assert.Nil(t, c.UpdateCredentialProduct("new-app", DeveloperID, key, "orders", false))
+	assert.Nil(t, c.UpdateAppCredential("new-app", DeveloperID, key, false))
+	cred, err := c.GetAppCredential("new-app", DeveloperID, key)
+	assert.Nil(t, err)
+	assert.Equal(t, "revoked", cred.Status)
+	assert.Equal(t, []models.ApiProductRef{{Apiproduct: "orders-gold", Status: "approved"}, {Apiproduct: "orders", Status: "revoked"}}, cred.ApiProducts)
+
+	assert.Nil(t, c.RemoveCredentialProduct("new-app", DeveloperID, key, "orders"))
+	assert.Len(t, s.App("new-app").Credentials[0].ApiProducts, 1)
+
+	app, err = c.GetDeveloperApp("new-app")
+	assert.Nil(t, err)
+	app.Attributes = []models.Attribute{{Name: "team", Value: "orders"}}
+	_, err = c.UpdateDeveloperApp(*app)
+	assert.Nil(t, err)
+	assert.Equal(t, app.Attributes, s.App("new-app").Attributes)
+
+	assert.Nil(t, c.RemoveDeveloperApp("new-app", DeveloperID))
+	assert.Nil(t, s.App("new-app"))
+	_, err = c.GetDeveloperApp("new-app")
+	assert.True(t, apigee.IsNotFound(err))
+}
@@ -0,0 +1,132 @@
+package simulator
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+)
+
+// specDetails - an entry of the spec store, a folder or a document
+type specDetails struct {
+	ID          string        `json:"id"`
+	Kind        string        `json:"kind"`
+	Name        string        `json:"name"`
+	Modified    string        `json:"modified,omitempty"`
+	IsTrashed   bool          `json:"isTrashed"`
+	SelfLink    string        `json:"self"`
+	ContentLink string        `json:"content,omitempty"`
+	Contents    []specDetails `json:"contents,omitempty"`
+	FolderLink  string        `json:"folder,omitempty"`
+	FolderID    string        `json:"folderId,omitempty"`
+}
+
+// specFolders - the ids of the folders the specs are in, and of their parents, by path, the home folder is "/"
+func (s *Server) specFolders() map[string]string {
+	paths := []string{}
+	for _, sp := range s.specs {
+		for folder := specFolder(sp); folder != "/"; folder = path.Dir(folder) {
+			if !slices.Contains(paths, folder) {
+				paths = append(paths, folder)
+			}
+		}
+	}
+	slices.Sort(paths)
+
+	folders := map[string]string{"/": "home"}
+	for i, folder := range paths {
+		folders[folder] = fmt.Sprintf("folder-%d", i+1)
+	}
+	return folders
+}
+
+func specFolder(sp *spec) string {
+	return path.Clean("/" + sp.Folder)
+}
+
+func folderLink(id string) string {
+	return fmt.Sprintf("/organizations/%s/specs/folder/%s", Organization, id)
+}
+
+// getSpecFolder - returns a folder of the spec store with its documents and sub folders
+func (s *Server) getSpecFolder(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	folders := s.specFolders()
+	folderPath := ""
+	for p, id := range folders {
+		if id == r.PathValue("id") {
+			folderPath = p
+		}
+	}
+	if folderPath == "" {
+		writeError(w, http.StatusNotFound, "folder.NotFound", fmt.Sprintf("Folder %s does not exist", r.PathValue("id")))
+		return
+	}
+
+	name := path.Base(folderPath)
+	if folderPath == "/" {
+		name = "/orgs/" + Organization + " root"
+	}
+	selfLink := folderLink(folders[folderPath])
+	folder := specDetails{
+		ID:       folders[folderPath],
+		Kind:     "Folder",
+		Name:     name,
+		SelfLink: selfLink,
+		Contents: []specDetails{},
+	}
+
+	for _, sp := range s.specs {
+		if specFolder(sp) != folderPath {
+			continue
+		}
+		docLink := fmt.Sprintf("/organizations/%s/specs/doc/%s", Organization, sp.ID)
+		folder.Contents = append(folder.Contents, specDetails{
+			ID:          sp.ID,
+			Kind:        "Doc",
+			Name:        sp.Name,
+			Modified:    sp.Modified,
+			IsTrashed:   sp.Trashed,
+			SelfLink:    docLink,
+			ContentLink: docLink + "/content",
+			FolderLink:  selfLink,
+			FolderID:    folder.ID,
+		})
+	}
+
+	subFolders := []string{}
+	for p := range folders {
+		if p != "/" && path.Dir(p) == folderPath {
+			subFolders = append(subFolders, p)
+		}
+	}
+	slices.Sort(subFolders)
+	for _, p := range subFolders {
+		folder.Contents = append(folder.Contents, specDetails{
+			ID:         folders[p],
+			Kind:       "Folder",
+			Name:       path.Base(p),
+			SelfLink:   folderLink(folders[p]),
+			FolderLink: selfLink,
+			FolderID:   folder.ID,
+		})
+	}
+	writeJSON(w, http.StatusOK, folder)
+}
+
+func (s *Server) getSpecContent(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, sp := range s.specs {
+		if sp.ID == r.PathValue("id") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(sp.content)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "spec.NotFound", fmt.Sprintf("Spec %s does not exist", r.PathValue("id")))
+}
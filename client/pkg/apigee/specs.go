@@ -1,15 +1,25 @@
 package apigee
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
+
+	"github.com/Axway/agents-apigee/client/pkg/config"
 )
 
 // GetSpecFile - downloads the specfile from apigee given the path of its location
 func (a *ApigeeClient) GetSpecFile(specPath string) ([]byte, error) {
+	return a.GetSpecFileWithContext(context.Background(), specPath)
+}
+
+// GetSpecFileWithContext - downloads the specfile from apigee given the path of its location, cancelled when the context is done
+func (a *ApigeeClient) GetSpecFileWithContext(ctx context.Context, specPath string) ([]byte, error) {
 	// Get the spec file
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s%s", a.dataURL, specPath),
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s%s", a.dataURL, specPath),
 		WithDefaultHeaders(),
 	).Execute()
 
@@ -17,7 +27,7 @@ func (a *ApigeeClient) GetSpecFile(specPath string) ([]byte, error) {
 		return nil, err
 	}
 	if response.Code != http.StatusOK {
-		return nil, fmt.Errorf("spec file not found at path")
+		return nil, newAPIError(response, "getting the spec file")
 	}
 
 	return response.Body, nil
@@ -25,32 +35,168 @@ func (a *ApigeeClient) GetSpecFile(specPath string) ([]byte, error) {
 
 // GetSpecFromURL - downloads the specfile from a URL outside of APIGEE
 func (a *ApigeeClient) GetSpecFromURL(url string, options ...RequestOption) ([]byte, error) {
+	return a.GetSpecFromURLWithContext(context.Background(), url, options...)
+}
+
+// GetSpecFromURLWithContext - downloads the specfile from a URL outside of APIGEE, cancelled when the context is done
+func (a *ApigeeClient) GetSpecFromURLWithContext(ctx context.Context, url string, options ...RequestOption) ([]byte, error) {
 	// Get the spec file
-	response, err := a.newRequest(http.MethodGet, url, options...).Execute()
+	response, err := a.newRequest(ctx, http.MethodGet, url, options...).Execute()
 
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the spec from url")
+	}
 
 	return response.Body, nil
 }
 
-// GetAllSpecs - downloads the specfile from apigee given the path of its location
+// WithSpecCredentials - returns the option that authenticates the download of a spec from a URL with the named
+// credential profile, the apigee token is never sent to the spec server
+func (a *ApigeeClient) WithSpecCredentials(name string) (RequestOption, error) {
+	creds, found := a.cfg.GetSpecCredentials()[name]
+	if !found {
+		return nil, fmt.Errorf("spec credentials %s are not configured", name)
+	}
+	specClient := a.specClients[name]
+
+	return func(r *apigeeRequest) {
+		if r.headers == nil {
+			r.headers = make(map[string]string)
+		}
+		for key, val := range creds.Headers {
+			r.headers[key] = val
+		}
+		if creds.Token != "" {
+			r.headers["Authorization"] = fmt.Sprintf("Bearer %s", creds.Token)
+		} else if creds.Username != "" {
+			r.headers["Authorization"] = fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)))
+		}
+		if specClient != nil {
+			r.client = specClient
+		}
+		// a rejected profile credential is not replaced with a refreshed apigee token
+		r.refreshAuth = nil
+	}, nil
+}
+
+// SpecValidators - the validators returned with a spec downloaded from a URL, sent with the next download so an
+// unchanged spec is not downloaded again
+type SpecValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// SpecDownload - a spec downloaded from a URL, the content is empty when the spec was not modified
+type SpecDownload struct {
+	Content     []byte
+	Validators  SpecValidators
+	NotModified bool
+}
+
+// GetSpecFromURLIfModifiedWithContext - downloads the specfile from a URL outside of APIGEE unless it has not been
+// modified since the validators were returned, cancelled when the context is done
+func (a *ApigeeClient) GetSpecFromURLIfModifiedWithContext(ctx context.Context, url string, validators SpecValidators, options ...RequestOption) (*SpecDownload, error) {
+	conditions := map[string]string{}
+	if validators.ETag != "" {
+		conditions["If-None-Match"] = validators.ETag
+	}
+	if validators.LastModified != "" {
+		conditions["If-Modified-Since"] = validators.LastModified
+	}
+
+	response, err := a.newRequest(ctx, http.MethodGet, url, append(options, WithHeaders(conditions))...).Execute()
+	if err != nil {
+		return nil, err
+	}
+	if response.Code == http.StatusNotModified {
+		return &SpecDownload{Validators: validators, NotModified: true}, nil
+	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the spec from url")
+	}
+
+	return &SpecDownload{
+		Content: response.Body,
+		Validators: SpecValidators{
+			ETag:         http.Header(response.Headers).Get("ETag"),
+			LastModified: http.Header(response.Headers).Get("Last-Modified"),
+		},
+	}, nil
+}
+
+// GetAllSpecs - returns the specs in the folders of the spec store, the trashed specs and folders, and the folders
+// that can not contain a selected folder, are skipped
 func (a *ApigeeClient) GetAllSpecs() ([]SpecDetails, error) {
-	// Get the spec file
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/organizations/%s/specs/folder/home", a.dataURL, a.cfg.Organization),
+	return a.GetAllSpecsWithContext(context.Background())
+}
+
+// GetAllSpecsWithContext - returns the specs in the folders of the spec store, the trashed specs and folders, and
+// the folders that can not contain a selected folder, are skipped, cancelled when the context is done
+func (a *ApigeeClient) GetAllSpecsWithContext(ctx context.Context) ([]SpecDetails, error) {
+	specs := []SpecDetails{}
+	visited := map[string]bool{}
+	err := a.walkSpecFolder(ctx, fmt.Sprintf("/organizations/%s/specs/folder/home", a.cfg.Organization), "/", a.cfg.GetSpecFolders(), visited, &specs)
+	if err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// walkSpecFolder - adds the specs in the folder, and in each of its sub folders that may contain a selected folder,
+// with the path of their folder
+func (a *ApigeeClient) walkSpecFolder(ctx context.Context, folderLink, folderPath string, folders *config.SpecFolderSelector, visited map[string]bool, specs *[]SpecDetails) error {
+	folder, err := a.getSpecFolder(ctx, folderLink)
+	if err != nil {
+		return err
+	}
+	// a folder is only read once, should the store link a folder from more than one place
+	visited[folderLink] = true
+
+	for _, item := range folder.Contents {
+		if item.IsTrashed {
+			continue
+		}
+
+		if item.Kind != SpecKindFolder {
+			item.FolderPath = folderPath
+			*specs = append(*specs, item)
+			continue
+		}
+
+		link := item.SelfLink
+		if link == "" {
+			link = fmt.Sprintf("/organizations/%s/specs/folder/%s", a.cfg.Organization, item.ID)
+		}
+		subPath := path.Join(folderPath, item.Name)
+		if visited[link] || !folders.IsWalked(subPath) {
+			continue
+		}
+		if err = a.walkSpecFolder(ctx, link, subPath, folders, visited, specs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *ApigeeClient) getSpecFolder(ctx context.Context, folderLink string) (*SpecDetails, error) {
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s%s", a.dataURL, folderLink),
 		WithDefaultHeaders(),
 	).Execute()
 
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the specs")
+	}
 
-	details := SpecDetails{}
-	err = json.Unmarshal(response.Body, &details)
+	folder := &SpecDetails{}
+	err = json.Unmarshal(response.Body, folder)
 	if err != nil {
 		return nil, err
 	}
-
-	return details.Contents, nil
+	return folder, nil
 }
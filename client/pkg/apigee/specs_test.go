@@ -0,0 +1,123 @@
+package apigee
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Axway/agent-sdk/pkg/api"
+	"github.com/Axway/agents-apigee/client/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSpecFromURLIfModified(t *testing.T) {
+	const etag = `"v1"`
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte(`{"openapi": "3.0.1"}`))
+	}))
+	defer server.Close()
+
+	c := createTestClient(t, nil)
+	c.apiClient, _ = newHTTPClient(c.cfg)
+
+	// the first download returns the content and its validators
+	download, err := c.GetSpecFromURLIfModifiedWithContext(context.Background(), server.URL, SpecValidators{})
+	assert.Nil(t, err)
+	assert.False(t, download.NotModified)
+	assert.Equal(t, `{"openapi": "3.0.1"}`, string(download.Content))
+	assert.Equal(t, SpecValidators{ETag: etag, LastModified: lastModified}, download.Validators)
+
+	// an unchanged spec is not downloaded again
+	download, err = c.GetSpecFromURLIfModifiedWithContext(context.Background(), server.URL, download.Validators)
+	assert.Nil(t, err)
+	assert.True(t, download.NotModified)
+	assert.Empty(t, download.Content)
+	assert.Equal(t, etag, download.Validators.ETag)
+}
+
+func TestWithSpecCredentials(t *testing.T) {
+	received := http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	c := createTestClient(t, nil)
+	c.apiClient, _ = newHTTPClient(c.cfg)
+	c.cfg.Specs = &config.ApigeeSpecConfig{
+		Credentials: map[string]*config.SpecCredentials{
+			"git":   {Name: "git", Token: "abc", Headers: map[string]string{"X-Tenant": "sales"}},
+			"nexus": {Name: "nexus", Username: "user", Password: "pass", Headers: map[string]string{}},
+		},
+	}
+	_, err := c.WithSpecCredentials("other")
+	assert.NotNil(t, err)
+
+	option, err := c.WithSpecCredentials("git")
+	assert.Nil(t, err)
+	request := c.newRequest(context.Background(), http.MethodGet, server.URL, option)
+	// a rejected request is not replayed with a refreshed apigee token
+	assert.Nil(t, request.refreshAuth)
+	response, err := request.Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	assert.Equal(t, "Bearer abc", received.Get("Authorization"))
+	assert.Equal(t, "sales", received.Get("X-Tenant"))
+
+	option, err = c.WithSpecCredentials("nexus")
+	assert.Nil(t, err)
+	_, err = c.GetSpecFromURLIfModifiedWithContext(context.Background(), server.URL, SpecValidators{}, option)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Basic dXNlcjpwYXNz", received.Get("Authorization"))
+}
+
+func TestWalkSpecFolderSelected(t *testing.T) {
+	mockClient := &api.MockHTTPClient{
+		Responses: []api.MockResponse{
+			{
+				RespCode: http.StatusOK,
+				RespData: `{"id": "home", "kind": "Folder", "contents": [
+					{"id": "1", "kind": "Doc", "name": "root"},
+					{"id": "sales", "kind": "Folder", "name": "sales"},
+					{"id": "marketing", "kind": "Folder", "name": "marketing"}
+				]}`,
+			},
+			{
+				RespCode: http.StatusOK,
+				RespData: `{"id": "sales", "kind": "Folder", "contents": [
+					{"id": "2", "kind": "Doc", "name": "orders"},
+					{"id": "public", "kind": "Folder", "name": "public"}
+				]}`,
+			},
+			{
+				RespCode: http.StatusOK,
+				RespData: `{"id": "public", "kind": "Folder", "contents": [{"id": "3", "kind": "Doc", "name": "catalog"}]}`,
+			},
+		},
+	}
+	c := createTestClient(t, mockClient)
+	folders, err := config.NewSpecFolderSelector("/sales/public")
+	assert.Nil(t, err)
+
+	// the marketing folder can not contain the selected folder, it is not read
+	specs := []SpecDetails{}
+	err = c.walkSpecFolder(context.Background(), "/organizations/org/specs/folder/home", "/", folders, map[string]bool{}, &specs)
+	assert.Nil(t, err)
+	assert.Len(t, mockClient.Requests, 3)
+	assert.Equal(t, "http://data.com/organizations/org/specs/folder/public", mockClient.Requests[2].URL)
+
+	paths := []string{}
+	for _, spec := range specs {
+		paths = append(paths, spec.FolderPath+":"+spec.Name)
+	}
+	assert.Equal(t, []string{"/:root", "/sales:orders", "/sales/public:catalog"}, paths)
+}
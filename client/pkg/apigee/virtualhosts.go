@@ -1,6 +1,7 @@
 package apigee
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -11,13 +12,21 @@ import (
 
 // GetAllVirtualHosts - returns an array of all virtual hosts defined
 func (a *ApigeeClient) GetAllEnvironmentVirtualHosts(envName string) ([]*models.VirtualHost, error) {
+	return a.GetAllEnvironmentVirtualHostsWithContext(context.Background(), envName)
+}
+
+// GetAllEnvironmentVirtualHostsWithContext - returns an array of all virtual hosts defined, cancelled when the context is done
+func (a *ApigeeClient) GetAllEnvironmentVirtualHostsWithContext(ctx context.Context, envName string) ([]*models.VirtualHost, error) {
 	// Get the spec file
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/environments/%s", a.orgURL, envName),
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/environments/%s", a.orgURL, envName),
 		WithDefaultHeaders(),
 	).Execute()
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the environment")
+	}
 
 	hosts := VirtualHosts{}
 	err = json.Unmarshal(response.Body, &hosts)
@@ -32,7 +41,7 @@ func (a *ApigeeClient) GetAllEnvironmentVirtualHosts(envName string) ([]*models.
 		wg.Add(1)
 		go func(host string) {
 			defer wg.Done()
-			vh, err := a.GetVirtualHost(envName, host)
+			vh, err := a.GetVirtualHostWithContext(ctx, envName, host)
 			if err != nil {
 				return
 			}
@@ -48,13 +57,21 @@ func (a *ApigeeClient) GetAllEnvironmentVirtualHosts(envName string) ([]*models.
 
 // GetAllVirtualHosts - returns an array of all virtual hosts defined
 func (a *ApigeeClient) GetVirtualHost(envName, virtualHostName string) (*models.VirtualHost, error) {
+	return a.GetVirtualHostWithContext(context.Background(), envName, virtualHostName)
+}
+
+// GetVirtualHostWithContext - returns the named virtual host of the environment, cancelled when the context is done
+func (a *ApigeeClient) GetVirtualHostWithContext(ctx context.Context, envName, virtualHostName string) (*models.VirtualHost, error) {
 	// Get the spec file
-	response, err := a.newRequest(http.MethodGet, fmt.Sprintf("%s/environments/%s/virtualhosts/%s", a.orgURL, envName, virtualHostName),
+	response, err := a.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/environments/%s/virtualhosts/%s", a.orgURL, envName, virtualHostName),
 		WithDefaultHeaders(),
 	).Execute()
 	if err != nil {
 		return nil, err
 	}
+	if response.Code != http.StatusOK {
+		return nil, newAPIError(response, "getting the virtual host")
+	}
 
 	virtualHost := &models.VirtualHost{}
 	err = json.Unmarshal(response.Body, &virtualHost)
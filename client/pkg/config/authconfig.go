@@ -8,6 +8,11 @@ type AuthConfig struct {
 	Username       string `config:"username"`
 	Password       string `config:"password"`
 	BasicAuth      bool   `config:"useBasicAuth"`
+	Token          string `config:"token"`
+	ServiceAccount string `config:"serviceAccount"`
+	Passcode       string `config:"passcode"`
+	MFASeed        string `config:"mfaSeed"`
+	TokenFile      string `config:"tokenFile"`
 }
 
 // GetServerUsername - Returns the APIGEE auth server username
@@ -39,3 +44,28 @@ func (a *AuthConfig) GetPassword() string {
 func (a *AuthConfig) UseBasicAuth() bool {
 	return a.BasicAuth
 }
+
+// GetToken - Returns the Google OAuth access token used for APIGEE X and hybrid
+func (a *AuthConfig) GetToken() string {
+	return a.Token
+}
+
+// GetServiceAccount - Returns the path to the Google service account key file used for APIGEE X and hybrid
+func (a *AuthConfig) GetServiceAccount() string {
+	return a.ServiceAccount
+}
+
+// GetPasscode - Returns the one-time passcode used to get the initial APIGEE refresh token for SSO users
+func (a *AuthConfig) GetPasscode() string {
+	return a.Passcode
+}
+
+// GetMFASeed - Returns the base32 seed used to generate the APIGEE MFA token
+func (a *AuthConfig) GetMFASeed() string {
+	return a.MFASeed
+}
+
+// GetTokenFile - Returns the path to the file the APIGEE refresh token is saved in
+func (a *AuthConfig) GetTokenFile() string {
+	return a.TokenFile
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
@@ -18,6 +20,8 @@ type props interface {
 	IntPropertyValue(name string) int
 	BoolPropertyValue(name string) bool
 	DurationPropertyValue(name string) time.Duration
+	AddObjectSliceProperty(envPrefix string, intfPropertyNames []string, options ...properties.ObjectOpt)
+	ObjectSlicePropertyValue(name string) []map[string]interface{}
 }
 
 func NewApigeeConfig() *ApigeeConfig {
@@ -26,39 +30,61 @@ func NewApigeeConfig() *ApigeeConfig {
 		Intervals: &ApigeeIntervals{},
 		Workers:   &ApigeeWorkers{},
 		Specs:     &ApigeeSpecConfig{},
+		Retry:     &ApigeeRetry{},
+		TLS:       &ApigeeTLS{},
 	}
 }
 
 // ApigeeConfig - represents the config for gateway
 type ApigeeConfig struct {
 	corecfg.IConfigValidator
-	Organization    string            `config:"organization"`
-	Environment     string            `config:"environment"`
-	URL             string            `config:"url"`
-	DataURL         string            `config:"dataURL"`
-	APIVersion      string            `config:"apiVersion"`
-	Filter          string            `config:"filter"`
-	DeveloperID     string            `config:"developerID"`
-	Auth            *AuthConfig       `config:"auth"`
-	Intervals       *ApigeeIntervals  `config:"interval"`
-	Workers         *ApigeeWorkers    `config:"workers"`
-	Specs           *ApigeeSpecConfig `config:"specs"`
-	CloneAttributes bool              `config:"cloneAttributes"`
-	AllTraffic      bool              `config:"allTraffic"`
-	NotSetTraffic   bool              `config:"notSetTraffic"`
-	FilteredAPIs    []string          `config:"filteredAPIs"`
-	FilterMetrics   bool              `config:"filterMetrics"`
-	mode            discoveryMode
+	Platform         string            `config:"platform"`
+	Organization     string            `config:"organization"`
+	Environment      string            `config:"environment"`
+	EnvironmentExcl  string            `config:"environmentExclude"`
+	EnvironmentMap   string            `config:"environmentMapping"`
+	URL              string            `config:"url"`
+	DataURL          string            `config:"dataURL"`
+	APIVersion       string            `config:"apiVersion"`
+	Filter           string            `config:"filter"`
+	DeveloperID      string            `config:"developerID"`
+	Auth             *AuthConfig       `config:"auth"`
+	Intervals        *ApigeeIntervals  `config:"interval"`
+	Workers          *ApigeeWorkers    `config:"workers"`
+	Specs            *ApigeeSpecConfig `config:"specs"`
+	Retry            *ApigeeRetry      `config:"retry"`
+	RequestTimeout   time.Duration     `config:"requestTimeout"`
+	ProxyURL         string            `config:"proxyURL"`
+	TLS              *ApigeeTLS        `config:"tls"`
+	CloneAttributes  bool              `config:"cloneAttributes"`
+	AllTraffic       bool              `config:"allTraffic"`
+	NotSetTraffic    bool              `config:"notSetTraffic"`
+	FilteredAPIs     []string          `config:"filteredAPIs"`
+	FilterMetrics    bool              `config:"filterMetrics"`
+	RemovalGrace     time.Duration     `config:"removalGracePeriod"`
+	RevisionStrategy string            `config:"revisionStrategy"`
+	mode             discoveryMode
+	environments     *EnvironmentSelector
+	environmentsErr  error
+	specFolders      *SpecFolderSelector
+	specFoldersErr   error
+	specCredsErr     error
 }
 
 // ApigeeWorkers - number of workers for the apigee agent to use
 type ApigeeSpecConfig struct {
 	DisablePollForSpecs bool   `config:"disablePollForSpecs"`
 	Unstructured        bool   `config:"unstructured"`
+	GenerateFromFlows   bool   `config:"generateFromFlows"`
 	MatchOnURL          bool   `config:"matchOnURL"`
 	LocalPath           string `config:"localDirectory"`
 	SpecExtensions      string `config:"extensions"`
 	Extensions          []string
+	Folders             string        `config:"folders"`
+	CacheDirectory      string        `config:"cacheDirectory"`
+	Watch               string        `config:"watch"`
+	WatchInterval       time.Duration `config:"watchInterval"`
+	Credentials         map[string]*SpecCredentials
 }
 
 // ApigeeIntervals - intervals for the apigee agent to use
@@ -76,6 +102,22 @@ type ApigeeWorkers struct {
 	Product int `config:"product"`
 }
 
+// ApigeeRetry - retry and rate limit settings for the apigee api calls
+type ApigeeRetry struct {
+	MaxRetries     int           `config:"maxRetries"`
+	InitialBackoff time.Duration `config:"initialBackoff"`
+	MaxBackoff     time.Duration `config:"maxBackoff"`
+	RateLimit      int           `config:"rateLimit"`
+}
+
+// ApigeeTLS - tls settings for the apigee api calls, a private ca and a client certificate for mutual tls
+type ApigeeTLS struct {
+	InsecureSkipVerify bool   `config:"insecureSkipVerify"`
+	RootCACertPath     string `config:"rootCACertPath"`
+	ClientCertPath     string `config:"clientCertPath"`
+	ClientKeyPath      string `config:"clientKeyPath"`
+}
+
 type discoveryMode int
 
 const (
@@ -105,23 +147,84 @@ func stringToDiscoveryMode(s string) discoveryMode {
 	return 0
 }
 
+// revision strategies, publish the latest deployed revision or each deployed revision of a proxy
+const (
+	RevisionStrategyLatest = "latest"
+	RevisionStrategyAll    = "all"
+)
+
+// spec watch modes, how changes to the spec files in the local directory are noticed
+const (
+	SpecWatchNotify = "notify"
+	SpecWatchPoll   = "poll"
+	SpecWatchOff    = "off"
+)
+
+type platformMode int
+
+const (
+	platformEdge = iota + 1
+	platformX
+	platformHybrid
+)
+
+const (
+	platformEdgeString   = "edge"
+	platformXString      = "x"
+	platformHybridString = "hybrid"
+)
+
+func (m platformMode) String() string {
+	return map[platformMode]string{
+		platformEdge:   platformEdgeString,
+		platformX:      platformXString,
+		platformHybrid: platformHybridString,
+	}[m]
+}
+
+func stringToPlatformMode(s string) platformMode {
+	if mode, ok := map[string]platformMode{
+		platformEdgeString:   platformEdge,
+		platformXString:      platformX,
+		platformHybridString: platformHybrid,
+	}[strings.ToLower(s)]; ok {
+		return mode
+	}
+	return 0
+}
+
+const (
+	defaultEdgeURL = "https://api.enterprise.apigee.com"
+	defaultXURL    = "https://apigee.googleapis.com"
+)
+
 const (
 	pathURL                     = "apigee.url"
 	pathDataURL                 = "apigee.dataURL"
 	pathAPIVersion              = "apigee.apiVersion"
 	pathOrganization            = "apigee.organization"
 	pathEnvironment             = "apigee.environment"
+	pathEnvironmentExclude      = "apigee.environmentExclude"
+	pathEnvironmentMapping      = "apigee.environmentMapping"
 	pathMode                    = "apigee.discoveryMode"
+	pathPlatform                = "apigee.platform"
 	pathFilter                  = "apigee.filter"
 	pathCloneAttributes         = "apigee.cloneAttributes"
 	pathAllTraffic              = "apigee.allTraffic"
 	pathNotSetTraffic           = "apigee.notSetTraffic"
+	pathRemovalGracePeriod      = "apigee.removalGracePeriod"
+	pathRevisionStrategy        = "apigee.revisionStrategy"
 	pathAuthURL                 = "apigee.auth.url"
 	pathAuthServerUsername      = "apigee.auth.serverUsername"
 	pathAuthServerPassword      = "apigee.auth.serverPassword"
 	pathAuthUsername            = "apigee.auth.username"
 	pathAuthPassword            = "apigee.auth.password"
 	pathAuthBasicAuth           = "apigee.auth.useBasicAuth"
+	pathAuthToken               = "apigee.auth.token"
+	pathAuthServiceAccount      = "apigee.auth.serviceAccount"
+	pathAuthPasscode            = "apigee.auth.passcode"
+	pathAuthMFASeed             = "apigee.auth.mfaSeed"
+	pathAuthTokenFile           = "apigee.auth.tokenFile"
 	pathSpecInterval            = "apigee.interval.spec"
 	pathProxyInterval           = "apigee.interval.proxy"
 	pathProductInterval         = "apigee.interval.product"
@@ -130,19 +233,38 @@ const (
 	pathSpecWorkers             = "apigee.workers.spec"
 	pathProxyWorkers            = "apigee.workers.proxy"
 	pathProductWorkers          = "apigee.workers.product"
+	pathRetryMax                = "apigee.retry.maxRetries"
+	pathRetryInitialBackoff     = "apigee.retry.initialBackoff"
+	pathRetryMaxBackoff         = "apigee.retry.maxBackoff"
+	pathRetryRateLimit          = "apigee.retry.rateLimit"
+	pathRequestTimeout          = "apigee.requestTimeout"
+	pathProxyURL                = "apigee.proxyURL"
+	pathTLSInsecureSkipVerify   = "apigee.tls.insecureSkipVerify"
+	pathTLSRootCACertPath       = "apigee.tls.rootCACertPath"
+	pathTLSClientCertPath       = "apigee.tls.clientCertPath"
+	pathTLSClientKeyPath        = "apigee.tls.clientKeyPath"
 	pathSpecMatchOnURL          = "apigee.specConfig.matchOnURL"
 	pathSpecLocalPath           = "apigee.specConfig.localPath"
 	pathSpecExtensions          = "apigee.specConfig.extensions"
 	pathSpecUnstructured        = "apigee.specConfig.unstructured"
+	pathSpecGenerateFromFlows   = "apigee.specConfig.generateFromFlows"
 	pathSpecDisablePollForSpecs = "apigee.specConfig.disablePollForSpecs"
+	pathSpecFolders             = "apigee.specConfig.folders"
+	pathSpecCacheDirectory      = "apigee.specConfig.cacheDirectory"
+	pathSpecCredentials         = "apigee.specConfig.credentials"
+	pathSpecWatch               = "apigee.specConfig.watch"
+	pathSpecWatchInterval       = "apigee.specConfig.watchInterval"
 )
 
 // AddProperties - adds config needed for apigee client
 func AddProperties(rootProps props) {
 	rootProps.AddStringProperty(pathMode, "proxy", "APIGEE Organization")
+	rootProps.AddStringProperty(pathPlatform, "edge", "APIGEE Platform, edge, x, or hybrid")
 	rootProps.AddStringProperty(pathOrganization, "", "APIGEE Organization")
-	rootProps.AddStringProperty(pathEnvironment, "", "APIGEE Environment to discover resources from and track usages of")
-	rootProps.AddStringProperty(pathURL, "https://api.enterprise.apigee.com", "APIGEE Base URL")
+	rootProps.AddStringProperty(pathEnvironment, "", "Comma separated APIGEE Environments, globs or /regular expressions/, to discover resources from and track usages of, all when not set")
+	rootProps.AddStringProperty(pathEnvironmentExclude, "", "Comma separated APIGEE Environments, globs or /regular expressions/, to skip")
+	rootProps.AddStringProperty(pathEnvironmentMapping, "", "Comma separated environment=stage pairs, the stage name an APIGEE Environment is displayed as in Central")
+	rootProps.AddStringProperty(pathURL, defaultEdgeURL, "APIGEE Base URL")
 	rootProps.AddStringProperty(pathAPIVersion, "v1", "APIGEE API Version")
 	rootProps.AddStringProperty(pathFilter, "", "Filter used on discovering Apigee products")
 	rootProps.AddStringProperty(pathDataURL, "https://apigee.com/dapi/api", "APIGEE Data API URL")
@@ -152,9 +274,16 @@ func AddProperties(rootProps props) {
 	rootProps.AddStringProperty(pathAuthUsername, "", "Username to use to authenticate to APIGEE")
 	rootProps.AddStringProperty(pathAuthPassword, "", "Password for the user to authenticate to APIGEE")
 	rootProps.AddBoolProperty(pathAuthBasicAuth, false, "Set to true to use basic authentication to authenticate to APIGEE")
+	rootProps.AddStringProperty(pathAuthToken, "", "Google OAuth access token used to authenticate to APIGEE X or hybrid")
+	rootProps.AddStringProperty(pathAuthServiceAccount, "", "Path to the Google service account JSON key file used to authenticate to APIGEE X or hybrid")
+	rootProps.AddStringProperty(pathAuthPasscode, "", "One-time passcode used to get the initial refresh token when the APIGEE user authenticates with SSO")
+	rootProps.AddStringProperty(pathAuthMFASeed, "", "Base32 seed used to generate the MFA token when the APIGEE user has MFA enabled")
+	rootProps.AddStringProperty(pathAuthTokenFile, "", "Path to the file the APIGEE refresh token is saved in, so it is reused on restart")
 	rootProps.AddBoolProperty(pathCloneAttributes, false, "Set to true to copy the tags when provisioning a Product in product mode")
 	rootProps.AddBoolProperty(pathAllTraffic, false, "Set to true to report metrics for all traffic for the selected mode")
 	rootProps.AddBoolProperty(pathNotSetTraffic, false, "Set to true to report metrics for values reported with (not set) ast the name")
+	rootProps.AddStringProperty(pathRevisionStrategy, RevisionStrategyLatest, "Publish the latest deployed revision (latest) or a Central revision for each deployed revision (all) of a proxy, only in proxy mode")
+	rootProps.AddDurationProperty(pathRemovalGracePeriod, 24*time.Hour, "The time a service that is no longer deployed on APIGEE is kept as deprecated before it is removed, 0 removes it right away")
 	rootProps.AddDurationProperty(pathSpecInterval, 30*time.Minute, "The time interval between checking for updated specs", properties.WithLowerLimit(1*time.Minute))
 	rootProps.AddDurationProperty(pathProxyInterval, 30*time.Second, "The time interval between checking for updated proxies", properties.WithUpperLimit(5*time.Minute))
 	rootProps.AddDurationProperty(pathProductInterval, 30*time.Second, "The time interval between checking for updated products", properties.WithUpperLimit(5*time.Minute))
@@ -163,11 +292,27 @@ func AddProperties(rootProps props) {
 	rootProps.AddIntProperty(pathProxyWorkers, 10, "Max number of workers discovering proxies")
 	rootProps.AddIntProperty(pathSpecWorkers, 20, "Max number of workers discovering specs")
 	rootProps.AddIntProperty(pathProductWorkers, 10, "Max number of workers discovering products")
+	rootProps.AddIntProperty(pathRetryMax, 3, "Max number of times a throttled or failed APIGEE api call is retried, 0 disables retries")
+	rootProps.AddDurationProperty(pathRetryInitialBackoff, 1*time.Second, "The time to wait before the first retry, doubled for each following retry")
+	rootProps.AddDurationProperty(pathRetryMaxBackoff, 30*time.Second, "The longest time to wait between retries")
+	rootProps.AddIntProperty(pathRetryRateLimit, 20, "Max number of APIGEE api calls per second across all workers, 0 disables the limit")
+	rootProps.AddDurationProperty(pathRequestTimeout, 60*time.Second, "The time to wait for a single APIGEE api call before cancelling it, 0 disables the timeout")
+	rootProps.AddStringProperty(pathProxyURL, "", "The proxy URL used for all calls to APIGEE, including authentication")
+	rootProps.AddBoolProperty(pathTLSInsecureSkipVerify, false, "Set to true to skip verifying the APIGEE server certificate")
+	rootProps.AddStringProperty(pathTLSRootCACertPath, "", "Path to a PEM file with the CA certificates used to verify the APIGEE server certificate")
+	rootProps.AddStringProperty(pathTLSClientCertPath, "", "Path to the PEM client certificate presented to APIGEE for mutual TLS")
+	rootProps.AddStringProperty(pathTLSClientKeyPath, "", "Path to the PEM private key of the client certificate presented to APIGEE")
 	rootProps.AddBoolProperty(pathSpecMatchOnURL, true, "Set to false to skip matching spec URLs to proxy URLs")
 	rootProps.AddStringProperty(pathSpecLocalPath, "", "Path to a local directory that contains the spec files")
-	rootProps.AddStringProperty(pathSpecExtensions, "json,yaml,yml", "Comma separated list of spec file extensions, needed for proxy mode")
+	rootProps.AddStringProperty(pathSpecExtensions, "json,yaml,yml,wsdl,graphql,proto", "Comma separated list of spec file extensions, needed for proxy mode")
 	rootProps.AddBoolProperty(pathSpecUnstructured, false, "Set to true to enable discovering apis that have no associated spec")
+	rootProps.AddBoolProperty(pathSpecGenerateFromFlows, false, "Set to true to generate an OpenAPI spec from the conditional flows of proxies that have no associated spec")
 	rootProps.AddBoolProperty(pathSpecDisablePollForSpecs, false, "Set to true to disable polling apigee for specs, rely on the local directory or spec URLs")
+	rootProps.AddStringProperty(pathSpecFolders, "", "Comma separated spec store folder paths, globs or /regular expressions/, to discover specs in, with their sub folders, all when not set")
+	rootProps.AddStringProperty(pathSpecCacheDirectory, "", "Path to a directory the downloaded spec contents are cached in across restarts, kept in memory only when not set")
+	rootProps.AddObjectSliceProperty(pathSpecCredentials, specCredentialsProperties)
+	rootProps.AddStringProperty(pathSpecWatch, SpecWatchNotify, "How changes to the spec files in the local directory republish their apis: file system notifications (notify), falling back to polling when unavailable, polling (poll) or not at all (off)")
+	rootProps.AddDurationProperty(pathSpecWatchInterval, 30*time.Second, "The time interval between checking the local directory for changed spec files, when polling", properties.WithLowerLimit(1*time.Second))
 }
 
 // ParseConfig - parse the config on startup
@@ -177,18 +322,46 @@ func ParseConfig(rootProps props) *ApigeeConfig {
 	for _, e := range strings.Split(specExtensions, ",") {
 		extensions = append(extensions, strings.TrimSpace(e))
 	}
+
+	platform := stringToPlatformMode(rootProps.StringPropertyValue(pathPlatform))
+	url := strings.TrimSuffix(rootProps.StringPropertyValue(pathURL), "/")
+	if (platform == platformX || platform == platformHybrid) && url == defaultEdgeURL {
+		// the edge url is the default, switch to the google management api
+		url = defaultXURL
+	}
+
+	environment := rootProps.StringPropertyValue(pathEnvironment)
+	environmentExcl := rootProps.StringPropertyValue(pathEnvironmentExclude)
+	environmentMap := rootProps.StringPropertyValue(pathEnvironmentMapping)
+	environments, environmentsErr := NewEnvironmentSelector(environment, environmentExcl, environmentMap)
+
+	specFolders := rootProps.StringPropertyValue(pathSpecFolders)
+	specFolderSelector, specFoldersErr := NewSpecFolderSelector(specFolders)
+
+	specCredentials, specCredsErr := parseSpecCredentials(rootProps.ObjectSlicePropertyValue(pathSpecCredentials))
+
 	return &ApigeeConfig{
-		Organization:    rootProps.StringPropertyValue(pathOrganization),
-		Environment:     rootProps.StringPropertyValue(pathEnvironment),
-		URL:             strings.TrimSuffix(rootProps.StringPropertyValue(pathURL), "/"),
-		APIVersion:      rootProps.StringPropertyValue(pathAPIVersion),
-		DataURL:         strings.TrimSuffix(rootProps.StringPropertyValue(pathDataURL), "/"),
-		DeveloperID:     rootProps.StringPropertyValue(pathDeveloper),
-		mode:            stringToDiscoveryMode(rootProps.StringPropertyValue(pathMode)),
-		Filter:          rootProps.StringPropertyValue(pathFilter),
-		CloneAttributes: rootProps.BoolPropertyValue(pathCloneAttributes),
-		AllTraffic:      rootProps.BoolPropertyValue(pathAllTraffic),
-		NotSetTraffic:   rootProps.BoolPropertyValue(pathNotSetTraffic),
+		Platform:         platform.String(),
+		Organization:     rootProps.StringPropertyValue(pathOrganization),
+		Environment:      environment,
+		EnvironmentExcl:  environmentExcl,
+		EnvironmentMap:   environmentMap,
+		environments:     environments,
+		environmentsErr:  environmentsErr,
+		specFolders:      specFolderSelector,
+		specFoldersErr:   specFoldersErr,
+		specCredsErr:     specCredsErr,
+		URL:              url,
+		APIVersion:       rootProps.StringPropertyValue(pathAPIVersion),
+		DataURL:          strings.TrimSuffix(rootProps.StringPropertyValue(pathDataURL), "/"),
+		DeveloperID:      rootProps.StringPropertyValue(pathDeveloper),
+		mode:             stringToDiscoveryMode(rootProps.StringPropertyValue(pathMode)),
+		Filter:           rootProps.StringPropertyValue(pathFilter),
+		CloneAttributes:  rootProps.BoolPropertyValue(pathCloneAttributes),
+		AllTraffic:       rootProps.BoolPropertyValue(pathAllTraffic),
+		NotSetTraffic:    rootProps.BoolPropertyValue(pathNotSetTraffic),
+		RemovalGrace:     rootProps.DurationPropertyValue(pathRemovalGracePeriod),
+		RevisionStrategy: strings.ToLower(rootProps.StringPropertyValue(pathRevisionStrategy)),
 		Intervals: &ApigeeIntervals{
 			Stats:   rootProps.DurationPropertyValue(pathStatsInterval),
 			Proxy:   rootProps.DurationPropertyValue(pathProxyInterval),
@@ -200,6 +373,20 @@ func ParseConfig(rootProps props) *ApigeeConfig {
 			Spec:    rootProps.IntPropertyValue(pathSpecWorkers),
 			Product: rootProps.IntPropertyValue(pathProductWorkers),
 		},
+		Retry: &ApigeeRetry{
+			MaxRetries:     rootProps.IntPropertyValue(pathRetryMax),
+			InitialBackoff: rootProps.DurationPropertyValue(pathRetryInitialBackoff),
+			MaxBackoff:     rootProps.DurationPropertyValue(pathRetryMaxBackoff),
+			RateLimit:      rootProps.IntPropertyValue(pathRetryRateLimit),
+		},
+		RequestTimeout: rootProps.DurationPropertyValue(pathRequestTimeout),
+		ProxyURL:       rootProps.StringPropertyValue(pathProxyURL),
+		TLS: &ApigeeTLS{
+			InsecureSkipVerify: rootProps.BoolPropertyValue(pathTLSInsecureSkipVerify),
+			RootCACertPath:     rootProps.StringPropertyValue(pathTLSRootCACertPath),
+			ClientCertPath:     rootProps.StringPropertyValue(pathTLSClientCertPath),
+			ClientKeyPath:      rootProps.StringPropertyValue(pathTLSClientKeyPath),
+		},
 		Auth: &AuthConfig{
 			Username:       rootProps.StringPropertyValue(pathAuthUsername),
 			Password:       rootProps.StringPropertyValue(pathAuthPassword),
@@ -207,14 +394,25 @@ func ParseConfig(rootProps props) *ApigeeConfig {
 			ServerPassword: rootProps.StringPropertyValue(pathAuthServerPassword),
 			URL:            rootProps.StringPropertyValue(pathAuthURL),
 			BasicAuth:      rootProps.BoolPropertyValue(pathAuthBasicAuth),
+			Token:          rootProps.StringPropertyValue(pathAuthToken),
+			ServiceAccount: rootProps.StringPropertyValue(pathAuthServiceAccount),
+			Passcode:       rootProps.StringPropertyValue(pathAuthPasscode),
+			MFASeed:        rootProps.StringPropertyValue(pathAuthMFASeed),
+			TokenFile:      rootProps.StringPropertyValue(pathAuthTokenFile),
 		},
 		Specs: &ApigeeSpecConfig{
 			MatchOnURL:          rootProps.BoolPropertyValue(pathSpecMatchOnURL),
 			LocalPath:           rootProps.StringPropertyValue(pathSpecLocalPath),
 			DisablePollForSpecs: rootProps.BoolPropertyValue(pathSpecDisablePollForSpecs),
 			Unstructured:        rootProps.BoolPropertyValue(pathSpecUnstructured),
+			GenerateFromFlows:   rootProps.BoolPropertyValue(pathSpecGenerateFromFlows),
 			SpecExtensions:      specExtensions,
 			Extensions:          extensions,
+			Folders:             specFolders,
+			CacheDirectory:      rootProps.StringPropertyValue(pathSpecCacheDirectory),
+			Watch:               strings.ToLower(rootProps.StringPropertyValue(pathSpecWatch)),
+			WatchInterval:       rootProps.DurationPropertyValue(pathSpecWatchInterval),
+			Credentials:         specCredentials,
 		},
 	}
 }
@@ -225,6 +423,26 @@ func (a *ApigeeConfig) ValidateCfg() (err error) {
 		return errors.New("invalid APIGEE configuration: discoveryMode must be proxy or product")
 	}
 
+	if stringToPlatformMode(a.Platform) == 0 {
+		return errors.New("invalid APIGEE configuration: platform must be edge, x, or hybrid")
+	}
+
+	if a.environmentsErr != nil {
+		return fmt.Errorf("invalid APIGEE configuration: %s", a.environmentsErr)
+	}
+
+	if a.specFoldersErr != nil {
+		return fmt.Errorf("invalid APIGEE configuration: %s", a.specFoldersErr)
+	}
+
+	if a.specCredsErr != nil {
+		return fmt.Errorf("invalid APIGEE configuration: %s", a.specCredsErr)
+	}
+
+	if a.Specs != nil && a.Specs.Watch != "" && a.Specs.Watch != SpecWatchNotify && a.Specs.Watch != SpecWatchPoll && a.Specs.Watch != SpecWatchOff {
+		return errors.New("invalid APIGEE configuration: spec watch must be notify, poll or off")
+	}
+
 	if a.URL == "" {
 		return errors.New("invalid APIGEE configuration: url is not configured")
 	}
@@ -237,12 +455,8 @@ func (a *ApigeeConfig) ValidateCfg() (err error) {
 		return errors.New("invalid APIGEE configuration: data url is not configured")
 	}
 
-	if a.Auth == nil || a.Auth.Username == "" {
-		return errors.New("invalid APIGEE configuration: username is not configured")
-	}
-
-	if a.Auth == nil || a.Auth.Password == "" {
-		return errors.New("invalid APIGEE configuration: password is not configured")
+	if err = a.validateAuth(); err != nil {
+		return err
 	}
 
 	if a.DeveloperID == "" {
@@ -257,9 +471,65 @@ func (a *ApigeeConfig) ValidateCfg() (err error) {
 		return errors.New("invalid APIGEE configuration: spec workers must be greater than 0")
 	}
 
+	if a.Retry != nil && (a.Retry.MaxRetries < 0 || a.Retry.RateLimit < 0) {
+		return errors.New("invalid APIGEE configuration: retry max retries and rate limit must not be negative")
+	}
+
+	if a.Retry != nil && a.Retry.MaxRetries > 0 && (a.Retry.InitialBackoff <= 0 || a.Retry.MaxBackoff < a.Retry.InitialBackoff) {
+		return errors.New("invalid APIGEE configuration: retry max backoff must be greater than the initial backoff")
+	}
+
+	if a.RequestTimeout < 0 {
+		return errors.New("invalid APIGEE configuration: request timeout must not be negative")
+	}
+
+	if a.RevisionStrategy != "" && a.RevisionStrategy != RevisionStrategyLatest && a.RevisionStrategy != RevisionStrategyAll {
+		return errors.New("invalid APIGEE configuration: revision strategy must be latest or all")
+	}
+
+	if a.RemovalGrace < 0 {
+		return errors.New("invalid APIGEE configuration: removal grace period must not be negative")
+	}
+
+	if a.ProxyURL != "" {
+		if u, err := url.Parse(a.ProxyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.New("invalid APIGEE configuration: proxy url must be an absolute url")
+		}
+	}
+
+	if a.TLS != nil && (a.TLS.ClientCertPath == "") != (a.TLS.ClientKeyPath == "") {
+		return errors.New("invalid APIGEE configuration: tls client cert and key must be configured together")
+	}
+
 	return
 }
 
+func (a *ApigeeConfig) validateAuth() error {
+	if a.IsApigeeX() {
+		if a.Auth == nil || (a.Auth.Token == "" && a.Auth.ServiceAccount == "") {
+			return errors.New("invalid APIGEE configuration: token or service account is not configured")
+		}
+		return nil
+	}
+
+	if a.Auth != nil && a.Auth.Passcode != "" {
+		// sso users exchange the passcode for a refresh token, which must be saved to survive restarts
+		if a.Auth.TokenFile == "" {
+			return errors.New("invalid APIGEE configuration: token file must be configured when using a passcode")
+		}
+		return nil
+	}
+
+	if a.Auth == nil || a.Auth.Username == "" {
+		return errors.New("invalid APIGEE configuration: username is not configured")
+	}
+
+	if a.Auth == nil || a.Auth.Password == "" {
+		return errors.New("invalid APIGEE configuration: password is not configured")
+	}
+	return nil
+}
+
 // GetAuth - Returns the Auth Config
 func (a *ApigeeConfig) GetAuth() *AuthConfig {
 	return a.Auth
@@ -275,6 +545,55 @@ func (a *ApigeeConfig) GetWorkers() *ApigeeWorkers {
 	return a.Workers
 }
 
+// GetRetry - Returns the retry and rate limit settings
+func (a *ApigeeConfig) GetRetry() *ApigeeRetry {
+	return a.Retry
+}
+
+// GetProxyURL - Returns the proxy URL used for the api calls
+func (a *ApigeeConfig) GetProxyURL() string {
+	return a.ProxyURL
+}
+
+// GetTLS - Returns the tls settings for the api calls
+func (a *ApigeeConfig) GetTLS() *ApigeeTLS {
+	return a.TLS
+}
+
+// GetEnvironments - Returns the selection of environments, nil selects all environments
+func (a *ApigeeConfig) GetEnvironments() *EnvironmentSelector {
+	return a.environments
+}
+
+// GetSpecFolders - Returns the selection of spec store folders, nil selects all folders
+func (a *ApigeeConfig) GetSpecFolders() *SpecFolderSelector {
+	return a.specFolders
+}
+
+// GetSpecCredentials - Returns the spec credential profiles, keyed by their name
+func (a *ApigeeConfig) GetSpecCredentials() map[string]*SpecCredentials {
+	if a.Specs == nil {
+		return nil
+	}
+	return a.Specs.Credentials
+}
+
+// GetRemovalGracePeriod - Returns the time a service no longer on the dataplane is deprecated before it is removed
+func (a *ApigeeConfig) GetRemovalGracePeriod() time.Duration {
+	return a.RemovalGrace
+}
+
+// GetRequestTimeout - Returns the time to wait for a single api call
+func (a *ApigeeConfig) GetRequestTimeout() time.Duration {
+	return a.RequestTimeout
+}
+
+// IsApigeeX - returns true when the agent connects to the Apigee X or hybrid management api
+func (a *ApigeeConfig) IsApigeeX() bool {
+	platform := stringToPlatformMode(a.Platform)
+	return platform == platformX || platform == platformHybrid
+}
+
 func (a *ApigeeConfig) IsProxyMode() bool {
 	return a.mode == discoveryModeProxy
 }
@@ -283,6 +602,16 @@ func (a *ApigeeConfig) IsProductMode() bool {
 	return a.mode == discoveryModeProduct
 }
 
+// PublishAllRevisions - returns true when each deployed revision of a proxy is published, not only the latest
+func (a *ApigeeConfig) PublishAllRevisions() bool {
+	return a.RevisionStrategy == RevisionStrategyAll
+}
+
+// WatchSpecFiles - returns true when changes to the spec files in the local directory republish their apis
+func (a *ApigeeConfig) WatchSpecFiles() bool {
+	return a.Specs != nil && a.Specs.LocalPath != "" && a.Specs.Watch != SpecWatchOff
+}
+
 func (a *ApigeeConfig) ShouldCloneAttributes() bool {
 	return a.CloneAttributes
 }
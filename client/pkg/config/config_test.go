@@ -16,6 +16,11 @@ func TestValidateConfig(t *testing.T) {
 	assert.Equal(t, "invalid APIGEE configuration: discoveryMode must be proxy or product", err.Error())
 	cfg.mode = discoveryModeProxy
 
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: platform must be edge, x, or hybrid", err.Error())
+	cfg.Platform = "edge"
+
 	err = cfg.ValidateCfg()
 	assert.NotNil(t, err)
 	assert.Equal(t, "invalid APIGEE configuration: url is not configured", err.Error())
@@ -58,6 +63,96 @@ func TestValidateConfig(t *testing.T) {
 
 	err = cfg.ValidateCfg()
 	assert.Nil(t, err)
+
+	cfg.Retry = &ApigeeRetry{MaxRetries: -1}
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: retry max retries and rate limit must not be negative", err.Error())
+	cfg.Retry.MaxRetries = 3
+
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: retry max backoff must be greater than the initial backoff", err.Error())
+	cfg.Retry.InitialBackoff = time.Second
+	cfg.Retry.MaxBackoff = 30 * time.Second
+
+	err = cfg.ValidateCfg()
+	assert.Nil(t, err)
+
+	cfg.RequestTimeout = -time.Second
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: request timeout must not be negative", err.Error())
+	cfg.RequestTimeout = time.Minute
+
+	err = cfg.ValidateCfg()
+	assert.Nil(t, err)
+
+	cfg.RevisionStrategy = "oldest"
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: revision strategy must be latest or all", err.Error())
+	cfg.RevisionStrategy = RevisionStrategyAll
+	assert.True(t, cfg.PublishAllRevisions())
+
+	cfg.Specs = &ApigeeSpecConfig{Watch: "inotify"}
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: spec watch must be notify, poll or off", err.Error())
+	cfg.Specs.Watch = SpecWatchPoll
+	cfg.Specs.LocalPath = "./specs"
+	assert.True(t, cfg.WatchSpecFiles())
+	cfg.Specs.Watch = SpecWatchOff
+	assert.False(t, cfg.WatchSpecFiles())
+	assert.False(t, cfg.Specs.GenerateFromFlows)
+
+	cfg.RemovalGrace = -time.Hour
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: removal grace period must not be negative", err.Error())
+	cfg.RemovalGrace = 0
+
+	err = cfg.ValidateCfg()
+	assert.Nil(t, err)
+
+	cfg.ProxyURL = "proxy.com:3128"
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: proxy url must be an absolute url", err.Error())
+	cfg.ProxyURL = "http://proxy.com:3128"
+
+	cfg.TLS = &ApigeeTLS{ClientCertPath: "client.crt"}
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: tls client cert and key must be configured together", err.Error())
+	cfg.TLS.ClientKeyPath = "client.key"
+
+	err = cfg.ValidateCfg()
+	assert.Nil(t, err)
+
+	// sso users authenticate with a passcode and save the refresh token
+	cfg.Auth = &AuthConfig{Passcode: "passcode"}
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: token file must be configured when using a passcode", err.Error())
+	cfg.Auth.TokenFile = "/data/apigee_token"
+
+	err = cfg.ValidateCfg()
+	assert.Nil(t, err)
+
+	// apigee x requires a token rather than a username and password
+	cfg.Platform = "x"
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid APIGEE configuration: token or service account is not configured", err.Error())
+	cfg.Auth = &AuthConfig{Token: "token"}
+
+	err = cfg.ValidateCfg()
+	assert.Nil(t, err)
+
+	cfg.Auth = &AuthConfig{ServiceAccount: "/keys/service-account.json"}
+	err = cfg.ValidateCfg()
+	assert.Nil(t, err)
 }
 
 type propData struct {
@@ -115,6 +210,17 @@ func (f *fakeProps) DurationPropertyValue(name string) time.Duration {
 	return 0
 }
 
+func (f *fakeProps) AddObjectSliceProperty(name string, propertyNames []string, opts ...properties.ObjectOpt) {
+	f.props[name] = propData{"object", "", []map[string]interface{}{}, nil}
+}
+
+func (f *fakeProps) ObjectSlicePropertyValue(name string) []map[string]interface{} {
+	if prop, ok := f.props[name]; ok {
+		return prop.val.([]map[string]interface{})
+	}
+	return nil
+}
+
 func TestApigeeProperties(t *testing.T) {
 	newProps := &fakeProps{props: map[string]propData{}}
 
@@ -124,7 +230,11 @@ func TestApigeeProperties(t *testing.T) {
 	assert.Contains(t, newProps.props, pathDataURL)
 	assert.Contains(t, newProps.props, pathAPIVersion)
 	assert.Contains(t, newProps.props, pathOrganization)
+	assert.Contains(t, newProps.props, pathEnvironment)
+	assert.Contains(t, newProps.props, pathEnvironmentExclude)
+	assert.Contains(t, newProps.props, pathEnvironmentMapping)
 	assert.Contains(t, newProps.props, pathMode)
+	assert.Contains(t, newProps.props, pathPlatform)
 	assert.Contains(t, newProps.props, pathFilter)
 	assert.Contains(t, newProps.props, pathCloneAttributes)
 	assert.Contains(t, newProps.props, pathAllTraffic)
@@ -134,6 +244,11 @@ func TestApigeeProperties(t *testing.T) {
 	assert.Contains(t, newProps.props, pathAuthServerPassword)
 	assert.Contains(t, newProps.props, pathAuthUsername)
 	assert.Contains(t, newProps.props, pathAuthPassword)
+	assert.Contains(t, newProps.props, pathAuthToken)
+	assert.Contains(t, newProps.props, pathAuthServiceAccount)
+	assert.Contains(t, newProps.props, pathAuthPasscode)
+	assert.Contains(t, newProps.props, pathAuthMFASeed)
+	assert.Contains(t, newProps.props, pathAuthTokenFile)
 	assert.Contains(t, newProps.props, pathSpecInterval)
 	assert.Contains(t, newProps.props, pathProxyInterval)
 	assert.Contains(t, newProps.props, pathProductInterval)
@@ -142,13 +257,41 @@ func TestApigeeProperties(t *testing.T) {
 	assert.Contains(t, newProps.props, pathSpecWorkers)
 	assert.Contains(t, newProps.props, pathProxyWorkers)
 	assert.Contains(t, newProps.props, pathProductWorkers)
+	assert.Contains(t, newProps.props, pathRetryMax)
+	assert.Contains(t, newProps.props, pathRetryInitialBackoff)
+	assert.Contains(t, newProps.props, pathRetryMaxBackoff)
+	assert.Contains(t, newProps.props, pathRetryRateLimit)
+	assert.Contains(t, newProps.props, pathRequestTimeout)
+	assert.Contains(t, newProps.props, pathRemovalGracePeriod)
+	assert.Contains(t, newProps.props, pathRevisionStrategy)
+	assert.Contains(t, newProps.props, pathProxyURL)
+	assert.Contains(t, newProps.props, pathTLSInsecureSkipVerify)
+	assert.Contains(t, newProps.props, pathTLSRootCACertPath)
+	assert.Contains(t, newProps.props, pathTLSClientCertPath)
+	assert.Contains(t, newProps.props, pathTLSClientKeyPath)
+	assert.Contains(t, newProps.props, pathSpecFolders)
+	assert.Contains(t, newProps.props, pathSpecCacheDirectory)
+	assert.Contains(t, newProps.props, pathSpecCredentials)
+	assert.Contains(t, newProps.props, pathSpecWatch)
+	assert.Contains(t, newProps.props, pathSpecWatchInterval)
+	assert.Contains(t, newProps.props, pathSpecGenerateFromFlows)
 
 	// validate defaults
 	cfg := ParseConfig(newProps)
 	assert.Equal(t, "proxy", cfg.mode.String())
 	assert.True(t, cfg.IsProxyMode())
 	assert.False(t, cfg.IsProductMode())
+	assert.Equal(t, "edge", cfg.Platform)
+	assert.False(t, cfg.IsApigeeX())
 	assert.Equal(t, "", cfg.Organization)
+	assert.True(t, cfg.GetEnvironments().IsSelected("prod"))
+	assert.Equal(t, "prod", cfg.GetEnvironments().GetStage("prod"))
+	assert.True(t, cfg.GetSpecFolders().IsSelected("/sales"))
+	assert.Equal(t, "", cfg.Specs.CacheDirectory)
+	assert.Empty(t, cfg.GetSpecCredentials())
+	assert.Equal(t, SpecWatchNotify, cfg.Specs.Watch)
+	assert.Equal(t, 30*time.Second, cfg.Specs.WatchInterval)
+	assert.False(t, cfg.WatchSpecFiles())
 	assert.Equal(t, "https://api.enterprise.apigee.com", cfg.URL)
 	assert.Equal(t, "v1", cfg.APIVersion)
 	assert.Equal(t, "", cfg.Filter)
@@ -159,6 +302,16 @@ func TestApigeeProperties(t *testing.T) {
 	assert.Equal(t, "", cfg.GetAuth().GetUsername())
 	assert.Equal(t, "", cfg.GetAuth().GetPassword())
 	assert.Equal(t, false, cfg.GetAuth().UseBasicAuth())
+	assert.Equal(t, 3, cfg.GetRetry().MaxRetries)
+	assert.Equal(t, 1*time.Second, cfg.GetRetry().InitialBackoff)
+	assert.Equal(t, 30*time.Second, cfg.GetRetry().MaxBackoff)
+	assert.Equal(t, 20, cfg.GetRetry().RateLimit)
+	assert.Equal(t, 60*time.Second, cfg.GetRequestTimeout())
+	assert.Equal(t, 24*time.Hour, cfg.GetRemovalGracePeriod())
+	assert.Equal(t, RevisionStrategyLatest, cfg.RevisionStrategy)
+	assert.False(t, cfg.PublishAllRevisions())
+	assert.Equal(t, "", cfg.GetProxyURL())
+	assert.Equal(t, &ApigeeTLS{}, cfg.GetTLS())
 	assert.Equal(t, false, cfg.ShouldCloneAttributes())
 	assert.Equal(t, false, cfg.ShouldReportAllTraffic())
 	assert.Equal(t, false, cfg.ShouldReportNotSetTraffic())
@@ -170,4 +323,54 @@ func TestApigeeProperties(t *testing.T) {
 	assert.Equal(t, 10, cfg.GetWorkers().Proxy)
 	assert.Equal(t, 20, cfg.GetWorkers().Spec)
 	assert.Equal(t, 10, cfg.GetWorkers().Product)
+
+	// validate the environment selection
+	newProps.props[pathEnvironment] = propData{"string", "", "prod*, /^test-[0-9]+$/", nil}
+	newProps.props[pathEnvironmentExclude] = propData{"string", "", "prod-old", nil}
+	newProps.props[pathEnvironmentMapping] = propData{"string", "", "prod=Production", nil}
+	cfg = ParseConfig(newProps)
+	assert.True(t, cfg.GetEnvironments().IsSelected("prod"))
+	assert.True(t, cfg.GetEnvironments().IsSelected("test-1"))
+	assert.False(t, cfg.GetEnvironments().IsSelected("prod-old"))
+	assert.False(t, cfg.GetEnvironments().IsSelected("dev"))
+	assert.Equal(t, "Production", cfg.GetEnvironments().GetStage("prod"))
+
+	// validate an invalid environment selection fails validation
+	newProps.props[pathEnvironment] = propData{"string", "", "/prod[/", nil}
+	cfg = ParseConfig(newProps)
+	err := cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid APIGEE configuration: environment pattern /prod[/ is not a valid regular expression")
+	newProps.props[pathEnvironment] = propData{"string", "", "", nil}
+	newProps.props[pathEnvironmentExclude] = propData{"string", "", "", nil}
+	newProps.props[pathEnvironmentMapping] = propData{"string", "", "", nil}
+
+	// validate the spec folder selection
+	newProps.props[pathSpecFolders] = propData{"string", "", "/sales", nil}
+	cfg = ParseConfig(newProps)
+	assert.True(t, cfg.GetSpecFolders().IsSelected("/sales/orders"))
+	assert.False(t, cfg.GetSpecFolders().IsSelected("/marketing"))
+	newProps.props[pathSpecFolders] = propData{"string", "", "/sales[", nil}
+	cfg = ParseConfig(newProps)
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid APIGEE configuration: spec folder pattern /sales[ is not a valid glob")
+	newProps.props[pathSpecFolders] = propData{"string", "", "", nil}
+
+	// validate the spec credential profiles
+	newProps.props[pathSpecCredentials] = propData{"object", "", []map[string]interface{}{{"name": "git", "token": "abc"}}, nil}
+	cfg = ParseConfig(newProps)
+	assert.Equal(t, "abc", cfg.GetSpecCredentials()["git"].Token)
+	newProps.props[pathSpecCredentials] = propData{"object", "", []map[string]interface{}{{"name": "git", "username": "user"}}, nil}
+	cfg = ParseConfig(newProps)
+	err = cfg.ValidateCfg()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid APIGEE configuration: spec credentials git must set the username and password together")
+	newProps.props[pathSpecCredentials] = propData{"object", "", []map[string]interface{}{}, nil}
+
+	// validate apigee x switches the default url
+	newProps.props[pathPlatform] = propData{"string", "", "x", nil}
+	cfg = ParseConfig(newProps)
+	assert.True(t, cfg.IsApigeeX())
+	assert.Equal(t, "https://apigee.googleapis.com", cfg.URL)
 }
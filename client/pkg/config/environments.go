@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnvironmentSelector - selects the apigee environments the agent discovers, provisions and tracks, and the
+// name of the stage each environment is displayed as in Central
+type EnvironmentSelector struct {
+	include []namePattern
+	exclude []namePattern
+	stages  map[string]string
+}
+
+// NewEnvironmentSelector - parses the comma separated include and exclude patterns and the comma separated
+// environment=stage mapping
+func NewEnvironmentSelector(include, exclude, mapping string) (*EnvironmentSelector, error) {
+	selector := &EnvironmentSelector{
+		include: []namePattern{},
+		exclude: []namePattern{},
+		stages:  map[string]string{},
+	}
+
+	var err error
+	if selector.include, err = parsePatterns("environment", include); err != nil {
+		return nil, err
+	}
+	if selector.exclude, err = parsePatterns("environment", exclude); err != nil {
+		return nil, err
+	}
+
+	for _, m := range splitList(mapping) {
+		env, stage, found := strings.Cut(m, "=")
+		env, stage = strings.TrimSpace(env), strings.TrimSpace(stage)
+		if !found || env == "" || stage == "" {
+			return nil, fmt.Errorf("environment mapping %s must be set as environment=stage", m)
+		}
+		selector.stages[env] = stage
+	}
+	return selector, nil
+}
+
+// IsSelected - returns true when the environment matches an include pattern, or none are set, and no exclude pattern
+func (s *EnvironmentSelector) IsSelected(env string) bool {
+	if s == nil {
+		return true
+	}
+
+	included := len(s.include) == 0
+	for _, p := range s.include {
+		if p.matches(env) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+
+	for _, p := range s.exclude {
+		if p.matches(env) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetStage - returns the name the environment is displayed as in Central, the environment name when not mapped
+func (s *EnvironmentSelector) GetStage(env string) string {
+	if s == nil {
+		return env
+	}
+	if stage, ok := s.stages[env]; ok {
+		return stage
+	}
+	return env
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironmentSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  string
+		exclude  string
+		mapping  string
+		err      string
+		selected map[string]bool
+		stages   map[string]string
+	}{
+		{
+			name:     "should select all environments when no patterns are set",
+			selected: map[string]bool{"dev": true, "prod": true},
+			stages:   map[string]string{"dev": "dev"},
+		},
+		{
+			name:     "should select the listed environments",
+			include:  "dev, test",
+			selected: map[string]bool{"dev": true, "test": true, "prod": false},
+		},
+		{
+			name:     "should select the environments matching a glob",
+			include:  "prod-*",
+			selected: map[string]bool{"prod-eu": true, "prod-us": true, "prod": false},
+		},
+		{
+			name:     "should select the environments matching a regular expression",
+			include:  "/^(dev|test)[0-9]*$/",
+			selected: map[string]bool{"dev1": true, "test": true, "dev-eu": false},
+		},
+		{
+			name:     "should skip the excluded environments",
+			include:  "prod-*",
+			exclude:  "prod-old, /-tmp$/",
+			selected: map[string]bool{"prod-eu": true, "prod-old": false, "prod-eu-tmp": false},
+		},
+		{
+			name:     "should exclude from all environments",
+			exclude:  "internal",
+			selected: map[string]bool{"prod": true, "internal": false},
+		},
+		{
+			name:    "should map environments to stages",
+			mapping: "prod=Production, dev = Development",
+			stages:  map[string]string{"prod": "Production", "dev": "Development", "test": "test"},
+		},
+		{
+			name:    "should fail on an invalid glob",
+			include: "prod-[",
+			err:     "environment pattern prod-[ is not a valid glob",
+		},
+		{
+			name:    "should fail on an invalid regular expression",
+			exclude: "/prod-(/",
+			err:     "environment pattern /prod-(/ is not a valid regular expression",
+		},
+		{
+			name:    "should fail on a mapping without a stage",
+			mapping: "prod=",
+			err:     "environment mapping prod= must be set as environment=stage",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			selector, err := NewEnvironmentSelector(tc.include, tc.exclude, tc.mapping)
+			if tc.err != "" {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			for env, selected := range tc.selected {
+				assert.Equal(t, selected, selector.IsSelected(env), env)
+			}
+			for env, stage := range tc.stages {
+				assert.Equal(t, stage, selector.GetStage(env), env)
+			}
+		})
+	}
+
+	// a nil selector selects every environment without mapping
+	var selector *EnvironmentSelector
+	assert.True(t, selector.IsSelected("prod"))
+	assert.Equal(t, "prod", selector.GetStage("prod"))
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// namePattern - a glob, or a regular expression when enclosed in slashes, /^prod-.*$/
+type namePattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+// newPattern - parses the pattern, kind names what the pattern selects in the errors
+func newPattern(kind, pattern string) (namePattern, error) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		regex, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return namePattern{}, fmt.Errorf("%s pattern %s is not a valid regular expression: %s", kind, pattern, err)
+		}
+		return namePattern{regex: regex}, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return namePattern{}, fmt.Errorf("%s pattern %s is not a valid glob: %s", kind, pattern, err)
+	}
+	return namePattern{glob: pattern}, nil
+}
+
+func (p namePattern) matches(name string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(name)
+	}
+	matched, _ := path.Match(p.glob, name)
+	return matched
+}
+
+// mayMatchBelow - returns true when a path below the folder may match the pattern, a regular expression may match
+// any path
+func (p namePattern) mayMatchBelow(folder string) bool {
+	if p.regex != nil || folder == "/" {
+		return true
+	}
+	globParts := strings.Split(p.glob, "/")
+	folderParts := strings.Split(folder, "/")
+	if len(globParts) <= len(folderParts) {
+		return false
+	}
+	matched, _ := path.Match(strings.Join(globParts[:len(folderParts)], "/"), folder)
+	return matched
+}
+
+// parsePatterns - parses the comma separated patterns
+func parsePatterns(kind, patterns string) ([]namePattern, error) {
+	parsed := []namePattern{}
+	for _, p := range splitList(patterns) {
+		pattern, err := newPattern(kind, p)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, pattern)
+	}
+	return parsed, nil
+}
+
+// splitList - splits a comma separated list, dropping the empty values
+func splitList(list string) []string {
+	values := []string{}
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
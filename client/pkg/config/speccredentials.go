@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the properties of each spec credential profile, APIGEE_SPECCONFIG_CREDENTIALS_NAME_1, set with the same suffix
+const (
+	specCredentialsName       = "name"
+	specCredentialsToken      = "token"
+	specCredentialsUsername   = "username"
+	specCredentialsPassword   = "password"
+	specCredentialsHeaders    = "headers"
+	specCredentialsClientCert = "clientCert"
+	specCredentialsClientKey  = "clientKey"
+)
+
+var specCredentialsProperties = []string{
+	specCredentialsName,
+	specCredentialsToken,
+	specCredentialsUsername,
+	specCredentialsPassword,
+	specCredentialsHeaders,
+	specCredentialsClientCert,
+	specCredentialsClientKey,
+}
+
+// SpecCredentials - a named credential profile, applied when downloading a spec from a URL that references it in the
+// association.json file of a proxy revision
+type SpecCredentials struct {
+	Name           string
+	Token          string
+	Username       string
+	Password       string
+	Headers        map[string]string
+	ClientCertPath string
+	ClientKeyPath  string
+}
+
+// HasClientCert - returns true when the spec downloads present a client certificate
+func (c *SpecCredentials) HasClientCert() bool {
+	return c.ClientCertPath != ""
+}
+
+// parseSpecCredentials - parses the credential profiles, keyed by their name
+func parseSpecCredentials(values []map[string]interface{}) (map[string]*SpecCredentials, error) {
+	profiles := map[string]*SpecCredentials{}
+	for _, v := range values {
+		value := func(name string) string {
+			s, _ := v[name].(string)
+			return strings.TrimSpace(s)
+		}
+
+		profile := &SpecCredentials{
+			Name:           value(specCredentialsName),
+			Token:          value(specCredentialsToken),
+			Username:       value(specCredentialsUsername),
+			Password:       value(specCredentialsPassword),
+			Headers:        map[string]string{},
+			ClientCertPath: value(specCredentialsClientCert),
+			ClientKeyPath:  value(specCredentialsClientKey),
+		}
+		if profile.Name == "" {
+			return nil, fmt.Errorf("spec credentials must have a name")
+		}
+		if _, found := profiles[profile.Name]; found {
+			return nil, fmt.Errorf("spec credentials %s are configured more than once", profile.Name)
+		}
+		if profile.Token != "" && profile.Username != "" {
+			return nil, fmt.Errorf("spec credentials %s must set a token or a username, not both", profile.Name)
+		}
+		if (profile.Username == "") != (profile.Password == "") {
+			return nil, fmt.Errorf("spec credentials %s must set the username and password together", profile.Name)
+		}
+		if (profile.ClientCertPath == "") != (profile.ClientKeyPath == "") {
+			return nil, fmt.Errorf("spec credentials %s must set the client cert and key together", profile.Name)
+		}
+
+		// the headers are comma separated, X-Api-Key=abc,X-Tenant=sales
+		for _, h := range splitList(value(specCredentialsHeaders)) {
+			name, val, found := strings.Cut(h, "=")
+			name = strings.TrimSpace(name)
+			if !found || name == "" {
+				return nil, fmt.Errorf("spec credentials %s header %s must be a name=value pair", profile.Name, h)
+			}
+			profile.Headers[name] = strings.TrimSpace(val)
+		}
+		profiles[profile.Name] = profile
+	}
+	return profiles, nil
+}
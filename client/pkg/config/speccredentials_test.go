@@ -0,0 +1,71 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSpecCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []map[string]interface{}
+		err      string
+		expected map[string]*SpecCredentials
+	}{
+		{
+			name:     "should have no profiles when none are set",
+			expected: map[string]*SpecCredentials{},
+		},
+		{
+			name: "should parse each kind of credentials",
+			values: []map[string]interface{}{
+				{"name": "git", "token": "abc"},
+				{"name": "nexus", "username": "user", "password": "pass"},
+				{"name": "internal", "headers": "X-Api-Key=abc, X-Tenant = sales", "clientCert": "client.crt", "clientKey": "client.key"},
+			},
+			expected: map[string]*SpecCredentials{
+				"git":      {Name: "git", Token: "abc", Headers: map[string]string{}},
+				"nexus":    {Name: "nexus", Username: "user", Password: "pass", Headers: map[string]string{}},
+				"internal": {Name: "internal", Headers: map[string]string{"X-Api-Key": "abc", "X-Tenant": "sales"}, ClientCertPath: "client.crt", ClientKeyPath: "client.key"},
+			},
+		},
+		{
+			name:   "should fail without a name",
+			values: []map[string]interface{}{{"token": "abc"}},
+			err:    "spec credentials must have a name",
+		},
+		{
+			name:   "should fail when a name is used twice",
+			values: []map[string]interface{}{{"name": "git", "token": "abc"}, {"name": "git", "token": "def"}},
+			err:    "spec credentials git are configured more than once",
+		},
+		{
+			name:   "should fail with a token and a username",
+			values: []map[string]interface{}{{"name": "git", "token": "abc", "username": "user", "password": "pass"}},
+			err:    "spec credentials git must set a token or a username, not both",
+		},
+		{
+			name:   "should fail with a client cert and no key",
+			values: []map[string]interface{}{{"name": "git", "clientCert": "client.crt"}},
+			err:    "spec credentials git must set the client cert and key together",
+		},
+		{
+			name:   "should fail with a header that is not a pair",
+			values: []map[string]interface{}{{"name": "git", "headers": "X-Api-Key"}},
+			err:    "spec credentials git header X-Api-Key must be a name=value pair",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			profiles, err := parseSpecCredentials(tc.values)
+			if tc.err != "" {
+				assert.NotNil(t, err)
+				assert.Equal(t, tc.err, err.Error())
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, profiles)
+		})
+	}
+}
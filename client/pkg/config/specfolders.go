@@ -0,0 +1,62 @@
+package config
+
+import (
+	"path"
+	"strings"
+)
+
+// SpecFolderSelector - selects the spec store folders the specs are discovered in
+type SpecFolderSelector struct {
+	include []namePattern
+}
+
+// NewSpecFolderSelector - parses the comma separated folder paths and patterns, /payments or /*/public, folder
+// paths start at the home folder of the spec store
+func NewSpecFolderSelector(include string) (*SpecFolderSelector, error) {
+	selector := &SpecFolderSelector{include: []namePattern{}}
+	for _, f := range splitList(include) {
+		// a glob is a folder path, with or without the leading and trailing slash
+		if !(len(f) > 2 && strings.HasPrefix(f, "/") && strings.HasSuffix(f, "/")) {
+			f = path.Clean("/" + f)
+		}
+		pattern, err := newPattern("spec folder", f)
+		if err != nil {
+			return nil, err
+		}
+		selector.include = append(selector.include, pattern)
+	}
+	return selector, nil
+}
+
+// IsWalked - returns true when the folder, or one of the folders in it, may be selected, the other folders are not
+// read from the spec store
+func (s *SpecFolderSelector) IsWalked(folder string) bool {
+	if s.IsSelected(folder) {
+		return true
+	}
+	folder = path.Clean("/" + folder)
+	for _, p := range s.include {
+		if p.mayMatchBelow(folder) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSelected - returns true when the folder, or one of the folders it is in, matches a pattern, or none are set
+func (s *SpecFolderSelector) IsSelected(folder string) bool {
+	if s == nil || len(s.include) == 0 {
+		return true
+	}
+
+	for f := path.Clean("/" + folder); ; f = path.Dir(f) {
+		for _, p := range s.include {
+			if p.matches(f) {
+				return true
+			}
+		}
+		if f == "/" {
+			return false
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecFolderSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  string
+		err      string
+		selected map[string]bool
+	}{
+		{
+			name:     "should select all folders when no patterns are set",
+			selected: map[string]bool{"/": true, "/sales": true},
+		},
+		{
+			name:     "should select the listed folders and their sub folders",
+			include:  "/sales, payments/",
+			selected: map[string]bool{"/": false, "/sales": true, "/sales/orders": true, "/payments": true, "/salesforce": false},
+		},
+		{
+			name:     "should select the folders matching a glob",
+			include:  "/*/public",
+			selected: map[string]bool{"/sales/public": true, "/sales/public/v2": true, "/sales/internal": false},
+		},
+		{
+			name:     "should select the folders matching a regular expression",
+			include:  "/^/(sales|payments)$/",
+			selected: map[string]bool{"/sales/orders": true, "/payments": true, "/marketing": false},
+		},
+		{
+			name:     "should select the home folder",
+			include:  "/",
+			selected: map[string]bool{"/": true, "/sales": true},
+		},
+		{
+			name:    "should fail on an invalid glob",
+			include: "/sales[",
+			err:     "spec folder pattern /sales[ is not a valid glob",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			selector, err := NewSpecFolderSelector(tc.include)
+			if tc.err != "" {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			for folder, selected := range tc.selected {
+				assert.Equal(t, selected, selector.IsSelected(folder), folder)
+			}
+		})
+	}
+}
+
+func TestSpecFolderSelectorIsWalked(t *testing.T) {
+	tests := []struct {
+		name    string
+		include string
+		walked  map[string]bool
+	}{
+		{
+			name:   "should walk all folders when no patterns are set",
+			walked: map[string]bool{"/": true, "/sales": true},
+		},
+		{
+			name:    "should walk the folders above and below a listed folder",
+			include: "/sales/orders",
+			walked:  map[string]bool{"/": true, "/sales": true, "/sales/orders": true, "/sales/orders/v2": true, "/sales/returns": false, "/marketing": false},
+		},
+		{
+			name:    "should walk the folders that may contain a glob match",
+			include: "/*/public",
+			walked:  map[string]bool{"/sales": true, "/sales/public": true, "/sales/internal": false, "/sales/internal/public": false},
+		},
+		{
+			name:    "should walk all folders for a regular expression",
+			include: "/^/sales$/",
+			walked:  map[string]bool{"/marketing": true, "/sales/orders": true},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			selector, err := NewSpecFolderSelector(tc.include)
+			assert.Nil(t, err)
+			for folder, walked := range tc.walked {
+				assert.Equal(t, walked, selector.IsWalked(folder), folder)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ toolchain go1.23.8
 require (
 	github.com/Axway/agent-sdk v1.1.121
 	github.com/Axway/agents-apigee/client v0.0.0-00010101000000-000000000000
+	github.com/fsnotify/fsnotify v1.5.4
 	github.com/stretchr/testify v1.9.0
 )
 
@@ -24,7 +25,6 @@ require (
 	github.com/elastic/go-windows v1.0.2 // indirect
 	github.com/emicklei/proto v1.9.2 // indirect
 	github.com/fatih/color v1.17.0 // indirect
-	github.com/fsnotify/fsnotify v1.5.4 // indirect
 	github.com/getkin/kin-openapi v0.131.0 // indirect
 	github.com/go-openapi/jsonpointer v0.21.0 // indirect
 	github.com/go-openapi/swag v0.23.0 // indirect
@@ -89,6 +89,7 @@ require (
 	golang.org/x/sync v0.15.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
+	golang.org/x/time v0.3.0 // indirect
 	golang.org/x/tools v0.33.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
 	google.golang.org/grpc v1.65.0 // indirect
@@ -1,15 +1,24 @@
 package apigee
 
 import (
+	"context"
+	"path/filepath"
+	"time"
+
 	"github.com/Axway/agent-sdk/pkg/agent"
 	corecfg "github.com/Axway/agent-sdk/pkg/config"
 	"github.com/Axway/agent-sdk/pkg/filter"
 	"github.com/Axway/agent-sdk/pkg/jobs"
+	"github.com/Axway/agent-sdk/pkg/util/log"
 
 	"github.com/Axway/agents-apigee/client/pkg/apigee"
 	"github.com/Axway/agents-apigee/client/pkg/config"
 )
 
+// defaultCacheStoragePath - the directory of the cache snapshot when central.cacheStoragePath is not set, the
+// same directory the sdk persists its cache to
+const defaultCacheStoragePath = "./data/cache"
+
 // AgentConfig - represents the config for agent
 type AgentConfig struct {
 	CentralCfg corecfg.CentralConfig `config:"central"`
@@ -23,6 +32,11 @@ type Agent struct {
 	discoveryFilter filter.Filter
 	stopChan        chan struct{}
 	agentCache      *agentCache
+	cachePath       string
+	specContent     *specContentStore
+	reconcileJob    *reconcileJob
+	ctx             context.Context
+	cancel          context.CancelFunc
 }
 
 // NewAgent - Creates a new Agent
@@ -37,12 +51,22 @@ func NewAgent(agentCfg *AgentConfig) (*Agent, error) {
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	newAgent := &Agent{
 		apigeeClient:    apigeeClient,
 		cfg:             agentCfg,
 		discoveryFilter: discoveryFilter,
 		stopChan:        make(chan struct{}),
 		agentCache:      newAgentCache(),
+		cachePath:       cacheSnapshotPath(agentCfg.CentralCfg),
+		specContent:     newSpecContentStore(agentCfg.ApigeeCfg.Specs.CacheDirectory),
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+
+	// start from the snapshot of the previous run, a snapshot that can not be read is replaced on the next save
+	if err := newAgent.agentCache.Load(newAgent.cachePath); err != nil {
+		log.Warnf("could not load the agent cache, discovering all apis again: %s", err)
 	}
 
 	// newAgent.handleSubscriptions()
@@ -52,12 +76,26 @@ func NewAgent(agentCfg *AgentConfig) (*Agent, error) {
 		agent.GetCacheManager(),
 		agentCfg.ApigeeCfg.IsProductMode(),
 		agentCfg.ApigeeCfg.ShouldCloneAttributes(),
+		agentCfg.ApigeeCfg.GetEnvironments(),
 	)
 	agent.RegisterProvisioner(provisioner)
 
 	return newAgent, nil
 }
 
+// cacheSnapshotPath - the file the agent cache is saved to, named after the agent like the sdk cache file
+func cacheSnapshotPath(centralCfg corecfg.CentralConfig) string {
+	cachePath := centralCfg.GetCacheStoragePath()
+	if cachePath == "" {
+		cachePath = defaultCacheStoragePath
+	}
+	name := centralCfg.GetAgentName()
+	if name == "" {
+		name = centralCfg.GetEnvironmentName()
+	}
+	return filepath.Join(cachePath, name+"-apigee.json")
+}
+
 func (a *Agent) Run() error {
 	// Start the agent jobs
 	err := a.registerJobs()
@@ -78,11 +116,15 @@ func (a *Agent) registerJobs() error {
 	}
 
 	parseSpec := a.cfg.ApigeeCfg.IsProxyMode() && a.cfg.ApigeeCfg.Specs.MatchOnURL // parse specs if proxy mode and match on url set
-	if !a.cfg.ApigeeCfg.Specs.DisablePollForSpecs {
+	// the spec store is only available on apigee edge
+	if !a.cfg.ApigeeCfg.Specs.DisablePollForSpecs && !a.cfg.ApigeeCfg.IsApigeeX() {
 		specsJob := newPollSpecsJob().
+			SetContext(a.ctx).
 			SetSpecClient(a.apigeeClient).
 			SetSpecCache(a.agentCache).
 			SetWorkers(a.cfg.ApigeeCfg.GetWorkers().Spec).
+			SetFolders(a.cfg.ApigeeCfg.GetSpecFolders()).
+			SetSpecContent(a.specContent).
 			SetParseSpec(parseSpec)
 
 		_, err = jobs.RegisterIntervalJobWithName(specsJob, a.apigeeClient.GetConfig().GetIntervals().Spec, "Poll Specs")
@@ -93,14 +135,19 @@ func (a *Agent) registerJobs() error {
 	}
 
 	var validatorReady jobFirstRunDone
+	var republishSpecFiles specFilesChanged
+	pollInterval := a.apigeeClient.GetConfig().GetIntervals().Product
 
 	if a.cfg.ApigeeCfg.IsProxyMode() {
 		proxiesJob := newPollProxiesJob().
+			SetContext(a.ctx).
 			SetSpecClient(a.apigeeClient).
 			SetSpecCache(a.agentCache).
 			SetSpecsReady(startPollingJob).
-			SetEnvironment(a.cfg.ApigeeCfg.Environment).
+			SetEnvironments(a.cfg.ApigeeCfg.GetEnvironments()).
+			SetShouldPushAPI(a.shouldPushAPI).
 			SetWorkers(a.cfg.ApigeeCfg.GetWorkers().Proxy).
+			SetSpecContent(a.specContent).
 			SetMatchOnURL(a.cfg.ApigeeCfg.Specs.MatchOnURL)
 
 		_, err = jobs.RegisterIntervalJobWithName(proxiesJob, a.apigeeClient.GetConfig().GetIntervals().Proxy, "Poll Proxies")
@@ -110,8 +157,11 @@ func (a *Agent) registerJobs() error {
 
 		// register the api validator job
 		validatorReady = proxiesJob.FirstRunComplete
+		republishSpecFiles = proxiesJob.republishSpecFiles
+		pollInterval = a.apigeeClient.GetConfig().GetIntervals().Proxy
 	} else {
-		productsJob := newPollProductsJob(a.apigeeClient, a.agentCache, startPollingJob, a.cfg.ApigeeCfg.GetWorkers().Product, a.shouldPushAPI)
+		productsJob := newPollProductsJob(a.ctx, a.apigeeClient, a.agentCache, startPollingJob, a.cfg.ApigeeCfg.GetWorkers().Product, a.shouldPushAPI, a.cfg.ApigeeCfg.GetEnvironments()).
+			SetSpecContent(a.specContent)
 		_, err = jobs.RegisterIntervalJobWithName(productsJob, a.apigeeClient.GetConfig().GetIntervals().Product, "Poll Products")
 		if err != nil {
 			return err
@@ -119,7 +169,31 @@ func (a *Agent) registerJobs() error {
 
 		// register the api validator job
 		validatorReady = productsJob.FirstRunComplete
+		republishSpecFiles = productsJob.republishSpecFiles
+	}
+
+	// the apis of the spec files changed in the local directory are published again, until the agent stops
+	if a.cfg.ApigeeCfg.WatchSpecFiles() {
+		specs := a.cfg.ApigeeCfg.Specs
+		go newSpecWatcher(specs.LocalPath, specs.Watch, specs.WatchInterval).watch(a.ctx, republishSpecFiles)
 	}
+
+	// deprecate the services of apis no longer on apigee, the validator removes them after the grace period
+	a.reconcileJob = newReconcileJob(a.agentCache, agent.GetCacheManager(), agent.GetCentralClient(), a.cfg.ApigeeCfg.GetRemovalGracePeriod())
+	_, err = jobs.RegisterIntervalJobWithName(a.reconcileJob, pollInterval, "Reconcile Services")
+	if err != nil {
+		return err
+	}
+
+	persistInterval := a.cfg.CentralCfg.GetCacheStorageInterval()
+	if persistInterval <= 0 {
+		persistInterval = time.Minute
+	}
+	_, err = jobs.RegisterIntervalJobWithName(newPersistCacheJob(a.agentCache, a.cachePath), persistInterval, "Persist Agent Cache")
+	if err != nil {
+		return err
+	}
+
 	_, err = jobs.RegisterSingleRunJobWithName(newRegisterAPIValidatorJob(validatorReady, a.registerValidator), "Register API Validator")
 
 	agent.NewAPIKeyCredentialRequestBuilder(agent.WithCRDIsSuspendable()).Register()
@@ -133,23 +207,22 @@ func (a *Agent) running() {
 	<-a.stopChan
 }
 
-// Stop - signals the agent to stop
+// Stop - signals the agent to stop, cancelling the outstanding apigee calls of the jobs
 func (a *Agent) Stop() {
+	a.cancel()
+	if err := a.agentCache.Save(a.cachePath); err != nil {
+		log.Errorf("could not save the agent cache: %s", err)
+	}
 	a.stopChan <- struct{}{}
 }
 
-// shouldPushAPI - callback used determine if the Product should be pushed to Central or not
+// shouldPushAPI - callback used determine if the Product, or Proxy, should be pushed to Central or not
 func (a *Agent) shouldPushAPI(attributes map[string]string) bool {
 	// Evaluate the filter condition
 	return a.discoveryFilter.Evaluate(attributes)
 }
 
-// apiValidator - registers the agent jobs
-func (a *Agent) apiValidator(proxyName, envName string) bool {
-	// get the api with the product name and portal name
-	return true
-}
-
+// registerValidator - the services of apis that are no longer deployed are removed once their grace period ends
 func (a *Agent) registerValidator() {
-	agent.RegisterAPIValidator(a.apiValidator)
+	agent.RegisterAPIValidator(a.reconcileJob.validate)
 }
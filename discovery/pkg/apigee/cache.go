@@ -2,6 +2,7 @@ package apigee
 
 import (
 	"fmt"
+	"maps"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +15,27 @@ type agentCache struct {
 	cache              cache.Cache
 	specEndpointToKeys map[string][]specCacheItem
 	mutex              *sync.Mutex
+	// deployedAPIs - the cache keys of the apis found on apigee by the last complete poll, mapped to the api id
+	deployedAPIs   map[string]string
+	deployedPolled bool
+	// handledRevisions - the proxy revisions the polls have handled, keyed by their revision cache key
+	handledRevisions map[string]handledRevision
+	// deprecatedAPIs - the time the service instances of the apis no longer deployed were deprecated, keyed by
+	// their deployed cache key
+	deprecatedAPIs map[string]time.Time
+}
+
+// handledRevision - a proxy revision a poll has handled, it is handled again once it is modified
+type handledRevision struct {
+	Proxy string `json:"proxy"`
+	// LastModified - the last modified time, in ms, of the revision when it was handled
+	LastModified int  `json:"lastModified"`
+	Published    bool `json:"published"`
+	Filtered     bool `json:"filtered"`
+	// Tags - the tags the discovery filter was evaluated against, evaluated again while the revision is unchanged
+	Tags map[string]string `json:"tags,omitempty"`
+	// SpecURL - the url the spec of the revision is downloaded from, its content is kept while the revision is deployed
+	SpecURL string `json:"specURL,omitempty"`
 }
 
 type specCacheItem struct {
@@ -21,6 +43,9 @@ type specCacheItem struct {
 	Name        string
 	ContentPath string
 	ModDate     time.Time
+	Endpoints   []string
+	// Folder - the path of the spec store folder the spec is in
+	Folder string
 }
 
 func newAgentCache() *agentCache {
@@ -28,6 +53,9 @@ func newAgentCache() *agentCache {
 		cache:              cache.New(),
 		specEndpointToKeys: make(map[string][]specCacheItem),
 		mutex:              &sync.Mutex{},
+		deployedAPIs:       make(map[string]string),
+		handledRevisions:   make(map[string]handledRevision),
+		deprecatedAPIs:     make(map[string]time.Time),
 	}
 }
 
@@ -35,20 +63,26 @@ func specPrimaryKey(name string) string {
 	return fmt.Sprintf("spec-%s", name)
 }
 
-func (a *agentCache) AddSpecToCache(id, path, name string, modDate time.Time, endpoints ...string) {
+func (a *agentCache) AddSpecToCache(id, path, name, folder string, modDate time.Time, endpoints ...string) {
 	item := specCacheItem{
 		ID:          id,
 		Name:        strings.ToLower(name),
 		ContentPath: path,
 		ModDate:     modDate,
+		Endpoints:   endpoints,
+		Folder:      folder,
 	}
+	a.addSpecItem(specPrimaryKey(name), item)
+}
 
-	a.cache.SetWithSecondaryKey(specPrimaryKey(name), path, item)
-	a.cache.SetSecondaryKey(specPrimaryKey(name), strings.ToLower(name))
-	a.cache.SetSecondaryKey(specPrimaryKey(name), id)
+// addSpecItem - adds the spec with the primary key, found by its path, lower case name, id and endpoints
+func (a *agentCache) addSpecItem(primaryKey string, item specCacheItem) {
+	a.cache.SetWithSecondaryKey(primaryKey, item.ContentPath, item)
+	a.cache.SetSecondaryKey(primaryKey, item.Name)
+	a.cache.SetSecondaryKey(primaryKey, item.ID)
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
-	for _, ep := range endpoints {
+	for _, ep := range item.Endpoints {
 		if _, found := a.specEndpointToKeys[ep]; !found {
 			a.specEndpointToKeys[ep] = []specCacheItem{}
 		}
@@ -166,3 +200,128 @@ func (a *agentCache) GetPublishedProxy(cacheKey string) (*apic.ServiceBody, erro
 	sb := item.(*apic.ServiceBody)
 	return sb, nil
 }
+
+// SetHandledRevision - saves the proxy revision a poll has handled, whether or not it was published
+func (a *agentCache) SetHandledRevision(revisionKey string, revision handledRevision) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.handledRevisions[revisionKey] = revision
+}
+
+// GetHandledRevision - the proxy revision with the revision cache key, false when no poll has handled it
+func (a *agentCache) GetHandledRevision(revisionKey string) (handledRevision, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	revision, found := a.handledRevisions[revisionKey]
+	return revision, found
+}
+
+// IsProxyHandled - returns true when a poll has handled a deployed revision of the proxy
+func (a *agentCache) IsProxyHandled(proxyName string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, revision := range a.handledRevisions {
+		if revision.Proxy == proxyName {
+			return true
+		}
+	}
+	return false
+}
+
+// getHandledRevisions - returns a copy of the handled revisions
+func (a *agentCache) getHandledRevisions() map[string]handledRevision {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return maps.Clone(a.handledRevisions)
+}
+
+// GetHandledSpecURLs - the urls the specs of the handled revisions are downloaded from
+func (a *agentCache) GetHandledSpecURLs() map[string]struct{} {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	urls := map[string]struct{}{}
+	for _, revision := range a.handledRevisions {
+		if revision.SpecURL != "" {
+			urls[revision.SpecURL] = struct{}{}
+		}
+	}
+	return urls
+}
+
+// UpdateDeployedAPIs - replaces the deployed apis with the ones found by a complete poll, the previous
+// state of the apis the poll could not read the deployments of is kept. The handled revisions that are no
+// longer deployed are forgotten, so they are handled again when they are deployed again
+func (a *agentCache) UpdateDeployedAPIs(inventory *deploymentInventory) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	deployed := inventory.getDeployed()
+	for key, apiID := range a.deployedAPIs {
+		if inventory.isUnknown(apiID) {
+			deployed[key] = apiID
+		}
+	}
+	a.deployedAPIs = deployed
+	a.deployedPolled = true
+
+	for key, revision := range a.handledRevisions {
+		if !inventory.hasRevision(key) && !inventory.isUnknown(revision.Proxy) {
+			delete(a.handledRevisions, key)
+		}
+	}
+}
+
+// IsAPIDeployed - returns true when the last complete poll found the api with the cache key on apigee
+func (a *agentCache) IsAPIDeployed(cacheKey string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	_, found := a.deployedAPIs[cacheKey]
+	return found
+}
+
+// HasDeployedAPIs - returns true once a poll has completed and the deployed apis are known
+func (a *agentCache) HasDeployedAPIs() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.deployedPolled
+}
+
+// SetDeprecated - saves the time the service instances with the cache key were deprecated
+func (a *agentCache) SetDeprecated(cacheKey string, deprecatedAt time.Time) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.deprecatedAPIs[cacheKey] = deprecatedAt
+}
+
+// GetDeprecated - the time the service instances with the cache key were deprecated, false when they are not
+func (a *agentCache) GetDeprecated(cacheKey string) (time.Time, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	deprecatedAt, found := a.deprecatedAPIs[cacheKey]
+	return deprecatedAt, found
+}
+
+// RemoveDeprecated - forgets the deprecated api with the cache key, it is deployed again
+func (a *agentCache) RemoveDeprecated(cacheKey string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.deprecatedAPIs, cacheKey)
+}
+
+// RetainDeprecated - forgets the deprecated apis whose service instances have been removed
+func (a *agentCache) RetainDeprecated(cacheKeys map[string]struct{}) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for cacheKey := range a.deprecatedAPIs {
+		if _, found := cacheKeys[cacheKey]; !found {
+			delete(a.deprecatedAPIs, cacheKey)
+		}
+	}
+}
+
+// getDeprecatedAPIs - returns a copy of the deprecated apis
+func (a *agentCache) getDeprecatedAPIs() map[string]time.Time {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return maps.Clone(a.deprecatedAPIs)
+}
@@ -13,8 +13,8 @@ func Test_cacheSpecs(t *testing.T) {
 	assert.NotNil(t, c)
 
 	// add specs to cache
-	c.AddSpecToCache("id1", "/path/id1", "name-id1", time.Now())
-	c.AddSpecToCache("id2", "/path/id2", "name-id2", time.Now(), "http://id2/endpoint1", "http://id2/endpoint2")
+	c.AddSpecToCache("id1", "/path/id1", "name-id1", "/", time.Now())
+	c.AddSpecToCache("id2", "/path/id2", "name-id2", "/sales", time.Now(), "http://id2/endpoint1", "http://id2/endpoint2")
 
 	// get spec items
 
@@ -87,3 +87,26 @@ func Test_cacheProducts(t *testing.T) {
 	changed = c.HasProductChanged("prod2", time.Now().Add(-1*time.Hour), "789") // no match returns changed
 	assert.True(t, changed)
 }
+
+func Test_cacheDeployedAPIs(t *testing.T) {
+	c := newAgentCache()
+	assert.False(t, c.HasDeployedAPIs())
+
+	inventory := newDeploymentInventory()
+	inventory.addDeployed(createProxyCacheKey("proxy1", "prod"), "proxy1")
+	inventory.addDeployed(createProxyCacheKey("proxy2", "prod"), "proxy2")
+	c.UpdateDeployedAPIs(inventory)
+	assert.True(t, c.HasDeployedAPIs())
+	assert.True(t, c.IsAPIDeployed(createProxyCacheKey("proxy1", "prod")))
+	assert.True(t, c.IsAPIDeployed(createProxyCacheKey("proxy2", "prod")))
+
+	// proxy1 was undeployed, the deployments of proxy2 could not be read so it is kept
+	inventory = newDeploymentInventory()
+	inventory.addUnknown("proxy2")
+	c.UpdateDeployedAPIs(inventory)
+	assert.False(t, c.IsAPIDeployed(createProxyCacheKey("proxy1", "prod")))
+	assert.True(t, c.IsAPIDeployed(createProxyCacheKey("proxy2", "prod")))
+
+	c.UpdateDeployedAPIs(newDeploymentInventory())
+	assert.False(t, c.IsAPIDeployed(createProxyCacheKey("proxy2", "prod")))
+}
@@ -5,8 +5,11 @@ const (
 	association = "association.json"
 )
 
+// Association - the association.json resource file of a proxy revision, credentials names the spec credential
+// profile the spec at the url is downloaded with
 type Association struct {
-	URL string `json:"url"`
+	URL         string `json:"url"`
+	Credentials string `json:"credentials,omitempty"`
 }
 
 type jobFirstRunDone func() bool
@@ -19,6 +22,8 @@ const (
 
 const (
 	cacheKeyAttribute    = "cacheKey"
+	revisionAttribute    = "apigeeRevision"
+	specFolderAttribute  = "apigeeSpecFolder"
 	agentProductTagName  = "AgentCreated"
 	agentProductTagValue = "true"
 )
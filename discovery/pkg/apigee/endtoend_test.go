@@ -0,0 +1,258 @@
+package apigee
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/apic"
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	defs "github.com/Axway/agent-sdk/pkg/apic/definitions"
+	"github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/apic/provisioning/mock"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee"
+	"github.com/Axway/agents-apigee/client/pkg/apigee/simulator"
+	"github.com/Axway/agents-apigee/client/pkg/config"
+)
+
+func newSimulatorClient(t *testing.T) (*simulator.Server, *apigee.ApigeeClient) {
+	s, err := simulator.NewServer()
+	assert.Nil(t, err)
+	t.Cleanup(s.Close)
+
+	client, err := apigee.NewClient(s.Config())
+	assert.Nil(t, err)
+	assert.Eventually(t, client.IsReady, 10*time.Second, 10*time.Millisecond)
+	return s, client
+}
+
+// publishedServices - collects the service bodies the jobs publish
+type publishedServices struct {
+	lock     sync.Mutex
+	services map[string]apic.ServiceBody
+}
+
+func (p *publishedServices) publish(sb apic.ServiceBody) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.services[fmt.Sprintf("%s-%s", sb.APIName, sb.Stage)] = sb
+	return nil
+}
+
+func TestDiscoveryEndToEnd(t *testing.T) {
+	_, client := newSimulatorClient(t)
+	cache := newAgentCache()
+
+	specsJob := newPollSpecsJob().
+		SetSpecClient(client).
+		SetSpecCache(cache).
+		SetWorkers(2).
+		SetParseSpec(true)
+	assert.Nil(t, specsJob.Execute())
+	assert.True(t, specsJob.FirstRunComplete())
+
+	spec, err := cache.GetSpecWithName("petstore")
+	assert.Nil(t, err)
+	assert.Equal(t, "/organizations/org/specs/doc/1/content", spec.ContentPath)
+	path, err := cache.GetSpecPathWithEndpoint("http://test.example.com:8080/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "/organizations/org/specs/doc/2/content", path)
+	spec, err = cache.GetSpecWithName("orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "/sales/orders", spec.Folder)
+	// the trashed spec is skipped
+	_, err = cache.GetSpecWithName("orders-v1")
+	assert.NotNil(t, err)
+
+	// proxy mode publishes each deployed proxy revision
+	proxies := &publishedServices{services: map[string]apic.ServiceBody{}}
+	proxiesJob := newPollProxiesJob().
+		SetSpecClient(client).
+		SetSpecCache(cache).
+		SetSpecsReady(specsJob.FirstRunComplete).
+		SetWorkers(2)
+	proxiesJob.publishFunc = proxies.publish
+	assert.True(t, proxiesJob.Ready())
+	assert.Nil(t, proxiesJob.Execute())
+	assert.True(t, proxiesJob.FirstRunComplete())
+	assert.Len(t, proxies.services, 2)
+
+	petstore, found := proxies.services["petstore-prod"]
+	assert.True(t, found)
+	assert.Equal(t, "Petstore", petstore.NameToPush)
+	assert.Equal(t, "1", petstore.Version)
+	assert.Equal(t, []string{provisioning.APIKeyCRD}, petstore.GetCredentialRequestDefinitions(nil))
+	assert.Equal(t, []apic.EndpointDefinition{{Host: "api.example.com", Protocol: "https", BasePath: "/petstore"}}, petstore.Endpoints)
+	assert.Contains(t, string(petstore.SpecDefinition), "listPets")
+	assert.Equal(t, "/", petstore.ServiceAttributes[specFolderAttribute])
+
+	orders, found := proxies.services["orders-test"]
+	assert.True(t, found)
+	assert.Equal(t, "2", orders.Version)
+	assert.Equal(t, []string{provisioning.OAuthSecretCRD}, orders.GetCredentialRequestDefinitions(nil))
+	assert.Equal(t, []apic.EndpointDefinition{{Host: "test.example.com:8080", Port: 8080, Protocol: "http", BasePath: "/orders"}}, orders.Endpoints)
+	assert.Contains(t, string(orders.SpecDefinition), "listOrders")
+	assert.Equal(t, "/sales/orders", orders.ServiceAttributes[specFolderAttribute])
+
+	_, err = cache.GetPublishedProxy(createProxyCacheKey("petstore", "prod"))
+	assert.Nil(t, err)
+
+	// the deployed proxies are the inventory the api validator checks the services against
+	assert.True(t, cache.HasDeployedAPIs())
+	assert.True(t, cache.IsAPIDeployed(createProxyCacheKey("petstore", "prod")))
+	assert.True(t, cache.IsAPIDeployed(createProxyCacheKey("orders", "test")))
+	assert.False(t, cache.IsAPIDeployed(createProxyCacheKey("petstore", "test")))
+
+	// product mode publishes the products that are not created by the agent
+	products := &publishedServices{services: map[string]apic.ServiceBody{}}
+	productsJob := newPollProductsJob(context.Background(), client, cache, specsJob.FirstRunComplete, 2, func(map[string]string) bool { return true }, nil)
+	productsJob.isPublishedFunc = func(string) bool { return false }
+	productsJob.publishFunc = products.publish
+	assert.Nil(t, productsJob.Execute())
+	assert.True(t, productsJob.FirstRunComplete())
+	assert.Len(t, products.services, 2)
+
+	product, found := products.services["petstore-product-"]
+	assert.True(t, found)
+	assert.Equal(t, "Petstore", product.NameToPush)
+	assert.Equal(t, "public", product.ServiceAttributes["access"])
+	assert.Equal(t, "/", product.ServiceAttributes[specFolderAttribute])
+	assert.Contains(t, string(product.SpecDefinition), "listPets")
+	_, found = products.services["orders-"]
+	assert.True(t, found)
+
+	_, err = cache.GetProductWithName("orders")
+	assert.Nil(t, err)
+	assert.True(t, cache.IsAPIDeployed(createProductCacheKey("orders")))
+	assert.False(t, cache.IsAPIDeployed(createProxyCacheKey("orders", "test")))
+}
+
+func TestDiscoverySpecFolders(t *testing.T) {
+	_, client := newSimulatorClient(t)
+	cache := newAgentCache()
+
+	folders, err := config.NewSpecFolderSelector("/sales")
+	assert.Nil(t, err)
+	specsJob := newPollSpecsJob().
+		SetSpecClient(client).
+		SetSpecCache(cache).
+		SetFolders(folders)
+	assert.Nil(t, specsJob.Execute())
+
+	// only the specs in the selected folder, and its sub folders, are discovered
+	spec, err := cache.GetSpecWithName("orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "/sales/orders", spec.Folder)
+	_, err = cache.GetSpecWithName("petstore")
+	assert.NotNil(t, err)
+}
+
+// simulatorCacheManager - returns the access requests the provisioner granted to the app
+type simulatorCacheManager struct {
+	products map[string][]string
+}
+
+func (m *simulatorCacheManager) grant(appName string, properties map[string]string) {
+	m.products[appName] = append(m.products[appName], properties[prodNameRef])
+}
+
+func (m *simulatorCacheManager) GetAccessRequestsByApp(managedAppName string) []*v1.ResourceInstance {
+	instances := []*v1.ResourceInstance{}
+	for i, productName := range m.products[managedAppName] {
+		ar := management.NewAccessRequest(fmt.Sprintf("ar%d", i), "env")
+		ar.Spec.ManagedApplication = managedAppName
+		util.SetAgentDetailsKey(ar, prodNameRef, productName)
+		ri, _ := ar.AsInstance()
+		instances = append(instances, ri)
+	}
+	return instances
+}
+
+func (m *simulatorCacheManager) GetAPIServiceInstanceByName(apiName string) (*v1.ResourceInstance, error) {
+	return nil, fmt.Errorf("instance %s not found", apiName)
+}
+
+func TestProvisioningEndToEnd(t *testing.T) {
+	s, client := newSimulatorClient(t)
+	cacheMan := &simulatorCacheManager{products: map[string][]string{}}
+	p := NewProvisioner(client, 30, cacheMan, false, false, nil)
+	appName := "e2e-app"
+
+	status := p.ApplicationRequestProvision(mock.MockApplicationRequest{AppName: appName})
+	assert.Equal(t, provisioning.Success, status.GetStatus())
+	app := s.App(appName)
+	assert.NotNil(t, app)
+	assert.Contains(t, app.Attributes, apigee.ApigeeAgentAttribute)
+	assert.Empty(t, app.Credentials)
+
+	// the access request creates a product for the proxy, and for the plan quota
+	status, _ = p.AccessRequestProvision(&mock.MockAccessRequest{
+		AppName:         appName,
+		InstanceDetails: map[string]interface{}{defs.AttrExternalAPIID: "petstore", defs.AttrExternalAPIStage: "prod"},
+	})
+	assert.Equal(t, provisioning.Success, status.GetStatus())
+	assert.Equal(t, "petstore-no-quota", status.GetProperties()[prodNameRef])
+	cacheMan.grant(appName, status.GetProperties())
+	product := s.Product("petstore-no-quota")
+	assert.NotNil(t, product)
+	assert.Equal(t, []string{"petstore"}, product.Proxies)
+	assert.Equal(t, []string{"prod"}, product.Environments)
+
+	status, cred := p.CredentialProvision(&mock.MockCredentialRequest{AppName: appName, CredDefName: provisioning.APIKeyCRD})
+	assert.Equal(t, provisioning.Success, status.GetStatus())
+	credDetails := status.GetProperties()
+	app = s.App(appName)
+	assert.Len(t, app.Credentials, 1)
+	key := app.Credentials[0].ConsumerKey
+	assert.Equal(t, key, cred.GetData()[provisioning.APIKey])
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), cred.GetExpirationTime(), time.Minute)
+
+	status, _ = p.AccessRequestProvision(&mock.MockAccessRequest{
+		AppName:         appName,
+		InstanceDetails: map[string]interface{}{defs.AttrExternalAPIID: "orders", defs.AttrExternalAPIStage: "test"},
+		PlanName:        "gold",
+		QuotaLimit:      1000,
+		QuotaInterval:   provisioning.Monthly,
+	})
+	assert.Equal(t, provisioning.Success, status.GetStatus())
+	product = s.Product("orders-gold")
+	assert.NotNil(t, product)
+	assert.Equal(t, "1000", product.Quota)
+	assert.Equal(t, "month", product.QuotaTimeUnit)
+	assert.Len(t, s.App(appName).Credentials[0].ApiProducts, 2)
+
+	status, _ = p.CredentialUpdate(&mock.MockCredentialRequest{AppName: appName, Details: credDetails, Action: provisioning.Suspend})
+	assert.Equal(t, provisioning.Success, status.GetStatus())
+	assert.Equal(t, "revoked", s.App(appName).Credentials[0].Status)
+	status, _ = p.CredentialUpdate(&mock.MockCredentialRequest{AppName: appName, Details: credDetails, Action: provisioning.Enable})
+	assert.Equal(t, provisioning.Success, status.GetStatus())
+	assert.Equal(t, "approved", s.App(appName).Credentials[0].Status)
+
+	status = p.AccessRequestDeprovision(&mock.MockAccessRequest{
+		AppName:         appName,
+		InstanceDetails: map[string]interface{}{defs.AttrExternalAPIID: "orders"},
+		PlanName:        "gold",
+		QuotaLimit:      1000,
+		QuotaInterval:   provisioning.Monthly,
+	})
+	assert.Equal(t, provisioning.Success, status.GetStatus())
+	for _, ref := range s.App(appName).Credentials[0].ApiProducts {
+		if ref.Apiproduct == "orders-gold" {
+			assert.Equal(t, "revoked", ref.Status)
+		}
+	}
+
+	status = p.CredentialDeprovision(&mock.MockCredentialRequest{AppName: appName, Details: credDetails})
+	assert.Equal(t, provisioning.Success, status.GetStatus())
+	assert.Empty(t, s.App(appName).Credentials)
+
+	status = p.ApplicationRequestDeprovision(mock.MockApplicationRequest{AppName: appName})
+	assert.Equal(t, provisioning.Success, status.GetStatus())
+	assert.Nil(t, s.App(appName))
+}
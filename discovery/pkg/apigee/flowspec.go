@@ -0,0 +1,274 @@
+package apigee
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/bundle"
+)
+
+const (
+	specGeneratedDetail = "specGenerated"
+	defaultAPIKeyHeader = "x-api-key"
+)
+
+var (
+	// pathConditionRegex - matches a proxy path suffix condition, e.g. proxy.pathsuffix MatchesPath "/orders/*"
+	pathConditionRegex = regexp.MustCompile(`(?i)proxy\.pathsuffix\s+(?:MatchesPath|Matches|Equals|~/|~|==|=)\s+"([^"]*)"`)
+	// verbConditionRegex - matches a request verb condition, e.g. request.verb = "GET"
+	verbConditionRegex = regexp.MustCompile(`(?i)request\.verb\s+(?:Equals|==|=|is)\s+"([a-z]+)"`)
+	// paramNameRegex - the characters that are removed from a path segment to name a parameter after it
+	paramNameRegex = regexp.MustCompile(`[^A-Za-z0-9]`)
+)
+
+// generatedSpec - the parts of an OpenAPI 3 document that are generated from the proxy flows
+type generatedSpec struct {
+	OpenAPI    string                                    `json:"openapi"`
+	Info       generatedInfo                             `json:"info"`
+	Servers    []generatedServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*generatedOperation `json:"paths"`
+	Components *generatedComponents                      `json:"components,omitempty"`
+}
+
+type generatedInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type generatedServer struct {
+	URL string `json:"url"`
+}
+
+type generatedOperation struct {
+	OperationID string                       `json:"operationId,omitempty"`
+	Summary     string                       `json:"summary,omitempty"`
+	Parameters  []generatedParameter         `json:"parameters,omitempty"`
+	Responses   map[string]generatedResponse `json:"responses"`
+	Security    []map[string][]string        `json:"security,omitempty"`
+}
+
+type generatedParameter struct {
+	Name     string            `json:"name"`
+	In       string            `json:"in"`
+	Required bool              `json:"required"`
+	Schema   map[string]string `json:"schema"`
+}
+
+type generatedResponse struct {
+	Description string `json:"description"`
+}
+
+type generatedComponents struct {
+	SecuritySchemes map[string]*generatedSecurityScheme `json:"securitySchemes"`
+}
+
+type generatedSecurityScheme struct {
+	Type   string `json:"type"`
+	Name   string `json:"name,omitempty"`
+	In     string `json:"in,omitempty"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// specFromFlows - generates an OpenAPI 3 spec from the conditional flows of the proxy endpoints, the paths are
+// prefixed with the base path of their endpoint. Returns nil when no flow has a path or verb condition.
+func specFromFlows(proxyBundle *bundle.Bundle, title, description, version string, urls []string) ([]byte, error) {
+	spec := &generatedSpec{
+		OpenAPI: "3.0.1",
+		Info: generatedInfo{
+			Title:       title,
+			Description: description,
+			Version:     version,
+		},
+		Servers: serversFromURLs(urls),
+		Paths:   map[string]map[string]*generatedOperation{},
+	}
+	schemes := map[string]*generatedSecurityScheme{}
+
+	for _, endpoint := range proxyBundle.ProxyEndpoints {
+		// the policies in the pre and post flows apply to every operation of the endpoint
+		endpointSecurity := securityFromSteps(proxyBundle, schemes, endpoint.PreFlow, endpoint.PostFlow)
+
+		for _, flow := range endpoint.Flows {
+			paths, verbs := parseFlowCondition(flow.Condition)
+			if len(paths) == 0 && len(verbs) == 0 {
+				continue
+			}
+			if len(paths) == 0 {
+				// a flow on the verb alone applies to the base path
+				paths = []string{"/"}
+			}
+			if len(verbs) == 0 {
+				// a flow on the path alone is published as a get operation
+				verbs = []string{"get"}
+			}
+
+			security := slices.Concat(endpointSecurity, securityFromSteps(proxyBundle, schemes, flow))
+			for _, flowPath := range paths {
+				specPath, params := pathWithParameters(flowPath)
+				specPath = path.Join("/", endpoint.GetBasePath(), specPath)
+				if _, found := spec.Paths[specPath]; !found {
+					spec.Paths[specPath] = map[string]*generatedOperation{}
+				}
+
+				for _, verb := range verbs {
+					spec.Paths[specPath][verb] = newGeneratedOperation(flow, params, security)
+				}
+			}
+		}
+	}
+
+	if len(spec.Paths) == 0 {
+		return nil, nil
+	}
+	if len(schemes) > 0 {
+		spec.Components = &generatedComponents{SecuritySchemes: schemes}
+	}
+	return json.Marshal(spec)
+}
+
+func newGeneratedOperation(flow *bundle.Flow, params []generatedParameter, security []string) *generatedOperation {
+	operation := &generatedOperation{
+		OperationID: flow.Name,
+		Summary:     flow.Description,
+		Parameters:  params,
+		Responses: map[string]generatedResponse{
+			"default": {Description: "The response from the proxy"},
+		},
+	}
+	if operation.Summary == "" {
+		operation.Summary = flow.Name
+	}
+
+	// all of the policies in the flows have to pass, so all schemes are in a single requirement
+	if len(security) > 0 {
+		requirement := map[string][]string{}
+		for _, scheme := range security {
+			requirement[scheme] = []string{}
+		}
+		operation.Security = []map[string][]string{requirement}
+	}
+	return operation
+}
+
+// parseFlowCondition - returns the path suffixes and lower case verbs the flow condition matches
+func parseFlowCondition(condition string) ([]string, []string) {
+	paths := []string{}
+	for _, match := range pathConditionRegex.FindAllStringSubmatch(condition, -1) {
+		if !slices.Contains(paths, match[1]) {
+			paths = append(paths, match[1])
+		}
+	}
+
+	verbs := []string{}
+	for _, match := range verbConditionRegex.FindAllStringSubmatch(condition, -1) {
+		verb := strings.ToLower(match[1])
+		if !slices.Contains(verbs, verb) {
+			verbs = append(verbs, verb)
+		}
+	}
+	return paths, verbs
+}
+
+// pathWithParameters - replaces the wildcard segments of the path with parameters, named after the segment before them
+func pathWithParameters(flowPath string) (string, []generatedParameter) {
+	params := []generatedParameter{}
+	segments := strings.Split(strings.Trim(flowPath, "/"), "/")
+
+	for i, segment := range segments {
+		if segment != "*" && segment != "**" {
+			continue
+		}
+
+		name := ""
+		if i > 0 && !strings.Contains(segments[i-1], "{") {
+			name = paramNameRegex.ReplaceAllString(segments[i-1], "")
+			if len(name) > 1 && strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") {
+				name = strings.TrimSuffix(name, "s")
+			}
+		}
+		if name == "" || slices.ContainsFunc(params, func(p generatedParameter) bool { return p.Name == name+"Id" }) {
+			name = fmt.Sprintf("param%d", len(params)+1)
+		} else {
+			name += "Id"
+		}
+
+		segments[i] = fmt.Sprintf("{%s}", name)
+		params = append(params, generatedParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   map[string]string{"type": "string"},
+		})
+	}
+
+	return "/" + strings.Join(segments, "/"), params
+}
+
+// securityFromSteps - adds the security schemes of the authentication policies in the flows, returns their names
+func securityFromSteps(proxyBundle *bundle.Bundle, schemes map[string]*generatedSecurityScheme, flows ...*bundle.Flow) []string {
+	names := []string{}
+	for _, flow := range flows {
+		if flow == nil {
+			continue
+		}
+		for _, step := range flow.Request {
+			policy := proxyBundle.GetPolicy(step.Name)
+			if policy == nil || !policy.Enabled {
+				continue
+			}
+
+			scheme := securitySchemeFromPolicy(policy)
+			if scheme == nil {
+				continue
+			}
+			schemes[policy.Name] = scheme
+			if !slices.Contains(names, policy.Name) {
+				names = append(names, policy.Name)
+			}
+		}
+	}
+	return names
+}
+
+// securitySchemeFromPolicy - returns the security scheme of a VerifyAPIKey or OAuthV2 policy, nil for other policies
+func securitySchemeFromPolicy(policy *bundle.Policy) *generatedSecurityScheme {
+	switch policy.Type {
+	case apiKeyPolicy:
+		scheme := &generatedSecurityScheme{Type: "apiKey", In: "header", Name: defaultAPIKeyHeader}
+		if settings, err := policy.VerifyAPIKey(); err == nil && settings.In != "" {
+			scheme.In = settings.In
+			scheme.Name = settings.Name
+		}
+		return scheme
+	case oauthPolicy:
+		// only the policies that verify tokens protect the flow, the others issue tokens
+		if settings, err := policy.OAuthV2(); err != nil || settings.Operation != bundle.VerifyAccessTokenOperation {
+			return nil
+		}
+		return &generatedSecurityScheme{Type: "http", Scheme: "bearer"}
+	}
+	return nil
+}
+
+// serversFromURLs - the distinct scheme and host of the endpoint urls, the base paths are part of the spec paths
+func serversFromURLs(urls []string) []generatedServer {
+	servers := []generatedServer{}
+	for _, endpointURL := range urls {
+		u, err := url.Parse(endpointURL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+
+		server := generatedServer{URL: fmt.Sprintf("%s://%s", u.Scheme, u.Host)}
+		if !slices.Contains(servers, server) {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
@@ -0,0 +1,173 @@
+package apigee
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/bundle"
+)
+
+func Test_parseFlowCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		paths     []string
+		verbs     []string
+	}{
+		{
+			name:      "matches path and verb",
+			condition: `(proxy.pathsuffix MatchesPath "/orders/*") and (request.verb = "GET")`,
+			paths:     []string{"/orders/*"},
+			verbs:     []string{"get"},
+		},
+		{
+			name:      "short operators and several verbs",
+			condition: `(proxy.pathsuffix ~/ "/orders") and ((request.verb == "post") or (request.verb Equals "PUT"))`,
+			paths:     []string{"/orders"},
+			verbs:     []string{"post", "put"},
+		},
+		{
+			name:      "several paths",
+			condition: `(proxy.pathsuffix = "/pets") or (proxy.pathsuffix matchespath "/animals")`,
+			paths:     []string{"/pets", "/animals"},
+			verbs:     []string{},
+		},
+		{
+			name:      "negated verbs are not operations",
+			condition: `(request.verb != "OPTIONS") and (request.verb NotEquals "HEAD")`,
+			paths:     []string{},
+			verbs:     []string{},
+		},
+		{
+			name:      "conditions on other variables",
+			condition: `request.header.mock = "true"`,
+			paths:     []string{},
+			verbs:     []string{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, verbs := parseFlowCondition(tc.condition)
+			assert.Equal(t, tc.paths, paths)
+			assert.Equal(t, tc.verbs, verbs)
+		})
+	}
+}
+
+func Test_pathWithParameters(t *testing.T) {
+	tests := []struct {
+		flowPath string
+		specPath string
+		params   []string
+	}{
+		{flowPath: "/orders", specPath: "/orders", params: []string{}},
+		{flowPath: "/orders/*", specPath: "/orders/{orderId}", params: []string{"orderId"}},
+		{flowPath: "/orders/*/items/*", specPath: "/orders/{orderId}/items/{itemId}", params: []string{"orderId", "itemId"}},
+		{flowPath: "/address/*/", specPath: "/address/{addressId}", params: []string{"addressId"}},
+		{flowPath: "/*/*", specPath: "/{param1}/{param2}", params: []string{"param1", "param2"}},
+		{flowPath: "/files/**", specPath: "/files/{fileId}", params: []string{"fileId"}},
+		{flowPath: "/", specPath: "/", params: []string{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.flowPath, func(t *testing.T) {
+			specPath, params := pathWithParameters(tc.flowPath)
+			assert.Equal(t, tc.specPath, specPath)
+			names := []string{}
+			for _, p := range params {
+				assert.Equal(t, "path", p.In)
+				assert.True(t, p.Required)
+				names = append(names, p.Name)
+			}
+			assert.Equal(t, tc.params, names)
+		})
+	}
+}
+
+func newFlowsBundle() *bundle.Bundle {
+	return &bundle.Bundle{
+		ProxyEndpoints: []*bundle.ProxyEndpoint{
+			{
+				Name:                "default",
+				HTTPProxyConnection: &bundle.HTTPProxyConnection{BasePath: "/orders"},
+				PreFlow:             &bundle.Flow{Request: []*bundle.Step{{Name: "verify-api-key"}, {Name: "spike-arrest"}}},
+				Flows: []*bundle.Flow{
+					{
+						Name:        "getOrder",
+						Description: "Get an order",
+						Condition:   `(proxy.pathsuffix MatchesPath "/*") and (request.verb = "GET")`,
+					},
+					{
+						Name:      "createOrder",
+						Condition: `(proxy.pathsuffix MatchesPath "/") and (request.verb = "POST")`,
+						Request:   []*bundle.Step{{Name: "verify-token"}},
+					},
+					{
+						Name: "fault",
+					},
+				},
+			},
+			{
+				Name:                "admin",
+				HTTPProxyConnection: &bundle.HTTPProxyConnection{BasePath: "/admin"},
+				Flows: []*bundle.Flow{
+					{
+						Name:      "purge",
+						Condition: `request.verb = "DELETE"`,
+						Request:   []*bundle.Step{{Name: "issue-token"}, {Name: "disabled-key"}},
+					},
+				},
+			},
+		},
+		Policies: []*bundle.Policy{
+			{Name: "verify-api-key", Type: apiKeyPolicy, Enabled: true, Content: []byte(`<VerifyAPIKey name="verify-api-key"><APIKey ref="request.queryparam.apikey"/></VerifyAPIKey>`)},
+			{Name: "spike-arrest", Type: "SpikeArrest", Enabled: true},
+			{Name: "verify-token", Type: oauthPolicy, Enabled: true, Content: []byte(`<OAuthV2 name="verify-token"><Operation>VerifyAccessToken</Operation></OAuthV2>`)},
+			{Name: "issue-token", Type: oauthPolicy, Enabled: true, Content: []byte(`<OAuthV2 name="issue-token"><Operation>GenerateAccessToken</Operation></OAuthV2>`)},
+			{Name: "disabled-key", Type: apiKeyPolicy, Enabled: false},
+		},
+	}
+}
+
+func Test_specFromFlows(t *testing.T) {
+	data, err := specFromFlows(newFlowsBundle(), "Orders", "The orders api", "3", []string{
+		"https://api.example.com/orders",
+		"https://api.example.com/admin",
+		"http://internal.example.com:8080/orders",
+	})
+	assert.Nil(t, err)
+
+	spec := &generatedSpec{}
+	assert.Nil(t, json.Unmarshal(data, spec))
+	assert.Equal(t, "3.0.1", spec.OpenAPI)
+	assert.Equal(t, generatedInfo{Title: "Orders", Description: "The orders api", Version: "3"}, spec.Info)
+	assert.Equal(t, []generatedServer{{URL: "https://api.example.com"}, {URL: "http://internal.example.com:8080"}}, spec.Servers)
+	assert.Len(t, spec.Paths, 3)
+
+	getOrder := spec.Paths["/orders/{param1}"]["get"]
+	assert.NotNil(t, getOrder)
+	assert.Equal(t, "getOrder", getOrder.OperationID)
+	assert.Equal(t, "Get an order", getOrder.Summary)
+	assert.Len(t, getOrder.Parameters, 1)
+	assert.Equal(t, []map[string][]string{{"verify-api-key": {}}}, getOrder.Security)
+
+	createOrder := spec.Paths["/orders"]["post"]
+	assert.NotNil(t, createOrder)
+	assert.Equal(t, "createOrder", createOrder.Summary)
+	assert.Equal(t, []map[string][]string{{"verify-api-key": {}, "verify-token": {}}}, createOrder.Security)
+
+	purge := spec.Paths["/admin"]["delete"]
+	assert.NotNil(t, purge)
+	assert.Empty(t, purge.Security)
+
+	assert.Equal(t, map[string]*generatedSecurityScheme{
+		"verify-api-key": {Type: "apiKey", In: "query", Name: "apikey"},
+		"verify-token":   {Type: "http", Scheme: "bearer"},
+	}, spec.Components.SecuritySchemes)
+
+	// nothing is generated without conditional flows
+	data, err = specFromFlows(&bundle.Bundle{ProxyEndpoints: []*bundle.ProxyEndpoint{{Name: "default"}}}, "Orders", "", "1", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, data)
+}
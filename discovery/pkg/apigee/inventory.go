@@ -0,0 +1,66 @@
+package apigee
+
+import (
+	"maps"
+	"sync"
+)
+
+// deploymentInventory - the apis a single poll finds on apigee, the workers of the poll add to it concurrently
+type deploymentInventory struct {
+	lock     sync.Mutex
+	deployed map[string]string
+	// unknown - the ids of the apis whose deployments could not be read
+	unknown map[string]struct{}
+	// revisions - the cache keys of the deployed revisions the poll has handled
+	revisions map[string]struct{}
+}
+
+func newDeploymentInventory() *deploymentInventory {
+	return &deploymentInventory{
+		deployed:  make(map[string]string),
+		unknown:   make(map[string]struct{}),
+		revisions: make(map[string]struct{}),
+	}
+}
+
+// addDeployed - adds an api found on apigee with the cache key it is published with
+func (i *deploymentInventory) addDeployed(cacheKey, apiID string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.deployed[cacheKey] = apiID
+}
+
+// addUnknown - adds an api that exists but whose deployments could not be read, it is not treated as removed
+func (i *deploymentInventory) addUnknown(apiID string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.unknown[apiID] = struct{}{}
+}
+
+func (i *deploymentInventory) isUnknown(apiID string) bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	_, found := i.unknown[apiID]
+	return found
+}
+
+// addRevision - adds a deployed revision with its revision cache key
+func (i *deploymentInventory) addRevision(revisionKey string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.revisions[revisionKey] = struct{}{}
+}
+
+func (i *deploymentInventory) hasRevision(revisionKey string) bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	_, found := i.revisions[revisionKey]
+	return found
+}
+
+// getDeployed - returns a copy of the deployed apis
+func (i *deploymentInventory) getDeployed() map[string]string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return maps.Clone(i.deployed)
+}
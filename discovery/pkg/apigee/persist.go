@@ -0,0 +1,164 @@
+package apigee
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/apic"
+	"github.com/Axway/agent-sdk/pkg/jobs"
+	"github.com/Axway/agent-sdk/pkg/util/log"
+)
+
+// cacheSnapshotVersion - increased when the snapshot format changes, a snapshot of another version is not loaded
+const cacheSnapshotVersion = 4
+
+// cacheSnapshot - the on disk format of the agent cache, the items are keyed by their primary cache key
+type cacheSnapshot struct {
+	Version   int                         `json:"version"`
+	Specs     map[string]specCacheItem    `json:"specs"`
+	Products  map[string]productCacheItem `json:"products"`
+	Published map[string]publishedService `json:"published"`
+	Revisions map[string]handledRevision  `json:"revisions"`
+	// Deprecated - the time the service instances of the apis that are no longer deployed were deprecated
+	Deprecated map[string]time.Time `json:"deprecated"`
+}
+
+// publishedService - the details of a published proxy service that tell if a revision has changed
+type publishedService struct {
+	Version string `json:"version"`
+	Stage   string `json:"stage"`
+	Hash    string `json:"hash"`
+}
+
+// Save - writes a snapshot of the cache to the file, the file is replaced at once so a failed write keeps the
+// previous snapshot
+func (a *agentCache) Save(path string) error {
+	snapshot := cacheSnapshot{
+		Version:    cacheSnapshotVersion,
+		Specs:      map[string]specCacheItem{},
+		Products:   map[string]productCacheItem{},
+		Published:  map[string]publishedService{},
+		Revisions:  a.getHandledRevisions(),
+		Deprecated: a.getDeprecatedAPIs(),
+	}
+
+	for _, key := range a.cache.GetKeys() {
+		item, err := a.cache.Get(key)
+		if err != nil {
+			continue
+		}
+		switch item := item.(type) {
+		case specCacheItem:
+			snapshot.Specs[key] = item
+		case productCacheItem:
+			snapshot.Products[key] = item
+		case *apic.ServiceBody:
+			hash, _ := item.ServiceAgentDetails[fmt.Sprintf("%s-hash", item.Stage)].(string)
+			snapshot.Published[key] = publishedService{Version: item.Version, Stage: item.Stage, Hash: hash}
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic - writes the data to a temporary file that then replaces the file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load - adds the items of the snapshot in the file to the cache, a missing file leaves the cache empty
+func (a *agentCache) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	snapshot := cacheSnapshot{}
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("reading the cache snapshot %s: %w", path, err)
+	}
+	if snapshot.Version != cacheSnapshotVersion {
+		return fmt.Errorf("cache snapshot %s has version %d, expected %d", path, snapshot.Version, cacheSnapshotVersion)
+	}
+
+	for key, item := range snapshot.Specs {
+		a.addSpecItem(key, item)
+	}
+	for key, item := range snapshot.Products {
+		a.cache.Set(key, item)
+	}
+	for key, item := range snapshot.Published {
+		a.AddPublishedServiceToCache(key, &apic.ServiceBody{
+			Version:             item.Version,
+			Stage:               item.Stage,
+			ServiceAgentDetails: map[string]interface{}{fmt.Sprintf("%s-hash", item.Stage): item.Hash},
+		})
+	}
+	for key, revision := range snapshot.Revisions {
+		a.SetHandledRevision(key, revision)
+	}
+	for key, deprecatedAt := range snapshot.Deprecated {
+		a.SetDeprecated(key, deprecatedAt)
+	}
+	return nil
+}
+
+// job that saves a snapshot of the agent cache, so a restarted agent only handles what changed
+type persistCacheJob struct {
+	jobs.Job
+	cache  *agentCache
+	path   string
+	logger log.FieldLogger
+}
+
+func newPersistCacheJob(cache *agentCache, path string) *persistCacheJob {
+	return &persistCacheJob{
+		cache:  cache,
+		path:   path,
+		logger: log.NewFieldLogger().WithComponent("persistCache").WithPackage("apigee").WithField("path", path),
+	}
+}
+
+func (j *persistCacheJob) Ready() bool {
+	return true
+}
+
+func (j *persistCacheJob) Status() error {
+	return nil
+}
+
+func (j *persistCacheJob) Execute() error {
+	j.logger.Trace("saving the agent cache")
+	// a failed save is tried again on the next interval, the previous snapshot is kept until then
+	if err := j.cache.Save(j.path); err != nil {
+		j.logger.WithError(err).Error("could not save the agent cache")
+	}
+	return nil
+}
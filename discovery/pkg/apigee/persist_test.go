@@ -0,0 +1,104 @@
+package apigee
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/apic"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cacheSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "agent-apigee.json")
+	modDate := time.Now().Add(-1 * time.Hour)
+
+	c := newAgentCache()
+	c.AddSpecToCache("id1", "/path/id1", "Name-ID1", "/sales", modDate, "http://id1/endpoint1")
+	c.AddProductToCache("prod1", modDate, "123")
+	c.AddPublishedServiceToCache(createProxyCacheKey("proxy1", "prod"), &apic.ServiceBody{
+		Version:             "2",
+		Stage:               "prod",
+		ServiceAgentDetails: map[string]interface{}{"prod-hash": "abc"},
+	})
+	c.SetHandledRevision(createRevisionCacheKey("proxy1", "prod", "2"), handledRevision{Proxy: "proxy1", LastModified: 1234, Published: true})
+	c.SetDeprecated(createProductCacheKey("prod2"), modDate)
+	assert.Nil(t, c.Save(path))
+
+	// the file is replaced at once, no temporary file is left behind
+	files, err := os.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	loaded := newAgentCache()
+	assert.Nil(t, loaded.Load(path))
+
+	spec, err := loaded.GetSpecWithPath("/path/id1")
+	assert.Nil(t, err)
+	assert.Equal(t, "id1", spec.ID)
+	assert.Equal(t, "/sales", spec.Folder)
+	_, err = loaded.GetSpecWithName("name-id1")
+	assert.Nil(t, err)
+	specPath, err := loaded.GetSpecPathWithEndpoint("http://id1/endpoint1")
+	assert.Nil(t, err)
+	assert.Equal(t, "/path/id1", specPath)
+	assert.False(t, loaded.HasSpecChanged("name-id1", modDate))
+
+	assert.False(t, loaded.HasProductChanged("prod1", modDate, "123"))
+	assert.True(t, loaded.HasProductChanged("prod1", modDate, "456"))
+
+	published, err := loaded.GetPublishedProxy(createProxyCacheKey("proxy1", "prod"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2", published.Version)
+	assert.Equal(t, "abc", published.ServiceAgentDetails["prod-hash"])
+
+	revision, found := loaded.GetHandledRevision(createRevisionCacheKey("proxy1", "prod", "2"))
+	assert.True(t, found)
+	assert.Equal(t, handledRevision{Proxy: "proxy1", LastModified: 1234, Published: true}, revision)
+
+	deprecatedAt, found := loaded.GetDeprecated(createProductCacheKey("prod2"))
+	assert.True(t, found)
+	assert.True(t, modDate.Equal(deprecatedAt))
+}
+
+func Test_cacheLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		noFile   bool
+		wantErr  bool
+	}{
+		{
+			name:   "missing file",
+			noFile: true,
+		},
+		{
+			name:     "corrupt file",
+			contents: `{"version": 1, "specs": `,
+			wantErr:  true,
+		},
+		{
+			name:     "other version",
+			contents: `{"version": 3, "revisions": {}}`,
+			wantErr:  true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent-apigee.json")
+			if !tc.noFile {
+				assert.Nil(t, os.WriteFile(path, []byte(tc.contents), 0o600))
+			}
+
+			c := newAgentCache()
+			err := c.Load(path)
+			if tc.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Empty(t, c.getHandledRevisions())
+		})
+	}
+}
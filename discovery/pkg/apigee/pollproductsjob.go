@@ -3,7 +3,9 @@ package apigee
 import (
 	"context"
 	"fmt"
+	"iter"
 	"path"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -15,7 +17,6 @@ import (
 	coreutil "github.com/Axway/agent-sdk/pkg/util"
 	"github.com/Axway/agent-sdk/pkg/util/log"
 
-	"github.com/Axway/agents-apigee/client/pkg/apigee"
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
 	"github.com/Axway/agents-apigee/client/pkg/config"
 	"github.com/Axway/agents-apigee/discovery/pkg/util"
@@ -25,9 +26,9 @@ const specLocalTag = "spec_local"
 
 type productClient interface {
 	GetConfig() *config.ApigeeConfig
-	GetProducts() (apigee.Products, error)
-	GetProduct(productName string) (*models.ApiProduct, error)
-	GetSpecFile(specPath string) ([]byte, error)
+	ListProductsWithContext(ctx context.Context) iter.Seq2[string, error]
+	GetProductWithContext(ctx context.Context, productName string) (*models.ApiProduct, error)
+	GetSpecFileWithContext(ctx context.Context, specPath string) ([]byte, error)
 	IsReady() bool
 }
 
@@ -39,9 +40,11 @@ type productCacheItem struct {
 
 type productCache interface {
 	GetSpecWithName(name string) (*specCacheItem, error)
+	GetSpecWithPath(path string) (*specCacheItem, error)
 	AddProductToCache(name string, modDate time.Time, specHash string)
 	HasProductChanged(name string, modDate time.Time, specHash string) bool
 	GetProductWithName(name string) (*productCacheItem, error)
+	UpdateDeployedAPIs(inventory *deploymentInventory)
 }
 
 type isPublishedFunc func(string) bool
@@ -50,6 +53,7 @@ type getAttributeFunc func(string, string) string
 // job that will poll for any new portals on APIGEE Edge
 type pollProductsJob struct {
 	jobs.Job
+	ctx              context.Context
 	client           productClient
 	cache            productCache
 	firstRun         bool
@@ -59,14 +63,20 @@ type pollProductsJob struct {
 	getAttributeFunc getAttributeFunc
 	publishFunc      agent.PublishAPIFunc
 	logger           log.FieldLogger
-	workers          int
+	pool             *workerPool
+	specContent      *specContentStore
 	running          bool
 	runningLock      sync.Mutex
 	shouldPushAPI    func(map[string]string) bool
+	environments     *config.EnvironmentSelector
+	// localSpecs - the spec file in the local directory of each product with a spec_local attribute
+	localSpecs     map[string]string
+	localSpecsLock sync.Mutex
 }
 
-func newPollProductsJob(client productClient, cache productCache, specsReady jobFirstRunDone, workers int, shouldPushAPI func(map[string]string) bool) *pollProductsJob {
+func newPollProductsJob(ctx context.Context, client productClient, cache productCache, specsReady jobFirstRunDone, workers int, shouldPushAPI func(map[string]string) bool, environments *config.EnvironmentSelector) *pollProductsJob {
 	job := &pollProductsJob{
+		ctx:              ctx,
 		client:           client,
 		cache:            cache,
 		firstRun:         true,
@@ -75,13 +85,22 @@ func newPollProductsJob(client productClient, cache productCache, specsReady job
 		isPublishedFunc:  agent.IsAPIPublishedByID,
 		getAttributeFunc: agent.GetAttributeOnPublishedAPIByID,
 		publishFunc:      agent.PublishAPI,
-		workers:          workers,
+		pool:             newWorkerPool("products", workers),
+		specContent:      newSpecContentStore(""),
 		runningLock:      sync.Mutex{},
 		shouldPushAPI:    shouldPushAPI,
+		environments:     environments,
+		localSpecs:       map[string]string{},
 	}
 	return job
 }
 
+// SetSpecContent - the store the spec contents are shared with the other jobs in
+func (j *pollProductsJob) SetSpecContent(specContent *specContentStore) *pollProductsJob {
+	j.specContent = specContent
+	return j
+}
+
 func (j *pollProductsJob) Ready() bool {
 	j.logger.Trace("checking if the apigee client is ready for calls")
 	if !j.client.IsReady() {
@@ -102,84 +121,153 @@ func (j *pollProductsJob) updateRunning(running bool) {
 	j.running = running
 }
 
-func (j *pollProductsJob) isRunning() bool {
+// startRunning - marks the job running, returns false when a run has not completed
+func (j *pollProductsJob) startRunning() bool {
 	j.runningLock.Lock()
 	defer j.runningLock.Unlock()
-	return j.running
+	if j.running {
+		return false
+	}
+	j.running = true
+	return true
 }
 
 func (j *pollProductsJob) Execute() error {
 	j.logger.Trace("executing")
 
-	if j.isRunning() {
+	if !j.startRunning() {
 		j.logger.Warn("previous spec poll job run has not completed, will run again on next interval")
 		return nil
 	}
-	j.updateRunning(true)
 	defer j.updateRunning(false)
 
-	products, err := j.client.GetProducts()
-	if err != nil {
-		j.logger.WithError(err).Error("getting products")
-		return err
+	// handle the products as each page of names is read
+	var listErr error
+	run := j.pool.start(j.ctx)
+	inventory := newDeploymentInventory()
+	for p, err := range j.client.ListProductsWithContext(j.ctx) {
+		if err == nil {
+			// stop handing out products once the agent is stopping
+			err = j.ctx.Err()
+		}
+		if err != nil {
+			listErr = err
+			break
+		}
+		run.submit(p, func(ctx context.Context) error {
+			return j.handleProduct(ctx, p, inventory)
+		})
 	}
+	run.wait()
 
-	limiter := make(chan string, j.workers)
-
-	wg := sync.WaitGroup{}
-	wg.Add(len(products))
-	for _, p := range products {
-		go func() {
-			defer wg.Done()
-			name := <-limiter
-			j.handleProduct(name)
-		}()
-		limiter <- p
+	if listErr != nil {
+		j.logger.WithError(listErr).Error("getting products")
+		return listErr
 	}
 
-	wg.Wait()
-	close(limiter)
-
+	// only a complete list of the products may mark the services of deleted products as removed
+	j.cache.UpdateDeployedAPIs(inventory)
 	j.firstRun = false
-	return nil
+	return run.result(j.client.IsReady)
 }
 
 func (j *pollProductsJob) FirstRunComplete() bool {
 	return !j.firstRun
 }
 
-func (j *pollProductsJob) handleProduct(productName string) {
+// republishSpecFiles - publishes the products of the changed spec files in the local directory again, a product
+// names its spec file in the spec_local attribute. A running poll leaves the products to its next run, which
+// handles all products
+func (j *pollProductsJob) republishSpecFiles(files []string) {
+	products := j.localSpecProducts(files)
+	if len(products) == 0 {
+		return
+	}
+
+	if !j.startRunning() {
+		return
+	}
+	defer j.updateRunning(false)
+	if j.firstRun {
+		return
+	}
+
+	run := j.pool.start(j.ctx)
+	inventory := newDeploymentInventory()
+	for _, p := range products {
+		j.logger.WithField("productName", p).Info("spec file changed, publishing the product again")
+		run.submit(p, func(ctx context.Context) error {
+			return j.handleProduct(ctx, p, inventory)
+		})
+	}
+	run.wait()
+
+	if err := run.err(); err != nil {
+		j.logger.WithError(err).Error("publishing the products of the changed spec files")
+	}
+}
+
+// setLocalSpec - the spec file in the local directory of the product, empty when it has none
+func (j *pollProductsJob) setLocalSpec(productName, file string) {
+	j.localSpecsLock.Lock()
+	defer j.localSpecsLock.Unlock()
+	if file == "" {
+		delete(j.localSpecs, productName)
+		return
+	}
+	j.localSpecs[productName] = path.Clean(file)
+}
+
+// localSpecProducts - the products with one of the spec files in the local directory, sorted by name
+func (j *pollProductsJob) localSpecProducts(files []string) []string {
+	j.localSpecsLock.Lock()
+	defer j.localSpecsLock.Unlock()
+	products := []string{}
+	for productName, file := range j.localSpecs {
+		if slices.Contains(files, file) {
+			products = append(products, productName)
+		}
+	}
+	slices.Sort(products)
+	return products
+}
+
+// handleProduct - publishes the product when it has changed, a product the discovery filter or the selected
+// environments exclude is not deployed as far as its service is concerned
+func (j *pollProductsJob) handleProduct(ctx context.Context, productName string, inventory *deploymentInventory) error {
 	logger := j.logger.WithField("productName", productName)
 	logger.Trace("handling product")
 
 	// get product full details
-	ctx := addLoggerToContext(context.Background(), logger)
+	ctx = addLoggerToContext(ctx, logger)
 
 	// get the full product details
-	productDetails, err := j.client.GetProduct(productName)
+	productDetails, err := j.client.GetProductWithContext(ctx, productName)
 	if err != nil {
-		logger.WithError(err).Trace("could not retrieve product details")
-		return
+		logger.WithError(err).Error("could not retrieve product details")
+		inventory.addUnknown(productName)
+		return err
 	}
 	logger = logger.WithField("productDisplay", productDetails.DisplayName)
 
 	if !j.shouldPublishProduct(logger, productDetails) {
 		logger.Trace("product has been filtered out")
-		return
+		return nil
 	}
+	inventory.addDeployed(createProductCacheKey(productName), productName)
 
 	// try to get spec by using the name of the product
 	ctx, err = j.getSpecDetails(ctx, productDetails)
 	if err != nil {
 		logger.Trace("could not find spec for product by name")
-		return
+		return nil
 	}
 
 	// create service
 	serviceBody, specHash, err := j.buildServiceBody(ctx, productDetails)
 	if err != nil {
 		logger.WithError(err).Error("building service body")
-		return
+		return err
 	}
 
 	serviceBodyHash, _ := coreutil.ComputeHash(*serviceBody)
@@ -204,9 +292,12 @@ func (j *pollProductsJob) handleProduct(productName string) {
 		err = j.publishAPI(*serviceBody, hashString, cacheKey)
 	}
 
-	if err == nil {
-		j.cache.AddProductToCache(productName, time.UnixMilli(int64(productDetails.LastModifiedAt)), specHashString)
+	if err != nil {
+		logger.WithError(err).Error("publishing api")
+		return err
 	}
+	j.cache.AddProductToCache(productName, time.UnixMilli(int64(productDetails.LastModifiedAt)), specHashString)
+	return nil
 }
 
 func (j *pollProductsJob) shouldPublishProduct(logger log.FieldLogger, product *models.ApiProduct) bool {
@@ -226,18 +317,38 @@ func (j *pollProductsJob) shouldPublishProduct(logger log.FieldLogger, product *
 		return false
 	}
 
+	if !j.inSelectedEnvironment(product) {
+		logger.WithField("environments", product.Environments).Trace("product is not in a selected environment")
+		return false
+	}
+
 	logger.WithField("attributes", attributes).Trace("checking against discovery filter")
 	return j.shouldPushAPI(attributes)
 }
 
+// inSelectedEnvironment - returns true when the product is in one of the selected environments, or in all environments
+func (j *pollProductsJob) inSelectedEnvironment(product *models.ApiProduct) bool {
+	if len(product.Environments) == 0 {
+		return true
+	}
+	for _, env := range product.Environments {
+		if j.environments.IsSelected(env) {
+			return true
+		}
+	}
+	return false
+}
+
 func (j *pollProductsJob) getSpecDetails(ctx context.Context, product *models.ApiProduct) (context.Context, error) {
 	for _, att := range product.Attributes {
 		// find the spec_local tag
 		if strings.ToLower(att.Name) == specLocalTag {
+			j.setLocalSpec(product.Name, att.Value)
 			ctx = context.WithValue(ctx, specPathField, strings.Join([]string{specLocalTag, att.Value}, "_"))
 			return ctx, nil
 		}
 	}
+	j.setLocalSpec(product.Name, "")
 
 	specDetails, err := j.cache.GetSpecWithName(product.Name)
 	if err != nil {
@@ -248,6 +359,7 @@ func (j *pollProductsJob) getSpecDetails(ctx context.Context, product *models.Ap
 		}
 	}
 	ctx = context.WithValue(ctx, specPathField, specDetails.ContentPath)
+	ctx = context.WithValue(ctx, specFolderField, specDetails.Folder)
 	return ctx, nil
 }
 
@@ -257,15 +369,16 @@ func (j *pollProductsJob) buildServiceBody(ctx context.Context, product *models.
 
 	var spec []byte
 	var err error
+	specName := specPath
 	if strings.HasPrefix(specPath, specLocalTag) {
 		logger = logger.WithField("specLocalDir", "true")
 		fileName := strings.TrimPrefix(specPath, specLocalTag+"_")
-		filePath := path.Join(j.client.GetConfig().Specs.LocalPath, fileName)
-		spec, err = loadSpecFile(logger, filePath)
+		specName = path.Join(j.client.GetConfig().Specs.LocalPath, fileName)
+		spec, err = loadSpecFile(logger, specName)
 	} else {
 		logger = logger.WithField("specLocalDir", "false")
 		// get the spec to build the service body
-		spec, err = j.client.GetSpecFile(specPath)
+		spec, err = j.specContent.getSpecFile(ctx, j.cache, specPath, j.client.GetSpecFileWithContext)
 	}
 
 	if err != nil {
@@ -292,6 +405,10 @@ func (j *pollProductsJob) buildServiceBody(ctx context.Context, product *models.
 		name = strings.ReplaceAll(name, " ", "_")
 		serviceAttributes[name] = att.Value
 	}
+	// specs in the local directory are not in a spec store folder
+	if folder, _ := ctx.Value(specFolderField).(string); folder != "" {
+		serviceAttributes[specFolderAttribute] = folder
+	}
 
 	logger.Debug("creating service body")
 	sb, err := apic.NewServiceBodyBuilder().
@@ -299,6 +416,7 @@ func (j *pollProductsJob) buildServiceBody(ctx context.Context, product *models.
 		SetAPIName(product.Name).
 		SetDescription(product.Description).
 		SetAPISpec(spec).
+		SetResourceType(specType(specName, spec)).
 		SetTitle(product.DisplayName).
 		SetServiceAttribute(serviceAttributes).
 		SetServiceAgentDetails(serviceDetails).
@@ -316,7 +434,6 @@ func (j *pollProductsJob) publishAPI(serviceBody apic.ServiceBody, hashString, c
 	err := j.publishFunc(serviceBody)
 	if err == nil {
 		log.Infof("Published API %s to AMPLIFY Central", serviceBody.NameToPush)
-		return err
 	}
-	return nil
+	return err
 }
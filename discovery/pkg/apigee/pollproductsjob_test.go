@@ -1,12 +1,17 @@
 package apigee
 
 import (
+	"context"
 	"fmt"
+	"iter"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Axway/agent-sdk/pkg/apic"
-	"github.com/Axway/agents-apigee/client/pkg/apigee"
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
 	"github.com/Axway/agents-apigee/client/pkg/config"
 	"github.com/stretchr/testify/assert"
@@ -22,6 +27,10 @@ func Test_pollProductsJob(t *testing.T) {
 		filterFailed   bool
 		specNotInCache bool
 		apiPublished   bool
+		cancelled      bool
+		environments   string
+		notInEnvs      bool
+		disconnected   bool
 	}{
 		{
 			name:         "api already published create update",
@@ -42,18 +51,36 @@ func Test_pollProductsJob(t *testing.T) {
 			name:           "do not publish when spec was not in the cache",
 			specNotInCache: true,
 		},
+		{
+			name:         "api published when in a selected environment",
+			environments: "dev, int",
+		},
+		{
+			name:         "do not publish when not in a selected environment",
+			environments: "dev, prod",
+			notInEnvs:    true,
+		},
 		{
 			name:         "do not publish when should publish check fails",
 			filterFailed: true,
 		},
 		{
-			name:          "should stop when getting product details fails",
+			name:          "should continue when getting product details fails",
+			getProductErr: true,
+		},
+		{
+			name:          "should fail when the apigee connection is broken",
 			getProductErr: true,
+			disconnected:  true,
 		},
 		{
 			name:          "should stop when getting all products fails",
 			allProductErr: true,
 		},
+		{
+			name:      "should stop when the job context is cancelled",
+			cancelled: true,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -63,10 +90,12 @@ func Test_pollProductsJob(t *testing.T) {
 				allProductErr: tc.allProductErr,
 				getProductErr: tc.getProductErr,
 				specNotFound:  tc.specNotFound,
+				disconnected:  tc.disconnected,
 			}
 
 			cache := mockProductCache{
 				specNotInCache: tc.specNotInCache,
+				deployed:       map[string]string{},
 			}
 
 			readyFunc := func() bool {
@@ -77,7 +106,16 @@ func Test_pollProductsJob(t *testing.T) {
 				return !tc.filterFailed
 			}
 
-			productJob := newPollProductsJob(client, cache, readyFunc, 10, filterFunc)
+			ctx, cancel := context.WithCancel(context.Background())
+			if tc.cancelled {
+				cancel()
+			}
+			defer cancel()
+
+			environments, err := config.NewEnvironmentSelector(tc.environments, "", "")
+			assert.Nil(t, err)
+
+			productJob := newPollProductsJob(ctx, client, cache, readyFunc, 10, filterFunc, environments)
 			assert.False(t, productJob.FirstRunComplete())
 
 			productJob.isPublishedFunc = func(id string) bool {
@@ -91,15 +129,23 @@ func Test_pollProductsJob(t *testing.T) {
 				return nil
 			}
 
-			err := productJob.Execute()
-			if tc.allProductErr {
+			err = productJob.Execute()
+			if tc.allProductErr || tc.cancelled || tc.disconnected {
 				assert.NotNil(t, err)
 			} else {
 				assert.Nil(t, err)
+
+				// only the products that pass the discovery filter and the selected environments are deployed
+				productName := tc.productName
+				if productName == "" {
+					productName = "RTE"
+				}
+				_, deployed := cache.deployed[createProductCacheKey(productName)]
+				assert.Equal(t, !tc.getProductErr && !tc.filterFailed && !tc.notInEnvs, deployed)
 			}
 
 			// error getting all proxies should not flip first run
-			if tc.allProductErr || tc.getProductErr || tc.filterFailed || tc.specNotInCache {
+			if tc.allProductErr || tc.getProductErr || tc.filterFailed || tc.specNotInCache || tc.cancelled || tc.notInEnvs {
 				assert.False(t, publishCalled)
 			} else {
 				assert.True(t, publishCalled)
@@ -108,6 +154,53 @@ func Test_pollProductsJob(t *testing.T) {
 	}
 }
 
+func Test_pollProductsJobSpecFileChanged(t *testing.T) {
+	client := &mockProductClient{
+		t:         t,
+		cfg:       config.NewApigeeConfig(),
+		specLocal: "sales/rte.json",
+	}
+	client.cfg.Specs.LocalPath = t.TempDir()
+	specFile := filepath.Join(client.cfg.Specs.LocalPath, "sales", "rte.json")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(specFile), 0700))
+	assert.Nil(t, os.WriteFile(specFile, []byte(oasSpec), 0600))
+
+	environments, err := config.NewEnvironmentSelector("", "", "")
+	assert.Nil(t, err)
+	ready := func() bool { return true }
+	filter := func(map[string]string) bool { return true }
+	productJob := newPollProductsJob(context.Background(), client, mockProductCache{}, ready, 1, filter, environments)
+	productJob.isPublishedFunc = func(string) bool { return false }
+
+	specs := []string{}
+	productJob.publishFunc = func(sb apic.ServiceBody) error {
+		specs = append(specs, string(sb.SpecDefinition))
+		return nil
+	}
+
+	// the first run publishes all products
+	productJob.republishSpecFiles([]string{"sales/rte.json"})
+	assert.Empty(t, specs)
+	assert.Nil(t, productJob.Execute())
+	assert.Equal(t, []string{oasSpec}, specs)
+
+	// only the products naming the spec file are published again
+	productJob.republishSpecFiles([]string{"rte.json", "sales/cell.json"})
+	assert.Len(t, specs, 1)
+
+	changedSpec := strings.Replace(oasSpec, "1.0.0", "2.0.0", 1)
+	assert.Nil(t, os.WriteFile(specFile, []byte(changedSpec), 0600))
+	productJob.republishSpecFiles([]string{"sales/rte.json"})
+	assert.Equal(t, []string{oasSpec, changedSpec}, specs)
+
+	// a product that no longer names the spec file is not published again
+	client.specLocal = ""
+	assert.Nil(t, productJob.Execute())
+	published := len(specs)
+	productJob.republishSpecFiles([]string{"sales/rte.json"})
+	assert.Len(t, specs, published)
+}
+
 type mockProductClient struct {
 	t             *testing.T
 	cfg           *config.ApigeeConfig
@@ -115,27 +208,32 @@ type mockProductClient struct {
 	allProductErr bool
 	getProductErr bool
 	specNotFound  bool
+	// disconnected - the apigee client is no longer authenticated
+	disconnected bool
+	// specLocal - the spec_local attribute of the products, naming their spec file in the local directory
+	specLocal string
 }
 
 func (m mockProductClient) GetConfig() *config.ApigeeConfig {
 	return m.cfg
 }
 
-func (m mockProductClient) GetProducts() (products apigee.Products, err error) {
+func (m mockProductClient) ListProductsWithContext(_ context.Context) iter.Seq2[string, error] {
 	productName := m.productName
 	if productName == "" {
 		productName = "RTE"
 	}
 
-	products = []string{productName}
-	if m.allProductErr {
-		products = nil
-		err = fmt.Errorf("error get all products")
+	return func(yield func(string, error) bool) {
+		if m.allProductErr {
+			yield("", fmt.Errorf("error get all products"))
+			return
+		}
+		yield(productName, nil)
 	}
-	return
 }
 
-func (m mockProductClient) GetProduct(productName string) (*models.ApiProduct, error) {
+func (m mockProductClient) GetProductWithContext(_ context.Context, productName string) (*models.ApiProduct, error) {
 	products := map[string]*models.ApiProduct{
 		"RTE": {ApiResources: []string{},
 			ApprovalType: "auto",
@@ -213,6 +311,9 @@ func (m mockProductClient) GetProduct(productName string) (*models.ApiProduct, e
 	if m.getProductErr {
 		return nil, fmt.Errorf("error get product")
 	}
+	if product, found := products[productName]; found && m.specLocal != "" {
+		product.Attributes = append(product.Attributes, models.Attribute{Name: specLocalTag, Value: m.specLocal})
+	}
 	return products[productName], nil
 }
 
@@ -236,15 +337,17 @@ const oasSpec = `{
   }
 }`
 
-func (m mockProductClient) GetSpecFile(path string) ([]byte, error) {
+func (m mockProductClient) GetSpecFileWithContext(_ context.Context, path string) ([]byte, error) {
 	assert.Equal(m.t, specPath, path)
 	return []byte(oasSpec), nil
 }
 
-func (m mockProductClient) IsReady() bool { return false }
+func (m mockProductClient) IsReady() bool { return !m.disconnected }
 
 type mockProductCache struct {
 	specNotInCache bool
+	// deployed - receives the deployed products of a complete poll when set
+	deployed map[string]string
 }
 
 func (m mockProductCache) GetSpecWithName(name string) (*specCacheItem, error) {
@@ -259,9 +362,27 @@ func (m mockProductCache) GetSpecWithName(name string) (*specCacheItem, error) {
 	}, nil
 }
 
+func (m mockProductCache) GetSpecWithPath(path string) (*specCacheItem, error) {
+	if m.specNotInCache {
+		return nil, fmt.Errorf("spec not in cache")
+	}
+	return &specCacheItem{
+		ID:          "id",
+		Name:        "name",
+		ContentPath: path,
+		ModDate:     time.Now(),
+	}, nil
+}
+
 func (m mockProductCache) AddPublishedServiceToCache(cacheKey string, serviceBody *apic.ServiceBody) {
 }
 
+func (m mockProductCache) UpdateDeployedAPIs(inventory *deploymentInventory) {
+	if m.deployed != nil {
+		maps.Copy(m.deployed, inventory.getDeployed())
+	}
+}
+
 func (m mockProductCache) AddProductToCache(name string, modDate time.Time, specHash string) {
 }
 
@@ -3,8 +3,13 @@ package apigee
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
 	"path"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/Axway/agent-sdk/pkg/agent"
@@ -16,6 +21,7 @@ import (
 	"github.com/Axway/agent-sdk/pkg/util/log"
 
 	"github.com/Axway/agents-apigee/client/pkg/apigee"
+	"github.com/Axway/agents-apigee/client/pkg/apigee/bundle"
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
 	"github.com/Axway/agents-apigee/client/pkg/config"
 	"github.com/Axway/agents-apigee/discovery/pkg/util"
@@ -24,27 +30,30 @@ import (
 const (
 	gatewayType = "Apigee"
 
-	proxyNameField       ctxKeys = "proxy"
-	envNameField         ctxKeys = "environment"
-	revNameField         ctxKeys = "revision"
-	specPathField        ctxKeys = "specPath"
-	hasQuotaPolicyField  ctxKeys = "hasQuota"
-	hasAPIKeyPolicyField ctxKeys = "hasAPIKey"
-	hasOAuthPolicyField  ctxKeys = "hasOauth"
-	endpointsField       ctxKeys = "endpoints"
+	proxyNameField   ctxKeys = "proxy"
+	envNameField     ctxKeys = "environment"
+	revNameField     ctxKeys = "revision"
+	specPathField    ctxKeys = "specPath"
+	specFolderField  ctxKeys = "specFolder"
+	specCredsField   ctxKeys = "specCredentials"
+	specChangedField ctxKeys = "specChanged"
+	policiesField    ctxKeys = "policies"
+	endpointsField   ctxKeys = "endpoints"
+	bundleField      ctxKeys = "bundle"
 )
 
 type proxyClient interface {
 	GetConfig() *config.ApigeeConfig
-	GetAllProxies() (apigee.Proxies, error)
-	GetRevision(proxyName, revision string) (*models.ApiProxyRevision, error)
-	GetRevisionResourceFile(proxyName, revision, resourceType, resourceName string) ([]byte, error)
-	GetRevisionConnectionType(proxyName, revision string) (*apigee.HTTPProxyConnection, error)
-	GetDeployments(apiName string) (*models.DeploymentDetails, error)
-	GetVirtualHost(envName, virtualHostName string) (*models.VirtualHost, error)
-	GetSpecFile(specPath string) ([]byte, error)
-	GetSpecFromURL(url string, options ...apigee.RequestOption) ([]byte, error)
-	GetRevisionPolicyByName(proxyName, revision, policyName string) (*apigee.PolicyDetail, error)
+	ListProxiesWithContext(ctx context.Context) iter.Seq2[string, error]
+	GetRevisionWithContext(ctx context.Context, proxyName, revision string) (*models.ApiProxyRevision, error)
+	GetRevisionResourceFileWithContext(ctx context.Context, proxyName, revision, resourceType, resourceName string) ([]byte, error)
+	GetRevisionBundleWithContext(ctx context.Context, proxyName, revision string) (*bundle.Bundle, error)
+	GetDeploymentsWithContext(ctx context.Context, apiName string) (*models.DeploymentDetails, error)
+	GetVirtualHostWithContext(ctx context.Context, envName, virtualHostName string) (*models.VirtualHost, error)
+	GetEnvironmentGroupHostnamesWithContext(ctx context.Context, envName string) ([]string, error)
+	GetSpecFileWithContext(ctx context.Context, specPath string) ([]byte, error)
+	GetSpecFromURLIfModifiedWithContext(ctx context.Context, url string, validators apigee.SpecValidators, options ...apigee.RequestOption) (*apigee.SpecDownload, error)
+	WithSpecCredentials(name string) (apigee.RequestOption, error)
 	IsReady() bool
 }
 
@@ -53,37 +62,59 @@ type proxyCache interface {
 	GetSpecWithName(name string) (*specCacheItem, error)
 	GetSpecPathWithEndpoint(endpoint string) (string, error)
 	AddPublishedServiceToCache(cacheKey string, serviceBody *apic.ServiceBody)
+	GetPublishedProxy(cacheKey string) (*apic.ServiceBody, error)
+	GetHandledRevision(revisionKey string) (handledRevision, bool)
+	SetHandledRevision(revisionKey string, revision handledRevision)
+	IsProxyHandled(proxyName string) bool
+	GetHandledSpecURLs() map[string]struct{}
+	UpdateDeployedAPIs(inventory *deploymentInventory)
 }
 
 // job that will poll for any new portals on APIGEE Edge
 type pollProxiesJob struct {
 	jobs.Job
-	client      proxyClient
-	firstRun    bool
-	cache       proxyCache
-	logger      log.FieldLogger
-	specsReady  jobFirstRunDone
-	pubLock     sync.Mutex
-	publishFunc agent.PublishAPIFunc
-	environment string
-	workers     int
-	running     bool
-	matchOnURL  bool
-	runningLock sync.Mutex
-	lastTime    int
-	runTime     int
+	ctx           context.Context
+	client        proxyClient
+	firstRun      bool
+	cache         proxyCache
+	logger        log.FieldLogger
+	specsReady    jobFirstRunDone
+	pubLock       sync.Mutex
+	publishFunc   agent.PublishAPIFunc
+	isPublished   isPublishedFunc
+	environments  *config.EnvironmentSelector
+	shouldPushAPI func(map[string]string) bool
+	pool          *workerPool
+	specContent   *specContentStore
+	running       bool
+	matchOnURL    bool
+	runningLock   sync.Mutex
+	// specChanged - the proxies whose spec file in the local directory changed, published again on the next run
+	specChanged     map[string]bool
+	specChangedLock sync.Mutex
 }
 
 func newPollProxiesJob() *pollProxiesJob {
 	job := &pollProxiesJob{
+		ctx:         context.Background(),
 		firstRun:    true,
 		logger:      log.NewFieldLogger().WithComponent("pollProxies").WithPackage("apigee"),
 		publishFunc: agent.PublishAPI,
+		isPublished: agent.IsAPIPublishedByID,
+		pool:        newWorkerPool("proxies", 1),
+		specContent: newSpecContentStore(""),
 		runningLock: sync.Mutex{},
+		specChanged: map[string]bool{},
 	}
 	return job
 }
 
+// SetContext - the context the api calls are made with, cancelling it stops the job
+func (j *pollProxiesJob) SetContext(ctx context.Context) *pollProxiesJob {
+	j.ctx = ctx
+	return j
+}
+
 func (j *pollProxiesJob) SetSpecClient(client proxyClient) *pollProxiesJob {
 	j.client = client
 	return j
@@ -94,8 +125,21 @@ func (j *pollProxiesJob) SetSpecCache(cache proxyCache) *pollProxiesJob {
 	return j
 }
 
-func (j *pollProxiesJob) SetEnvironment(env string) *pollProxiesJob {
-	j.environment = env
+// SetEnvironments - the environments the proxies are discovered in, all when nil
+func (j *pollProxiesJob) SetEnvironments(environments *config.EnvironmentSelector) *pollProxiesJob {
+	j.environments = environments
+	return j
+}
+
+// SetShouldPushAPI - the discovery filter the proxy tags are evaluated against, all proxies are published when nil
+func (j *pollProxiesJob) SetShouldPushAPI(shouldPushAPI func(map[string]string) bool) *pollProxiesJob {
+	j.shouldPushAPI = shouldPushAPI
+	return j
+}
+
+// SetSpecContent - the store the spec contents are shared with the other jobs in
+func (j *pollProxiesJob) SetSpecContent(specContent *specContentStore) *pollProxiesJob {
+	j.specContent = specContent
 	return j
 }
 
@@ -104,8 +148,9 @@ func (j *pollProxiesJob) SetSpecsReady(specsReady jobFirstRunDone) *pollProxiesJ
 	return j
 }
 
+// SetWorkers - the number of workers handling the proxies, their environments share the same workers
 func (j *pollProxiesJob) SetWorkers(workers int) *pollProxiesJob {
-	j.workers = workers
+	j.pool = newWorkerPool("proxies", workers)
 	return j
 }
 
@@ -138,123 +183,265 @@ func (j *pollProxiesJob) updateRunning(running bool) {
 	j.running = running
 }
 
-func (j *pollProxiesJob) isRunning() bool {
+// startRunning - marks the job running, returns false when a run has not completed
+func (j *pollProxiesJob) startRunning() bool {
 	j.runningLock.Lock()
 	defer j.runningLock.Unlock()
-	return j.running
+	if j.running {
+		return false
+	}
+	j.running = true
+	return true
 }
 
 func (j *pollProxiesJob) Execute() error {
 	j.logger.Trace("executing")
 
-	if j.isRunning() {
+	if !j.startRunning() {
 		j.logger.Warn("previous proxies poll job run has not completed, will run again on next interval")
 		return nil
 	}
-	j.updateRunning(true)
 	defer j.updateRunning(false)
 
-	allProxies, err := j.client.GetAllProxies()
-	if err != nil {
-		j.logger.WithError(err).Error("getting proxies")
-		return err
+	agent.PublishingLock()
+	defer agent.PublishingUnlock()
+
+	// handle the proxies as each page of names is read
+	var listErr error
+	run := j.pool.start(j.ctx)
+	inventory := newDeploymentInventory()
+	specChanged := j.takeSpecChanged()
+	for proxyName, err := range j.client.ListProxiesWithContext(j.ctx) {
+		if err == nil {
+			// stop handing out proxies once the agent is stopping
+			err = j.ctx.Err()
+		}
+		if err != nil {
+			listErr = err
+			break
+		}
+		run.submit(proxyName, func(ctx context.Context) error {
+			return j.handleProxy(context.WithValue(ctx, specChangedField, specChanged[proxyName]), run, proxyName, inventory)
+		})
 	}
+	run.wait()
 
-	limiter := make(chan string, j.workers)
+	if listErr != nil {
+		j.logger.WithError(listErr).Error("getting proxies")
+		return listErr
+	}
+
+	// only a complete list of the proxies may mark the services of missing proxies as removed
+	j.cache.UpdateDeployedAPIs(inventory)
+	j.specContent.retainURLs(j.cache.GetHandledSpecURLs())
+	j.firstRun = false
+	return run.result(j.client.IsReady)
+}
+
+// republishSpecFiles - publishes the proxies of the changed spec files in the local directory again, the spec file
+// of a proxy is named after it. A running poll leaves the proxies to its next run
+func (j *pollProxiesJob) republishSpecFiles(files []string) {
+	proxies := []string{}
+	for _, file := range files {
+		// the proxies a poll handled, including the ones filtered out or not published for lack of a spec
+		if proxyName, ok := j.specFileProxy(file); ok && j.cache.IsProxyHandled(proxyName) {
+			proxies = append(proxies, proxyName)
+		}
+	}
+	if len(proxies) == 0 {
+		return
+	}
+	j.markSpecChanged(proxies)
+
+	if !j.startRunning() {
+		return
+	}
+	defer j.updateRunning(false)
+	if j.firstRun {
+		// the first run handles all proxies
+		return
+	}
 
 	agent.PublishingLock()
 	defer agent.PublishingUnlock()
 
-	wg := sync.WaitGroup{}
-	wg.Add(len(allProxies))
-	j.runTime = j.lastTime
-	for _, proxyName := range allProxies {
-		go func() {
-			defer wg.Done()
-			name := <-limiter
-			j.handleProxy(name)
-		}()
-		limiter <- proxyName
+	run := j.pool.start(j.ctx)
+	inventory := newDeploymentInventory()
+	for proxyName := range j.takeSpecChanged() {
+		j.logger.WithField(proxyNameField.String(), proxyName).Info("spec file changed, publishing the proxy again")
+		run.submit(proxyName, func(ctx context.Context) error {
+			return j.handleProxy(context.WithValue(ctx, specChangedField, true), run, proxyName, inventory)
+		})
 	}
+	run.wait()
 
-	wg.Wait()
-	close(limiter)
+	if err := run.err(); err != nil {
+		j.logger.WithError(err).Error("publishing the proxies of the changed spec files")
+	}
+}
 
-	j.firstRun = false
-	return nil
+// specFileProxy - the name of the proxy of the spec file, a file in the local directory with one of the extensions
+func (j *pollProxiesJob) specFileProxy(file string) (string, bool) {
+	ext := path.Ext(file)
+	if strings.Contains(file, "/") || ext == "" || !slices.Contains(j.client.GetConfig().Specs.Extensions, ext[1:]) {
+		return "", false
+	}
+	return strings.TrimSuffix(file, ext), true
 }
 
-func (j *pollProxiesJob) handleProxy(proxyName string) {
+// markSpecChanged - the proxies are published again on the next run, even if their revisions have not changed
+func (j *pollProxiesJob) markSpecChanged(proxies []string) {
+	j.specChangedLock.Lock()
+	defer j.specChangedLock.Unlock()
+	for _, proxyName := range proxies {
+		j.specChanged[proxyName] = true
+	}
+}
+
+// takeSpecChanged - the proxies to publish again on this run, the proxies marked while it runs wait for the next one
+func (j *pollProxiesJob) takeSpecChanged() map[string]bool {
+	j.specChangedLock.Lock()
+	defer j.specChangedLock.Unlock()
+	specChanged := j.specChanged
+	j.specChanged = map[string]bool{}
+	return specChanged
+}
+
+func (j *pollProxiesJob) handleProxy(ctx context.Context, run *workerRun, proxyName string, inventory *deploymentInventory) error {
 	logger := j.logger.WithField(proxyNameField.String(), proxyName)
 	logger.Debug("handling proxy")
 
-	ctx := addLoggerToContext(context.Background(), logger)
+	ctx = addLoggerToContext(ctx, logger)
 	ctx = context.WithValue(ctx, proxyNameField, proxyName)
 
-	details, err := j.client.GetDeployments(proxyName)
+	details, err := j.client.GetDeploymentsWithContext(ctx, proxyName)
 	if err != nil {
 		logger.WithError(err).Error("getting deployment")
-		return // proxy may not have had any deployments
+		inventory.addUnknown(proxyName)
+		return fmt.Errorf("getting deployments: %w", err)
 	}
 
-	wg := sync.WaitGroup{}
+	envs := run.group()
 	for _, env := range details.Environment {
-		// only handle proxies that are in the specified environment, if set
-		if !(j.environment == "" || j.environment == env.Name) {
+		// only handle proxies that are in the selected environments
+		if !j.environments.IsSelected(env.Name) {
 			continue
 		}
 
-		wg.Add(1)
-		go func(environment models.DeploymentDetailsEnvironment) {
-			defer wg.Done()
-			j.handleEnvironment(ctx, environment)
-		}(env)
+		envs.submit(func() error {
+			// a proxy the discovery filter excludes is not deployed as far as its service is concerned
+			notDeployed, err := j.handleEnvironment(ctx, env, inventory)
+			if !notDeployed {
+				inventory.addDeployed(createProxyCacheKey(proxyName, env.Name), proxyName)
+			}
+			return err
+		})
 	}
-
-	wg.Wait()
+	return envs.wait()
 }
 
-func (j *pollProxiesJob) handleEnvironment(ctx context.Context, env models.DeploymentDetailsEnvironment) {
+// handleEnvironment - handles the deployed revisions, returns true when none is deployed or the discovery filter
+// excluded all of them
+func (j *pollProxiesJob) handleEnvironment(ctx context.Context, env models.DeploymentDetailsEnvironment, inventory *deploymentInventory) (bool, error) {
 	logger := getLoggerFromContext(ctx).WithField(envNameField.String(), env.Name)
 	addLoggerToContext(ctx, logger)
 	logger.Debug("handling environment")
 
 	ctx = context.WithValue(ctx, envNameField, env.Name)
 
-	wg := sync.WaitGroup{}
-	for _, rev := range env.Revision {
-		wg.Add(1)
-		go func(revName string) {
-			defer wg.Done()
-			j.handleRevision(ctx, revName)
-		}(rev.Name)
+	revisions := deployedRevisions(logger, env.Revision)
+	if len(revisions) == 0 {
+		return true, nil
+	}
+	if len(revisions) > 1 && !j.client.GetConfig().PublishAllRevisions() {
+		revisions = revisions[len(revisions)-1:]
 	}
 
-	wg.Wait()
+	// the revisions are published in order, the instance serves the latest one
+	filtered := 0
+	errs := []error{}
+	for i, revName := range revisions {
+		inventory.addRevision(createRevisionCacheKey(getStringFromContext(ctx, proxyNameField), env.Name, revName))
+		revFiltered, err := j.handleRevision(ctx, revName, i == len(revisions)-1)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("environment %s revision %s: %w", env.Name, revName, err))
+		}
+		if revFiltered {
+			filtered++
+		}
+	}
+	return filtered == len(revisions), errors.Join(errs...)
 }
 
-func (j *pollProxiesJob) handleRevision(ctx context.Context, revName string) {
+// deployedRevisions - the names of the revisions that are deployed in the environment, ordered by revision number
+func deployedRevisions(logger log.FieldLogger, revisions []models.DeploymentDetailsRevision) []string {
+	names := []string{}
+	for _, rev := range revisions {
+		if rev.State != apigee.DeployedState {
+			logger.WithField(revNameField.String(), rev.Name).WithField("state", rev.State).Debug("skipping revision that is not deployed")
+			continue
+		}
+		names = append(names, rev.Name)
+	}
+
+	slices.SortFunc(names, func(a, b string) int {
+		aNum, _ := strconv.Atoi(a)
+		bNum, _ := strconv.Atoi(b)
+		return aNum - bNum
+	})
+	return names
+}
+
+// handleRevision - publishes the revision when it has changed, returns true when the discovery filter excluded it
+func (j *pollProxiesJob) handleRevision(ctx context.Context, revName string, latest bool) (bool, error) {
 	logger := getLoggerFromContext(ctx).WithField(revNameField.String(), revName)
 	addLoggerToContext(ctx, logger)
 	logger.Debug("handling revision")
 
-	revision, err := j.client.GetRevision(getStringFromContext(ctx, proxyNameField), revName)
+	revision, err := j.client.GetRevisionWithContext(ctx, getStringFromContext(ctx, proxyNameField), revName)
 	if err != nil {
 		logger.WithError(err).Error("getting revision")
-		return
-	}
-
-	if revision.LastModifiedAt <= j.runTime {
-		return
-	}
-	if j.lastTime < revision.LastModifiedAt {
-		j.lastTime = revision.LastModifiedAt
+		return false, err
+	}
+
+	// a revision is handled again once it is modified or its spec file in the local directory changed. An
+	// unchanged published revision is also published again when its service was removed, or when it is the latest
+	// revision and the instance serves another one, after a newer revision was undeployed
+	revisionKey := createRevisionCacheKey(getStringFromContext(ctx, proxyNameField), getStringFromContext(ctx, envNameField), revName)
+	handled, found := j.cache.GetHandledRevision(revisionKey)
+	changedSpec, _ := ctx.Value(specChangedField).(bool)
+	if found && !changedSpec && handled.LastModified == revision.LastModifiedAt {
+		// the discovery filter may have changed since, a revision it now excludes is left out of the deployed apis
+		if !j.passesFilter(ctx, handled.Tags) {
+			if !handled.Filtered {
+				logger.Debug("revision has been filtered out")
+				handled.Filtered, handled.Published = true, false
+				j.cache.SetHandledRevision(revisionKey, handled)
+			}
+			return true, nil
+		}
+		unchanged := !handled.Filtered && (!handled.Published || (j.isPublished(revision.Name) && (!latest || j.isRevisionPublished(ctx, revision.Revision))))
+		if unchanged {
+			return false, nil
+		}
 	}
+	handled = handledRevision{Proxy: getStringFromContext(ctx, proxyNameField), LastModified: revision.LastModifiedAt}
 
 	ctx = context.WithValue(ctx, revNameField, revision)
 	logger = logger.WithField(revNameField.String(), revision.Revision)
 	addLoggerToContext(ctx, logger)
 
+	ctx, err = j.getBundle(ctx)
+	if err != nil {
+		return false, err
+	}
+	handled.Tags = j.filterTags(ctx)
+	if !j.passesFilter(ctx, handled.Tags) {
+		logger.Debug("revision has been filtered out")
+		handled.Filtered = true
+		j.cache.SetHandledRevision(revisionKey, handled)
+		return true, nil
+	}
 	ctx = j.checkPolicies(ctx)
 
 	// get URLs
@@ -265,47 +452,79 @@ func (j *pollProxiesJob) handleRevision(ctx context.Context, revName string) {
 		specURL = revision.Spec.(string)
 		ctx = context.WithValue(ctx, specPathField, specURL)
 	} else {
-		specURL = j.specFromRevision(ctx)
+		specAssociation := j.specFromRevision(ctx)
+		specURL = specAssociation.URL
 		ctx = context.WithValue(ctx, specPathField, specURL)
+		ctx = context.WithValue(ctx, specCredsField, specAssociation.Credentials)
 	}
 
+	if isFullURL(specURL) {
+		handled.SpecURL = specURL
+	}
 	if specURL != "" {
 		logger = logger.WithField(specPathField.String(), specURL)
 		addLoggerToContext(ctx, logger)
 		logger.Debug("will download spec from URL in revision")
 	}
 
-	j.publish(ctx)
+	// a revision that failed is handled again on the next poll
+	handled.Published, err = j.publish(ctx)
+	if err != nil {
+		return false, err
+	}
+	j.cache.SetHandledRevision(revisionKey, handled)
+	return false, nil
 }
 
-func (j *pollProxiesJob) checkPolicies(ctx context.Context) context.Context {
-	logger := getLoggerFromContext(ctx)
-	logger.Trace("checking revision policies for authentication")
+// filterTags - the tags of the revision the discovery filter is evaluated against
+func (j *pollProxiesJob) filterTags(ctx context.Context) map[string]string {
 	revision := ctx.Value(revNameField).(*models.ApiProxyRevision)
+	proxyBundle := ctx.Value(bundleField).(*bundle.Bundle)
+	return proxyFilterTags(getStringFromContext(ctx, proxyNameField), getStringFromContext(ctx, envNameField), revision, proxyBundle)
+}
 
-	for _, p := range revision.Policies {
-		logger := logger.WithField("policyName", p)
-		logger.Tracef("getting policy details")
-		policyDetails, err := j.client.GetRevisionPolicyByName(getStringFromContext(ctx, proxyNameField), revision.Revision, p)
-		if err != nil {
-			logger.WithError(err).Debug("getting policy")
-			continue
-		}
+// passesFilter - evaluates the discovery filter against the tags of the revision
+func (j *pollProxiesJob) passesFilter(ctx context.Context, tags map[string]string) bool {
+	if j.shouldPushAPI == nil {
+		return true
+	}
 
-		switch policyDetails.PolicyType {
-		case quotaPolicy:
-			ctx = context.WithValue(ctx, hasQuotaPolicyField, true)
-		case apiKeyPolicy:
-			ctx = context.WithValue(ctx, hasAPIKeyPolicyField, true)
-		case oauthPolicy:
-			ctx = context.WithValue(ctx, hasOAuthPolicyField, true)
-		}
+	getLoggerFromContext(ctx).WithField("tags", tags).Trace("checking against discovery filter")
+	return j.shouldPushAPI(tags)
+}
+
+// isRevisionPublished - returns true when the instance of the environment was last published with the revision
+func (j *pollProxiesJob) isRevisionPublished(ctx context.Context, revName string) bool {
+	cacheKey := createProxyCacheKey(getStringFromContext(ctx, proxyNameField), getStringFromContext(ctx, envNameField))
+	serviceBody, err := j.cache.GetPublishedProxy(cacheKey)
+	return err == nil && serviceBody.Version == revName
+}
+
+// getBundle - downloads and parses the revision bundle, the endpoints and policies are read from it. A revision
+// without its bundle is not published, it is handled again on the next poll
+func (j *pollProxiesJob) getBundle(ctx context.Context) (context.Context, error) {
+	logger := getLoggerFromContext(ctx)
+	revision := ctx.Value(revNameField).(*models.ApiProxyRevision)
+
+	proxyBundle, err := j.client.GetRevisionBundleWithContext(ctx, getStringFromContext(ctx, proxyNameField), revision.Revision)
+	if err != nil {
+		logger.WithError(err).Error("could not get the revision bundle")
+		return ctx, fmt.Errorf("getting the revision bundle: %w", err)
 	}
+	return context.WithValue(ctx, bundleField, proxyBundle), nil
+}
+
+func (j *pollProxiesJob) checkPolicies(ctx context.Context) context.Context {
+	logger := getLoggerFromContext(ctx)
+	logger.Trace("checking revision policies for authentication")
+	proxyBundle := ctx.Value(bundleField).(*bundle.Bundle)
 
-	return ctx
+	return context.WithValue(ctx, policiesField, policiesFromBundle(logger, proxyBundle))
 }
 
-func (j *pollProxiesJob) specFromRevision(ctx context.Context) string {
+// specFromRevision - finds the spec of the revision, the association.json file may also name the credentials the
+// spec is downloaded with
+func (j *pollProxiesJob) specFromRevision(ctx context.Context) Association {
 	logger := getLoggerFromContext(ctx)
 	logger.Trace("checking revision resource files")
 
@@ -315,53 +534,85 @@ func (j *pollProxiesJob) specFromRevision(ctx context.Context) string {
 		if resource.Type != openapi || resource.Name != association {
 			continue
 		}
-		if path := j.getSpecFromResourceFile(ctx, resource.Type, resource.Name); path != "" {
-			return path
+		if specAssociation := j.getSpecFromResourceFile(ctx, resource.Type, resource.Name); specAssociation.URL != "" {
+			return specAssociation
+		}
+	}
+
+	// use a wsdl or graphql schema in the revision resource files
+	for _, resource := range revision.ResourceFiles.ResourceFile {
+		if slices.Contains(specResourceTypes, resource.Type) {
+			logger.WithField("resourceType", resource.Type).WithField("resourceName", resource.Name).Debug("found spec resource file on revision")
+			return Association{URL: specResourcePath(resource.Type, resource.Name)}
 		}
 	}
 
 	// get a spec match based off the proxy name to the spec name
 	specData, _ := j.cache.GetSpecWithName(revision.Name)
 	if specData != nil {
-		return specData.ContentPath
+		return Association{URL: specData.ContentPath}
 	}
 
-	return j.getSpecFromVirtualHosts(ctx)
+	return Association{URL: j.getSpecFromVirtualHosts(ctx)}
 }
 
 func (j *pollProxiesJob) getVirtualHostURLs(ctx context.Context) context.Context {
 	logger := getLoggerFromContext(ctx)
-	revision := ctx.Value(revNameField).(*models.ApiProxyRevision)
 	envName := getStringFromContext(ctx, envNameField)
-	proxyName := getStringFromContext(ctx, proxyNameField)
 	allURLs := getStringArrayFromContext(ctx, endpointsField)
+	proxyBundle := ctx.Value(bundleField).(*bundle.Bundle)
 
-	connection, err := j.client.GetRevisionConnectionType(proxyName, revision.Revision)
-	if err != nil {
-		logger.WithError(err).Error("could not get the revision connection type")
-		return context.WithValue(ctx, endpointsField, allURLs)
-	}
+	// the urls of each virtual host, the same host may serve several proxy endpoints
+	virtualHostURLs := make(map[string][]string)
+	for _, endpoint := range proxyBundle.ProxyEndpoints {
+		logger := logger.WithField("proxyEndpoint", endpoint.Name)
+
+		virtualHosts := endpoint.GetVirtualHosts()
+		if j.client.GetConfig().IsApigeeX() {
+			// apigee x serves every proxy endpoint on the hostnames of the environment groups
+			virtualHosts = []string{""}
+		}
+		if len(virtualHosts) == 0 {
+			logger.Debug("proxy endpoint has no virtual hosts")
+		}
 
-	virtualHostURLs := make(map[string]map[string][]string)
+		for _, virtualHost := range virtualHosts {
+			if _, ok := virtualHostURLs[virtualHost]; !ok {
+				hostURLs, err := j.getHostURLs(ctx, envName, virtualHost)
+				if err != nil {
+					logger.WithError(err).WithField("virtualHost", virtualHost).Error("could not get the virtual host info")
+					continue
+				}
+				virtualHostURLs[virtualHost] = hostURLs
+			}
 
-	if _, ok := virtualHostURLs[envName]; !ok {
-		virtualHostURLs[envName] = make(map[string][]string)
+			for _, url := range virtualHostURLs[virtualHost] {
+				endpointURL := fmt.Sprintf("%s%s", url, endpoint.GetBasePath())
+				if !slices.Contains(allURLs, endpointURL) {
+					allURLs = append(allURLs, endpointURL)
+				}
+			}
+		}
 	}
 
-	if _, ok := virtualHostURLs[envName][connection.VirtualHost]; !ok {
-		virtualHost, err := j.client.GetVirtualHost(envName, connection.VirtualHost)
+	return context.WithValue(ctx, endpointsField, allURLs)
+}
+
+// getHostURLs - returns the urls of the virtual host, or of the environment groups when on apigee x
+func (j *pollProxiesJob) getHostURLs(ctx context.Context, envName, virtualHostName string) ([]string, error) {
+	if j.client.GetConfig().IsApigeeX() {
+		hostnames, err := j.client.GetEnvironmentGroupHostnamesWithContext(ctx, envName)
 		if err != nil {
-			logger.WithError(err).Error("could not get the virtual host info")
-			return context.WithValue(ctx, endpointsField, allURLs)
+			return nil, err
 		}
-		virtualHostURLs[envName][connection.VirtualHost] = urlsFromVirtualHost(virtualHost)
+		return urlsFromHostnames(hostnames), nil
 	}
 
-	for _, url := range virtualHostURLs[envName][connection.VirtualHost] {
-		allURLs = append(allURLs, fmt.Sprintf("%s%s", url, connection.BasePath))
+	virtualHost, err := j.client.GetVirtualHostWithContext(ctx, envName, virtualHostName)
+	if err != nil {
+		return nil, err
 	}
-
-	return context.WithValue(ctx, endpointsField, allURLs)
+	return urlsFromVirtualHost(virtualHost), nil
 }
 
 func (j *pollProxiesJob) getSpecFromVirtualHosts(ctx context.Context) string {
@@ -391,27 +642,28 @@ func (j *pollProxiesJob) getSpecFromVirtualHosts(ctx context.Context) string {
 	return ""
 }
 
-func (j *pollProxiesJob) getSpecFromResourceFile(ctx context.Context, resourceType, resourceName string) string {
+func (j *pollProxiesJob) getSpecFromResourceFile(ctx context.Context, resourceType, resourceName string) Association {
 	logger := getLoggerFromContext(ctx)
 	revision := ctx.Value(revNameField).(*models.ApiProxyRevision)
 	logger.Debug("found openapi resource file on revision")
 
 	// get the association.json file content
-	resFileContent, err := j.client.GetRevisionResourceFile(getStringFromContext(ctx, proxyNameField), revision.Revision, resourceType, resourceName)
+	resFileContent, err := j.client.GetRevisionResourceFileWithContext(ctx, getStringFromContext(ctx, proxyNameField), revision.Revision, resourceType, resourceName)
 	if err != nil {
 		logger.WithError(err).Debug("could not download resource file content")
 	}
-	associationFile := &Association{}
-	err = json.Unmarshal(resFileContent, associationFile)
+	associationFile := Association{}
+	err = json.Unmarshal(resFileContent, &associationFile)
 	if err != nil {
 		logger.WithError(err).Debug("could not read resource file content")
 	}
 
 	// return the association.json file content
-	return associationFile.URL
+	return associationFile
 }
 
-func (j *pollProxiesJob) publish(ctx context.Context) {
+// publish - publishes the service of the revision, returns false when it has no spec to publish
+func (j *pollProxiesJob) publish(ctx context.Context) (bool, error) {
 	logger := getLoggerFromContext(ctx)
 	envName := getStringFromContext(ctx, envNameField)
 	revision := ctx.Value(revNameField).(*models.ApiProxyRevision)
@@ -419,10 +671,10 @@ func (j *pollProxiesJob) publish(ctx context.Context) {
 	serviceBody, err := j.buildServiceBody(ctx)
 	if err != nil {
 		logger.WithError(err).Error("building service body")
-		return
+		return false, err
 	}
 	if serviceBody == nil {
-		return
+		return false, nil
 	}
 
 	serviceBodyHash, _ := coreutil.ComputeHash(*serviceBody)
@@ -432,24 +684,38 @@ func (j *pollProxiesJob) publish(ctx context.Context) {
 	// Check DiscoveryCache for API
 	j.pubLock.Lock() // only publish one at a time
 	defer j.pubLock.Unlock()
-	value := agent.GetAttributeOnPublishedAPIByID(revision.Name, fmt.Sprintf("%s-hash", envName))
+	value := j.publishedHash(cacheKey, revision.Name, envName)
 
 	err = nil
-	if !agent.IsAPIPublishedByID(revision.Name) {
+	if !j.isPublished(revision.Name) {
 		// call new API
 		err = j.publishAPI(*serviceBody, envName, hashString, cacheKey)
 	} else if value != hashString {
-		// handle update
+		// handle update, the new revision keeps the spec as it may be what changed
 		log.Tracef("%s has been updated, push new revision", revision.Name)
 		serviceBody.APIUpdateSeverity = "Major"
-		serviceBody.SpecDefinition = []byte{}
 		log.Tracef("%+v", serviceBody)
 		err = j.publishAPI(*serviceBody, envName, hashString, cacheKey)
 	}
 
-	if err == nil {
-		j.cache.AddPublishedServiceToCache(cacheKey, serviceBody)
+	if err != nil {
+		logger.WithError(err).Error("publishing api")
+		return false, err
+	}
+	j.cache.AddPublishedServiceToCache(cacheKey, serviceBody)
+	return true, nil
+}
+
+// publishedHash - the hash of the service last published for the environment, from the agent cache when this agent
+// published it, otherwise from the service found on Central
+func (j *pollProxiesJob) publishedHash(cacheKey, apiID, envName string) string {
+	hashKey := fmt.Sprintf("%s-hash", envName)
+	if serviceBody, err := j.cache.GetPublishedProxy(cacheKey); err == nil {
+		if hash, ok := serviceBody.ServiceAgentDetails[hashKey].(string); ok {
+			return hash
+		}
 	}
+	return agent.GetAttributeOnPublishedAPIByID(apiID, hashKey)
 }
 
 func (j *pollProxiesJob) buildServiceBody(ctx context.Context) (*apic.ServiceBody, error) {
@@ -457,13 +723,19 @@ func (j *pollProxiesJob) buildServiceBody(ctx context.Context) (*apic.ServiceBod
 	revision := ctx.Value(revNameField).(*models.ApiProxyRevision)
 	specPath := getStringFromContext(ctx, specPathField)
 
-	spec, err := j.findSpecFile(specPath, revision)
+	spec, resourceType, err := j.findSpecFile(ctx, specPath, revision)
 	// if we should have a spec and can not get it then fall out
 	if err != nil {
 		logger.WithError(err).WithField("specInfo", specPath).Error("could not gather spec")
 		return nil, err
 	}
 
+	specGenerated := false
+	if len(spec) == 0 && j.client.GetConfig().Specs.GenerateFromFlows {
+		spec = j.specFromFlows(ctx)
+		specGenerated = len(spec) > 0
+	}
+
 	if len(spec) == 0 && !j.client.GetConfig().Specs.Unstructured {
 		log.Warn("skipping proxy creation without a spec")
 		return nil, nil
@@ -478,55 +750,125 @@ func (j *pollProxiesJob) buildServiceBody(ctx context.Context) (*apic.ServiceBod
 		"specContentHash":             specHashString,
 		definitions.AttrExternalAPIID: revision.Name,
 	}
-
-	crds := []string{}
-	if ctx.Value(hasAPIKeyPolicyField) != nil {
-		crds = append(crds, provisioning.APIKeyCRD)
+	if specGenerated {
+		serviceDetails[specGeneratedDetail] = true
 	}
-	if ctx.Value(hasOAuthPolicyField) != nil {
-		crds = append(crds, provisioning.OAuthSecretCRD)
+
+	policies := ctx.Value(policiesField).(*proxyPolicies)
+	policies.addAgentDetails(serviceDetails)
+	crds := policies.credentialRequestDefinitions()
+
+	serviceAttributes := policies.serviceAttributes()
+	if folder := j.specFolder(specPath); folder != "" {
+		serviceAttributes[specFolderAttribute] = folder
 	}
 
 	urls := ctx.Value(endpointsField).([]string)
 	endpoints := createEndpointsFromURLS(urls)
 
+	// the revision attributes tell the central revisions of each apigee revision apart
+	revisionAttributes := map[string]string{revisionAttribute: revision.Revision}
+
+	// the instance stage stays the apigee environment, provisioning deploys the products to it, only a mapped
+	// environment is displayed with another name
+	envName := getStringFromContext(ctx, envNameField)
+	stageDisplayName := j.environments.GetStage(envName)
+	if stageDisplayName == envName {
+		stageDisplayName = ""
+	}
+
 	sb, err := apic.NewServiceBodyBuilder().
 		SetID(revision.Name).
 		SetAPIName(revision.Name).
-		SetStage(getStringFromContext(ctx, envNameField)).
+		SetStage(envName).
+		SetStageDisplayName(stageDisplayName).
 		SetDescription(revision.Description).
 		SetAPISpec(spec).
+		SetResourceType(resourceType).
 		SetTitle(revision.DisplayName).
 		SetVersion(revision.Revision).
 		SetAccessRequestDefinitionName(provisioning.APIKeyARD, false).
 		SetCredentialRequestDefinitions(crds).
 		SetServiceEndpoints(endpoints).
 		SetServiceAgentDetails(serviceDetails).
+		SetServiceAttribute(serviceAttributes).
+		SetRevisionAttribute(revisionAttributes).
+		SetInstanceAttribute(revisionAttributes).
 		SetSourceDataplaneType(apic.Apigee, false).
 		Build()
 	return &sb, err
 }
 
-func (j *pollProxiesJob) findSpecFile(specPath string, revision *models.ApiProxyRevision) ([]byte, error) {
+// specFolder - the spec store folder of the spec, empty when the spec is not from the spec store
+func (j *pollProxiesJob) specFolder(specPath string) string {
+	if specPath == "" {
+		return ""
+	}
+	specData, err := j.cache.GetSpecWithPath(specPath)
+	if err != nil || specData == nil {
+		return ""
+	}
+	return specData.Folder
+}
+
+// specFromFlows - generates a spec from the conditional flows in the revision bundle, nil when it has none
+func (j *pollProxiesJob) specFromFlows(ctx context.Context) []byte {
+	logger := getLoggerFromContext(ctx)
+	revision := ctx.Value(revNameField).(*models.ApiProxyRevision)
+	proxyBundle := ctx.Value(bundleField).(*bundle.Bundle)
+
+	spec, err := specFromFlows(proxyBundle, revision.DisplayName, revision.Description, revision.Revision, getStringArrayFromContext(ctx, endpointsField))
+	if err != nil {
+		logger.WithError(err).Error("could not generate a spec from the proxy flows")
+		return nil
+	}
+	if len(spec) > 0 {
+		logger.Debug("generated a spec from the proxy flows")
+	}
+	return spec
+}
+
+// findSpecFile - gets the spec of the revision along with its central spec type, the type is empty when the sdk
+// discovers it from the content
+func (j *pollProxiesJob) findSpecFile(ctx context.Context, specPath string, revision *models.ApiProxyRevision) ([]byte, string, error) {
 	// get the spec to build the service body
 	if j.client.GetConfig().Specs.LocalPath != "" {
 		specFilePath := path.Join(j.client.GetConfig().Specs.LocalPath, revision.Name)
-		spec, err := findSpecFile(j.logger, specFilePath, j.client.GetConfig().Specs.Extensions)
-		if len(spec) > 0 && err != nil {
-			return spec, err
+		spec, filePath, _ := findSpecFile(j.logger, specFilePath, j.client.GetConfig().Specs.Extensions)
+		if len(spec) > 0 {
+			return spec, specType(filePath, spec), nil
 		}
 	}
 
-	if isFullURL(specPath) {
-		return j.client.GetSpecFromURL(specPath)
-	}
-
-	if specPath != "" {
+	var spec []byte
+	var err error
+	if resourceType, resourceName, ok := parseSpecResourcePath(specPath); ok {
+		spec, err = j.client.GetRevisionResourceFileWithContext(ctx, getStringFromContext(ctx, proxyNameField), revision.Revision, resourceType, resourceName)
+	} else if isFullURL(specPath) {
+		spec, err = j.specFromURL(ctx, specPath)
+	} else if specPath != "" {
 		// try to get the spec from the APIgee spec repo
-		return j.client.GetSpecFile(specPath)
+		spec, err = j.specContent.getSpecFile(ctx, j.cache, specPath, j.client.GetSpecFileWithContext)
 	}
+	if err != nil || len(spec) == 0 {
+		return nil, "", err
+	}
+	return spec, specType(specPath, spec), nil
+}
 
-	return nil, nil
+// specFromURL - downloads the spec, with the credentials named in the association.json file
+func (j *pollProxiesJob) specFromURL(ctx context.Context, specURL string) ([]byte, error) {
+	options := []apigee.RequestOption{}
+	if credentials, _ := ctx.Value(specCredsField).(string); credentials != "" {
+		option, err := j.client.WithSpecCredentials(credentials)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	return j.specContent.getURL(ctx, specURL, func(ctx context.Context, validators apigee.SpecValidators) (*apigee.SpecDownload, error) {
+		return j.client.GetSpecFromURLIfModifiedWithContext(ctx, specURL, validators, options...)
+	})
 }
 
 func (j *pollProxiesJob) publishAPI(serviceBody apic.ServiceBody, envName, hashString, cacheKey string) error {
@@ -538,7 +880,6 @@ func (j *pollProxiesJob) publishAPI(serviceBody apic.ServiceBody, envName, hashS
 	err := j.publishFunc(serviceBody)
 	if err == nil {
 		log.Infof("Published API %s to AMPLIFY Central", serviceBody.NameToPush)
-		return err
 	}
-	return nil
+	return err
 }
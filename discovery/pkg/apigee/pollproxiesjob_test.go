@@ -1,13 +1,19 @@
 package apigee
 
 import (
+	"context"
 	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/Axway/agent-sdk/pkg/apic"
 	"github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/filter"
 	"github.com/Axway/agents-apigee/client/pkg/apigee"
+	"github.com/Axway/agents-apigee/client/pkg/apigee/bundle"
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
 	"github.com/Axway/agents-apigee/client/pkg/config"
 	"github.com/stretchr/testify/assert"
@@ -21,23 +27,52 @@ const (
 	fullSpecPath = "http://host.com/path/to/spec"
 	apiKeyName   = "apiKeyPolicy"
 	oauthName    = "oauthPolicy"
+	quotaName    = "quotaPolicy"
+	testSpec     = `{"openapi":"3.0.1","info":{"title":"A Proxy","version":"1.0.0"},"paths":{}}`
 )
 
 func Test_pollProxiesJob(t *testing.T) {
 	tests := []struct {
-		name             string
-		specPath         bool
-		specName         bool
-		allProxyErr      bool
-		getDeploymentErr bool
-		getRevisionErr   bool
-		specFound        bool
-		revSpec          bool
-		fullSpec         bool
-		specInResource   bool
-		hasAPIKey        bool
-		hasOauth         bool
+		name              string
+		specPath          bool
+		specName          bool
+		allProxyErr       bool
+		getDeploymentErr  bool
+		getRevisionErr    bool
+		specFound         bool
+		revSpec           bool
+		fullSpec          bool
+		specInResource    bool
+		specCredentials   string
+		wsdlResource      bool
+		hasAPIKey         bool
+		hasOauth          bool
+		hasQuota          bool
+		apigeeX           bool
+		multipleEndpoints bool
+		generateSpec      bool
+		cancelled         bool
+		disconnected      bool
 	}{
+		{
+			name:      "should create proxy with environment group endpoints on apigee x",
+			specName:  true,
+			specFound: true,
+			hasAPIKey: true,
+			apigeeX:   true,
+		},
+		{
+			name:              "should create proxy with endpoints for every proxy endpoint and virtual host",
+			revSpec:           true,
+			specFound:         true,
+			multipleEndpoints: true,
+		},
+		{
+			name:         "should create proxy with a spec generated from the proxy flows",
+			specFound:    true,
+			generateSpec: true,
+			hasAPIKey:    true,
+		},
 		{
 			name:           "should create proxy when spec in revision resource file",
 			specPath:       true,
@@ -46,6 +81,30 @@ func Test_pollProxiesJob(t *testing.T) {
 			hasOauth:       true,
 			hasAPIKey:      true,
 		},
+		{
+			name:            "should create proxy when spec url in revision resource file has credentials",
+			specFound:       true,
+			specInResource:  true,
+			specCredentials: "git",
+		},
+		{
+			name:            "should skip the proxy when spec credentials in revision resource file are not configured",
+			specInResource:  true,
+			specCredentials: "other",
+		},
+		{
+			name:         "should create proxy with the wsdl in the revision resource files",
+			specFound:    true,
+			wsdlResource: true,
+		},
+		{
+			name:      "should create proxy with the policy settings from the bundle",
+			revSpec:   true,
+			specFound: true,
+			hasAPIKey: true,
+			hasOauth:  true,
+			hasQuota:  true,
+		},
 		{
 			name:      "should create proxy when spec is matched by name",
 			specName:  true,
@@ -75,31 +134,50 @@ func Test_pollProxiesJob(t *testing.T) {
 			name: "should stop when no spec found but has api key policy",
 		},
 		{
-			name:           "should stop when getting proxy revision fails",
+			name:           "should continue when getting proxy revision fails",
 			getRevisionErr: true,
 		},
 		{
-			name:             "should stop when getting proxy deployment fails",
+			name:             "should continue when getting proxy deployment fails",
+			getDeploymentErr: true,
+		},
+		{
+			name:             "should fail when the apigee connection is broken",
 			getDeploymentErr: true,
+			disconnected:     true,
 		},
 		{
 			name:        "should stop when getting all proxies fails",
 			allProxyErr: true,
 		},
+		{
+			name:      "should stop when the job context is cancelled",
+			cancelled: true,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewApigeeConfig()
+			if tc.apigeeX {
+				cfg.Platform = "x"
+			}
+			cfg.Specs.GenerateFromFlows = tc.generateSpec
 			client := mockProxyClient{
-				t:                t,
-				cfg:              config.NewApigeeConfig(),
-				allProxyErr:      tc.allProxyErr,
-				getDeploymentErr: tc.getDeploymentErr,
-				getRevisionErr:   tc.getRevisionErr,
-				revSpec:          tc.revSpec,
-				fullSpec:         tc.fullSpec,
-				specInResource:   tc.specInResource,
-				hasAPIKey:        tc.hasAPIKey,
-				hasOauth:         tc.hasOauth,
+				t:                 t,
+				cfg:               cfg,
+				allProxyErr:       tc.allProxyErr,
+				getDeploymentErr:  tc.getDeploymentErr,
+				getRevisionErr:    tc.getRevisionErr,
+				revSpec:           tc.revSpec,
+				fullSpec:          tc.fullSpec,
+				specInResource:    tc.specInResource,
+				specCredentials:   tc.specCredentials,
+				wsdlResource:      tc.wsdlResource,
+				hasAPIKey:         tc.hasAPIKey,
+				hasOauth:          tc.hasOauth,
+				hasQuota:          tc.hasQuota,
+				multipleEndpoints: tc.multipleEndpoints,
+				disconnected:      tc.disconnected,
 			}
 
 			proxyJob := newPollProxiesJob().
@@ -109,8 +187,15 @@ func Test_pollProxiesJob(t *testing.T) {
 				SetWorkers(10)
 			assert.False(t, proxyJob.FirstRunComplete())
 
+			if tc.cancelled {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				proxyJob.SetContext(ctx)
+			}
+
 			// receive the publish call and validate what was published
 			proxyJob.publishFunc = func(sb apic.ServiceBody) error {
+				assert.False(t, tc.cancelled)
 				assert.Equal(t, proxyName, sb.APIName)
 				assert.Equal(t, proxyName, sb.RestAPIID)
 				assert.Equal(t, envName, sb.Stage)
@@ -126,22 +211,503 @@ func Test_pollProxiesJob(t *testing.T) {
 				}
 				assert.Equal(t, crds, sb.GetCredentialRequestDefinitions(make([]string, 0)))
 
+				if tc.hasAPIKey {
+					assert.Equal(t, "header", sb.ServiceAgentDetails[apiKeyInDetail])
+					assert.Equal(t, "x-api-key", sb.ServiceAgentDetails[apiKeyNameDetail])
+				}
+				if tc.hasOauth {
+					assert.Equal(t, "VerifyAccessToken", sb.ServiceAgentDetails[oauthOperationDetail])
+					assert.Equal(t, "read write", sb.ServiceAgentDetails[oauthScopesDetail])
+				} else {
+					assert.NotContains(t, sb.ServiceAgentDetails, oauthOperationDetail)
+				}
+				if tc.hasQuota {
+					assert.Equal(t, "100", sb.ServiceAgentDetails[quotaAllowDetail])
+					assert.Equal(t, "1", sb.ServiceAgentDetails[quotaIntervalDetail])
+					assert.Equal(t, "minute", sb.ServiceAgentDetails[quotaTimeUnitDetail])
+					assert.Equal(t, quotaSourcePolicy, sb.ServiceAttributes[quotaSourceAttribute])
+					assert.Equal(t, "100", sb.ServiceAttributes[quotaLimitAttribute])
+					assert.Equal(t, "1 minute", sb.ServiceAttributes[quotaPeriodAttribute])
+				} else {
+					assert.NotContains(t, sb.ServiceAgentDetails, quotaAllowDetail)
+					assert.NotContains(t, sb.ServiceAttributes, quotaSourceAttribute)
+				}
+
+				if tc.apigeeX {
+					assert.Len(t, sb.Endpoints, 1)
+					assert.Equal(t, "api.example.com", sb.Endpoints[0].Host)
+					assert.Equal(t, "https", sb.Endpoints[0].Protocol)
+					assert.Equal(t, "/basepath", sb.Endpoints[0].BasePath)
+				}
+
+				if tc.multipleEndpoints {
+					assert.Equal(t, []apic.EndpointDefinition{
+						{Host: "api.example.com", Protocol: "https", BasePath: "/basepath"},
+						{Host: "internal.example.com:8080", Port: 8080, Protocol: "http", BasePath: "/basepath"},
+						{Host: "api.example.com", Protocol: "https", BasePath: "/admin"},
+					}, sb.Endpoints)
+				}
+
+				if tc.wsdlResource {
+					assert.Equal(t, apic.Wsdl, sb.ResourceType)
+				}
+
 				if tc.specFound {
 					assert.NotEmpty(t, sb.SpecDefinition)
 				} else {
 					assert.Empty(t, sb.SpecDefinition)
 				}
+
+				if tc.generateSpec {
+					assert.Equal(t, true, sb.ServiceAgentDetails[specGeneratedDetail])
+					assert.Contains(t, string(sb.SpecDefinition), `"/basepath/pets/{petId}"`)
+				} else {
+					assert.NotContains(t, sb.ServiceAgentDetails, specGeneratedDetail)
+				}
 				return nil
 			}
 
-			proxyJob.Execute()
+			// failed proxies are logged, only listing them or a broken connection fails the run
+			err := proxyJob.Execute()
+			if tc.allProxyErr || tc.cancelled || tc.disconnected {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
 
 			// error getting all proxies should not flip first run
-			assert.NotEqual(t, tc.allProxyErr, proxyJob.FirstRunComplete())
+			assert.NotEqual(t, tc.allProxyErr || tc.cancelled, proxyJob.FirstRunComplete())
+		})
+	}
+}
+
+func Test_pollProxiesJobRevisions(t *testing.T) {
+	deployed := []models.DeploymentDetailsRevision{
+		{Name: "2", State: apigee.DeployedState},
+		{Name: "10", State: apigee.DeployedState},
+		{Name: "11", State: "undeployed"},
+	}
+
+	tests := []struct {
+		name      string
+		strategy  string
+		revisions []models.DeploymentDetailsRevision
+		published []string
+	}{
+		{
+			name:      "should publish the latest deployed revision",
+			strategy:  config.RevisionStrategyLatest,
+			revisions: deployed,
+			published: []string{"10"},
+		},
+		{
+			name:      "should publish each deployed revision in order",
+			strategy:  config.RevisionStrategyAll,
+			revisions: deployed,
+			published: []string{"2", "10"},
+		},
+		{
+			name:      "should not deploy the proxy when no revision is deployed",
+			strategy:  config.RevisionStrategyAll,
+			revisions: deployed[2:],
+			published: []string{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewApigeeConfig()
+			cfg.RevisionStrategy = tc.strategy
+			client := mockProxyClient{t: t, cfg: cfg, revSpec: true, revisions: tc.revisions}
+
+			cache := newAgentCache()
+			published := []string{}
+			proxyJob := newPollProxiesJob().
+				SetSpecClient(client).
+				SetSpecCache(cache).
+				SetWorkers(1)
+			// the revisions after the first are published as new revisions of the service
+			proxyJob.isPublished = func(string) bool { return len(published) > 0 }
+			proxyJob.publishFunc = func(sb apic.ServiceBody) error {
+				assert.Equal(t, sb.Version, sb.RevisionAttributes[revisionAttribute])
+				assert.Equal(t, sb.Version, sb.InstanceAttributes[revisionAttribute])
+				assert.NotEmpty(t, sb.SpecDefinition)
+				published = append(published, sb.Version)
+				return nil
+			}
+			assert.Nil(t, proxyJob.Execute())
+			assert.Equal(t, tc.published, published)
+			assert.Equal(t, len(tc.published) > 0, cache.IsAPIDeployed(createProxyCacheKey(proxyName, envName)))
+		})
+	}
+}
+
+func Test_pollProxiesJobEnvironments(t *testing.T) {
+	tests := []struct {
+		name             string
+		include          string
+		exclude          string
+		mapping          string
+		published        bool
+		stageDisplayName string
+	}{
+		{
+			name:      "should publish proxies in all environments when none are selected",
+			published: true,
+		},
+		{
+			name:             "should publish with the mapped stage name",
+			include:          "pr*",
+			mapping:          "prod=Production",
+			published:        true,
+			stageDisplayName: "Production",
+		},
+		{
+			name:    "should skip proxies in environments that are not included",
+			include: "dev, /^test-.*$/",
+		},
+		{
+			name:    "should skip proxies in excluded environments",
+			exclude: "prod",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			environments, err := config.NewEnvironmentSelector(tc.include, tc.exclude, tc.mapping)
+			assert.Nil(t, err)
+
+			published := false
+			proxyJob := newPollProxiesJob().
+				SetSpecClient(mockProxyClient{t: t, cfg: config.NewApigeeConfig(), revSpec: true}).
+				SetSpecCache(mockProxyCache{}).
+				SetEnvironments(environments).
+				SetWorkers(1)
+			proxyJob.publishFunc = func(sb apic.ServiceBody) error {
+				published = true
+				assert.Equal(t, envName, sb.Stage)
+				assert.Equal(t, tc.stageDisplayName, sb.StageDisplayName)
+				return nil
+			}
+			assert.Nil(t, proxyJob.Execute())
+			assert.Equal(t, tc.published, published)
+		})
+	}
+}
+
+func Test_pollProxiesJobFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    string
+		published bool
+	}{
+		{
+			name:      "should publish all proxies without a filter",
+			published: true,
+		},
+		{
+			name:      "should publish proxies matching a bundle property",
+			filter:    `tag.visibility == "public"`,
+			published: true,
+		},
+		{
+			name:      "should publish proxies matching the name and environment",
+			filter:    `tag.name == "` + proxyName + `" && tag.environment == "` + envName + `"`,
+			published: true,
+		},
+		{
+			name:   "should skip proxies not matching the base path",
+			filter: `tag.basePath == "/health"`,
+		},
+		{
+			name:   "should skip proxies not matching the virtual host",
+			filter: `tag.virtualHost.MatchRegEx("^internal")`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			discoveryFilter, err := filter.NewFilter(tc.filter)
+			assert.Nil(t, err)
+
+			cache := newAgentCache()
+			published := false
+			proxyJob := newPollProxiesJob().
+				SetSpecClient(mockProxyClient{t: t, cfg: config.NewApigeeConfig(), revSpec: true}).
+				SetSpecCache(cache).
+				SetShouldPushAPI(func(tags map[string]string) bool { return discoveryFilter.Evaluate(tags) }).
+				SetWorkers(1)
+			proxyJob.publishFunc = func(sb apic.ServiceBody) error {
+				published = true
+				return nil
+			}
+			assert.Nil(t, proxyJob.Execute())
+			assert.Equal(t, tc.published, published)
+
+			// a filtered proxy is not deployed, so its service is removed
+			assert.Equal(t, tc.published, cache.IsAPIDeployed(createProxyCacheKey(proxyName, envName)))
 		})
 	}
 }
 
+func Test_pollProxiesJobBundleErr(t *testing.T) {
+	client := mockProxyClient{
+		t:            t,
+		cfg:          config.NewApigeeConfig(),
+		revSpec:      true,
+		lastModified: 1000,
+		getBundleErr: true,
+	}
+
+	published := 0
+	proxyJob := newPollProxiesJob().
+		SetSpecClient(&client).
+		SetSpecCache(newAgentCache()).
+		SetWorkers(1)
+	proxyJob.isPublished = func(string) bool { return false }
+	proxyJob.publishFunc = func(sb apic.ServiceBody) error {
+		published++
+		return nil
+	}
+
+	// the revision is not published without its bundle
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, 0, published)
+
+	// and is handled again on the next poll
+	client.getBundleErr = false
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, 1, published)
+}
+
+func Test_pollProxiesJobFilterChanged(t *testing.T) {
+	bundles := 0
+	client := mockProxyClient{
+		t:            t,
+		cfg:          config.NewApigeeConfig(),
+		revSpec:      true,
+		lastModified: 1000,
+		bundles:      &bundles,
+	}
+
+	cache := newAgentCache()
+	discoveryFilter := `tag.visibility == "public"`
+	published := 0
+	proxyJob := newPollProxiesJob().
+		SetSpecClient(&client).
+		SetSpecCache(cache).
+		SetShouldPushAPI(func(tags map[string]string) bool {
+			f, err := filter.NewFilter(discoveryFilter)
+			assert.Nil(t, err)
+			return f.Evaluate(tags)
+		}).
+		SetWorkers(1)
+	proxyJob.isPublished = func(string) bool { return true }
+	proxyJob.publishFunc = func(sb apic.ServiceBody) error {
+		published++
+		return nil
+	}
+
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, 1, published)
+	assert.True(t, cache.IsAPIDeployed(createProxyCacheKey(proxyName, envName)))
+
+	// the filter changed, the unchanged revision it now excludes is no longer deployed so its service is removed
+	discoveryFilter = `tag.visibility == "private"`
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, 1, published)
+	assert.Equal(t, 1, bundles)
+	assert.False(t, cache.IsAPIDeployed(createProxyCacheKey(proxyName, envName)))
+
+	// the service was removed, the filter changed back, the revision is published again
+	proxyJob.isPublished = func(string) bool { return false }
+	discoveryFilter = `tag.visibility == "public"`
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, 2, published)
+	assert.True(t, cache.IsAPIDeployed(createProxyCacheKey(proxyName, envName)))
+}
+
+func Test_pollProxiesJobLatestRevisionUndeployed(t *testing.T) {
+	client := mockProxyClient{
+		t:            t,
+		cfg:          config.NewApigeeConfig(),
+		revSpec:      true,
+		lastModified: 1000,
+		revisions: []models.DeploymentDetailsRevision{
+			{Name: "1", State: apigee.DeployedState},
+			{Name: "2", State: apigee.DeployedState},
+		},
+	}
+
+	published := []string{}
+	proxyJob := newPollProxiesJob().
+		SetSpecClient(&client).
+		SetSpecCache(newAgentCache()).
+		SetWorkers(1)
+	proxyJob.isPublished = func(string) bool { return true }
+	proxyJob.publishFunc = func(sb apic.ServiceBody) error {
+		published = append(published, sb.Version)
+		return nil
+	}
+
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, []string{"2"}, published)
+
+	// the revisions have not changed
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, []string{"2"}, published)
+
+	// revision 2 was undeployed, the unchanged revision 1 is the latest again
+	client.revisions = client.revisions[:1]
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, []string{"2", "1"}, published)
+}
+
+func Test_pollProxiesJobRestoredCache(t *testing.T) {
+	client := mockProxyClient{
+		t:            t,
+		cfg:          config.NewApigeeConfig(),
+		revSpec:      true,
+		lastModified: 1000,
+	}
+	path := filepath.Join(t.TempDir(), "agent-apigee.json")
+
+	published := 0
+	newJob := func(cache *agentCache) *pollProxiesJob {
+		proxyJob := newPollProxiesJob().
+			SetSpecClient(&client).
+			SetSpecCache(cache).
+			SetWorkers(1)
+		proxyJob.isPublished = func(string) bool { return true }
+		proxyJob.publishFunc = func(sb apic.ServiceBody) error {
+			published++
+			return nil
+		}
+		return proxyJob
+	}
+
+	cache := newAgentCache()
+	assert.Nil(t, newJob(cache).Execute())
+	assert.Equal(t, 1, published)
+	_, found := cache.GetHandledRevision(createRevisionCacheKey(proxyName, envName, revName))
+	assert.True(t, found)
+	assert.Nil(t, cache.Save(path))
+
+	// the agent restarted, the unchanged revision is not published again
+	restored := newAgentCache()
+	assert.Nil(t, restored.Load(path))
+	assert.Nil(t, newJob(restored).Execute())
+	assert.Equal(t, 1, published)
+
+	// a revision changed while the agent was stopped
+	client.lastModified = 2000
+	client.hasAPIKey = true
+	restored = newAgentCache()
+	assert.Nil(t, restored.Load(path))
+	assert.Nil(t, newJob(restored).Execute())
+	assert.Equal(t, 2, published)
+}
+
+func Test_pollProxiesJobUnpublishedRevision(t *testing.T) {
+	bundles := 0
+	client := mockProxyClient{
+		t:            t,
+		cfg:          config.NewApigeeConfig(),
+		lastModified: 1000,
+		bundles:      &bundles,
+	}
+
+	proxyJob := newPollProxiesJob().
+		SetSpecClient(&client).
+		SetSpecCache(newAgentCache()).
+		SetWorkers(1)
+	proxyJob.isPublished = func(string) bool { return false }
+	proxyJob.publishFunc = func(sb apic.ServiceBody) error {
+		assert.Fail(t, "a revision without a spec was published")
+		return nil
+	}
+
+	// the revision without a spec is not published
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, 1, bundles)
+
+	// nor handled again while it is unchanged
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, 1, bundles)
+
+	// a modified revision is handled again
+	client.lastModified = 2000
+	assert.Nil(t, proxyJob.Execute())
+	assert.Equal(t, 2, bundles)
+}
+
+func Test_pollProxiesJobSpecFileChanged(t *testing.T) {
+	client := mockProxyClient{
+		t:            t,
+		cfg:          config.NewApigeeConfig(),
+		lastModified: 1000,
+	}
+	client.cfg.Specs.LocalPath = t.TempDir()
+	client.cfg.Specs.Extensions = []string{"json", "yaml"}
+	specFile := filepath.Join(client.cfg.Specs.LocalPath, proxyName+".json")
+
+	specs := []string{}
+	updates := 0
+	proxyJob := newPollProxiesJob().
+		SetSpecClient(&client).
+		SetSpecCache(newAgentCache()).
+		SetWorkers(1)
+	proxyJob.isPublished = func(name string) bool { return name == proxyName && len(specs) > 0 }
+	proxyJob.publishFunc = func(sb apic.ServiceBody) error {
+		specs = append(specs, string(sb.SpecDefinition))
+		if sb.APIUpdateSeverity != "" {
+			updates++
+		}
+		return nil
+	}
+
+	// the first run handles all proxies, the proxy without a spec is not published
+	proxyJob.republishSpecFiles([]string{proxyName + ".json"})
+	assert.Nil(t, proxyJob.Execute())
+	assert.Empty(t, specs)
+
+	// its spec file was added
+	assert.Nil(t, os.WriteFile(specFile, []byte(testSpec), 0600))
+	proxyJob.republishSpecFiles([]string{proxyName + ".json"})
+	assert.Equal(t, []string{testSpec}, specs)
+	assert.Equal(t, 0, updates)
+
+	// spec files that are not named after a handled proxy with one of the extensions are ignored
+	proxyJob.republishSpecFiles([]string{"other.json", "sub/" + proxyName + ".json", proxyName + ".txt"})
+	assert.Empty(t, proxyJob.takeSpecChanged())
+	assert.Len(t, specs, 1)
+
+	// the spec file was touched without changes, the published service is not updated
+	assert.Nil(t, os.WriteFile(specFile, []byte(testSpec), 0600))
+	proxyJob.republishSpecFiles([]string{proxyName + ".json"})
+	assert.Len(t, specs, 1)
+
+	// the revision has not changed, its spec file has, the published service is updated with the new spec
+	changedSpec := `{"openapi":"3.0.1","info":{"title":"A Proxy","version":"2.0.0"},"paths":{}}`
+	assert.Nil(t, os.WriteFile(specFile, []byte(changedSpec), 0600))
+	proxyJob.republishSpecFiles([]string{proxyName + ".json"})
+	assert.Equal(t, []string{testSpec, changedSpec}, specs)
+	assert.Equal(t, 1, updates)
+
+	// the spec file was replaced while a poll was running, the next poll publishes it
+	assert.Nil(t, os.Remove(specFile))
+	yamlSpec := "openapi: 3.0.1\ninfo:\n  title: A Proxy\n  version: 3.0.0\npaths: {}\n"
+	assert.Nil(t, os.WriteFile(filepath.Join(client.cfg.Specs.LocalPath, proxyName+".yaml"), []byte(yamlSpec), 0600))
+	proxyJob.updateRunning(true)
+	proxyJob.republishSpecFiles([]string{proxyName + ".json", proxyName + ".yaml"})
+	assert.Len(t, specs, 2)
+	proxyJob.updateRunning(false)
+	assert.Nil(t, proxyJob.Execute())
+	assert.Len(t, specs, 3)
+	assert.Equal(t, yamlSpec, specs[2])
+
+	// and only that poll
+	assert.Nil(t, proxyJob.Execute())
+	assert.Len(t, specs, 3)
+}
+
 type mockProxyClient struct {
 	t                *testing.T
 	cfg              *config.ApigeeConfig
@@ -151,24 +717,38 @@ type mockProxyClient struct {
 	revSpec          bool
 	fullSpec         bool
 	specInResource   bool
-	hasAPIKey        bool
-	hasOauth         bool
+	// specCredentials - the credential profile named in the association.json file, with the full spec url
+	specCredentials   string
+	wsdlResource      bool
+	hasAPIKey         bool
+	hasOauth          bool
+	hasQuota          bool
+	multipleEndpoints bool
+	// disconnected - the apigee client is no longer authenticated
+	disconnected bool
+	// revisions - the deployed revisions, a single deployed revision when not set
+	revisions    []models.DeploymentDetailsRevision
+	lastModified int
+	// bundles - counts the revision bundle downloads when set
+	bundles      *int
+	getBundleErr bool
 }
 
 func (m mockProxyClient) GetConfig() *config.ApigeeConfig {
 	return m.cfg
 }
 
-func (m mockProxyClient) GetAllProxies() (proxies apigee.Proxies, err error) {
-	proxies = []string{proxyName}
-	if m.allProxyErr {
-		proxies = nil
-		err = fmt.Errorf("error")
+func (m mockProxyClient) ListProxiesWithContext(_ context.Context) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		if m.allProxyErr {
+			yield("", fmt.Errorf("error"))
+			return
+		}
+		yield(proxyName, nil)
 	}
-	return
 }
 
-func (m mockProxyClient) GetDeployments(apiName string) (deployment *models.DeploymentDetails, err error) {
+func (m mockProxyClient) GetDeploymentsWithContext(_ context.Context, apiName string) (deployment *models.DeploymentDetails, err error) {
 	assert.Contains(m.t, proxyName, apiName)
 	deployment = &models.DeploymentDetails{
 		Environment: []models.DeploymentDetailsEnvironment{
@@ -176,12 +756,16 @@ func (m mockProxyClient) GetDeployments(apiName string) (deployment *models.Depl
 				Name: envName,
 				Revision: []models.DeploymentDetailsRevision{
 					{
-						Name: revName,
+						Name:  revName,
+						State: apigee.DeployedState,
 					},
 				},
 			},
 		},
 	}
+	if m.revisions != nil {
+		deployment.Environment[0].Revision = m.revisions
+	}
 	if m.getDeploymentErr {
 		deployment = nil
 		err = fmt.Errorf("error")
@@ -189,17 +773,22 @@ func (m mockProxyClient) GetDeployments(apiName string) (deployment *models.Depl
 	return
 }
 
-func (m mockProxyClient) GetRevision(apiName, revision string) (rev *models.ApiProxyRevision, err error) {
+func (m mockProxyClient) GetRevisionWithContext(_ context.Context, apiName, revision string) (rev *models.ApiProxyRevision, err error) {
 	assert.Contains(m.t, proxyName, apiName)
-	assert.Contains(m.t, revName, revision)
+	if m.revisions == nil {
+		assert.Contains(m.t, revName, revision)
+	}
 	rev = &models.ApiProxyRevision{
 		Name:           proxyName,
 		DisplayName:    "A Proxy",
-		Revision:       revName,
+		Revision:       revision,
 		Description:    "A Proxy Description",
 		Policies:       []string{},
 		LastModifiedAt: int(time.Now().UnixMilli()),
 	}
+	if m.lastModified > 0 {
+		rev.LastModifiedAt = m.lastModified
+	}
 	if m.revSpec {
 		rev.Spec = specPath
 	}
@@ -216,11 +805,11 @@ func (m mockProxyClient) GetRevision(apiName, revision string) (rev *models.ApiP
 			},
 		}
 	}
-	if m.hasAPIKey {
-		rev.Policies = append(rev.Policies, apiKeyName)
-	}
-	if m.hasOauth {
-		rev.Policies = append(rev.Policies, oauthName)
+	if m.wsdlResource {
+		rev.ResourceFiles.ResourceFile = append(rev.ResourceFiles.ResourceFile, models.ApiProxyRevisionResourceFilesResourceFile{
+			Type: "wsdl",
+			Name: "orders.wsdl",
+		})
 	}
 	if m.getRevisionErr {
 		rev = nil
@@ -229,51 +818,109 @@ func (m mockProxyClient) GetRevision(apiName, revision string) (rev *models.ApiP
 	return
 }
 
-func (m mockProxyClient) GetRevisionConnectionType(proxyName, revision string) (*apigee.HTTPProxyConnection, error) {
-	return &apigee.HTTPProxyConnection{VirtualHost: "virtualhost.com"}, nil
+func (m mockProxyClient) GetRevisionBundleWithContext(_ context.Context, proxyName, revision string) (*bundle.Bundle, error) {
+	if m.bundles != nil {
+		*m.bundles++
+	}
+	if m.getBundleErr {
+		return nil, fmt.Errorf("error")
+	}
+	b := &bundle.Bundle{
+		ProxyEndpoints: []*bundle.ProxyEndpoint{
+			{
+				Name: "default",
+				HTTPProxyConnection: &bundle.HTTPProxyConnection{
+					BasePath:     "/basepath",
+					VirtualHosts: []string{"secure"},
+					Properties:   []*bundle.Property{{Name: "visibility", Value: "public"}},
+				},
+				PreFlow: &bundle.Flow{Request: []*bundle.Step{{Name: quotaName}}},
+			},
+		},
+		Policies: []*bundle.Policy{
+			// a quota policy that is not enabled, and an oauth policy that is not in any flow
+			{Name: quotaName, Type: quotaPolicy, Enabled: m.hasQuota, Content: []byte(`<Quota name="quota"><Allow count="100"/><Interval>1</Interval><TimeUnit>minute</TimeUnit></Quota>`)},
+			{Name: "unused", Type: oauthPolicy, Enabled: true, Content: []byte(`<OAuthV2 name="unused"><Operation>VerifyAccessToken</Operation></OAuthV2>`)},
+		},
+	}
+	if m.hasAPIKey {
+		b.ProxyEndpoints[0].PreFlow.Request = append(b.ProxyEndpoints[0].PreFlow.Request, &bundle.Step{Name: apiKeyName})
+		b.Policies = append(b.Policies, &bundle.Policy{Name: apiKeyName, Type: apiKeyPolicy, Enabled: true, Content: []byte(`<VerifyAPIKey name="apiKeyPolicy"><APIKey ref="request.header.x-api-key"/></VerifyAPIKey>`)})
+	}
+	if m.hasOauth {
+		b.ProxyEndpoints[0].PreFlow.Request = append(b.ProxyEndpoints[0].PreFlow.Request, &bundle.Step{Name: oauthName})
+		b.Policies = append(b.Policies, &bundle.Policy{Name: oauthName, Type: oauthPolicy, Enabled: true, Content: []byte(`<OAuthV2 name="oauthPolicy"><Operation>VerifyAccessToken</Operation><Scope>read write</Scope></OAuthV2>`)})
+	}
+	if m.cfg.Specs.GenerateFromFlows {
+		b.ProxyEndpoints[0].Flows = []*bundle.Flow{
+			{Name: "getPet", Condition: `(proxy.pathsuffix MatchesPath "/pets/*") and (request.verb = "GET")`},
+		}
+	}
+	if m.multipleEndpoints {
+		b.ProxyEndpoints[0].HTTPProxyConnection.VirtualHosts = append(b.ProxyEndpoints[0].HTTPProxyConnection.VirtualHosts, "default")
+		b.ProxyEndpoints = append(b.ProxyEndpoints, &bundle.ProxyEndpoint{
+			Name:                "admin",
+			HTTPProxyConnection: &bundle.HTTPProxyConnection{BasePath: "/admin", VirtualHosts: []string{"secure"}},
+		})
+	}
+	return b, nil
 }
 
-func (m mockProxyClient) GetRevisionResourceFile(apiName, revision, resourceType, resourceName string) ([]byte, error) {
+func (m mockProxyClient) GetRevisionResourceFileWithContext(_ context.Context, apiName, revision, resourceType, resourceName string) ([]byte, error) {
 	assert.Contains(m.t, proxyName, apiName)
 	assert.Contains(m.t, revName, revision)
+	if m.wsdlResource && resourceType == "wsdl" {
+		assert.Equal(m.t, "orders.wsdl", resourceName)
+		return []byte(testWSDL), nil
+	}
 	assert.Contains(m.t, openapi, resourceType)
 	assert.Contains(m.t, association, resourceName)
+	if m.specCredentials != "" {
+		return []byte(fmt.Sprintf(`{
+		"url": "%s",
+		"credentials": "%s"
+	}`, fullSpecPath, m.specCredentials)), nil
+	}
 	return []byte(fmt.Sprintf(`{
 		"url": "%s"
 	}`, specPath)), nil
 }
 
-func (m mockProxyClient) GetVirtualHost(envName, virtualHostName string) (*models.VirtualHost, error) {
-	return &models.VirtualHost{}, nil
+func (m mockProxyClient) GetVirtualHostWithContext(_ context.Context, envName, virtualHostName string) (*models.VirtualHost, error) {
+	if virtualHostName == "secure" {
+		return &models.VirtualHost{HostAliases: []string{"api.example.com"}, Port: "443", SSLInfo: &models.SslInfo{Enabled: "true"}}, nil
+	}
+	return &models.VirtualHost{HostAliases: []string{"internal.example.com"}, Port: "8080"}, nil
+}
+
+func (m mockProxyClient) GetEnvironmentGroupHostnamesWithContext(_ context.Context, envName string) ([]string, error) {
+	assert.True(m.t, m.cfg.IsApigeeX())
+	return []string{"api.example.com"}, nil
 }
 
-func (m mockProxyClient) GetSpecFile(path string) ([]byte, error) {
+func (m mockProxyClient) GetSpecFileWithContext(_ context.Context, path string) ([]byte, error) {
 	assert.Equal(m.t, specPath, path)
-	return []byte("spec"), nil
+	return []byte(testSpec), nil
 }
 
-func (m mockProxyClient) GetSpecFromURL(url string, options ...apigee.RequestOption) ([]byte, error) {
+func (m mockProxyClient) GetSpecFromURLIfModifiedWithContext(_ context.Context, url string, validators apigee.SpecValidators, options ...apigee.RequestOption) (*apigee.SpecDownload, error) {
 	assert.Equal(m.t, fullSpecPath, url)
-	return []byte("spec"), nil
+	if m.specCredentials != "" {
+		assert.Len(m.t, options, 1)
+	} else {
+		assert.Empty(m.t, options)
+	}
+	return &apigee.SpecDownload{Content: []byte(testSpec)}, nil
 }
 
-func (m mockProxyClient) GetRevisionPolicyByName(apiName, revision, policyName string) (policy *apigee.PolicyDetail, err error) {
-	assert.Contains(m.t, proxyName, apiName)
-	assert.Contains(m.t, revName, revision)
-	if policyName == apiKeyName {
-		policy = &apigee.PolicyDetail{
-			PolicyType: apiKeyPolicy,
-		}
-	}
-	if policyName == oauthName {
-		policy = &apigee.PolicyDetail{
-			PolicyType: oauthPolicy,
-		}
+func (m mockProxyClient) WithSpecCredentials(name string) (apigee.RequestOption, error) {
+	if name != "git" {
+		return nil, fmt.Errorf("spec credentials %s are not configured", name)
 	}
-	return
+	return apigee.WithHeader("Authorization", "Bearer abc"), nil
 }
 
-func (m mockProxyClient) IsReady() bool { return false }
+func (m mockProxyClient) IsReady() bool { return !m.disconnected }
 
 type mockProxyCache struct {
 	pathSpec bool
@@ -301,3 +948,23 @@ func (m mockProxyCache) GetSpecPathWithEndpoint(endpoint string) (string, error)
 }
 
 func (m mockProxyCache) AddPublishedServiceToCache(cacheKey string, serviceBody *apic.ServiceBody) {}
+
+func (m mockProxyCache) GetPublishedProxy(cacheKey string) (*apic.ServiceBody, error) {
+	return nil, fmt.Errorf("not found")
+}
+
+func (m mockProxyCache) GetHandledRevision(revisionKey string) (handledRevision, bool) {
+	return handledRevision{}, false
+}
+
+func (m mockProxyCache) SetHandledRevision(revisionKey string, revision handledRevision) {}
+
+func (m mockProxyCache) IsProxyHandled(proxyName string) bool {
+	return false
+}
+
+func (m mockProxyCache) GetHandledSpecURLs() map[string]struct{} {
+	return map[string]struct{}{}
+}
+
+func (m mockProxyCache) UpdateDeployedAPIs(inventory *deploymentInventory) {}
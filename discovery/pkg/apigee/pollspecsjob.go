@@ -1,6 +1,7 @@
 package apigee
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -11,26 +12,30 @@ import (
 	"github.com/Axway/agent-sdk/pkg/util/log"
 
 	"github.com/Axway/agents-apigee/client/pkg/apigee"
+	"github.com/Axway/agents-apigee/client/pkg/config"
 )
 
 type specClient interface {
-	GetSpecFile(specPath string) ([]byte, error)
-	GetAllSpecs() ([]apigee.SpecDetails, error)
+	GetSpecFileWithContext(ctx context.Context, specPath string) ([]byte, error)
+	GetAllSpecsWithContext(ctx context.Context) ([]apigee.SpecDetails, error)
 	IsReady() bool
 }
 
 type specCache interface {
-	AddSpecToCache(id, path, name string, modDate time.Time, endpoints ...string)
+	AddSpecToCache(id, path, name, folder string, modDate time.Time, endpoints ...string)
 	HasSpecChanged(is string, modDate time.Time) bool
 }
 
 // job that will poll for any new portals on APIGEE Edge
 type pollSpecsJob struct {
 	jobs.Job
+	ctx         context.Context
 	firstRun    bool
 	running     bool
 	parseSpec   bool
-	workers     int
+	pool        *workerPool
+	folders     *config.SpecFolderSelector
+	specContent *specContentStore
 	client      specClient
 	cache       specCache
 	logger      log.FieldLogger
@@ -39,13 +44,22 @@ type pollSpecsJob struct {
 
 func newPollSpecsJob() *pollSpecsJob {
 	job := &pollSpecsJob{
+		ctx:         context.Background(),
 		firstRun:    true,
 		logger:      log.NewFieldLogger().WithComponent("pollSpecs").WithPackage("apigee"),
+		pool:        newWorkerPool("specs", 1),
+		specContent: newSpecContentStore(""),
 		runningLock: sync.Mutex{},
 	}
 	return job
 }
 
+// SetContext - the context the api calls are made with, cancelling it stops the job
+func (j *pollSpecsJob) SetContext(ctx context.Context) *pollSpecsJob {
+	j.ctx = ctx
+	return j
+}
+
 func (j *pollSpecsJob) SetSpecClient(client specClient) *pollSpecsJob {
 	j.client = client
 	return j
@@ -56,8 +70,21 @@ func (j *pollSpecsJob) SetSpecCache(cache specCache) *pollSpecsJob {
 	return j
 }
 
+// SetWorkers - the number of workers downloading and parsing the specs
 func (j *pollSpecsJob) SetWorkers(workers int) *pollSpecsJob {
-	j.workers = workers
+	j.pool = newWorkerPool("specs", workers)
+	return j
+}
+
+// SetFolders - the spec store folders the specs are discovered in, all when nil
+func (j *pollSpecsJob) SetFolders(folders *config.SpecFolderSelector) *pollSpecsJob {
+	j.folders = folders
+	return j
+}
+
+// SetSpecContent - the store the spec contents are shared with the other jobs in
+func (j *pollSpecsJob) SetSpecContent(specContent *specContentStore) *pollSpecsJob {
+	j.specContent = specContent
 	return j
 }
 
@@ -97,37 +124,42 @@ func (j *pollSpecsJob) Execute() error {
 	j.updateRunning(true)
 	defer j.updateRunning(false)
 
-	allSpecs, err := j.client.GetAllSpecs()
+	allSpecs, err := j.client.GetAllSpecsWithContext(j.ctx)
 	if err != nil {
 		j.logger.WithError(err).Error("getting specs")
 		return err
 	}
 
-	limiter := make(chan apigee.SpecDetails, j.workers)
-
-	wg := sync.WaitGroup{}
-	wg.Add(len(allSpecs))
+	run := j.pool.start(j.ctx)
+	ids := map[string]struct{}{}
 	for _, spec := range allSpecs {
-		go func() {
-			defer wg.Done()
-			specDetails := <-limiter
-			j.handleSpec(specDetails)
-		}()
-		limiter <- spec
+		if !j.folders.IsSelected(spec.FolderPath) {
+			j.logger.WithField("specName", spec.Name).WithField("folder", spec.FolderPath).Trace("spec is not in a selected folder")
+			continue
+		}
+		ids[spec.ID] = struct{}{}
+		run.submit(spec.Name, func(ctx context.Context) error {
+			return j.handleSpec(ctx, spec)
+		})
 	}
+	run.wait()
 
-	wg.Wait()
-	close(limiter)
+	// specs skipped when the agent is stopping are not cached, this was not a full run
+	if err := j.ctx.Err(); err != nil {
+		return err
+	}
 
+	// the contents of the specs removed from the spec store are dropped
+	j.specContent.retainSpecs(ids)
 	j.firstRun = false
-	return nil
+	return run.result(j.client.IsReady)
 }
 
 func (j *pollSpecsJob) FirstRunComplete() bool {
 	return !j.firstRun
 }
 
-func (j *pollSpecsJob) handleSpec(spec apigee.SpecDetails) {
+func (j *pollSpecsJob) handleSpec(ctx context.Context, spec apigee.SpecDetails) error {
 	logger := j.logger.WithField("specName", spec.Name).WithField("specID", spec.ID)
 	logger.Trace("handling spec")
 	modDate, _ := time.Parse("2006-01-02T15:04:05.000000Z", spec.Modified)
@@ -135,31 +167,32 @@ func (j *pollSpecsJob) handleSpec(spec apigee.SpecDetails) {
 
 	if !j.cache.HasSpecChanged(spec.ID, modDate) {
 		logger.Trace("spec has not been modified")
-		return
+		return nil
 	}
 
 	endpoints := []string{}
 	if j.parseSpec {
 		// get the spec content
-		content, err := j.client.GetSpecFile(spec.ContentLink)
+		content, err := j.specContent.get(ctx, spec.ID, specVersion(modDate), func(ctx context.Context) ([]byte, error) {
+			return j.client.GetSpecFileWithContext(ctx, spec.ContentLink)
+		})
 		if err != nil {
 			j.logger.WithError(err).Error("getting spec content")
-			return
+			return err
 		}
 
 		// parse the spec
-		parser := apic.NewSpecResourceParser(content, "")
+		parser := apic.NewSpecResourceParser(content, specType(spec.Name, content))
 		err = parser.Parse()
 		if err != nil {
 			j.logger.WithError(err).Error("could not parse spec")
-			return
+			return err
 		}
 
-		// gather spec info
+		// gather spec info, a spec without endpoints, a graphql schema, is only matched to proxies by name
 		endpointDefs, err := parser.GetSpecProcessor().GetEndpoints()
 		if err != nil {
-			j.logger.WithError(err).Error("could not get spec endpoints")
-			return
+			logger.WithError(err).WithField("specType", parser.GetSpecProcessor().GetResourceType()).Warn("could not get spec endpoints, the spec is only matched by name")
 		}
 		for _, ep := range endpointDefs {
 			endpoints = append(endpoints, endpointToString(ep))
@@ -167,7 +200,8 @@ func (j *pollSpecsJob) handleSpec(spec apigee.SpecDetails) {
 	}
 
 	// add spec details to cache
-	j.cache.AddSpecToCache(spec.ID, spec.ContentLink, spec.Name, modDate, endpoints...)
+	j.cache.AddSpecToCache(spec.ID, spec.ContentLink, spec.Name, spec.FolderPath, modDate, endpoints...)
+	return nil
 }
 
 func endpointToString(endpoint apic.EndpointDefinition) string {
@@ -2,7 +2,6 @@ package apigee
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
 	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
@@ -12,6 +11,7 @@ import (
 	"github.com/Axway/agent-sdk/pkg/util/log"
 	"github.com/Axway/agents-apigee/client/pkg/apigee"
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+	"github.com/Axway/agents-apigee/client/pkg/config"
 )
 
 const (
@@ -26,6 +26,7 @@ type provisioner struct {
 	cacheManager          cacheManager
 	isProductMode         bool
 	shouldCloneAttributes bool
+	environments          *config.EnvironmentSelector
 	logger                log.FieldLogger
 }
 
@@ -52,13 +53,14 @@ type client interface {
 }
 
 // NewProvisioner creates a type to implement the SDK Provisioning methods for handling subscriptions
-func NewProvisioner(client client, credExpDays int, cacheMan cacheManager, isProductMode, cloneAttributes bool) prov.Provisioning {
+func NewProvisioner(client client, credExpDays int, cacheMan cacheManager, isProductMode, cloneAttributes bool, environments *config.EnvironmentSelector) prov.Provisioning {
 	return &provisioner{
 		client:                client,
 		credExpDays:           credExpDays,
 		cacheManager:          cacheMan,
 		isProductMode:         isProductMode,
 		shouldCloneAttributes: cloneAttributes,
+		environments:          environments,
 		logger:                log.NewFieldLogger().WithComponent("provision").WithPackage("apigee"),
 	}
 }
@@ -90,7 +92,7 @@ func (p provisioner) AccessRequestDeprovision(req prov.AccessRequest) prov.Reque
 
 	app, err := p.client.GetDeveloperApp(appName)
 	if err != nil {
-		if ok := strings.Contains(err.Error(), "404"); ok {
+		if apigee.IsNotFound(err) {
 			return ps.Success()
 		}
 
@@ -144,6 +146,11 @@ func (p provisioner) AccessRequestProvision(req prov.AccessRequest) (prov.Reques
 		return failed(logger, ps, fmt.Errorf("%s name not found", defs.AttrExternalAPIStage)), nil
 	}
 
+	// the environment may have been deselected since the api was discovered
+	if stage != "" && !p.environments.IsSelected(stage) {
+		return failed(logger, ps, fmt.Errorf("environment %s is not selected for the agent", stage)), nil
+	}
+
 	appName := req.GetApplicationName()
 	if appName == "" {
 		return failed(logger, ps, fmt.Errorf("application name not found")), nil
@@ -182,6 +189,11 @@ func (p provisioner) AccessRequestProvision(req prov.AccessRequest) (prov.Reques
 		product, err = p.productModeCreateProduct(logger, apiProductName, apiID, quota, quotaInterval, quotaTimeUnit)
 	} else {
 		logger.Debug("handling for proxy mode")
+		// the plan quota is set on the api product, a quota policy with hard-coded limits in the proxy ignores it
+		if conflict := planQuotaConflict(instDetails, req.GetQuota()); conflict != "" {
+			logger.WithField("plan", req.GetQuota().GetPlanName()).Warn(conflict)
+			ps.AddProperty(quotaConflictRef, conflict)
+		}
 		product, err = p.proxyModeCreateProduct(logger, apiProductName, apiID, stage, quota, quotaInterval, quotaTimeUnit)
 	}
 	if err != nil {
@@ -256,6 +268,17 @@ func (p provisioner) productModeCreateProduct(logger log.FieldLogger, targetProd
 		if p.shouldCloneAttributes {
 			attributes = curProduct.Attributes
 		}
+		// the cloned product is only deployed to the selected environments
+		environments := []string{}
+		for _, env := range curProduct.Environments {
+			if p.environments.IsSelected(env) {
+				environments = append(environments, env)
+			}
+		}
+		if len(curProduct.Environments) > 0 && len(environments) == 0 {
+			return nil, fmt.Errorf("product %s is not in a selected environment", curProduct.Name)
+		}
+
 		attributes = append(attributes, []models.Attribute{
 			{
 				Name:  agentProductTagName,
@@ -273,7 +296,7 @@ func (p provisioner) productModeCreateProduct(logger log.FieldLogger, targetProd
 			Attributes:   attributes,
 			Description:  curProduct.Description,
 			DisplayName:  targetProductName,
-			Environments: curProduct.Environments,
+			Environments: environments,
 			Name:         targetProductName,
 			Proxies:      curProduct.Proxies,
 			Scopes:       curProduct.Scopes,
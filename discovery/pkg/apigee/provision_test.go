@@ -2,6 +2,7 @@ package apigee
 
 import (
 	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/Axway/agent-sdk/pkg/util"
 	"github.com/Axway/agents-apigee/client/pkg/apigee"
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+	"github.com/Axway/agents-apigee/client/pkg/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,7 +39,7 @@ func TestAccessRequestDeprovision(t *testing.T) {
 			appName:   "app-one",
 			apiID:     "abc-123",
 			status:    provisioning.Success,
-			getAppErr: fmt.Errorf("404"),
+			getAppErr: &apigee.APIError{StatusCode: http.StatusNotFound},
 		},
 		{
 			name:      "should fail to deprovision an access request when retrieving the app, and the error is not a 404",
@@ -87,7 +89,7 @@ func TestAccessRequestDeprovision(t *testing.T) {
 				appName:     tc.appName,
 				key:         app.Credentials[0].ConsumerKey,
 				productName: fmt.Sprintf("%s-no-quota", tc.apiID),
-			}, 30, &mockCache{t: t}, false, false)
+			}, 30, &mockCache{t: t}, false, false, nil)
 
 			if tc.missingCred {
 				app.Credentials = nil
@@ -123,6 +125,10 @@ func TestAccessRequestProvision(t *testing.T) {
 		existingProd bool
 		noCreds      bool
 		isApiLinked  bool
+		quotaLimit   int64
+		quotaDetails map[string]interface{}
+		conflict     bool
+		environments string
 	}{
 		{
 			name:     "should provision an access request",
@@ -178,6 +184,54 @@ func TestAccessRequestProvision(t *testing.T) {
 			status:    provisioning.Error,
 			getAppErr: fmt.Errorf("error"),
 		},
+		{
+			name:         "should provision an access request with a plan quota the proxy quota policy matches",
+			appName:      "app-one",
+			apiID:        "abc-123",
+			newAPIID:     "abc-123-gold",
+			apiStage:     "prod",
+			status:       provisioning.Success,
+			quotaLimit:   1000,
+			quotaDetails: map[string]interface{}{quotaAllowDetail: "1000", quotaIntervalDetail: "1", quotaTimeUnitDetail: "day"},
+		},
+		{
+			name:         "should flag a plan quota the hard-coded proxy quota policy disagrees with",
+			appName:      "app-one",
+			apiID:        "abc-123",
+			newAPIID:     "abc-123-gold",
+			apiStage:     "prod",
+			status:       provisioning.Success,
+			quotaLimit:   1000,
+			quotaDetails: map[string]interface{}{quotaAllowDetail: "10", quotaIntervalDetail: "1", quotaTimeUnitDetail: "minute"},
+			conflict:     true,
+		},
+		{
+			name:         "should not flag a plan quota when the proxy quota policy reads it from the product",
+			appName:      "app-one",
+			apiID:        "abc-123",
+			newAPIID:     "abc-123-gold",
+			apiStage:     "prod",
+			status:       provisioning.Success,
+			quotaLimit:   1000,
+			quotaDetails: map[string]interface{}{quotaAllowDetail: "0", quotaAllowRefDetail: "verifyapikey.verify-api-key.apiproduct.developer.quota.limit"},
+		},
+		{
+			name:         "should provision an access request in a selected environment",
+			appName:      "app-one",
+			apiID:        "abc-123",
+			newAPIID:     "abc-123-no-quota",
+			apiStage:     "prod",
+			status:       provisioning.Success,
+			environments: "prod*",
+		},
+		{
+			name:         "should return an error when the environment is not selected",
+			appName:      "app-one",
+			apiID:        "abc-123",
+			apiStage:     "prod",
+			status:       provisioning.Error,
+			environments: "dev, test",
+		},
 		{
 			name:     "should return an error when the apiID is not found",
 			appName:  "app-one",
@@ -208,6 +262,9 @@ func TestAccessRequestProvision(t *testing.T) {
 				app.Credentials = nil
 			}
 
+			environments, err := config.NewEnvironmentSelector(tc.environments, "", "")
+			assert.Nil(t, err)
+
 			p := NewProvisioner(&mockClient{
 				addCredErr:  tc.addCredErr,
 				app:         app,
@@ -218,7 +275,7 @@ func TestAccessRequestProvision(t *testing.T) {
 				upCredErr:   tc.upCredErr,
 				productName: tc.newAPIID,
 				t:           t,
-			}, 30, &mockCache{t: t}, false, false)
+			}, 30, &mockCache{t: t}, false, false, environments)
 
 			mar := mock.MockAccessRequest{
 				InstanceDetails: map[string]interface{}{
@@ -228,10 +285,21 @@ func TestAccessRequestProvision(t *testing.T) {
 				AppDetails: nil,
 				AppName:    tc.appName,
 			}
+			for k, v := range tc.quotaDetails {
+				mar.InstanceDetails[k] = v
+			}
+			if tc.quotaLimit > 0 {
+				mar.QuotaLimit = tc.quotaLimit
+				mar.QuotaInterval = provisioning.Daily
+				mar.PlanName = "gold"
+			}
 
 			status, _ := p.AccessRequestProvision(&mar)
 			assert.Equal(t, tc.status.String(), status.GetStatus().String())
-			if tc.status == provisioning.Success {
+			if tc.conflict {
+				assert.Equal(t, 2, len(status.GetProperties()))
+				assert.Contains(t, status.GetProperties()[quotaConflictRef], "allows 10 requests")
+			} else if tc.status == provisioning.Success {
 				assert.Equal(t, 1, len(status.GetProperties()))
 			} else {
 				assert.Equal(t, 0, len(status.GetProperties()))
@@ -287,7 +355,7 @@ func TestApplicationRequestDeprovision(t *testing.T) {
 				productName: tc.apiID,
 				t:           t,
 				rmAppErr:    tc.rmAppErr,
-			}, 30, &mockCache{t: t}, false, false)
+			}, 30, &mockCache{t: t}, false, false, nil)
 
 			mar := mock.MockApplicationRequest{
 				AppName:  tc.appName,
@@ -335,7 +403,7 @@ func TestApplicationRequestProvision(t *testing.T) {
 				productName:  tc.apiID,
 				t:            t,
 				createAppErr: tc.createAppErr,
-			}, 30, &mockCache{t: t}, false, false)
+			}, 30, &mockCache{t: t}, false, false, nil)
 
 			mar := mock.MockApplicationRequest{
 				AppName:  tc.appName,
@@ -411,7 +479,7 @@ func TestCredentialDeprovision(t *testing.T) {
 				productName: tc.apiID,
 				t:           t,
 				getAppErr:   tc.getAppErr,
-			}, 30, &mockCache{t: t, appName: tc.appName}, false, false)
+			}, 30, &mockCache{t: t, appName: tc.appName}, false, false, nil)
 
 			thisHash, _ := util.ComputeHash(key)
 			mcr := mock.MockCredentialRequest{
@@ -480,7 +548,7 @@ func TestCredentialProvision(t *testing.T) {
 				productName: tc.apiID,
 				t:           t,
 				getAppErr:   tc.getAppErr,
-			}, 30, &mockCache{t: t, appName: tc.appName}, false, false)
+			}, 30, &mockCache{t: t, appName: tc.appName}, false, false, nil)
 
 			mcr := mock.MockCredentialRequest{
 				AppName:     tc.appName,
@@ -585,7 +653,7 @@ func TestCredentialUpdate(t *testing.T) {
 				t:           t,
 				getAppErr:   tc.getAppErr,
 				enable:      tc.action == provisioning.Enable,
-			}, 30, &mockCache{t: t, appName: tc.appName}, false, false)
+			}, 30, &mockCache{t: t, appName: tc.appName}, false, false, nil)
 
 			thisHash, _ := util.ComputeHash(key)
 			details := map[string]string{
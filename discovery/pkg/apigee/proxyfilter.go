@@ -0,0 +1,56 @@
+package apigee
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/bundle"
+	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+)
+
+// the tags the discovery filter is evaluated against in proxy mode, tag.name == "petstore"
+const (
+	filterNameTag        = "name"
+	filterDisplayNameTag = "displayName"
+	filterDescriptionTag = "description"
+	filterRevisionTag    = "revision"
+	filterEnvironmentTag = "environment"
+	filterVirtualHostTag = "virtualHost"
+	filterBasePathTag    = "basePath"
+)
+
+// filterTagReplacer - the filter only reads tag names made of word characters and dashes
+var filterTagReplacer = regexp.MustCompile(`[^\w-]`)
+
+// proxyFilterTags - the tags of a deployed proxy revision, the bundle properties are added with their names, the
+// virtual hosts and base paths of all proxy endpoints are comma separated
+func proxyFilterTags(proxyName, envName string, revision *models.ApiProxyRevision, proxyBundle *bundle.Bundle) map[string]string {
+	tags := map[string]string{}
+	for name, value := range proxyBundle.GetProperties() {
+		tags[filterTagReplacer.ReplaceAllString(name, "_")] = value
+	}
+
+	virtualHosts := []string{}
+	basePaths := slices.Clone(proxyBundle.BasePaths)
+	for _, endpoint := range proxyBundle.ProxyEndpoints {
+		for _, virtualHost := range endpoint.GetVirtualHosts() {
+			if !slices.Contains(virtualHosts, virtualHost) {
+				virtualHosts = append(virtualHosts, virtualHost)
+			}
+		}
+		if basePath := endpoint.GetBasePath(); basePath != "" && !slices.Contains(basePaths, basePath) {
+			basePaths = append(basePaths, basePath)
+		}
+	}
+
+	// the proxy details take precedence over a property with the same name
+	tags[filterNameTag] = proxyName
+	tags[filterDisplayNameTag] = revision.DisplayName
+	tags[filterDescriptionTag] = revision.Description
+	tags[filterRevisionTag] = revision.Revision
+	tags[filterEnvironmentTag] = envName
+	tags[filterVirtualHostTag] = strings.Join(virtualHosts, ",")
+	tags[filterBasePathTag] = strings.Join(basePaths, ",")
+	return tags
+}
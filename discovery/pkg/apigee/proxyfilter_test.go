@@ -0,0 +1,57 @@
+package apigee
+
+import (
+	"testing"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/bundle"
+	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_proxyFilterTags(t *testing.T) {
+	revision := &models.ApiProxyRevision{
+		Name:        "petstore",
+		DisplayName: "Petstore",
+		Description: "The petstore proxy",
+		Revision:    "3",
+	}
+	proxyBundle := &bundle.Bundle{
+		BasePaths: []string{"/petstore"},
+		ProxyEndpoints: []*bundle.ProxyEndpoint{
+			{
+				Name: "default",
+				HTTPProxyConnection: &bundle.HTTPProxyConnection{
+					BasePath:     "/petstore",
+					VirtualHosts: []string{"default", "secure"},
+					Properties:   []*bundle.Property{{Name: "team", Value: "pets"}, {Name: "name", Value: "overridden"}},
+				},
+			},
+			{
+				Name:                "admin",
+				HTTPProxyConnection: &bundle.HTTPProxyConnection{BasePath: "/admin", VirtualHosts: []string{"secure"}},
+			},
+		},
+		Resources: []*bundle.Resource{
+			{Type: "properties", Name: "settings.properties", Content: []byte("tier=gold")},
+		},
+	}
+
+	tags := proxyFilterTags("petstore", "prod", revision, proxyBundle)
+	assert.Equal(t, map[string]string{
+		filterNameTag:        "petstore",
+		filterDisplayNameTag: "Petstore",
+		filterDescriptionTag: "The petstore proxy",
+		filterRevisionTag:    "3",
+		filterEnvironmentTag: "prod",
+		filterVirtualHostTag: "default,secure",
+		filterBasePathTag:    "/petstore,/admin",
+		"team":               "pets",
+		"settings_tier":      "gold",
+	}, tags)
+
+	// a bundle without endpoints has only the proxy details
+	tags = proxyFilterTags("petstore", "prod", revision, &bundle.Bundle{})
+	assert.Equal(t, "", tags[filterVirtualHostTag])
+	assert.Equal(t, "", tags[filterBasePathTag])
+	assert.Len(t, tags, 7)
+}
@@ -0,0 +1,174 @@
+package apigee
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util/log"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/bundle"
+)
+
+// service agent details read from the proxy policies
+const (
+	apiKeyInDetail         = "apiKeyIn"
+	apiKeyNameDetail       = "apiKeyName"
+	oauthOperationDetail   = "oauthOperation"
+	oauthScopesDetail      = "oauthScopes"
+	quotaAllowDetail       = "quotaAllow"
+	quotaIntervalDetail    = "quotaInterval"
+	quotaTimeUnitDetail    = "quotaTimeUnit"
+	quotaAllowRefDetail    = "quotaAllowRef"
+	quotaIntervalRefDetail = "quotaIntervalRef"
+	quotaTimeUnitRefDetail = "quotaTimeUnitRef"
+)
+
+// service attributes describing the quota enforced by the proxy
+const (
+	quotaLimitAttribute  = "quotaLimit"
+	quotaPeriodAttribute = "quotaPeriod"
+	quotaSourceAttribute = "quotaSource"
+)
+
+// values of the quota source attribute
+const (
+	quotaSourcePolicy  = "policy"
+	quotaSourceProduct = "product"
+)
+
+// proxyPolicies - the settings of the authentication and quota policies that run in the flows of a revision
+type proxyPolicies struct {
+	apiKey *bundle.VerifyAPIKey
+	oauth  *bundle.OAuthV2
+	quota  *bundle.Quota
+	// disabled - the policies that are attached to a flow but have enabled set to false
+	disabled []string
+	// unreferenced - the policies in the bundle that are not run by any flow step
+	unreferenced []string
+}
+
+// policiesFromBundle - reads the policies that are enabled and attached to a flow, the first of each type is used
+func policiesFromBundle(logger log.FieldLogger, proxyBundle *bundle.Bundle) *proxyPolicies {
+	policies := &proxyPolicies{
+		disabled:     []string{},
+		unreferenced: []string{},
+	}
+
+	for _, policy := range proxyBundle.Policies {
+		logger := logger.WithField("policyName", policy.Name)
+		if !proxyBundle.IsPolicyReferenced(policy.Name) {
+			policies.unreferenced = append(policies.unreferenced, policy.Name)
+			continue
+		}
+		if !policy.Enabled {
+			policies.disabled = append(policies.disabled, policy.Name)
+			continue
+		}
+
+		var err error
+		switch policy.Type {
+		case apiKeyPolicy:
+			if policies.apiKey == nil {
+				policies.apiKey, err = policy.VerifyAPIKey()
+			}
+		case oauthPolicy:
+			var oauth *bundle.OAuthV2
+			oauth, err = policy.OAuthV2()
+			// a policy verifying tokens is preferred over one that issues them
+			if err == nil && (policies.oauth == nil || (oauth.Operation == bundle.VerifyAccessTokenOperation && policies.oauth.Operation != bundle.VerifyAccessTokenOperation)) {
+				policies.oauth = oauth
+			}
+		case quotaPolicy:
+			if policies.quota == nil {
+				policies.quota, err = policy.Quota()
+			}
+		}
+		if err != nil {
+			logger.WithError(err).Debug("could not read the policy settings")
+		}
+	}
+
+	if len(policies.disabled) > 0 {
+		logger.WithField("policies", strings.Join(policies.disabled, ",")).Debug("revision has disabled policies in its flows")
+	}
+	if len(policies.unreferenced) > 0 {
+		logger.WithField("policies", strings.Join(policies.unreferenced, ",")).Debug("revision has policies that are not in any flow")
+	}
+	return policies
+}
+
+// credentialRequestDefinitions - the credential types the authentication policies accept
+func (p *proxyPolicies) credentialRequestDefinitions() []string {
+	crds := []string{}
+	if p.apiKey != nil {
+		crds = append(crds, provisioning.APIKeyCRD)
+	}
+	if p.oauth != nil {
+		crds = append(crds, provisioning.OAuthSecretCRD)
+	}
+	return crds
+}
+
+// addAgentDetails - adds the policy settings the service body is built with to the agent details
+func (p *proxyPolicies) addAgentDetails(details map[string]interface{}) {
+	if p.apiKey != nil && p.apiKey.In != "" {
+		details[apiKeyInDetail] = p.apiKey.In
+		details[apiKeyNameDetail] = p.apiKey.Name
+	}
+	if p.oauth != nil {
+		details[oauthOperationDetail] = p.oauth.Operation
+		if len(p.oauth.Scopes) > 0 {
+			details[oauthScopesDetail] = strings.Join(p.oauth.Scopes, " ")
+		}
+	}
+	if p.quota != nil {
+		details[quotaAllowDetail] = strconv.Itoa(p.quota.Allow)
+		details[quotaIntervalDetail] = strconv.Itoa(p.quota.Interval)
+		details[quotaTimeUnitDetail] = p.quota.TimeUnit
+		if p.quota.AllowRef != "" {
+			details[quotaAllowRefDetail] = p.quota.AllowRef
+		}
+		if p.quota.IntervalRef != "" {
+			details[quotaIntervalRefDetail] = p.quota.IntervalRef
+		}
+		if p.quota.TimeUnitRef != "" {
+			details[quotaTimeUnitRefDetail] = p.quota.TimeUnitRef
+		}
+	}
+}
+
+// isProductQuotaRef - returns true when the ref is the quota setting of the api product the VerifyAPIKey policy
+// resolved, verifyapikey.{policy name}.apiproduct.developer.quota.{setting}
+func isProductQuotaRef(ref string) bool {
+	name, found := strings.CutPrefix(strings.ToLower(ref), "verifyapikey.")
+	if !found {
+		return false
+	}
+	name, setting, found := strings.Cut(name, ".apiproduct.developer.quota.")
+	return found && name != "" && setting != ""
+}
+
+// serviceAttributes - the quota limits of the proxy, the source is product when the limit is read from the api product,
+// a limit read from another flow variable is not known
+func (p *proxyPolicies) serviceAttributes() map[string]string {
+	attributes := map[string]string{}
+	if p.quota == nil {
+		return attributes
+	}
+
+	if isProductQuotaRef(p.quota.AllowRef) {
+		attributes[quotaSourceAttribute] = quotaSourceProduct
+		return attributes
+	}
+
+	attributes[quotaSourceAttribute] = quotaSourcePolicy
+	if p.quota.AllowRef == "" {
+		attributes[quotaLimitAttribute] = strconv.Itoa(p.quota.Allow)
+	}
+	if p.quota.Interval > 0 && p.quota.TimeUnit != "" && p.quota.IntervalRef == "" && p.quota.TimeUnitRef == "" {
+		attributes[quotaPeriodAttribute] = fmt.Sprintf("%d %s", p.quota.Interval, p.quota.TimeUnit)
+	}
+	return attributes
+}
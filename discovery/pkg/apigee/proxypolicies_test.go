@@ -0,0 +1,84 @@
+package apigee
+
+import (
+	"testing"
+
+	"github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee/bundle"
+)
+
+func Test_policiesFromBundle(t *testing.T) {
+	proxyBundle := &bundle.Bundle{
+		ProxyEndpoints: []*bundle.ProxyEndpoint{
+			{
+				Name:    "default",
+				PreFlow: &bundle.Flow{Request: []*bundle.Step{{Name: "issue-token"}, {Name: "verify-token"}, {Name: "off-key"}}},
+				Flows:   []*bundle.Flow{{Name: "list", Request: []*bundle.Step{{Name: "spike-arrest"}}}},
+			},
+		},
+		Policies: []*bundle.Policy{
+			{Name: "issue-token", Type: oauthPolicy, Enabled: true, Content: []byte(`<OAuthV2 name="issue-token"><Operation>GenerateAccessToken</Operation></OAuthV2>`)},
+			{Name: "off-key", Type: apiKeyPolicy, Enabled: false, Content: []byte(`<VerifyAPIKey name="off-key"/>`)},
+			{Name: "spike-arrest", Type: "SpikeArrest", Enabled: true},
+			{Name: "unused-quota", Type: quotaPolicy, Enabled: true, Content: []byte(`<Quota name="unused-quota"/>`)},
+			{Name: "verify-token", Type: oauthPolicy, Enabled: true, Content: []byte(`<OAuthV2 name="verify-token"><Operation>VerifyAccessToken</Operation><Scope>read</Scope></OAuthV2>`)},
+		},
+	}
+
+	policies := policiesFromBundle(log.NewFieldLogger(), proxyBundle)
+	assert.Nil(t, policies.apiKey)
+	assert.Nil(t, policies.quota)
+	assert.Equal(t, &bundle.OAuthV2{Operation: "VerifyAccessToken", Scopes: []string{"read"}}, policies.oauth)
+	assert.Equal(t, []string{"off-key"}, policies.disabled)
+	assert.Equal(t, []string{"unused-quota"}, policies.unreferenced)
+	assert.Equal(t, []string{provisioning.OAuthSecretCRD}, policies.credentialRequestDefinitions())
+
+	details := map[string]interface{}{}
+	policies.addAgentDetails(details)
+	assert.Equal(t, map[string]interface{}{oauthOperationDetail: "VerifyAccessToken", oauthScopesDetail: "read"}, details)
+
+	assert.Empty(t, policies.serviceAttributes())
+
+	// a bundle without policies has no credentials
+	policies = policiesFromBundle(log.NewFieldLogger(), &bundle.Bundle{})
+	assert.Empty(t, policies.credentialRequestDefinitions())
+}
+
+func Test_proxyPoliciesQuota(t *testing.T) {
+	// hard-coded limits
+	policies := &proxyPolicies{quota: &bundle.Quota{Allow: 100, Interval: 1, TimeUnit: "hour"}}
+	assert.Equal(t, map[string]string{quotaSourceAttribute: quotaSourcePolicy, quotaLimitAttribute: "100", quotaPeriodAttribute: "1 hour"}, policies.serviceAttributes())
+
+	details := map[string]interface{}{}
+	policies.addAgentDetails(details)
+	assert.Equal(t, map[string]interface{}{quotaAllowDetail: "100", quotaIntervalDetail: "1", quotaTimeUnitDetail: "hour"}, details)
+
+	// the period is read from the api product
+	policies.quota.IntervalRef = "verifyapikey.Verify-API-Key-1.apiproduct.developer.quota.interval"
+	assert.Equal(t, map[string]string{quotaSourceAttribute: quotaSourcePolicy, quotaLimitAttribute: "100"}, policies.serviceAttributes())
+
+	// the limit is read from the api product
+	policies = &proxyPolicies{quota: &bundle.Quota{AllowRef: "verifyapikey.Verify-API-Key-1.apiproduct.developer.quota.limit", Allow: 5}}
+	assert.Equal(t, map[string]string{quotaSourceAttribute: quotaSourceProduct}, policies.serviceAttributes())
+
+	details = map[string]interface{}{}
+	policies.addAgentDetails(details)
+	assert.Equal(t, "verifyapikey.Verify-API-Key-1.apiproduct.developer.quota.limit", details[quotaAllowRefDetail])
+
+	// the limit is read from a custom flow variable, it is not known
+	policies = &proxyPolicies{quota: &bundle.Quota{AllowRef: "request.header.allowed_quota", Allow: 5, Interval: 1, TimeUnit: "hour"}}
+	assert.Equal(t, map[string]string{quotaSourceAttribute: quotaSourcePolicy, quotaPeriodAttribute: "1 hour"}, policies.serviceAttributes())
+}
+
+func Test_isProductQuotaRef(t *testing.T) {
+	assert.True(t, isProductQuotaRef("verifyapikey.Verify-API-Key-1.apiproduct.developer.quota.limit"))
+	assert.True(t, isProductQuotaRef("VerifyAPIKey.verify.apiproduct.developer.quota.timeunit"))
+	assert.False(t, isProductQuotaRef("apiproduct.developer.quota.limit"))
+	assert.False(t, isProductQuotaRef("verifyapikey..apiproduct.developer.quota.limit"))
+	assert.False(t, isProductQuotaRef("verifyapikey.Verify-API-Key-1.apiproduct.developer.quota."))
+	assert.False(t, isProductQuotaRef("request.header.allowed_quota"))
+	assert.False(t, isProductQuotaRef(""))
+}
@@ -0,0 +1,79 @@
+package apigee
+
+import (
+	"fmt"
+	"strconv"
+
+	prov "github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/util"
+)
+
+// quotaConflictRef - the access request property describing a conflict between the plan and proxy quota
+const quotaConflictRef = "quotaConflict"
+
+// quotaPeriod - a quota interval normalized to the apigee time units the plan intervals map to
+type quotaPeriod struct {
+	interval int
+	timeUnit string
+}
+
+func (q quotaPeriod) String() string {
+	return fmt.Sprintf("%d %s", q.interval, q.timeUnit)
+}
+
+// planQuotaPeriod - the period of a central plan quota, as the api product created for the plan sets it
+func planQuotaPeriod(interval prov.QuotaInterval) (quotaPeriod, bool) {
+	period, ok := map[prov.QuotaInterval]quotaPeriod{
+		prov.Minute:   {1, "minute"},
+		prov.Hourly:   {1, "hour"},
+		prov.Daily:    {1, "day"},
+		prov.Weekly:   {7, "day"},
+		prov.Monthly:  {1, "month"},
+		prov.Annually: {12, "month"},
+	}[interval]
+	return period, ok
+}
+
+// policyQuotaPeriod - the period of a proxy quota policy, weeks are counted in days to compare with the plan period
+func policyQuotaPeriod(interval int, timeUnit string) quotaPeriod {
+	if timeUnit == "week" {
+		return quotaPeriod{interval * 7, "day"}
+	}
+	return quotaPeriod{interval, timeUnit}
+}
+
+// planQuotaConflict - describes how the quota policy hard-coded in the proxy disagrees with the central plan quota,
+// empty when the proxy has no quota policy or reads its limits from the api product the plan creates
+func planQuotaConflict(instDetails map[string]interface{}, quota prov.Quota) string {
+	if quota == nil {
+		return ""
+	}
+	allowDetail := util.ToString(instDetails[quotaAllowDetail])
+	allowRef := util.ToString(instDetails[quotaAllowRefDetail])
+	if allowDetail == "" || isProductQuotaRef(allowRef) {
+		return ""
+	}
+	if allowRef != "" {
+		return fmt.Sprintf("the proxy quota policy reads its limit from %s, not from the api product of the plan %s", allowRef, quota.GetPlanName())
+	}
+
+	allow, _ := strconv.Atoi(allowDetail)
+	if int64(allow) != quota.GetLimit() {
+		return fmt.Sprintf("the proxy quota policy allows %d requests, the plan %s allows %d", allow, quota.GetPlanName(), quota.GetLimit())
+	}
+
+	// the period is only compared when the policy does not read it from a flow variable
+	if util.ToString(instDetails[quotaIntervalRefDetail]) != "" || util.ToString(instDetails[quotaTimeUnitRefDetail]) != "" {
+		return ""
+	}
+	interval, _ := strconv.Atoi(util.ToString(instDetails[quotaIntervalDetail]))
+	timeUnit := util.ToString(instDetails[quotaTimeUnitDetail])
+	planPeriod, ok := planQuotaPeriod(quota.GetInterval())
+	if interval <= 0 || timeUnit == "" || !ok {
+		return ""
+	}
+	if policyPeriod := policyQuotaPeriod(interval, timeUnit); policyPeriod != planPeriod {
+		return fmt.Sprintf("the proxy quota policy period is %s, the plan %s period is %s", policyPeriod, quota.GetPlanName(), planPeriod)
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package apigee
+
+import (
+	"testing"
+
+	"github.com/Axway/agent-sdk/pkg/apic/provisioning"
+	"github.com/Axway/agent-sdk/pkg/apic/provisioning/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_planQuotaConflict(t *testing.T) {
+	tests := []struct {
+		name     string
+		details  map[string]interface{}
+		limit    int64
+		interval provisioning.QuotaInterval
+		conflict string
+	}{
+		{
+			name:     "no plan quota",
+			details:  map[string]interface{}{quotaAllowDetail: "10", quotaIntervalDetail: "1", quotaTimeUnitDetail: "day"},
+			limit:    100,
+			interval: 0,
+		},
+		{
+			name:     "no quota policy",
+			details:  map[string]interface{}{},
+			limit:    100,
+			interval: provisioning.Daily,
+		},
+		{
+			name:     "matching quota",
+			details:  map[string]interface{}{quotaAllowDetail: "100", quotaIntervalDetail: "1", quotaTimeUnitDetail: "day"},
+			limit:    100,
+			interval: provisioning.Daily,
+		},
+		{
+			name:     "matching weekly quota",
+			details:  map[string]interface{}{quotaAllowDetail: "100", quotaIntervalDetail: "1", quotaTimeUnitDetail: "week"},
+			limit:    100,
+			interval: provisioning.Weekly,
+		},
+		{
+			name:     "different limit",
+			details:  map[string]interface{}{quotaAllowDetail: "10", quotaIntervalDetail: "1", quotaTimeUnitDetail: "day"},
+			limit:    100,
+			interval: provisioning.Daily,
+			conflict: "the proxy quota policy allows 10 requests, the plan gold allows 100",
+		},
+		{
+			name:     "different period",
+			details:  map[string]interface{}{quotaAllowDetail: "100", quotaIntervalDetail: "1", quotaTimeUnitDetail: "hour"},
+			limit:    100,
+			interval: provisioning.Monthly,
+			conflict: "the proxy quota policy period is 1 hour, the plan gold period is 1 month",
+		},
+		{
+			name:     "limit read from the product",
+			details:  map[string]interface{}{quotaAllowDetail: "10", quotaAllowRefDetail: "verifyapikey.Verify-API-Key-1.apiproduct.developer.quota.limit"},
+			limit:    100,
+			interval: provisioning.Daily,
+		},
+		{
+			name:     "limit read from a custom flow variable",
+			details:  map[string]interface{}{quotaAllowDetail: "100", quotaAllowRefDetail: "request.header.allowed_quota"},
+			limit:    100,
+			interval: provisioning.Daily,
+			conflict: "the proxy quota policy reads its limit from request.header.allowed_quota, not from the api product of the plan gold",
+		},
+		{
+			name:     "period read from the product",
+			details:  map[string]interface{}{quotaAllowDetail: "100", quotaIntervalDetail: "1", quotaTimeUnitDetail: "hour", quotaTimeUnitRefDetail: "verifyapikey.Verify-API-Key-1.apiproduct.developer.quota.timeunit"},
+			limit:    100,
+			interval: provisioning.Monthly,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := mock.MockAccessRequest{QuotaLimit: tc.limit, QuotaInterval: tc.interval, PlanName: "gold"}
+			assert.Equal(t, tc.conflict, planQuotaConflict(tc.details, req.GetQuota()))
+		})
+	}
+}
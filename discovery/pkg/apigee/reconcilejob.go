@@ -0,0 +1,174 @@
+package apigee
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	defs "github.com/Axway/agent-sdk/pkg/apic/definitions"
+	"github.com/Axway/agent-sdk/pkg/jobs"
+	coreutil "github.com/Axway/agent-sdk/pkg/util"
+	"github.com/Axway/agent-sdk/pkg/util/log"
+)
+
+// release states set on the lifecycle of the service instances
+const (
+	deprecatedReleaseState = "deprecated"
+	stableReleaseState     = "stable"
+)
+
+type deployedCache interface {
+	IsAPIDeployed(cacheKey string) bool
+	HasDeployedAPIs() bool
+	SetDeprecated(cacheKey string, deprecatedAt time.Time)
+	GetDeprecated(cacheKey string) (time.Time, bool)
+	RemoveDeprecated(cacheKey string)
+	RetainDeprecated(cacheKeys map[string]struct{})
+}
+
+type instanceCache interface {
+	GetAPIServiceInstanceKeys() []string
+	GetAPIServiceInstanceByID(id string) (*v1.ResourceInstance, error)
+}
+
+type subResourceClient interface {
+	CreateSubResource(rm v1.ResourceMeta, subs map[string]interface{}) error
+}
+
+// job that deprecates the service instances of apis that are no longer deployed on apigee, the api validator
+// removes them once they have been deprecated for the grace period
+type reconcileJob struct {
+	jobs.Job
+	cache       deployedCache
+	instances   instanceCache
+	client      subResourceClient
+	gracePeriod time.Duration
+	logger      log.FieldLogger
+	// staleLock - the instances are deprecated by one run at a time, the time they were deprecated is kept in the
+	// cache so the grace period carries on when the agent restarts
+	staleLock sync.Mutex
+	now       func() time.Time
+}
+
+func newReconcileJob(cache deployedCache, instances instanceCache, client subResourceClient, gracePeriod time.Duration) *reconcileJob {
+	return &reconcileJob{
+		cache:       cache,
+		instances:   instances,
+		client:      client,
+		gracePeriod: gracePeriod,
+		logger:      log.NewFieldLogger().WithComponent("reconcile").WithPackage("apigee"),
+		now:         time.Now,
+	}
+}
+
+func (j *reconcileJob) Ready() bool {
+	return j.cache.HasDeployedAPIs()
+}
+
+func (j *reconcileJob) Status() error {
+	return nil
+}
+
+func (j *reconcileJob) Execute() error {
+	j.logger.Trace("executing")
+	if j.gracePeriod == 0 {
+		// nothing is deprecated, the api validator removes the services right away
+		return nil
+	}
+
+	j.staleLock.Lock()
+	defer j.staleLock.Unlock()
+
+	found := map[string]struct{}{}
+	for _, id := range j.instances.GetAPIServiceInstanceKeys() {
+		instance, err := j.instances.GetAPIServiceInstanceByID(id)
+		if err != nil || instance == nil {
+			continue
+		}
+		apiID, _ := coreutil.GetAgentDetailsValue(instance, defs.AttrExternalAPIID)
+		if apiID == "" {
+			continue
+		}
+		stage, _ := coreutil.GetAgentDetailsValue(instance, defs.AttrExternalAPIStage)
+		cacheKey := deployedCacheKey(apiID, stage)
+		found[cacheKey] = struct{}{}
+
+		logger := j.logger.WithField("instance", instance.Name).WithField("apiID", apiID)
+		if stage != "" {
+			logger = logger.WithField("stage", stage)
+		}
+
+		_, isStale := j.cache.GetDeprecated(cacheKey)
+		if j.cache.IsAPIDeployed(cacheKey) {
+			if isStale {
+				logger.Info("api is deployed again, restoring the service instance")
+				j.setReleaseState(logger, instance, stableReleaseState, "")
+				j.cache.RemoveDeprecated(cacheKey)
+			}
+			continue
+		}
+
+		if !isStale {
+			removeAt := j.now().Add(j.gracePeriod)
+			logger.WithField("removeAt", removeAt.Format(time.RFC3339)).Info("api is no longer deployed, deprecating the service instance")
+			j.setReleaseState(logger, instance, deprecatedReleaseState, fmt.Sprintf("The API is no longer deployed on Apigee, it will be removed after %s", removeAt.Format(time.RFC3339)))
+			j.cache.SetDeprecated(cacheKey, j.now())
+		}
+	}
+
+	// forget the instances that have been removed
+	j.cache.RetainDeprecated(found)
+	return nil
+}
+
+// setReleaseState - updates the release state on the lifecycle of the instance, keeping its stage
+func (j *reconcileJob) setReleaseState(logger log.FieldLogger, instance *v1.ResourceInstance, state, message string) {
+	svcInstance := management.NewAPIServiceInstance("", "")
+	if err := svcInstance.FromInstance(instance); err != nil {
+		logger.WithError(err).Error("could not read the service instance")
+		return
+	}
+
+	lifecycle := management.ApiServiceInstanceLifecycle{}
+	if svcInstance.Lifecycle != nil {
+		lifecycle = *svcInstance.Lifecycle
+	}
+	lifecycle.ReleaseState = management.ApiServiceInstanceLifecycleReleaseState{Name: state, Message: message}
+
+	err := j.client.CreateSubResource(instance.ResourceMeta, map[string]interface{}{
+		management.ApiServiceInstanceLifecycleSubResourceName: lifecycle,
+	})
+	if err != nil {
+		logger.WithError(err).WithField("releaseState", state).Error("could not update the service instance lifecycle")
+	}
+}
+
+// validate - the api validator, false once the api has been deprecated for the grace period
+func (j *reconcileJob) validate(apiID, stage string) bool {
+	if !j.cache.HasDeployedAPIs() {
+		return true
+	}
+
+	cacheKey := deployedCacheKey(apiID, stage)
+	if j.cache.IsAPIDeployed(cacheKey) {
+		return true
+	}
+	if j.gracePeriod == 0 {
+		return false
+	}
+
+	j.staleLock.Lock()
+	defer j.staleLock.Unlock()
+	deprecatedAt, isStale := j.cache.GetDeprecated(cacheKey)
+	return !isStale || j.now().Sub(deprecatedAt) < j.gracePeriod
+}
+
+// deployedCacheKey - proxies are published per environment, products without a stage
+func deployedCacheKey(apiID, stage string) string {
+	if stage == "" {
+		return createProductCacheKey(apiID)
+	}
+	return createProxyCacheKey(apiID, stage)
+}
@@ -0,0 +1,159 @@
+package apigee
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/api/v1"
+	management "github.com/Axway/agent-sdk/pkg/apic/apiserver/models/management/v1alpha1"
+	defs "github.com/Axway/agent-sdk/pkg/apic/definitions"
+	"github.com/Axway/agent-sdk/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockInstanceCache struct {
+	instances map[string]*v1.ResourceInstance
+}
+
+func (m *mockInstanceCache) add(name, apiID, stage string) {
+	instance := management.NewAPIServiceInstance(name, "env")
+	instance.Metadata.ID = name
+	instance.Lifecycle = &management.ApiServiceInstanceLifecycle{Stage: "production"}
+	util.SetAgentDetails(instance, map[string]interface{}{
+		defs.AttrExternalAPIID:    apiID,
+		defs.AttrExternalAPIStage: stage,
+	})
+	ri, _ := instance.AsInstance()
+	m.instances[name] = ri
+}
+
+func (m *mockInstanceCache) GetAPIServiceInstanceKeys() []string {
+	keys := []string{}
+	for key := range m.instances {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (m *mockInstanceCache) GetAPIServiceInstanceByID(id string) (*v1.ResourceInstance, error) {
+	if instance, ok := m.instances[id]; ok {
+		return instance, nil
+	}
+	return nil, fmt.Errorf("instance %s not found", id)
+}
+
+// mockSubResourceClient - saves the release state set on each instance
+type mockSubResourceClient struct {
+	releaseStates map[string]management.ApiServiceInstanceLifecycle
+}
+
+func (m *mockSubResourceClient) CreateSubResource(rm v1.ResourceMeta, subs map[string]interface{}) error {
+	m.releaseStates[rm.Name] = subs[management.ApiServiceInstanceLifecycleSubResourceName].(management.ApiServiceInstanceLifecycle)
+	return nil
+}
+
+func Test_reconcileJob(t *testing.T) {
+	cache := newAgentCache()
+	instances := &mockInstanceCache{instances: map[string]*v1.ResourceInstance{}}
+	instances.add("petstore-prod", "petstore", "prod")
+	instances.add("orders-prod", "orders", "prod")
+	client := &mockSubResourceClient{releaseStates: map[string]management.ApiServiceInstanceLifecycle{}}
+
+	now := time.Now()
+	job := newReconcileJob(cache, instances, client, time.Hour)
+	job.now = func() time.Time { return now }
+
+	// nothing is validated until the first poll completes
+	assert.False(t, job.Ready())
+	assert.True(t, job.validate("orders", "prod"))
+
+	inventory := newDeploymentInventory()
+	inventory.addDeployed(createProxyCacheKey("petstore", "prod"), "petstore")
+	cache.UpdateDeployedAPIs(inventory)
+	assert.True(t, job.Ready())
+
+	// orders was undeployed, it is kept until it has been deprecated
+	assert.True(t, job.validate("orders", "prod"))
+	assert.Nil(t, job.Execute())
+	assert.Len(t, client.releaseStates, 1)
+	lifecycle := client.releaseStates["orders-prod"]
+	assert.Equal(t, "production", lifecycle.Stage)
+	assert.Equal(t, deprecatedReleaseState, lifecycle.ReleaseState.Name)
+	assert.Contains(t, lifecycle.ReleaseState.Message, now.Add(time.Hour).Format(time.RFC3339))
+	assert.True(t, job.validate("petstore", "prod"))
+	assert.True(t, job.validate("orders", "prod"))
+
+	// the next run does not deprecate it again
+	delete(client.releaseStates, "orders-prod")
+	assert.Nil(t, job.Execute())
+	assert.Empty(t, client.releaseStates)
+
+	// orders is deployed again within the grace period
+	inventory.addDeployed(createProxyCacheKey("orders", "prod"), "orders")
+	cache.UpdateDeployedAPIs(inventory)
+	assert.Nil(t, job.Execute())
+	assert.Equal(t, stableReleaseState, client.releaseStates["orders-prod"].ReleaseState.Name)
+	assert.Empty(t, cache.getDeprecatedAPIs())
+
+	// orders is undeployed and the grace period ends
+	cache.UpdateDeployedAPIs(newDeploymentInventory())
+	assert.Nil(t, job.Execute())
+	now = now.Add(time.Hour)
+	assert.False(t, job.validate("orders", "prod"))
+
+	// the removed instances are forgotten
+	instances.instances = map[string]*v1.ResourceInstance{}
+	assert.Nil(t, job.Execute())
+	assert.Empty(t, cache.getDeprecatedAPIs())
+}
+
+func Test_reconcileJobRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent-apigee.json")
+	cache := newAgentCache()
+	instances := &mockInstanceCache{instances: map[string]*v1.ResourceInstance{}}
+	instances.add("orders-prod", "orders", "prod")
+	client := &mockSubResourceClient{releaseStates: map[string]management.ApiServiceInstanceLifecycle{}}
+
+	now := time.Now()
+	job := newReconcileJob(cache, instances, client, time.Hour)
+	job.now = func() time.Time { return now }
+	cache.UpdateDeployedAPIs(newDeploymentInventory())
+	assert.Nil(t, job.Execute())
+	assert.Equal(t, deprecatedReleaseState, client.releaseStates["orders-prod"].ReleaseState.Name)
+	assert.Nil(t, cache.Save(path))
+
+	// the agent restarted, the grace period carries on from the time the instance was deprecated
+	restored := newAgentCache()
+	assert.Nil(t, restored.Load(path))
+	restored.UpdateDeployedAPIs(newDeploymentInventory())
+	delete(client.releaseStates, "orders-prod")
+	job = newReconcileJob(restored, instances, client, time.Hour)
+	now = now.Add(30 * time.Minute)
+	job.now = func() time.Time { return now }
+	assert.Nil(t, job.Execute())
+	assert.Empty(t, client.releaseStates)
+	assert.True(t, job.validate("orders", "prod"))
+
+	now = now.Add(30 * time.Minute)
+	assert.False(t, job.validate("orders", "prod"))
+}
+
+func Test_reconcileJobNoGracePeriod(t *testing.T) {
+	cache := newAgentCache()
+	instances := &mockInstanceCache{instances: map[string]*v1.ResourceInstance{}}
+	instances.add("product", "product", "")
+	client := &mockSubResourceClient{releaseStates: map[string]management.ApiServiceInstanceLifecycle{}}
+	job := newReconcileJob(cache, instances, client, 0)
+
+	inventory := newDeploymentInventory()
+	inventory.addDeployed(createProductCacheKey("other"), "other")
+	cache.UpdateDeployedAPIs(inventory)
+
+	// the deleted product is removed right away without being deprecated
+	assert.Nil(t, job.Execute())
+	assert.Empty(t, client.releaseStates)
+	assert.False(t, job.validate("product", ""))
+	assert.True(t, job.validate("other", ""))
+}
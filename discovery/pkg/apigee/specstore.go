@@ -0,0 +1,328 @@
+package apigee
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/util/log"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee"
+)
+
+// specContentEntry - the version of a spec the store holds the content of
+type specContentEntry struct {
+	Key        string                `json:"key"`
+	Version    string                `json:"version,omitempty"`
+	Validators apigee.SpecValidators `json:"validators"`
+	Hash       string                `json:"hash"`
+}
+
+// specContentStore - holds the content of the specs, so a spec is downloaded once per change rather than by every
+// job that uses it. Spec store specs are keyed by their id and modified time, specs at a URL are revalidated with the
+// validators of the last download. The contents are kept by their hash, when dir is set they are also written to it
+// and read back after a restart. The specs no longer in the spec store, and the urls no deployed revision uses, are
+// dropped after each poll
+type specContentStore struct {
+	dir      string
+	logger   log.FieldLogger
+	lock     sync.Mutex
+	entries  map[string]specContentEntry
+	contents map[string][]byte
+	keyLocks map[string]*sync.Mutex
+	// dirLock - held while the files are written or pruned, a pruned directory keeps the content of every entry
+	dirLock sync.Mutex
+}
+
+func newSpecContentStore(dir string) *specContentStore {
+	return &specContentStore{
+		dir:      dir,
+		logger:   log.NewFieldLogger().WithComponent("specContentStore").WithPackage("apigee"),
+		entries:  map[string]specContentEntry{},
+		contents: map[string][]byte{},
+		keyLocks: map[string]*sync.Mutex{},
+	}
+}
+
+// specVersion - the version of a spec store spec in the content store
+func specVersion(modDate time.Time) string {
+	return strconv.FormatInt(modDate.UnixMilli(), 10)
+}
+
+// get - returns the content of the spec version, fetch is only called when the store does not have that version
+func (s *specContentStore) get(ctx context.Context, key, version string, fetch func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	unlock := s.lockKey(key)
+	defer unlock()
+
+	if entry, ok := s.entry(key); ok && entry.Version == version {
+		if content, ok := s.content(entry.Hash); ok {
+			return content, nil
+		}
+	}
+
+	content, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.put(specContentEntry{Key: key, Version: version}, content)
+	return content, nil
+}
+
+// getURL - returns the content of the spec at the url, download is sent the validators of the content the store has
+// and may tell that it has not been modified
+func (s *specContentStore) getURL(ctx context.Context, url string, download func(ctx context.Context, validators apigee.SpecValidators) (*apigee.SpecDownload, error)) ([]byte, error) {
+	unlock := s.lockKey(url)
+	defer unlock()
+
+	var content []byte
+	entry, ok := s.entry(url)
+	if ok {
+		content, ok = s.content(entry.Hash)
+	}
+	if !ok {
+		// without the content there is nothing to revalidate
+		entry = specContentEntry{}
+	}
+
+	result, err := download(ctx, entry.Validators)
+	if err != nil {
+		return nil, err
+	}
+	if result.NotModified && ok {
+		s.logger.WithField("url", url).Trace("spec has not been modified")
+		return content, nil
+	}
+	s.put(specContentEntry{Key: url, Validators: result.Validators}, result.Content)
+	return result.Content, nil
+}
+
+// lockKey - one caller at a time fetches a spec, the others wait and use what it stored
+func (s *specContentStore) lockKey(key string) func() {
+	s.lock.Lock()
+	keyLock, ok := s.keyLocks[key]
+	if !ok {
+		keyLock = &sync.Mutex{}
+		s.keyLocks[key] = keyLock
+	}
+	s.lock.Unlock()
+
+	keyLock.Lock()
+	return keyLock.Unlock
+}
+
+func (s *specContentStore) entry(key string) (specContentEntry, bool) {
+	s.lock.Lock()
+	entry, ok := s.entries[key]
+	s.lock.Unlock()
+	if ok || s.dir == "" {
+		return entry, ok
+	}
+
+	data, err := os.ReadFile(s.entryPath(key))
+	if err != nil {
+		return entry, false
+	}
+	if err = json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return specContentEntry{}, false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.entries[key] = entry
+	return entry, true
+}
+
+func (s *specContentStore) content(hash string) ([]byte, bool) {
+	s.lock.Lock()
+	content, ok := s.contents[hash]
+	s.lock.Unlock()
+	if ok || s.dir == "" {
+		return content, ok
+	}
+
+	content, err := os.ReadFile(s.contentPath(hash))
+	if err != nil || contentHash(content) != hash {
+		return nil, false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.contents[hash] = content
+	return content, true
+}
+
+// put - stores the content of the entry, the content of the version it replaces is dropped when no other spec has it
+func (s *specContentStore) put(entry specContentEntry, content []byte) {
+	entry.Hash = contentHash(content)
+
+	s.lock.Lock()
+	previous, replaced := s.entries[entry.Key]
+	s.entries[entry.Key] = entry
+	s.contents[entry.Hash] = content
+	unused := replaced && previous.Hash != entry.Hash && !s.hasContent(previous.Hash)
+	if unused {
+		delete(s.contents, previous.Hash)
+	}
+	s.lock.Unlock()
+
+	if s.dir == "" {
+		return
+	}
+	s.dirLock.Lock()
+	defer s.dirLock.Unlock()
+	logger := s.logger.WithField("key", entry.Key)
+	if err := s.write(entry, content); err != nil {
+		logger.WithError(err).Warn("could not write the spec content to the cache directory")
+		return
+	}
+	if unused {
+		os.Remove(s.contentPath(previous.Hash))
+	}
+}
+
+// retainSpecs - drops the spec store specs that are not in the ids, a complete spec poll lists the ids of all specs
+func (s *specContentStore) retainSpecs(ids map[string]struct{}) {
+	s.prune(func(entry specContentEntry) bool {
+		_, found := ids[entry.Key]
+		return entry.Version == "" || found
+	})
+}
+
+// retainURLs - drops the specs at the urls that are not in urls, the ones the deployed proxy revisions use
+func (s *specContentStore) retainURLs(urls map[string]struct{}) {
+	s.prune(func(entry specContentEntry) bool {
+		_, found := urls[entry.Key]
+		return entry.Version != "" || found
+	})
+}
+
+// prune - drops the entries that are not kept, along with the contents no other entry has
+func (s *specContentStore) prune(keep func(entry specContentEntry) bool) {
+	s.lock.Lock()
+	for key, entry := range s.entries {
+		if !keep(entry) {
+			delete(s.entries, key)
+			delete(s.keyLocks, key)
+		}
+	}
+	for hash := range s.contents {
+		if !s.hasContent(hash) {
+			delete(s.contents, hash)
+		}
+	}
+	s.lock.Unlock()
+
+	if s.dir != "" {
+		s.pruneDir(keep)
+	}
+}
+
+// pruneDir - removes the entry files that are not kept, and the content files no entry file refers to. The entries
+// not read since the restart are only on disk, so the files are read rather than the entries in memory
+func (s *specContentStore) pruneDir(keep func(entry specContentEntry) bool) {
+	s.dirLock.Lock()
+	defer s.dirLock.Unlock()
+
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.WithError(err).Warn("could not read the spec cache directory")
+		}
+		return
+	}
+
+	used := map[string]struct{}{}
+	contents := []string{}
+	for _, file := range files {
+		name, ext, _ := strings.Cut(file.Name(), ".")
+		if !isContentHash(name) {
+			// not a file of the store
+			continue
+		}
+		switch ext {
+		case "json":
+			entry := specContentEntry{}
+			data, err := os.ReadFile(filepath.Join(s.dir, file.Name()))
+			if err == nil && json.Unmarshal(data, &entry) == nil && keep(entry) {
+				used[entry.Hash] = struct{}{}
+				continue
+			}
+			os.Remove(filepath.Join(s.dir, file.Name()))
+		case "spec":
+			contents = append(contents, name)
+		}
+	}
+	for _, hash := range contents {
+		if _, found := used[hash]; !found {
+			os.Remove(s.contentPath(hash))
+		}
+	}
+}
+
+// hasContent - true when a spec in the store has the content, the store lock is held
+func (s *specContentStore) hasContent(hash string) bool {
+	for _, entry := range s.entries {
+		if entry.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// write - writes the content before the entry that refers to it, so an entry on disk always has its content
+func (s *specContentStore) write(entry specContentEntry, content []byte) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(s.dir, 0o750); err != nil {
+		return err
+	}
+	if err = writeFileAtomic(s.contentPath(entry.Hash), content); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.entryPath(entry.Key), data)
+}
+
+func (s *specContentStore) entryPath(key string) string {
+	return filepath.Join(s.dir, contentHash([]byte(key))+".json")
+}
+
+func (s *specContentStore) contentPath(hash string) string {
+	return filepath.Join(s.dir, hash+".spec")
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// isContentHash - returns true when the name is a hash the store names its files with
+func isContentHash(name string) bool {
+	hash, err := hex.DecodeString(name)
+	return err == nil && len(hash) == sha256.Size
+}
+
+// specPathCache - finds the spec store spec with a content path
+type specPathCache interface {
+	GetSpecWithPath(path string) (*specCacheItem, error)
+}
+
+// getSpecFile - returns the content of the spec store spec at the path, a spec the agent has not cached is fetched
+// every time as its version is not known
+func (s *specContentStore) getSpecFile(ctx context.Context, cache specPathCache, specPath string, fetch func(ctx context.Context, specPath string) ([]byte, error)) ([]byte, error) {
+	spec, err := cache.GetSpecWithPath(specPath)
+	if err != nil || spec == nil || spec.ID == "" {
+		return fetch(ctx, specPath)
+	}
+	return s.get(ctx, spec.ID, specVersion(spec.ModDate), func(ctx context.Context) ([]byte, error) {
+		return fetch(ctx, specPath)
+	})
+}
@@ -0,0 +1,196 @@
+package apigee
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Axway/agents-apigee/client/pkg/apigee"
+)
+
+func Test_specContentStoreGet(t *testing.T) {
+	store := newSpecContentStore("")
+	fetches := atomic.Int32{}
+	fetch := func(content string) func(ctx context.Context) ([]byte, error) {
+		return func(ctx context.Context) ([]byte, error) {
+			fetches.Add(1)
+			return []byte(content), nil
+		}
+	}
+
+	// the workers asking for the same version wait for the one fetching it
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := store.get(context.Background(), "id1", "1", fetch("v1"))
+			assert.Nil(t, err)
+			assert.Equal(t, "v1", string(content))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// a new version is fetched and replaces the content of the previous one
+	content, err := store.get(context.Background(), "id1", "2", fetch("v2"))
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", string(content))
+	assert.Equal(t, int32(2), fetches.Load())
+	assert.Len(t, store.contents, 1)
+
+	// a failed fetch is not stored
+	_, err = store.get(context.Background(), "id2", "1", func(ctx context.Context) ([]byte, error) {
+		return nil, fmt.Errorf("error")
+	})
+	assert.NotNil(t, err)
+	_, found := store.entries["id2"]
+	assert.False(t, found)
+}
+
+func Test_specContentStoreGetURL(t *testing.T) {
+	store := newSpecContentStore("")
+	validators := apigee.SpecValidators{ETag: `"v1"`}
+	sent := []apigee.SpecValidators{}
+	download := func(ctx context.Context, v apigee.SpecValidators) (*apigee.SpecDownload, error) {
+		sent = append(sent, v)
+		if v == validators {
+			return &apigee.SpecDownload{NotModified: true, Validators: v}, nil
+		}
+		return &apigee.SpecDownload{Content: []byte("v1"), Validators: validators}, nil
+	}
+
+	content, err := store.getURL(context.Background(), "https://specs/petstore.json", download)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(content))
+
+	content, err = store.getURL(context.Background(), "https://specs/petstore.json", download)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(content))
+
+	// the first download has no validators, the second is sent the validators of the first
+	assert.Equal(t, []apigee.SpecValidators{{}, validators}, sent)
+}
+
+func Test_specContentStoreDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "specs")
+	store := newSpecContentStore(dir)
+	_, err := store.get(context.Background(), "id1", "1", func(ctx context.Context) ([]byte, error) {
+		return []byte("v1"), nil
+	})
+	assert.Nil(t, err)
+
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+
+	// a store created after a restart reads the content from the directory
+	restarted := newSpecContentStore(dir)
+	content, err := restarted.get(context.Background(), "id1", "1", func(ctx context.Context) ([]byte, error) {
+		return nil, fmt.Errorf("spec fetched again")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(content))
+
+	// the content of a replaced version is removed
+	_, err = restarted.get(context.Background(), "id1", "2", func(ctx context.Context) ([]byte, error) {
+		return []byte("v2"), nil
+	})
+	assert.Nil(t, err)
+	files, err = os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+
+	// content that does not match its hash is fetched again
+	assert.Nil(t, os.WriteFile(restarted.contentPath(contentHash([]byte("v2"))), []byte("corrupt"), 0o600))
+	content, err = newSpecContentStore(dir).get(context.Background(), "id1", "2", func(ctx context.Context) ([]byte, error) {
+		return []byte("v2"), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", string(content))
+}
+
+func Test_specContentStorePrune(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "specs")
+	store := newSpecContentStore(dir)
+	put := func(key, content string) {
+		fetch := func(ctx context.Context) ([]byte, error) { return []byte(content), nil }
+		download := func(ctx context.Context, v apigee.SpecValidators) (*apigee.SpecDownload, error) {
+			return &apigee.SpecDownload{Content: []byte(content)}, nil
+		}
+		var err error
+		if isFullURL(key) {
+			_, err = store.getURL(context.Background(), key, download)
+		} else {
+			_, err = store.get(context.Background(), key, "1", fetch)
+		}
+		assert.Nil(t, err)
+	}
+	files := func() int {
+		files, err := os.ReadDir(dir)
+		assert.Nil(t, err)
+		return len(files)
+	}
+	put("id1", "a")
+	put("id2", "b")
+	put("https://specs/orders.json", "c")
+	put("https://specs/sales.json", "a")
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("kept"), 0o600))
+	assert.Equal(t, 8, files())
+
+	// the spec removed from the spec store is dropped, the urls are kept
+	store.retainSpecs(map[string]struct{}{"id1": {}})
+	assert.Len(t, store.entries, 3)
+	assert.Len(t, store.contents, 2)
+	assert.NotContains(t, store.keyLocks, "id2")
+	assert.Equal(t, 6, files())
+
+	// the content of the url no longer used is kept for the spec that has it
+	store.retainURLs(map[string]struct{}{"https://specs/orders.json": {}})
+	assert.Len(t, store.entries, 2)
+	assert.Len(t, store.contents, 2)
+	assert.Equal(t, 5, files())
+
+	// the entries only on disk after a restart are pruned too
+	newSpecContentStore(dir).retainSpecs(map[string]struct{}{})
+	assert.Equal(t, 3, files())
+	content, err := newSpecContentStore(dir).getURL(context.Background(), "https://specs/orders.json", func(ctx context.Context, v apigee.SpecValidators) (*apigee.SpecDownload, error) {
+		return &apigee.SpecDownload{NotModified: true}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "c", string(content))
+}
+
+func Test_specContentStoreGetSpecFile(t *testing.T) {
+	modDate := time.Now().Add(-1 * time.Hour)
+	cache := newAgentCache()
+	cache.AddSpecToCache("id1", "/path/id1", "Name-ID1", "", modDate)
+
+	store := newSpecContentStore("")
+	fetches := 0
+	fetch := func(ctx context.Context, specPath string) ([]byte, error) {
+		fetches++
+		return []byte(specPath), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		content, err := store.getSpecFile(context.Background(), cache, "/path/id1", fetch)
+		assert.Nil(t, err)
+		assert.Equal(t, "/path/id1", string(content))
+	}
+	assert.Equal(t, 1, fetches)
+
+	// the version of a spec that is not cached is not known
+	for i := 0; i < 2; i++ {
+		_, err := store.getSpecFile(context.Background(), cache, "/path/id2", fetch)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 3, fetches)
+}
@@ -0,0 +1,79 @@
+package apigee
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Axway/agent-sdk/pkg/apic"
+)
+
+// specResourceTypes - the revision resource file types that hold a spec, resourcefiles/wsdl/orders.wsdl
+var specResourceTypes = []string{"wsdl", "graphql"}
+
+// specResourcePrefix - starts the spec path of a spec in a revision resource file, like the spec_local tag of products
+const specResourcePrefix = "resourcefiles"
+
+// specTypeExtensions - the spec types told by the file extension, json and yaml files hold several types
+var specTypeExtensions = map[string]string{
+	".wsdl":     apic.Wsdl,
+	".graphql":  apic.GraphQL,
+	".graphqls": apic.GraphQL,
+	".gql":      apic.GraphQL,
+	".proto":    apic.Protobuf,
+}
+
+var (
+	protobufSyntax    = regexp.MustCompile(`^(syntax|edition)\s*=\s*"`)
+	graphQLDefinition = regexp.MustCompile(`^(schema|type|extend|interface|enum|input|union|scalar|directive)\b[^:]*(\{|@|$)|^"""|^"[^"]*"$`)
+)
+
+// specType - the central spec type of the spec, told by the extension of its name or its content. Empty for json
+// and yaml specs, the sdk tells the OpenAPI, AsyncAPI and RAML specs apart when parsing them
+func specType(name string, content []byte) string {
+	if t, found := specTypeExtensions[strings.ToLower(path.Ext(name))]; found {
+		return t
+	}
+
+	line := firstSpecLine(content)
+	switch {
+	case strings.HasPrefix(line, "<"):
+		// wsdl is the only xml spec type
+		return apic.Wsdl
+	case protobufSyntax.MatchString(line):
+		return apic.Protobuf
+	case graphQLDefinition.MatchString(line):
+		return apic.GraphQL
+	}
+	return ""
+}
+
+// firstSpecLine - the first line of the spec that is not blank or a comment, a graphql comment starts with # and a
+// protobuf comment with //
+func firstSpecLine(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		return line
+	}
+	return ""
+}
+
+// specResourcePath - the spec path of a revision resource file that holds a spec
+func specResourcePath(resourceType, resourceName string) string {
+	return path.Join(specResourcePrefix, resourceType, resourceName)
+}
+
+// parseSpecResourcePath - the type and name of the revision resource file the spec path points at
+func parseSpecResourcePath(specPath string) (string, string, bool) {
+	parts := strings.SplitN(specPath, "/", 3)
+	if len(parts) != 3 || parts[0] != specResourcePrefix {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
@@ -0,0 +1,142 @@
+package apigee
+
+import (
+	"testing"
+
+	"github.com/Axway/agent-sdk/pkg/apic"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testWSDL = `<?xml version="1.0" encoding="UTF-8"?>
+<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/" name="Orders">
+  <service name="OrdersService">
+    <port name="OrdersPort" binding="tns:OrdersBinding">
+      <soap:address location="https://api.example.com:8443/orders"/>
+    </port>
+  </service>
+</definitions>`
+	testGraphQL = `# the orders schema
+type Query {
+  order(id: ID!): Order
+}
+
+type Order {
+  id: ID!
+}`
+	testProtobuf = `// the orders service
+syntax = "proto3";
+
+service Orders {
+  rpc GetOrder (OrderRequest) returns (Order);
+}`
+	testAsyncAPI = `asyncapi: 2.6.0
+info:
+  title: Orders
+  version: 1.0.0
+channels: {}`
+)
+
+func Test_specType(t *testing.T) {
+	tests := []struct {
+		name     string
+		specName string
+		content  string
+		expected string
+	}{
+		{
+			name:     "should tell a wsdl from its extension",
+			specName: "/specs/orders.WSDL",
+			content:  "",
+			expected: apic.Wsdl,
+		},
+		{
+			name:     "should tell a graphql schema from its extension",
+			specName: "resourcefiles/graphql/orders.graphqls",
+			expected: apic.GraphQL,
+		},
+		{
+			name:     "should tell a protobuf from its extension",
+			specName: "orders.proto",
+			expected: apic.Protobuf,
+		},
+		{
+			name:     "should tell a wsdl from its content",
+			specName: "/organizations/org/specs/doc/1/content",
+			content:  testWSDL,
+			expected: apic.Wsdl,
+		},
+		{
+			name:     "should tell a graphql schema from its content",
+			specName: "https://git.example.com/orders/schema",
+			content:  testGraphQL,
+			expected: apic.GraphQL,
+		},
+		{
+			name:     "should tell a graphql schema with a description from its content",
+			content:  "\"\"\"\nThe orders\n\"\"\"\nschema {\n  query: Query\n}",
+			expected: apic.GraphQL,
+		},
+		{
+			name:     "should tell a protobuf from its content",
+			content:  testProtobuf,
+			expected: apic.Protobuf,
+		},
+		{
+			name:     "should leave a json spec to the sdk",
+			specName: "petstore.json",
+			content:  testSpec,
+		},
+		{
+			name:    "should leave a yaml spec to the sdk",
+			content: "type: object\nopenapi: 3.0.1",
+		},
+		{
+			name:    "should leave an asyncapi spec to the sdk",
+			content: testAsyncAPI,
+		},
+		{
+			name: "should leave an empty spec to the sdk",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, specType(tc.specName, []byte(tc.content)))
+		})
+	}
+}
+
+func Test_specTypeParsed(t *testing.T) {
+	// the sdk parses each spec as the type it is told
+	for content, expected := range map[string]string{
+		testWSDL:     apic.Wsdl,
+		testGraphQL:  apic.GraphQL,
+		testProtobuf: apic.Protobuf,
+		testAsyncAPI: apic.AsyncAPI,
+		testSpec:     apic.Oas3,
+	} {
+		parser := apic.NewSpecResourceParser([]byte(content), specType("", []byte(content)))
+		assert.Nil(t, parser.Parse())
+		assert.Equal(t, expected, parser.GetSpecProcessor().GetResourceType())
+	}
+
+	// the wsdl endpoints are matched to the proxies
+	parser := apic.NewSpecResourceParser([]byte(testWSDL), apic.Wsdl)
+	assert.Nil(t, parser.Parse())
+	endpoints, err := parser.GetSpecProcessor().GetEndpoints()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://api.example.com:8443/orders"}, []string{endpointToString(endpoints[0])})
+}
+
+func Test_specResourcePath(t *testing.T) {
+	specPath := specResourcePath("wsdl", "orders.wsdl")
+	assert.Equal(t, "resourcefiles/wsdl/orders.wsdl", specPath)
+
+	resourceType, resourceName, ok := parseSpecResourcePath(specPath)
+	assert.True(t, ok)
+	assert.Equal(t, "wsdl", resourceType)
+	assert.Equal(t, "orders.wsdl", resourceName)
+
+	_, _, ok = parseSpecResourcePath("/organizations/org/specs/doc/1/content")
+	assert.False(t, ok)
+}
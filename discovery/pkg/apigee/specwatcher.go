@@ -0,0 +1,270 @@
+package apigee
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/util/log"
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/Axway/agents-apigee/client/pkg/config"
+)
+
+// specWatchDelay - the time the notifications of the spec files are gathered for before they are reported, an
+// editor writes a file in several steps
+const specWatchDelay = time.Second
+
+// specFilesChanged - called with the paths of the spec files added, changed or removed, relative to the local
+// directory and separated by slashes
+type specFilesChanged func(files []string)
+
+// specWatcher - notices the spec files added, changed or removed in the local spec directory
+type specWatcher interface {
+	// watch - reports the changed spec files until the context is done
+	watch(ctx context.Context, changed specFilesChanged)
+}
+
+// newSpecWatcher - watches the directory with file system notifications, it is polled when they are not available
+// or the poll mode is configured
+func newSpecWatcher(dir, mode string, interval time.Duration) specWatcher {
+	logger := log.NewFieldLogger().WithComponent("specWatcher").WithPackage("apigee").WithField("specLocalDir", dir)
+	if mode != config.SpecWatchPoll {
+		w, err := newNotifySpecWatcher(dir, logger)
+		if err == nil {
+			return w
+		}
+		logger.WithError(err).Warn("file system notifications are not available, polling the local spec directory")
+	}
+	return newPollSpecWatcher(dir, interval, logger)
+}
+
+// relativeSpecPath - the path of the spec file relative to the local directory, separated by slashes
+func relativeSpecPath(dir, filePath string) (string, bool) {
+	rel, err := filepath.Rel(dir, filePath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// notifySpecWatcher - watches the local directory, and the directories in it, with inotify
+type notifySpecWatcher struct {
+	dir     string
+	delay   time.Duration
+	watcher *fsnotify.Watcher
+	// files - the files known in the watched directories, reported when their directory is removed or renamed
+	files  map[string]struct{}
+	logger log.FieldLogger
+}
+
+func newNotifySpecWatcher(dir string, logger log.FieldLogger) (*notifySpecWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &notifySpecWatcher{
+		dir:     dir,
+		delay:   specWatchDelay,
+		watcher: watcher,
+		files:   map[string]struct{}{},
+		logger:  logger,
+	}
+	if err := w.addDirs(dir, func(string) {}); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// addDirs - watches the directory and the directories in it, the notifications are not recursive. Found is called
+// with the files in them
+func (w *notifySpecWatcher) addDirs(dir string, found func(string)) error {
+	return filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return w.watcher.Add(filePath)
+		}
+		w.files[filePath] = struct{}{}
+		found(filePath)
+		return nil
+	})
+}
+
+func (w *notifySpecWatcher) watch(ctx context.Context, changed specFilesChanged) {
+	defer w.watcher.Close()
+
+	pending := map[string]struct{}{}
+	addPending := func(filePath string) {
+		if name, ok := relativeSpecPath(w.dir, filePath); ok {
+			pending[name] = struct{}{}
+		}
+	}
+
+	timer := time.NewTimer(w.delay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				// the files of a directory moved into the local directory are added along with it
+				if event.Op&fsnotify.Create != 0 {
+					if err := w.addDirs(event.Name, addPending); err != nil {
+						w.logger.WithError(err).WithField("directory", event.Name).Warn("could not watch the directory")
+					}
+					timer.Reset(w.delay)
+				}
+				continue
+			}
+			if _, err := os.Stat(event.Name); err == nil {
+				w.files[event.Name] = struct{}{}
+			} else {
+				delete(w.files, event.Name)
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					// a removed or renamed directory only notifies its own name, the files in it are gone too
+					w.removeDir(event.Name, addPending)
+				}
+			}
+			addPending(event.Name)
+			timer.Reset(w.delay)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.WithError(err).Warn("watching the local spec directory")
+		case <-timer.C:
+			if len(pending) == 0 {
+				continue
+			}
+			files := make([]string, 0, len(pending))
+			for name := range pending {
+				files = append(files, name)
+			}
+			slices.Sort(files)
+			pending = map[string]struct{}{}
+			changed(files)
+		}
+	}
+}
+
+// removeDir - forgets the known files in the directory, removed is called with each of them
+func (w *notifySpecWatcher) removeDir(dir string, removed func(string)) {
+	prefix := dir + string(filepath.Separator)
+	for filePath := range w.files {
+		if strings.HasPrefix(filePath, prefix) {
+			delete(w.files, filePath)
+			removed(filePath)
+		}
+	}
+}
+
+// specFileState - the modification time and size of a spec file, a changed file has a different one
+type specFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// pollSpecWatcher - scans the local directory, and the directories in it, on each interval
+type pollSpecWatcher struct {
+	dir      string
+	interval time.Duration
+	files    map[string]specFileState
+	logger   log.FieldLogger
+}
+
+func newPollSpecWatcher(dir string, interval time.Duration, logger log.FieldLogger) *pollSpecWatcher {
+	w := &pollSpecWatcher{
+		dir:      dir,
+		interval: interval,
+		files:    map[string]specFileState{},
+		logger:   logger,
+	}
+
+	// the files already in the directory are published by the poll jobs
+	files, err := w.scan()
+	if err != nil {
+		logger.WithError(err).Warn("could not scan the local spec directory")
+	}
+	w.files = files
+	return w
+}
+
+func (w *pollSpecWatcher) watch(ctx context.Context, changed specFilesChanged) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if files := w.poll(); len(files) > 0 {
+				changed(files)
+			}
+		}
+	}
+}
+
+// poll - the files added, changed or removed since the last scan, a scan that fails keeps the files of the last one
+func (w *pollSpecWatcher) poll() []string {
+	files, err := w.scan()
+	if err != nil {
+		w.logger.WithError(err).Warn("could not scan the local spec directory")
+		return nil
+	}
+
+	changed := []string{}
+	for name, state := range files {
+		if last, found := w.files[name]; !found || !last.modTime.Equal(state.modTime) || last.size != state.size {
+			changed = append(changed, name)
+		}
+	}
+	for name := range w.files {
+		if _, found := files[name]; !found {
+			changed = append(changed, name)
+		}
+	}
+	w.files = files
+	slices.Sort(changed)
+	return changed
+}
+
+func (w *pollSpecWatcher) scan() (map[string]specFileState, error) {
+	files := map[string]specFileState{}
+	err := filepath.WalkDir(w.dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// removed while scanning
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if name, ok := relativeSpecPath(w.dir, filePath); ok {
+			files[name] = specFileState{modTime: info.ModTime(), size: info.Size()}
+		}
+		return nil
+	})
+	return files, err
+}
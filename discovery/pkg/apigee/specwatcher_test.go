@@ -0,0 +1,104 @@
+package apigee
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/util/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_pollSpecWatcher(t *testing.T) {
+	dir := t.TempDir()
+	writeSpec := func(name, content string, modTime time.Time) {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filePath), 0700))
+		assert.Nil(t, os.WriteFile(filePath, []byte(content), 0600))
+		assert.Nil(t, os.Chtimes(filePath, modTime, modTime))
+	}
+	start := time.Now().Add(-time.Hour)
+	writeSpec("orders.json", testSpec, start)
+	writeSpec("sales/rte.json", testSpec, start)
+
+	// the files already in the directory are not reported
+	w := newPollSpecWatcher(dir, time.Minute, log.NewFieldLogger())
+	assert.Empty(t, w.poll())
+
+	// a file touched without changes is reported, the content is compared when it is published
+	writeSpec("orders.json", testSpec, start.Add(time.Minute))
+	writeSpec("sales/rte.yaml", testAsyncAPI, start)
+	assert.Nil(t, os.Remove(filepath.Join(dir, "sales", "rte.json")))
+	assert.Equal(t, []string{"orders.json", "sales/rte.json", "sales/rte.yaml"}, w.poll())
+	assert.Empty(t, w.poll())
+
+	// a directory that can not be scanned keeps the files of the last scan
+	assert.Nil(t, os.RemoveAll(dir))
+	assert.Empty(t, w.poll())
+	assert.Len(t, w.files, 2)
+}
+
+func Test_notifySpecWatcher(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "orders.json"), []byte(testSpec), 0600))
+
+	w, err := newNotifySpecWatcher(dir, log.NewFieldLogger())
+	if err != nil {
+		t.Skipf("file system notifications are not available: %s", err)
+	}
+	w.delay = 10 * time.Millisecond
+
+	lock := sync.Mutex{}
+	reported := []string{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.watch(ctx, func(files []string) {
+		lock.Lock()
+		defer lock.Unlock()
+		reported = append(reported, files...)
+	})
+	hasReported := func(files ...string) func() bool {
+		return func() bool {
+			lock.Lock()
+			defer lock.Unlock()
+			for _, f := range files {
+				if !slices.Contains(reported, f) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "orders.json"), []byte(testSpec+"\n"), 0600))
+	assert.Eventually(t, hasReported("orders.json"), 5*time.Second, 10*time.Millisecond)
+
+	// the files in a new directory are watched
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "sales"), 0700))
+	assert.Eventually(t, func() bool {
+		return slices.Contains(w.watcher.WatchList(), filepath.Join(dir, "sales"))
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "sales", "rte.json"), []byte(testSpec), 0600))
+	assert.Eventually(t, hasReported("sales/rte.json"), 5*time.Second, 10*time.Millisecond)
+
+	lock.Lock()
+	reported = []string{}
+	lock.Unlock()
+	assert.Nil(t, os.Remove(filepath.Join(dir, "orders.json")))
+	assert.Eventually(t, hasReported("orders.json"), 5*time.Second, 10*time.Millisecond)
+
+	// the files of a renamed directory are reported under both names
+	assert.Nil(t, os.Rename(filepath.Join(dir, "sales"), filepath.Join(dir, "retail")))
+	assert.Eventually(t, hasReported("sales/rte.json", "retail/rte.json"), 5*time.Second, 10*time.Millisecond)
+
+	// the files of a removed directory are reported
+	lock.Lock()
+	reported = []string{}
+	lock.Unlock()
+	assert.Nil(t, os.RemoveAll(filepath.Join(dir, "retail")))
+	assert.Eventually(t, hasReported("retail/rte.json"), 5*time.Second, 10*time.Millisecond)
+}
@@ -49,10 +49,24 @@ func urlsFromVirtualHost(virtualHost *models.VirtualHost) []string {
 	return urls
 }
 
+// urlsFromHostnames - environment group hostnames are always served over https
+func urlsFromHostnames(hostnames []string) []string {
+	urls := []string{}
+	for _, host := range hostnames {
+		urls = append(urls, fmt.Sprintf("https://%s", host))
+	}
+	return urls
+}
+
 func createProxyCacheKey(id, envName string) string {
 	return fmt.Sprintf("apiproxy-%s-%s", envName, id)
 }
 
+// createRevisionCacheKey - the key a handled proxy revision is saved with
+func createRevisionCacheKey(id, envName, revision string) string {
+	return fmt.Sprintf("revision-%s-%s-%s", envName, id, revision)
+}
+
 func createProductCacheKey(name string) string {
 	return fmt.Sprintf("apiproduct-%s", name)
 }
@@ -112,18 +126,19 @@ func createEndpointsFromURLS(urls []string) []apic.EndpointDefinition {
 	return endpoints
 }
 
-func findSpecFile(log log.FieldLogger, specFilePath string, exes []string) ([]byte, error) {
+// findSpecFile - returns the first spec file found with one of the extensions, along with its path
+func findSpecFile(log log.FieldLogger, specFilePath string, exes []string) ([]byte, string, error) {
 	for _, e := range exes {
 		filePath := fmt.Sprintf("%s.%s", specFilePath, e)
 		data, err := loadSpecFile(log, filePath)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		if data != nil {
-			return data, nil
+			return data, filePath, nil
 		}
 	}
-	return nil, nil
+	return nil, "", nil
 }
 
 func loadSpecFile(log log.FieldLogger, filePath string) ([]byte, error) {
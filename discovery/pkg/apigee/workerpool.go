@@ -0,0 +1,217 @@
+package apigee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Axway/agent-sdk/pkg/util/log"
+)
+
+// maxReportedFailures - the number of failed items named in the error of a run, the others are only counted
+const maxReportedFailures = 5
+
+// workerPool - handles the items of a poll job on a fixed number of workers. The work an item starts, the
+// environments of a proxy, is handled on a free worker or, when there is none, on the worker of the item that
+// started it, so nested work never uses more than the pool size of workers
+type workerPool struct {
+	kind    string
+	workers chan struct{}
+	logger  log.FieldLogger
+}
+
+// newWorkerPool - creates a pool of size workers, at least 1, kind names the items in the run summary, "proxies"
+func newWorkerPool(kind string, size int) *workerPool {
+	if size < 1 {
+		size = 1
+	}
+	return &workerPool{
+		kind:    kind,
+		workers: make(chan struct{}, size),
+		logger:  log.NewFieldLogger().WithComponent("workerPool").WithPackage("apigee").WithField("items", kind),
+	}
+}
+
+// runStats - the metrics of a single poll job run
+type runStats struct {
+	Items       int
+	Succeeded   int
+	Failed      int
+	Skipped     int
+	Nested      int
+	PeakWorkers int
+	Duration    time.Duration
+}
+
+type itemFailure struct {
+	item string
+	err  error
+}
+
+// workerRun - the items handled by a single poll job run
+type workerRun struct {
+	ctx      context.Context
+	pool     *workerPool
+	wg       sync.WaitGroup
+	started  time.Time
+	lock     sync.Mutex
+	stats    runStats
+	failures []itemFailure
+}
+
+// start - starts a run, items are no longer handed to workers once the context is cancelled
+func (p *workerPool) start(ctx context.Context) *workerRun {
+	return &workerRun{
+		ctx:      ctx,
+		pool:     p,
+		started:  time.Now(),
+		failures: []itemFailure{},
+	}
+}
+
+// submit - waits for a free worker to handle the item, the item is skipped when the run is cancelled first
+func (r *workerRun) submit(item string, handle func(ctx context.Context) error) {
+	r.lock.Lock()
+	r.stats.Items++
+	r.lock.Unlock()
+
+	select {
+	case r.pool.workers <- struct{}{}:
+	case <-r.ctx.Done():
+		r.lock.Lock()
+		r.stats.Skipped++
+		r.lock.Unlock()
+		return
+	}
+	r.workerAcquired()
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer r.release()
+		err := handle(r.ctx)
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		if err != nil {
+			r.stats.Failed++
+			r.failures = append(r.failures, itemFailure{item: item, err: err})
+			return
+		}
+		r.stats.Succeeded++
+	}()
+}
+
+// wait - waits for the submitted items and returns the metrics of the run
+func (r *workerRun) wait() runStats {
+	r.wg.Wait()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stats.Duration = time.Since(r.started)
+	r.pool.logger.
+		WithField("total", r.stats.Items).
+		WithField("succeeded", r.stats.Succeeded).
+		WithField("failed", r.stats.Failed).
+		WithField("skipped", r.stats.Skipped).
+		WithField("nested", r.stats.Nested).
+		WithField("peakWorkers", r.stats.PeakWorkers).
+		WithField("duration", r.stats.Duration.String()).
+		Debug("run complete")
+	return r.stats
+}
+
+// err - names the items that failed, nil when all items were handled
+func (r *workerRun) err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.failures) == 0 {
+		return nil
+	}
+
+	reported := []string{}
+	for _, f := range r.failures[:min(len(r.failures), maxReportedFailures)] {
+		reported = append(reported, fmt.Sprintf("%s: %s", f.item, f.err))
+	}
+	summary := strings.Join(reported, "; ")
+	if more := len(r.failures) - maxReportedFailures; more > 0 {
+		summary = fmt.Sprintf("%s; and %d more", summary, more)
+	}
+	return fmt.Errorf("%d of %d %s failed: %s", r.stats.Failed, r.stats.Items, r.pool.kind, summary)
+}
+
+// result - the error of the job run. The items that failed are logged and handled again on a later run, only a broken
+// connection to apigee fails the run, the sdk stops and restarts all of the agent jobs when one fails
+func (r *workerRun) result(connected func() bool) error {
+	err := r.err()
+	if err == nil || !connected() {
+		return err
+	}
+	r.pool.logger.WithError(err).Error("run completed with failed items")
+	return nil
+}
+
+func (r *workerRun) workerAcquired() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stats.PeakWorkers = max(r.stats.PeakWorkers, len(r.pool.workers))
+}
+
+func (r *workerRun) release() {
+	<-r.pool.workers
+}
+
+// workerGroup - the nested work of an item, the errors are returned to the item
+type workerGroup struct {
+	run  *workerRun
+	wg   sync.WaitGroup
+	lock sync.Mutex
+	errs []error
+}
+
+// group - starts the nested work of an item handled in the run
+func (r *workerRun) group() *workerGroup {
+	return &workerGroup{run: r, errs: []error{}}
+}
+
+// submit - handles the work on a free worker, or right away on the calling worker when all workers are busy
+func (g *workerGroup) submit(handle func() error) {
+	g.run.lock.Lock()
+	g.run.stats.Nested++
+	g.run.lock.Unlock()
+
+	select {
+	case g.run.pool.workers <- struct{}{}:
+		g.run.workerAcquired()
+		g.wg.Add(1)
+		go func() {
+			defer g.wg.Done()
+			defer g.run.release()
+			g.addErr(handle())
+		}()
+	default:
+		// waiting for a worker could wait on the workers waiting on this one
+		g.addErr(handle())
+	}
+}
+
+func (g *workerGroup) addErr(err error) {
+	if err == nil {
+		return
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.errs = append(g.errs, err)
+}
+
+// wait - waits for the nested work, the errors are joined
+func (g *workerGroup) wait() error {
+	g.wg.Wait()
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return errors.Join(g.errs...)
+}
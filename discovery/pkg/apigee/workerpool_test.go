@@ -0,0 +1,128 @@
+package apigee
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// concurrency - tracks the number of items being handled at once
+type concurrency struct {
+	lock    sync.Mutex
+	current int
+	peak    int
+}
+
+func (c *concurrency) handle() {
+	c.lock.Lock()
+	c.current++
+	c.peak = max(c.peak, c.current)
+	c.lock.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	c.lock.Lock()
+	c.current--
+	c.lock.Unlock()
+}
+
+func Test_workerPoolNested(t *testing.T) {
+	pool := newWorkerPool("proxies", 3)
+	run := pool.start(context.Background())
+	calls := &concurrency{}
+
+	handled := atomic.Int32{}
+	for i := 0; i < 20; i++ {
+		run.submit(fmt.Sprintf("proxy%d", i), func(ctx context.Context) error {
+			calls.handle()
+			envs := run.group()
+			for e := 0; e < 4; e++ {
+				envs.submit(func() error {
+					calls.handle()
+					handled.Add(1)
+					return nil
+				})
+			}
+			return envs.wait()
+		})
+	}
+	stats := run.wait()
+
+	assert.Nil(t, run.err())
+	assert.Equal(t, int32(80), handled.Load())
+	assert.Equal(t, 20, stats.Items)
+	assert.Equal(t, 20, stats.Succeeded)
+	assert.Equal(t, 80, stats.Nested)
+	assert.LessOrEqual(t, stats.PeakWorkers, 3)
+	// the nested work shares the workers of the items
+	assert.LessOrEqual(t, calls.peak, 3)
+	assert.Len(t, pool.workers, 0)
+}
+
+func Test_workerPoolErrors(t *testing.T) {
+	pool := newWorkerPool("products", 2)
+	run := pool.start(context.Background())
+
+	for i := 0; i < 10; i++ {
+		run.submit(fmt.Sprintf("product%d", i), func(ctx context.Context) error {
+			if i < 7 {
+				return fmt.Errorf("error")
+			}
+			return nil
+		})
+	}
+	stats := run.wait()
+
+	assert.Equal(t, 7, stats.Failed)
+	assert.Equal(t, 3, stats.Succeeded)
+	err := run.err()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "7 of 10 products failed")
+	assert.Contains(t, err.Error(), "and 2 more")
+}
+
+func Test_workerPoolResult(t *testing.T) {
+	pool := newWorkerPool("specs", 2)
+	run := pool.start(context.Background())
+	run.submit("spec1", func(ctx context.Context) error {
+		return fmt.Errorf("error")
+	})
+	run.wait()
+
+	// a failed item does not fail the job, only a broken connection to apigee does
+	assert.Nil(t, run.result(func() bool { return true }))
+	err := run.result(func() bool { return false })
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "1 of 1 specs failed")
+
+	run = pool.start(context.Background())
+	run.wait()
+	assert.Nil(t, run.result(func() bool { return false }))
+}
+
+func Test_workerPoolCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := newWorkerPool("specs", 1)
+	run := pool.start(ctx)
+
+	// the only worker is busy until the run is cancelled
+	run.submit("spec1", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	go cancel()
+	run.submit("spec2", func(ctx context.Context) error {
+		return nil
+	})
+	stats := run.wait()
+
+	assert.Equal(t, 2, stats.Items)
+	assert.Equal(t, 1, stats.Succeeded)
+	assert.Equal(t, 1, stats.Skipped)
+	assert.Nil(t, run.err())
+}
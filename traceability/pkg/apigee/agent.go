@@ -1,6 +1,7 @@
 package apigee
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -42,6 +43,8 @@ type Agent struct {
 	statCache     cache.Cache
 	cacheFilePath string
 	ready         bool
+	ctx           context.Context
+	cancel        context.CancelFunc
 }
 
 // NewAgent - Creates a new Agent
@@ -51,10 +54,13 @@ func NewAgent(agentCfg *AgentConfig) (*Agent, error) {
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	thisAgent = &Agent{
 		apigeeClient: apigeeClient,
 		cfg:          agentCfg,
 		statCache:    cache.New(),
+		ctx:          ctx,
+		cancel:       cancel,
 	}
 
 	return thisAgent, nil
@@ -67,6 +73,11 @@ func (a *Agent) BeatsReady() {
 	a.ready = true
 }
 
+// Stop - signals the agent to stop, cancelling the outstanding apigee calls of the stats jobs
+func (a *Agent) Stop() {
+	a.cancel()
+}
+
 func (a *Agent) IsReady() bool {
 	return a.ready && a.apigeeClient.IsReady()
 }
@@ -82,12 +93,13 @@ func (a *Agent) registerPollStatsJob() (string, error) {
 
 	val := os.Getenv("QA_SIMULATE_APIGEE_STATS")
 	if strings.ToLower(val) == "true" {
-		products, _ := a.apigeeClient.GetProducts()
+		products, _ := a.apigeeClient.GetProductsWithContext(a.ctx)
 		client = statsmock.NewStatsMock(a.apigeeClient, products)
 	}
 
 	// create the job that runs every minute
 	baseOpts := []func(*pollApigeeStats){
+		withContext(a.ctx),
 		withStatsClient(client),
 		withIsReady(a.IsReady),
 		withStatsCache(a.statCache),
@@ -95,6 +107,7 @@ func (a *Agent) registerPollStatsJob() (string, error) {
 		withAllTraffic(a.cfg.ApigeeCfg.ShouldReportAllTraffic()),
 		withNotSetTraffic(a.cfg.ApigeeCfg.ShouldReportNotSetTraffic()),
 		withFilteredAPIs(a.cfg.ApigeeCfg.FilteredAPIs, a.cfg.ApigeeCfg.FilterMetrics),
+		withEnvironments(a.cfg.ApigeeCfg.GetEnvironments()),
 	}
 	if a.cfg.ApigeeCfg.IsProductMode() {
 		baseOpts = append(baseOpts, withProductMode())
@@ -1,13 +1,15 @@
 package definitions
 
 import (
+	"context"
 	"time"
 
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
 )
 
+// StatsClient - the apigee calls of the stats job, cancelled when the context is done
 type StatsClient interface {
-	GetEnvironments() []string
-	GetStats(env, dimension, metricSelect string, start, end time.Time) (*models.Metrics, error)
-	GetProduct(productName string) (*models.ApiProduct, error)
+	GetEnvironmentsWithContext(ctx context.Context) ([]string, error)
+	GetStatsWithContext(ctx context.Context, env, dimension, metricSelect string, start, end time.Time) (*models.Metrics, error)
+	GetProductWithContext(ctx context.Context, productName string) (*models.ApiProduct, error)
 }
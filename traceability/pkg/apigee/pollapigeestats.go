@@ -1,6 +1,7 @@
 package apigee
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -19,6 +20,7 @@ import (
 	"github.com/Axway/agent-sdk/pkg/util/log"
 	"github.com/Axway/agents-apigee/client/pkg/apigee"
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+	"github.com/Axway/agents-apigee/client/pkg/config"
 	"github.com/Axway/agents-apigee/traceability/pkg/apigee/definitions"
 	"github.com/gofrs/uuid"
 )
@@ -50,6 +52,7 @@ type metricData struct {
 
 type pollApigeeStats struct {
 	jobs.Job
+	ctx                 context.Context
 	startTime           time.Time
 	endTime             time.Time
 	envs                []string
@@ -65,7 +68,7 @@ type pollApigeeStats struct {
 	cachePath           string
 	clonedProduct       map[string]string
 	dimension           string
-	environment         string
+	environments        *config.EnvironmentSelector
 	isProduct           bool
 	logger              log.FieldLogger
 	filteredAPIs        map[string]struct{}
@@ -74,6 +77,7 @@ type pollApigeeStats struct {
 
 func newPollStatsJob(options ...func(*pollApigeeStats)) *pollApigeeStats {
 	job := &pollApigeeStats{
+		ctx:            context.Background(),
 		mutex:          &sync.Mutex{},
 		clonedProduct:  make(map[string]string),
 		dimension:      "apiproxy",
@@ -88,9 +92,16 @@ func newPollStatsJob(options ...func(*pollApigeeStats)) *pollApigeeStats {
 	return job
 }
 
-func withEnvironment(env string) func(p *pollApigeeStats) {
+// withContext - the context the apigee calls are made with, cancelling it stops the job
+func withContext(ctx context.Context) func(p *pollApigeeStats) {
 	return func(p *pollApigeeStats) {
-		p.environment = env
+		p.ctx = ctx
+	}
+}
+
+func withEnvironments(environments *config.EnvironmentSelector) func(p *pollApigeeStats) {
+	return func(p *pollApigeeStats) {
+		p.environments = environments
 	}
 }
 
@@ -179,7 +190,13 @@ func (j *pollApigeeStats) Execute() error {
 	logger := j.logger.WithField("executionID", id)
 
 	logger.Trace("starting execution")
-	j.envs = j.client.GetEnvironments()
+	envs, err := j.client.GetEnvironmentsWithContext(j.ctx)
+	if err != nil {
+		// keep the start time so the next execution reports the metrics for this window
+		logger.WithError(err).Error("could not get the environments")
+		return err
+	}
+	j.envs = envs
 
 	// when start time is 0 we are in our regular execution loop
 	j.endTime = time.Now().Add(time.Minute * -10).Truncate(time.Minute)
@@ -199,7 +216,7 @@ func (j *pollApigeeStats) Execute() error {
 		}
 	}
 	for _, e := range j.envs {
-		if !(j.environment == "" || j.environment == e) {
+		if !j.environments.IsSelected(e) {
 			continue
 		}
 
@@ -207,8 +224,9 @@ func (j *pollApigeeStats) Execute() error {
 		go func(logger log.FieldLogger, envName string) {
 			defer wg.Done()
 			logger = logger.WithField("env", envName)
-			metrics, err := j.client.GetStats(envName, j.dimension, metricSelect, j.startTime, j.endTime)
+			metrics, err := j.client.GetStatsWithContext(j.ctx, envName, j.dimension, metricSelect, j.startTime, j.endTime)
 			if err != nil {
+				logger.WithError(err).Error("could not get the stats")
 				return
 			}
 
@@ -231,7 +249,7 @@ func (j *pollApigeeStats) Execute() error {
 		j.statCache.Save(j.cachePath)
 	}
 
-	err := j.eventGenerator.AddMetricDetailsFromEventReport(j.eventReport)
+	err = j.eventGenerator.AddMetricDetailsFromEventReport(j.eventReport)
 	if err != nil {
 		j.logger.WithError(err).Error("failed to add metrics through event generator")
 		return err
@@ -376,7 +394,7 @@ func (j *pollApigeeStats) getBaseProduct(name string) string {
 		return p
 	}
 
-	prod, err := j.client.GetProduct(name)
+	prod, err := j.client.GetProductWithContext(j.ctx, name)
 	if err != nil || prod == nil {
 		return name
 	}
@@ -1,8 +1,11 @@
 package apigee
 
 import (
+	"context"
 	"encoding/json"
 	"os"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,6 +13,7 @@ import (
 	"github.com/Axway/agent-sdk/pkg/transaction"
 	"github.com/Axway/agents-apigee/client/pkg/apigee"
 	"github.com/Axway/agents-apigee/client/pkg/apigee/models"
+	"github.com/Axway/agents-apigee/client/pkg/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,13 +24,18 @@ type mockClient struct {
 	responseCount int
 	statResponses []string
 	productsMap   map[string]string
+	statsLock     sync.Mutex
+	statsEnvs     []string
 }
 
-func (m *mockClient) GetEnvironments() []string {
-	return m.envs
+func (m *mockClient) GetEnvironmentsWithContext(_ context.Context) ([]string, error) {
+	return m.envs, nil
 }
 
-func (m *mockClient) GetStats(env, dimension, metricSelect string, start, end time.Time) (*models.Metrics, error) {
+func (m *mockClient) GetStatsWithContext(_ context.Context, env, dimension, metricSelect string, start, end time.Time) (*models.Metrics, error) {
+	m.statsLock.Lock()
+	defer m.statsLock.Unlock()
+	m.statsEnvs = append(m.statsEnvs, env)
 	content, _ := os.ReadFile(testdata + m.statResponses[m.responseCount])
 	metrics := &models.Metrics{}
 	json.Unmarshal(content, metrics)
@@ -34,7 +43,7 @@ func (m *mockClient) GetStats(env, dimension, metricSelect string, start, end ti
 	return metrics, nil
 }
 
-func (m *mockClient) GetProduct(productName string) (*models.ApiProduct, error) {
+func (m *mockClient) GetProductWithContext(_ context.Context, productName string) (*models.ApiProduct, error) {
 	if m.productsMap == nil {
 		// so empty
 	} else if p, ok := m.productsMap[productName]; ok {
@@ -257,3 +266,55 @@ func TestNewPollStatsJob(t *testing.T) {
 		})
 	}
 }
+
+func TestPollStatsEnvironments(t *testing.T) {
+	testCases := []struct {
+		name    string
+		include string
+		exclude string
+		envs    []string
+	}{
+		{
+			name: "should get the stats of all environments",
+			envs: []string{"dev", "prod-eu", "prod-us"},
+		},
+		{
+			name:    "should get the stats of the included environments",
+			include: "prod-*",
+			envs:    []string{"prod-eu", "prod-us"},
+		},
+		{
+			name:    "should skip the stats of the excluded environments",
+			include: "/^prod-/",
+			exclude: "prod-us",
+			envs:    []string{"prod-eu"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			eventReport, err := transaction.NewEventReportBuilder().
+				SetOnlyTrackMetrics(true).
+				Build()
+			assert.Nil(t, err)
+			environments, err := config.NewEnvironmentSelector(test.include, test.exclude, "")
+			assert.Nil(t, err)
+
+			client := &mockClient{
+				statResponses: []string{"only_success.json", "only_success.json", "only_success.json"},
+				envs:          []string{"dev", "prod-eu", "prod-us"},
+			}
+			job := newPollStatsJob(
+				withStatsCache(cache.New()),
+				withStatsClient(client),
+				withAllTraffic(true),
+				withEventReport(eventReport),
+				withEnvironments(environments),
+			)
+
+			assert.Nil(t, job.Execute())
+			sort.Strings(client.statsEnvs)
+			assert.Equal(t, test.envs, client.statsEnvs)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package statsmock
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/rand"
@@ -34,15 +35,15 @@ func NewStatsMock(client *apigee.ApigeeClient, products apigee.Products) definit
 	}
 }
 
-func (s *simulate) GetEnvironments() []string {
-	return s.client.GetEnvironments()
+func (s *simulate) GetEnvironmentsWithContext(ctx context.Context) ([]string, error) {
+	return s.client.GetEnvironmentsWithContext(ctx)
 }
 
-func (s *simulate) GetProduct(productName string) (*models.ApiProduct, error) {
-	return s.client.GetProduct(productName)
+func (s *simulate) GetProductWithContext(ctx context.Context, productName string) (*models.ApiProduct, error) {
+	return s.client.GetProductWithContext(ctx, productName)
 }
 
-func (s *simulate) GetStats(env, dimension, metricSelect string, start, end time.Time) (*models.Metrics, error) {
+func (s *simulate) GetStatsWithContext(_ context.Context, env, dimension, metricSelect string, start, end time.Time) (*models.Metrics, error) {
 	numProducts := rand.Intn(maxNumAPIs)
 
 	response := &models.Metrics{
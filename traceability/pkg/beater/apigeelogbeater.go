@@ -53,6 +53,7 @@ func (bt *customLogBeater) Run(b *beat.Beat) error {
 
 // Stop stops customLogTraceabilityAgent.
 func (bt *customLogBeater) Stop() {
+	apigee.GetAgent().Stop()
 	bt.client.Close()
 	close(bt.done)
 }